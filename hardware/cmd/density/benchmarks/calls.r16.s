@@ -0,0 +1,18 @@
+; Calls a function that increments a counter in memory ten times.
+	.entry main
+	.func main, inc
+
+main:	add.hi %s0, %zr, 10
+loop:	call inc
+	add.hi %s0, %s0, -1
+	bne %zr, %s0, loop
+halt:	jump halt
+
+inc:	load.h %t0, %zr, counter
+	add.hi %t0, %t0, 1
+	store.h %t0, %zr, counter
+	ret
+
+	.data
+	.object counter
+counter:	.half 0
@@ -0,0 +1,20 @@
+; Calls a function that increments a counter in memory ten times.
+	.entry main
+	.func main, inc
+
+main:	add.hi %z0, %zr, 10
+	add.ai %c0, %bp, counter
+loop:	call inc
+	add.hi %z0, %z0, -1
+	bne %zr, %z0, loop
+halt:	jump halt
+
+; The address of the counter is in %c0.
+inc:	load.h %x0, %c0, 0
+	add.hi %x0, %x0, 1
+	store.h %x0, %c0, 0
+	ret
+
+	.data
+	.object counter
+counter:	.half 0
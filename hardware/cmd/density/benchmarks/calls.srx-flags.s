@@ -0,0 +1,20 @@
+; Calls a function that increments a counter in memory ten times.
+	.entry main
+	.func main, inc
+
+main:	add.dl %z0, %zr, 10
+	add.al %c0, %bp, counter
+loop:	call.x inc
+	adds.i %z0, -1
+	jne.x loop
+halt:	jmp.x halt
+
+; The address of the counter is in %c0.
+inc:	load.shx %x0, %c0, 0
+	adds.i %x0, 1
+	store.hx %x0, %c0, 0
+	jmp.a %rp
+
+	.data
+	.object counter
+counter:	.half 0
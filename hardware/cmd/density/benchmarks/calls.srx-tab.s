@@ -0,0 +1,20 @@
+; Calls a function that increments a counter in memory ten times.
+	.entry main
+	.func main, inc
+
+main:	add.dl %z0, %zr, 10
+	aiupc.l %c0, counter
+loop:	call.ax %rp, %bp, inc
+	add.dl %z0, %z0, -1
+	bne.x %zr, %z0, loop
+halt:	beq.x %zr, %zr, halt
+
+; The address of the counter is in %c0.
+inc:	load.shx %x0, %c0, 0
+	add.dl %x0, %x0, 1
+	store.hx %x0, %c0, 0
+	jmp.ax %rp, 0
+
+	.data
+	.object counter
+counter:	.half 0
@@ -0,0 +1,11 @@
+; Counts down ten times from ten in nested loops.
+	.entry main
+	.func main
+
+main:	add.hi %s0, %zr, 10
+outer:	add.hi %s1, %zr, 10
+inner:	add.hi %s1, %s1, -1
+	bne %zr, %s1, inner
+	add.hi %s0, %s0, -1
+	bne %zr, %s0, outer
+halt:	jump halt
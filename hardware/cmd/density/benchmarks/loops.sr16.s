@@ -0,0 +1,11 @@
+; Counts down ten times from ten in nested loops.
+	.entry main
+	.func main
+
+main:	add.hi %z0, %zr, 10
+outer:	add.hi %z1, %zr, 10
+inner:	add.hi %z1, %z1, -1
+	bne %zr, %z1, inner
+	add.hi %z0, %z0, -1
+	bne %zr, %z0, outer
+halt:	jump halt
@@ -0,0 +1,11 @@
+; Counts down ten times from ten in nested loops.
+	.entry main
+	.func main
+
+main:	add.dl %z0, %zr, 10
+outer:	add.dl %z1, %zr, 10
+inner:	adds.i %z1, -1
+	jne.x inner
+	adds.i %z0, -1
+	jne.x outer
+halt:	jmp.x halt
@@ -0,0 +1,11 @@
+; Counts down ten times from ten in nested loops.
+	.entry main
+	.func main
+
+main:	add.dl %z0, %zr, 10
+outer:	add.dl %z1, %zr, 10
+inner:	add.dl %z1, %z1, -1
+	bne.x %zr, %z1, inner
+	add.dl %z0, %z0, -1
+	bne.x %zr, %z0, outer
+halt:	beq.x %zr, %zr, halt
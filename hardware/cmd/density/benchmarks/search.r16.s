@@ -0,0 +1,44 @@
+; Counts how many of four keys are in a table of eight halfwords, with an
+; unrolled search.
+	.entry main
+	.func main, find
+
+main:	add.hi %s1, %zr, 0
+	add.hi %a0, %zr, 7
+	call find
+	add.hi %a0, %zr, 42
+	call find
+	add.hi %a0, %zr, 99
+	call find
+	add.hi %a0, %zr, 1000
+	call find
+	store.h %s1, %zr, found
+halt:	jump halt
+
+; Increments %s1 if %a0 is in the table.
+find:	load.h %t0, %zr, table+0
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+2
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+4
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+6
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+8
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+10
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+12
+	beq %t0, %a0, hit
+	load.h %t0, %zr, table+14
+	beq %t0, %a0, hit
+	ret
+hit:	add.hi %s1, %s1, 1
+	ret
+
+	.data
+	.object found
+found:	.half 0
+	.rodata
+	.object table
+table:	.half 3, 7, 12, 19, 42, 77, 500, 1000
@@ -0,0 +1,46 @@
+; Counts how many of four keys are in a table of eight halfwords, with an
+; unrolled search.
+	.entry main
+	.func main, find
+
+main:	add.hi %z0, %zr, 0
+	add.ai %c0, %bp, table
+	add.hi %y0, %zr, 7
+	call find
+	add.hi %y0, %zr, 42
+	call find
+	add.hi %y0, %zr, 99
+	call find
+	add.hi %y0, %zr, 1000
+	call find
+	add.ai %c1, %bp, found
+	store.h %z0, %c1, 0
+halt:	jump halt
+
+; Increments %z0 if %y0 is in the table at %c0.
+find:	load.h %x0, %c0, 0
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 2
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 4
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 6
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 8
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 10
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 12
+	beq %x0, %y0, hit
+	load.h %x0, %c0, 14
+	beq %x0, %y0, hit
+	ret
+hit:	add.hi %z0, %z0, 1
+	ret
+
+	.data
+	.object found
+found:	.half 0
+	.rodata
+	.object table
+table:	.half 3, 7, 12, 19, 42, 77, 500, 1000
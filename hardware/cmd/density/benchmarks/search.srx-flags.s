@@ -0,0 +1,54 @@
+; Counts how many of four keys are in a table of eight halfwords, with an
+; unrolled search.
+	.entry main
+	.func main, find
+
+main:	add.dl %z0, %zr, 0
+	add.al %c0, %bp, table
+	add.dl %y0, %zr, 7
+	call.x find
+	add.dl %y0, %zr, 42
+	call.x find
+	add.dl %y0, %zr, 99
+	call.x find
+	add.dl %y0, %zr, 1000
+	call.x find
+	add.al %c1, %bp, found
+	store.hx %z0, %c1, 0
+halt:	jmp.x halt
+
+; Increments %z0 if %y0 is in the table at %c0.
+find:	load.shx %x0, %c0, 0
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 2
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 4
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 6
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 8
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 10
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 12
+	cmp %x0, %y0
+	jeq.x hit
+	load.shx %x0, %c0, 14
+	cmp %x0, %y0
+	jeq.x hit
+	jmp.a %rp
+hit:	adds.i %z0, 1
+	jmp.a %rp
+
+	.data
+	.object found
+found:	.half 0
+	.rodata
+	.object table
+table:	.half 3, 7, 12, 19, 42, 77, 500, 1000
@@ -0,0 +1,46 @@
+; Counts how many of four keys are in a table of eight halfwords, with an
+; unrolled search.
+	.entry main
+	.func main, find
+
+main:	add.dl %z0, %zr, 0
+	aiupc.l %c0, table
+	add.dl %y0, %zr, 7
+	call.ax %rp, %bp, find
+	add.dl %y0, %zr, 42
+	call.ax %rp, %bp, find
+	add.dl %y0, %zr, 99
+	call.ax %rp, %bp, find
+	add.dl %y0, %zr, 1000
+	call.ax %rp, %bp, find
+	aiupc.l %c1, found
+	store.hx %z0, %c1, 0
+halt:	beq.x %zr, %zr, halt
+
+; Increments %z0 if %y0 is in the table at %c0.
+find:	load.shx %x0, %c0, 0
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 2
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 4
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 6
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 8
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 10
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 12
+	beq.x %x0, %y0, hit
+	load.shx %x0, %c0, 14
+	beq.x %x0, %y0, hit
+	jmp.ax %rp, 0
+hit:	add.dl %z0, %z0, 1
+	jmp.ax %rp, 0
+
+	.data
+	.object found
+found:	.half 0
+	.rodata
+	.object table
+table:	.half 3, 7, 12, 19, 42, 77, 500, 1000
@@ -0,0 +1,135 @@
+// Command density compares the code density of r16, sr16 and the two
+// variants of SRX.
+//
+// Usage:
+//
+//	density [-steps n] [-dir directory] [benchmark...]
+//
+// It assembles and runs every benchmark, or only the given ones, on every
+// target, and reports the static size of the code in bytes, its number of
+// instructions, the number of instructions retired until the benchmark
+// halts, and a histogram of the lengths of the instructions of the code.
+//
+// Each target has its own source of each benchmark, named
+// <benchmark>.<target>.s, because their assembly languages differ. The
+// sources are embedded in the command, but -dir reads them from a
+// directory instead. Every benchmark must have a source for every target,
+// written with the instructions that its emulator runs, so that the
+// results only differ by the encodings and instruction sets.
+//
+// The results of SRX are marked as provisional, because most of its 16 and
+// 32-bit opcodes only have provisional allocations and no operations yet,
+// so its instructions are longer than its design intends.
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/density"
+	r16 "github.com/jespert/primordial/hardware/r16/density"
+	sr16 "github.com/jespert/primordial/hardware/sr16/density"
+	srx "github.com/jespert/primordial/hardware/srx/density"
+)
+
+//go:embed benchmarks/*.s
+var embedded embed.FS
+
+// targets in the order of the report.
+var targets = append([]density.Target{r16.Target, sr16.Target}, srx.Targets...)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// errUsage is returned for invalid command lines, once reported.
+var errUsage = errors.New("invalid usage")
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if err := compare(args, stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+
+		_, _ = fmt.Fprintf(stderr, "density: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func compare(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("density", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	steps := flags.Int("steps", 1_000_000, "maximum number of instructions to execute")
+	dir := flags.String("dir", "", "directory of the benchmark sources (default: embedded)")
+	if err := flags.Parse(args); err != nil {
+		flags.Usage()
+		return errUsage
+	}
+
+	sources, err := fs.Sub(embedded, "benchmarks")
+	if err != nil {
+		return err
+	}
+	if *dir != "" {
+		sources = os.DirFS(*dir)
+	}
+
+	benchmarks := flags.Args()
+	if len(benchmarks) == 0 {
+		if benchmarks, err = benchmarkNames(sources); err != nil {
+			return err
+		}
+	}
+
+	var results []*density.Result
+	for _, benchmark := range benchmarks {
+		for _, t := range targets {
+			name := benchmark + "." + t.Name() + ".s"
+			data, err := fs.ReadFile(sources, name)
+			if err != nil {
+				return err
+			}
+
+			r, err := density.Measure(t, benchmark, asm.Source{Name: name, Data: data}, *steps)
+			if err != nil {
+				return err
+			}
+			results = append(results, r)
+		}
+	}
+
+	return density.WriteReport(stdout, results)
+}
+
+// benchmarkNames returns the sorted names of the benchmarks with a source
+// in the directory, for any target.
+func benchmarkNames(sources fs.FS) ([]string, error) {
+	paths, err := fs.Glob(sources, "*.s")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, path := range paths {
+		name, _, _ := strings.Cut(path, ".")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no benchmarks found")
+	}
+
+	return names, nil
+}
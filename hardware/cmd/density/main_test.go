@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestRun(t *testing.T) {
+	var stderr bytes.Buffer
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 0, run(nil, verifier.Writer(), &stderr))
+	expect.Equal(t, "", stderr.String())
+	verifier.Verify()
+}
+
+func TestRun_dir(t *testing.T) {
+	dir := t.TempDir()
+	sources := map[string]string{
+		"halt.r16.s":       "halt:\tjump halt\n",
+		"halt.sr16.s":      "halt:\tjump halt\n",
+		"halt.srx-tab.s":   "halt:\tbeq.x %zr, %zr, halt\n",
+		"halt.srx-flags.s": "halt:\tjmp.x halt\n",
+	}
+	for name, source := range sources {
+		require.Success(t, os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644))
+	}
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"-dir", dir}, &stdout, &stderr))
+	expected := "benchmark   target       bytes  instrs   retired    4B    6B\n" +
+		"halt        r16              4       1         1     1     0\n" +
+		"halt        sr16             4       1         1     1     0\n" +
+		"halt        srx-tab*         6       1         1     0     1\n" +
+		"halt        srx-flags*       6       1         1     0     1\n" +
+		"\n* Provisional encodings: these results do not measure the design.\n"
+	expect.Equal(t, expected, stdout.String())
+	expect.Equal(t, "", stderr.String())
+}
+
+func TestRun_errors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		status   int
+		expected string
+	}{
+		{"unknown benchmark", []string{"foo"}, 1,
+			"density: open foo.r16.s: file does not exist\n"},
+		{"no halt", []string{"-steps", "10", "loops"}, 1,
+			"density: loops.r16.s: did not halt after 10 instructions\n"},
+		{"empty directory", []string{"-dir", t.TempDir()}, 1,
+			"density: no benchmarks found\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			expect.Equal(t, tc.status, run(tc.args, &stdout, &stderr))
+			expect.Equal(t, tc.expected, stderr.String())
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 2, run([]string{"-foo"}, &stdout, &stderr))
+}
@@ -0,0 +1,111 @@
+// Command isamap checks the encodings of the instruction sets of r16, sr16
+// and the two variants of SRX, and maps their utilisation.
+//
+// Usage:
+//
+//	isamap [-allow "first and second"]... [isa...]
+//
+// For every instruction set, or only the given ones (r16, sr16, srx-tab
+// and srx-flags), it prints the fields that select instructions, from the
+// first, with the values that select instructions, by format, and the
+// values that are free. Then it lists the instructions whose encodings
+// collide.
+//
+// It fails if two instructions collide, unless the collision is allowed
+// with -allow, which takes the mnemonics of both instructions, as listed,
+// and can be repeated.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	r16 "github.com/jespert/primordial/hardware/r16/isamap"
+	sr16 "github.com/jespert/primordial/hardware/sr16/isamap"
+	srx "github.com/jespert/primordial/hardware/srx/isamap"
+)
+
+// isas in the order of the report.
+var isas = append([]*isaspec.ISA{r16.ISA, sr16.ISA}, srx.ISAs...)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// errUsage is returned for invalid command lines, once reported.
+var errUsage = errors.New("invalid usage")
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if err := check(args, stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+
+		// Report every collision on its own line.
+		for _, line := range strings.Split(err.Error(), "\n") {
+			_, _ = fmt.Fprintf(stderr, "isamap: %s\n", line)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func check(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("isamap", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var allowed []string
+	flags.Func("allow", "allow the collision of two instructions, such as \"load and store\"", func(s string) error {
+		allowed = append(allowed, s)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		flags.Usage()
+		return errUsage
+	}
+
+	selected := isas
+	if flags.NArg() > 0 {
+		selected = nil
+		for _, name := range flags.Args() {
+			isa, err := lookup(name)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, isa)
+		}
+	}
+
+	var errs []error
+	for n, isa := range selected {
+		if n > 0 {
+			if _, err := fmt.Fprintln(stdout); err != nil {
+				return err
+			}
+		}
+
+		if err := isa.WriteMap(stdout); err != nil {
+			return err
+		}
+		errs = append(errs, isa.Check(allowed...))
+	}
+
+	return errors.Join(errs...)
+}
+
+// lookup returns the instruction set with the given name, which is the
+// name of its spec in lower case, with dashes instead of spaces.
+func lookup(name string) (*isaspec.ISA, error) {
+	for _, isa := range isas {
+		if strings.ReplaceAll(strings.ToLower(isa.Name), " ", "-") == name {
+			return isa, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown instruction set %s", name)
+}
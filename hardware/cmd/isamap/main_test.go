@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+)
+
+// fpCollisions are the collisions of the floating-point loads and stores of
+// the flags variant of SRX.
+var fpCollisions = []string{
+	"load.fh0x and store.fh0x", "load.fh1x and store.fh1x",
+	"load.fw0x and store.fw0x", "load.fw1x and store.fw1x",
+	"load.fd0x and store.fd0x", "load.fd1x and store.fd1x",
+	"load.fq0x and store.fq0x", "load.fq1x and store.fq1x",
+}
+
+func TestRun(t *testing.T) {
+	var stderr bytes.Buffer
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 1, run(nil, verifier.Writer(), &stderr))
+	verifier.Verify()
+
+	var expected strings.Builder
+	for _, c := range fpCollisions {
+		expected.WriteString("isamap: SRX flags: " + c + " collide\n")
+	}
+	expect.Equal(t, expected.String(), stderr.String())
+}
+
+func TestRun_allow(t *testing.T) {
+	var args []string
+	for _, c := range fpCollisions {
+		args = append(args, "-allow", c)
+	}
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run(append(args, "srx-flags"), &stdout, &stderr))
+	expect.Equal(t, "", stderr.String())
+
+	// Every collision must be allowed.
+	stderr.Reset()
+	expect.Equal(t, 1, run(append(args[2:], "srx-flags"), &stdout, &stderr))
+	expect.Equal(t, "isamap: SRX flags: load.fh0x and store.fh0x collide\n", stderr.String())
+}
+
+func TestRun_isa(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"srx-tab"}, &stdout, &stderr))
+	expected := "SRX tab\n" +
+		"class[0..1]: 1 used, 3 free\n" +
+		"  0x0..0x2 free\n" +
+		"  0x3 XA, XB\n" +
+		"    opcode[2..7]: 64 used, 0 free\n"
+	expect.Equal(t, true, strings.HasPrefix(stdout.String(), expected))
+	expect.Equal(t, "", stderr.String())
+}
+
+func TestRun_errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 1, run([]string{"foo"}, &stdout, &stderr))
+	expect.Equal(t, "", stdout.String())
+	expect.Equal(t, "isamap: unknown instruction set foo\n", stderr.String())
+
+	expect.Equal(t, 2, run([]string{"-foo"}, &stdout, &stderr))
+}
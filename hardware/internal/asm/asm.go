@@ -0,0 +1,712 @@
+// Package asm is the architecture-independent core of the assemblers.
+//
+// It parses the source, evaluates expressions, handles labels and
+// directives, lays out the segments and produces an executable with a
+// debug line table. Architectures only parse and encode instructions.
+//
+// The syntax is line-oriented. Each line can have any number of labels
+// followed by an instruction, a directive or an equate. Comments start with
+// a semicolon:
+//
+//	count = 10              ; Equate.
+//	start:                  ; Label.
+//	        add.hi %a0, %zr, count
+//	        .half  end - start
+//
+// Directives:
+//
+//	.text, .rodata, .data, .bss   Switch to a segment (.text by default).
+//	.byte, .half, .word x, ...    Emit 8, 16 or 32-bit values.
+//	.ascii "s", ...               Emit strings, without terminators.
+//	.space n[, fill]              Emit n bytes.
+//	.align n                      Pad to a multiple of n bytes.
+//	.entry x                      Set the entrypoint (the first address).
+//	.func, .object name, ...      Set the type of symbols.
+//
+// Segments are laid out in the order above, starting at the base address.
+// The symbol "." is the address of the current statement.
+package asm
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+)
+
+// Source is a named source file.
+type Source struct {
+	Name string
+	Data []byte
+}
+
+// Arch parses and encodes the instructions of an architecture.
+type Arch interface {
+	// Instruction parses an instruction, including pseudo-instructions, and
+	// returns its size in bytes and an encoder that produces that many
+	// bytes once all symbols are known.
+	Instruction(mnemonic string, operands []Operand) (size int, encode Encoder, err error)
+}
+
+// Encoder encodes a statement. The environment provides the address of the
+// statement and evaluates expressions.
+type Encoder func(e *Env) ([]byte, error)
+
+// Operand of an instruction, which is either a register or an expression.
+type Operand struct {
+	Pos Pos
+
+	// Name of the register, without the % prefix.
+	Register string
+
+	Expr Expr
+
+	// Strings are only valid in directives.
+	str      string
+	isString bool
+}
+
+// IsRegister reports whether the operand is a register.
+func (o *Operand) IsRegister() bool {
+	return o.Register != ""
+}
+
+// Config of an assembler.
+type Config struct {
+	Arch Arch
+
+	// Header of the executable. The endianness also applies to data
+	// directives.
+	Header exe.Header
+
+	// Address of the first segment.
+	Base uint16
+}
+
+// Assemble the sources into an executable.
+//
+// All errors are reported, not just the first one. Their type is *Error,
+// possibly joined.
+func Assemble(config Config, sources ...Source) (*exe.File, error) {
+	a := newAssembler(config)
+	for _, source := range sources {
+		a.source(source)
+	}
+
+	if len(a.errors) != 0 {
+		return nil, errors.Join(a.errors...)
+	}
+
+	if err := a.layout(); err != nil {
+		return nil, err
+	}
+
+	f := a.encode()
+	if len(a.errors) != 0 {
+		return nil, errors.Join(a.errors...)
+	}
+
+	return f, nil
+}
+
+type assembler struct {
+	config   Config
+	order    exe.ByteOrder
+	segments []*segment
+	current  *segment
+	symbols  map[string]*symbol
+	items    []item
+	entry    Operand
+	errors   []error
+}
+
+// segment is laid out by the first pass and filled by the second one.
+type segment struct {
+	name  string
+	size  int
+	align int
+	base  uint16
+	data  []byte
+
+	// Position of the directive that set the alignment.
+	alignPos Pos
+}
+
+// symbol is either a label or an equate.
+type symbol struct {
+	pos Pos
+	typ exe.SymbolType
+
+	// Value of equates.
+	value Expr
+
+	// Location of labels, which becomes an address once placed.
+	segment *segment
+	offset  int
+	address uint16
+	placed  bool
+}
+
+// item is a statement that takes space in a segment.
+type item struct {
+	pos     Pos
+	segment *segment
+	offset  int
+	size    int
+
+	// Nil for space reserved in .bss.
+	encode Encoder
+}
+
+// Segment indices, in layout order.
+const (
+	segmentText = iota
+	segmentROData
+	segmentData
+	segmentBSS
+)
+
+func newAssembler(config Config) *assembler {
+	a := &assembler{
+		config:  config,
+		order:   config.Header.Endianness.ByteOrder(),
+		symbols: make(map[string]*symbol),
+	}
+
+	for _, name := range []string{".text", ".rodata", ".data", ".bss"} {
+		a.segments = append(a.segments, &segment{name: name, align: 1})
+	}
+	a.current = a.segments[segmentText]
+	return a
+}
+
+func (a *assembler) source(source Source) {
+	lines := bytes.Split(source.Data, []byte("\n"))
+	for i, line := range lines {
+		if err := a.line(source.Name, i+1, string(line)); err != nil {
+			a.errors = append(a.errors, err)
+		}
+	}
+}
+
+func (a *assembler) line(file string, n int, text string) error {
+	tokens, err := lexLine(file, n, text)
+	if err != nil {
+		return err
+	}
+
+	for len(tokens) >= 2 && tokens[0].kind == tokenIdent && isPunct(tokens[1], ":") {
+		if err := a.label(tokens[0]); err != nil {
+			return err
+		}
+		tokens = tokens[2:]
+	}
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	first := tokens[0]
+	if first.kind != tokenIdent {
+		return errorf(first.pos, "unexpected %s", describe(first))
+	}
+
+	if len(tokens) >= 2 && isPunct(tokens[1], "=") {
+		return a.equate(first, tokens[2:])
+	}
+
+	operands, err := parseOperands(tokens[1:])
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(first.text, ".") && first.text != "." {
+		return a.directive(first, operands)
+	}
+
+	return a.instruction(first, operands)
+}
+
+func (a *assembler) define(name token, s *symbol) error {
+	if name.text == "." {
+		return errorf(name.pos, "cannot redefine \".\"")
+	}
+
+	if previous, ok := a.symbols[name.text]; ok {
+		return errorf(name.pos, "symbol %q already defined at %v", name.text, previous.pos)
+	}
+
+	s.pos = name.pos
+	a.symbols[name.text] = s
+	return nil
+}
+
+func (a *assembler) label(name token) error {
+	return a.define(name, &symbol{segment: a.current, offset: a.current.size})
+}
+
+func (a *assembler) equate(name token, tokens []token) error {
+	x, rest, err := parseExpr(tokens, name.pos)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) != 0 {
+		return errorf(rest[0].pos, "unexpected %s after expression", describe(rest[0]))
+	}
+
+	return a.define(name, &symbol{value: x})
+}
+
+func (a *assembler) instruction(mnemonic token, operands []Operand) error {
+	for _, o := range operands {
+		if o.isString {
+			return errorf(o.Pos, "strings are not valid operands of instructions")
+		}
+	}
+
+	size, encode, err := a.config.Arch.Instruction(mnemonic.text, operands)
+	if err != nil {
+		return atPos(mnemonic.pos, err)
+	}
+
+	return a.emit(mnemonic.pos, size, encode)
+}
+
+func (a *assembler) emit(pos Pos, size int, encode Encoder) error {
+	if a.current == a.segments[segmentBSS] && encode != nil {
+		return errorf(pos, "%s can only reserve space", a.current.name)
+	}
+
+	a.items = append(a.items, item{
+		pos:     pos,
+		segment: a.current,
+		offset:  a.current.size,
+		size:    size,
+		encode:  encode,
+	})
+	a.current.size += size
+	return nil
+}
+
+func (a *assembler) directive(name token, operands []Operand) error {
+	switch name.text {
+	case ".text", ".rodata", ".data", ".bss":
+		if err := expectOperands(name, operands, 0, 0); err != nil {
+			return err
+		}
+
+		for _, s := range a.segments {
+			if s.name == name.text {
+				a.current = s
+			}
+		}
+		return nil
+
+	case ".byte":
+		return a.values(name, operands, 1, -0x80, 0xff)
+
+	case ".half":
+		return a.values(name, operands, 2, -0x8000, 0xffff)
+
+	case ".word":
+		return a.values(name, operands, 4, -0x8000_0000, 0xffff_ffff)
+
+	case ".ascii":
+		if err := expectOperands(name, operands, 1, -1); err != nil {
+			return err
+		}
+
+		var data []byte
+		for _, o := range operands {
+			if !o.isString {
+				return errorf(o.Pos, "expected string")
+			}
+			data = append(data, o.str...)
+		}
+
+		return a.emit(name.pos, len(data), func(*Env) ([]byte, error) {
+			return data, nil
+		})
+
+	case ".space":
+		if err := expectOperands(name, operands, 1, 2); err != nil {
+			return err
+		}
+
+		size, err := a.constant(operands[0], 0, 0xffff)
+		if err != nil {
+			return err
+		}
+
+		return a.fill(name.pos, int(size), operands[1:])
+
+	case ".align":
+		if err := expectOperands(name, operands, 1, 1); err != nil {
+			return err
+		}
+
+		n, err := a.constant(operands[0], 1, 0x8000)
+		if err != nil {
+			return err
+		}
+		if n&(n-1) != 0 {
+			return errorf(operands[0].Pos, "alignment is not a power of two: %d", n)
+		}
+
+		if int(n) > a.current.align {
+			a.current.align = int(n)
+			a.current.alignPos = name.pos
+		}
+		padding := -a.current.size & int(n-1)
+		return a.fill(name.pos, padding, nil)
+
+	case ".entry":
+		if err := expectOperands(name, operands, 1, 1); err != nil {
+			return err
+		}
+		if a.entry.Expr != nil {
+			return errorf(name.pos, "entrypoint already defined")
+		}
+		if err := expectExpr(operands[0]); err != nil {
+			return err
+		}
+
+		a.entry = operands[0]
+		return nil
+
+	case ".func", ".object":
+		if err := expectOperands(name, operands, 1, -1); err != nil {
+			return err
+		}
+
+		t := exe.SymbolFunction
+		if name.text == ".object" {
+			t = exe.SymbolObject
+		}
+
+		for _, o := range operands {
+			x, ok := o.Expr.(*symbolExpr)
+			if !ok {
+				return errorf(o.Pos, "expected symbol name")
+			}
+
+			// Labels can be typed before they are defined, so this is
+			// checked once all labels are known.
+			a.items = append(a.items, item{pos: o.Pos, encode: a.typeSymbol(x, t)})
+		}
+		return nil
+
+	default:
+		return errorf(name.pos, "unknown directive %s", name.text)
+	}
+}
+
+// typeSymbol returns a pseudo-encoder that sets the type of a label.
+func (a *assembler) typeSymbol(x *symbolExpr, t exe.SymbolType) Encoder {
+	return func(*Env) ([]byte, error) {
+		s, ok := a.symbols[x.name]
+		if !ok || s.value != nil {
+			return nil, errorf(x.at, "%q is not a label", x.name)
+		}
+
+		s.typ = t
+		return nil, nil
+	}
+}
+
+func (a *assembler) values(name token, operands []Operand, size int, lo, hi int64) error {
+	if err := expectOperands(name, operands, 1, -1); err != nil {
+		return err
+	}
+
+	for _, o := range operands {
+		if err := expectExpr(o); err != nil {
+			return err
+		}
+	}
+
+	return a.emit(name.pos, size*len(operands), func(e *Env) ([]byte, error) {
+		var data []byte
+		for _, o := range operands {
+			v, err := e.EvalRange(o.Expr, lo, hi)
+			if err != nil {
+				return nil, err
+			}
+
+			switch size {
+			case 1:
+				data = append(data, byte(v))
+			case 2:
+				data = a.order.AppendUint16(data, uint16(v))
+			default:
+				data = a.order.AppendUint32(data, uint32(v))
+			}
+		}
+
+		return data, nil
+	})
+}
+
+func (a *assembler) fill(pos Pos, size int, operands []Operand) error {
+	var value byte
+	if len(operands) > 0 {
+		v, err := a.constant(operands[0], -0x80, 0xff)
+		if err != nil {
+			return err
+		}
+		value = byte(v)
+	}
+
+	if a.current == a.segments[segmentBSS] {
+		if value != 0 {
+			return errorf(pos, "%s can only be filled with zeros", a.current.name)
+		}
+		return a.emit(pos, size, nil)
+	}
+
+	data := bytes.Repeat([]byte{value}, size)
+	return a.emit(pos, size, func(*Env) ([]byte, error) {
+		return data, nil
+	})
+}
+
+// constant evaluates an expression that is needed by the first pass, so it
+// cannot depend on addresses.
+func (a *assembler) constant(o Operand, lo, hi int64) (int64, error) {
+	if err := expectExpr(o); err != nil {
+		return 0, err
+	}
+
+	env := Env{symbols: a.symbols}
+	return env.EvalRange(o.Expr, lo, hi)
+}
+
+// layout places the segments one after another from the base address,
+// padding each one so that the next one is aligned.
+//
+// A program that does not fit in memory is reported at its first statement
+// that does not fit. Padding alone cannot overflow, because the end of
+// memory is aligned to any alignment.
+func (a *assembler) layout() error {
+	address := int(a.config.Base)
+	bases := make(map[*segment]int)
+	for i, s := range a.segments {
+		if padding := -address & (s.align - 1); padding != 0 {
+			if i == 0 {
+				return errorf(s.alignPos, "base address %04x is not aligned to %d bytes", address, s.align)
+			}
+
+			previous := a.segments[i-1]
+			a.items = append(a.items, item{
+				segment: previous,
+				offset:  previous.size,
+				size:    padding,
+				encode:  func(*Env) ([]byte, error) { return make([]byte, padding), nil },
+			})
+			previous.size += padding
+			address += padding
+		}
+
+		s.base = uint16(address)
+		bases[s] = address
+		address += s.size
+	}
+
+	if address > 0x10000 {
+		var pos Pos
+		for _, it := range a.items {
+			if it.segment != nil && bases[it.segment]+it.offset+it.size > 0x10000 {
+				pos = it.pos
+				break
+			}
+		}
+		return errorf(pos, "program does not fit in memory: ends at %x", address)
+	}
+
+	for _, s := range a.symbols {
+		if s.segment != nil {
+			s.address = s.segment.base + uint16(s.offset)
+			s.placed = true
+		}
+	}
+
+	return nil
+}
+
+// encode is the second pass.
+func (a *assembler) encode() *exe.File {
+	for _, s := range a.segments {
+		s.data = make([]byte, 0, s.size)
+	}
+
+	var lines exe.LineTable
+	for _, it := range a.items {
+		env := Env{symbols: a.symbols}
+		if it.segment == nil {
+			// Directives that do not take space.
+			if _, err := it.encode(&env); err != nil {
+				a.errors = append(a.errors, atPos(it.pos, err))
+			}
+			continue
+		}
+
+		env.Address = it.segment.base + uint16(it.offset)
+		if it.encode == nil {
+			continue
+		}
+
+		data, err := it.encode(&env)
+		if err != nil {
+			a.errors = append(a.errors, atPos(it.pos, err))
+			continue
+		}
+		if len(data) != it.size {
+			a.errors = append(a.errors, errorf(it.pos, "encoded %d bytes instead of %d", len(data), it.size))
+			continue
+		}
+
+		// Items are encoded in source order, but each segment is filled in
+		// order, so we can simply append.
+		it.segment.data = append(it.segment.data, data...)
+
+		if it.size > 0 {
+			lines = append(lines, exe.LineEntry{
+				Address: env.Address,
+				File:    it.pos.File,
+				Line:    it.pos.Line,
+			})
+		}
+	}
+
+	f := &exe.File{
+		Header:     a.config.Header,
+		Code:       a.segments[segmentText].data,
+		ROData:     a.segments[segmentROData].data,
+		PIData:     a.segments[segmentData].data,
+		ZIDataSize: uint16(a.segments[segmentBSS].size),
+		Entrypoint: a.config.Base,
+		Lines:      a.lineTable(lines),
+	}
+
+	if a.entry.Expr != nil {
+		env := Env{symbols: a.symbols}
+		entry, err := env.EvalU16(a.entry.Expr)
+		if err != nil {
+			a.errors = append(a.errors, atPos(a.entry.Pos, err))
+		}
+		f.Entrypoint = entry
+	}
+
+	for name, s := range a.symbols {
+		if s.placed {
+			f.Symbols = append(f.Symbols, exe.Symbol{Name: name, Address: s.address, Type: s.typ})
+		}
+	}
+	slices.SortFunc(f.Symbols, func(x, y exe.Symbol) int {
+		return cmp.Or(cmp.Compare(x.Address, y.Address), cmp.Compare(x.Name, y.Name))
+	})
+
+	return f
+}
+
+// lineTable sorts the entries, merges consecutive entries of the same line
+// and ends the table after the last initialised segment. Alignment padding
+// between segments has no line.
+func (a *assembler) lineTable(entries exe.LineTable) exe.LineTable {
+	slices.SortStableFunc(entries, func(x, y exe.LineEntry) int {
+		return cmp.Compare(x.Address, y.Address)
+	})
+
+	var lines exe.LineTable
+	for _, e := range entries {
+		if n := len(lines); n > 0 && lines[n-1].File == e.File && lines[n-1].Line == e.Line {
+			continue
+		}
+		lines = append(lines, e)
+	}
+
+	end := int(a.segments[segmentBSS].base)
+	if len(lines) > 0 && end < 0x10000 {
+		lines = append(lines, exe.LineEntry{Address: uint16(end)})
+	}
+
+	return lines
+}
+
+func parseOperands(tokens []token) ([]Operand, error) {
+	var operands []Operand
+	for len(tokens) > 0 {
+		t := tokens[0]
+		var o Operand
+		switch t.kind {
+		case tokenRegister:
+			o = Operand{Pos: t.pos, Register: t.text}
+			tokens = tokens[1:]
+
+		case tokenString:
+			o = Operand{Pos: t.pos, str: t.text, isString: true}
+			tokens = tokens[1:]
+
+		default:
+			x, rest, err := parseExpr(tokens, t.pos)
+			if err != nil {
+				return nil, err
+			}
+			o = Operand{Pos: t.pos, Expr: x}
+			tokens = rest
+		}
+		operands = append(operands, o)
+
+		if len(tokens) == 0 {
+			break
+		}
+		if !isPunct(tokens[0], ",") {
+			return nil, errorf(tokens[0].pos, "expected comma, got %s", describe(tokens[0]))
+		}
+		if tokens = tokens[1:]; len(tokens) == 0 {
+			return nil, errorf(t.pos, "missing operand after comma")
+		}
+	}
+
+	return operands, nil
+}
+
+// expectOperands checks the number of operands. A negative maximum means
+// no maximum.
+func expectOperands(name token, operands []Operand, lo, hi int) error {
+	n := len(operands)
+	switch {
+	case n < lo:
+		return errorf(name.pos, "%s needs at least %d operands, got %d", name.text, lo, n)
+	case hi >= 0 && n > hi:
+		return errorf(name.pos, "%s takes at most %d operands, got %d", name.text, hi, n)
+	default:
+		return nil
+	}
+}
+
+func expectExpr(o Operand) error {
+	if o.Expr == nil {
+		return errorf(o.Pos, "expected expression")
+	}
+
+	return nil
+}
+
+func isPunct(t token, text string) bool {
+	return t.kind == tokenPunct && t.text == text
+}
+
+// atPos gives a position to errors that do not have one.
+func atPos(pos Pos, err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	return &Error{Pos: pos, Msg: err.Error()}
+}
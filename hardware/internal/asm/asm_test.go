@@ -0,0 +1,190 @@
+package asm_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestAssemble(t *testing.T) {
+	main := `
+; Everything but the instructions is architecture-independent.
+count = (end - start) / 2
+mask  = ~0xf0 & 0xff | 1 << 8
+
+	.entry start
+	.func start, helper
+	.object table, buffer
+start:	li %r1, count
+	nop
+helper: li %r2, . - start
+	.rodata
+table:	.half mask, -1, 'a'
+	.ascii "hi\n", "\x00"
+	.data
+	.align 4
+	.byte 0x7f
+	.space 3, 0xee
+`
+
+	lib := `
+	.text
+	nop
+end:
+	.bss
+buffer:	.space 16
+`
+
+	f, err := assemble(
+		asm.Source{Name: "main.s", Data: []byte(main)},
+		asm.Source{Name: "lib.s", Data: []byte(lib)},
+	)
+	require.Success(t, err)
+
+	verifier := approval.NewTextVerifier(t)
+	dump(verifier.Writer(), f)
+	verifier.Verify()
+}
+
+func TestAssemble_errors(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{"\tnop %r1", "test.s:1:2: nop takes no operands"},
+		{"\tfoo", "test.s:1:2: unknown instruction foo"},
+		{"\tli %r1, 1, 2", "test.s:1:2: li takes 2 operands"},
+		{"\tli %r1, undefined", "test.s:1:10: undefined symbol \"undefined\""},
+		{"\tli %r1, 0x10000", "test.s:1:10: value out of range [-32768, 65535]: 65536"},
+		{"\tli %r1, 1 +", "test.s:1:12: missing expression"},
+		{"\tli %r1, (1", "test.s:1:10: missing closing parenthesis"},
+		{"\tli %r1, 1 / 0", "test.s:1:12: division by zero"},
+		{"\tli %r1 2", "test.s:1:9: expected comma, got \"2\""},
+		{"\tli %r1,", "test.s:1:5: missing operand after comma"},
+		{"\tli %r1, \"s\"", "test.s:1:10: strings are not valid operands of instructions"},
+		{"\t.byte 256", "test.s:1:8: value out of range [-128, 255]: 256"},
+		{"\t.ascii 1", "test.s:1:9: expected string"},
+		{"\t.align 3", "test.s:1:9: alignment is not a power of two: 3"},
+		{"end:\n\t.space end", "test.s:2:9: address of \"end\" is not known yet"},
+		{"\t.bss\n\t.byte 1", "test.s:2:2: .bss can only reserve space"},
+		{"\t.bss\n\t.space 1, 1", "test.s:2:2: .bss can only be filled with zeros"},
+		{"\t.foo", "test.s:1:2: unknown directive .foo"},
+		{"\t.text 1", "test.s:1:2: .text takes at most 0 operands, got 1"},
+		{"\t.func 1", "test.s:1:8: expected symbol name"},
+		{"\t.func x\nx = 1", "test.s:1:8: \"x\" is not a label"},
+		{"a:\na:", "test.s:2:1: symbol \"a\" already defined at test.s:1:1"},
+		{"a = b\nb = a\n\tli %r1, a", "test.s:2:5: circular definition of \"a\""},
+		{"\tli %r1, 1 $", "test.s:1:12: unexpected character '$'"},
+		{"\t\"s\"", "test.s:1:2: unexpected string"},
+		{"\t.ascii \"s", "test.s:1:9: unterminated literal"},
+		{"\tli %r1, 0x1g", "test.s:1:10: invalid number \"0x1g\""},
+		{"\tbad", "test.s:1:2: encoded 3 bytes instead of 2"},
+		{"\t.align 0x2000", "test.s:1:2: base address 1000 is not aligned to 8192 bytes"},
+		{"\tnop\n\t.space 0xf000", "test.s:2:2: program does not fit in memory: ends at 10002"},
+		{"\t.entry undefined", "test.s:1:9: undefined symbol \"undefined\""},
+		{"\t.entry 0x10000", "test.s:1:9: value out of range [-32768, 65535]: 65536"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			_, err := assemble(asm.Source{Name: "test.s", Data: []byte(tc.source)})
+			if err == nil {
+				t.Fatal("expected invalid source to fail")
+			}
+			expect.Equal(t, tc.expected, err.Error())
+
+			var e *asm.Error
+			expect.Equal(t, true, errors.As(err, &e))
+		})
+	}
+}
+
+func TestAssemble_all_errors(t *testing.T) {
+	source := "\tfoo\n\tbar\n"
+	_, err := assemble(asm.Source{Name: "test.s", Data: []byte(source)})
+	if err == nil {
+		t.Fatal("expected invalid source to fail")
+	}
+
+	expected := "test.s:1:2: unknown instruction foo\ntest.s:2:2: unknown instruction bar"
+	expect.Equal(t, expected, err.Error())
+}
+
+func assemble(sources ...asm.Source) (*exe.File, error) {
+	return asm.Assemble(asm.Config{
+		Arch:   toyArch{},
+		Header: exe.Header{Arch: exe.PackName("TOY")},
+		Base:   0x1000,
+	}, sources...)
+}
+
+// toyArch has a two-byte nop, a four-byte load immediate, and a broken
+// instruction that encodes more bytes than it reserves.
+type toyArch struct{}
+
+func (toyArch) Instruction(mnemonic string, operands []asm.Operand) (int, asm.Encoder, error) {
+	switch mnemonic {
+	case "nop":
+		if len(operands) != 0 {
+			return 0, nil, fmt.Errorf("nop takes no operands")
+		}
+		return 2, func(*asm.Env) ([]byte, error) { return []byte{0, 0}, nil }, nil
+
+	case "bad":
+		return 2, func(*asm.Env) ([]byte, error) { return []byte{0, 0, 0}, nil }, nil
+
+	case "li":
+		if len(operands) != 2 || !operands[0].IsRegister() || operands[1].IsRegister() {
+			return 0, nil, fmt.Errorf("li takes 2 operands")
+		}
+
+		register := operands[0].Register
+		return 4, func(e *asm.Env) ([]byte, error) {
+			v, err := e.EvalU16(operands[1].Expr)
+			if err != nil {
+				return nil, err
+			}
+			return []byte{1, register[1] - '0', byte(v), byte(v >> 8)}, nil
+		}, nil
+
+	default:
+		return 0, nil, fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+}
+
+func dump(w io.Writer, f *exe.File) {
+	segments := []struct {
+		name string
+		data []byte
+	}{
+		{"code", f.Code},
+		{"ro_data", f.ROData},
+		{"pi_data", f.PIData},
+	}
+	for _, s := range segments {
+		_, _ = fmt.Fprintf(w, "%-8s % x\n", s.name, s.data)
+	}
+	_, _ = fmt.Fprintf(w, "zi_data  %d\n", f.ZIDataSize)
+	_, _ = fmt.Fprintf(w, "entry    %04x\n", f.Entrypoint)
+
+	_, _ = fmt.Fprintln(w, "\nSymbols:")
+	for _, s := range f.Symbols {
+		_, _ = fmt.Fprintf(w, "%04x  %d  %s\n", s.Address, s.Type, s.Name)
+	}
+
+	_, _ = fmt.Fprintln(w, "\nLines:")
+	for _, l := range f.Lines {
+		if l.Line == 0 {
+			_, _ = fmt.Fprintf(w, "%04x  -\n", l.Address)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%04x  %s:%d\n", l.Address, l.File, l.Line)
+	}
+}
@@ -0,0 +1,269 @@
+package asm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Expr is an integer expression. Its value is only known once all symbols
+// are defined, so it is evaluated by the second pass.
+type Expr interface {
+	eval(e *Env) (int64, error)
+	pos() Pos
+}
+
+// Env is the environment in which expressions are evaluated.
+type Env struct {
+	// Address of the statement being encoded, which is the value of ".".
+	Address uint16
+
+	symbols map[string]*symbol
+
+	// Equates that are being evaluated, to detect cycles.
+	evaluating map[string]bool
+}
+
+// Eval evaluates an expression.
+func (e *Env) Eval(x Expr) (int64, error) {
+	return x.eval(e)
+}
+
+// EvalRange evaluates an expression and checks that its value is in the
+// range [lo, hi].
+func (e *Env) EvalRange(x Expr, lo, hi int64) (int64, error) {
+	v, err := x.eval(e)
+	if err != nil {
+		return 0, err
+	}
+
+	if v < lo || v > hi {
+		return 0, errorf(x.pos(), "value out of range [%d, %d]: %d", lo, hi, v)
+	}
+
+	return v, nil
+}
+
+// EvalU16 evaluates an expression that must fit in 16 bits, either signed
+// or unsigned, and returns its two's complement representation.
+func (e *Env) EvalU16(x Expr) (uint16, error) {
+	v, err := e.EvalRange(x, -0x8000, 0xffff)
+	return uint16(v), err
+}
+
+type numberExpr struct {
+	at    Pos
+	value int64
+}
+
+func (x *numberExpr) eval(*Env) (int64, error) { return x.value, nil }
+func (x *numberExpr) pos() Pos                 { return x.at }
+
+// Number returns a constant expression, which is useful to synthesise
+// operands of pseudo-instructions.
+func Number(value int64) Expr {
+	return &numberExpr{value: value}
+}
+
+type symbolExpr struct {
+	at   Pos
+	name string
+}
+
+func (x *symbolExpr) pos() Pos { return x.at }
+
+func (x *symbolExpr) eval(e *Env) (int64, error) {
+	if x.name == "." {
+		return int64(e.Address), nil
+	}
+
+	s, ok := e.symbols[x.name]
+	if !ok {
+		return 0, errorf(x.at, "undefined symbol %q", x.name)
+	}
+
+	if s.value == nil {
+		if !s.placed {
+			return 0, errorf(x.at, "address of %q is not known yet", x.name)
+		}
+		return int64(s.address), nil
+	}
+
+	if e.evaluating[x.name] {
+		return 0, errorf(x.at, "circular definition of %q", x.name)
+	}
+
+	if e.evaluating == nil {
+		e.evaluating = make(map[string]bool)
+	}
+	e.evaluating[x.name] = true
+	defer delete(e.evaluating, x.name)
+
+	return s.value.eval(e)
+}
+
+type unaryExpr struct {
+	at Pos
+	op string
+	x  Expr
+}
+
+func (x *unaryExpr) pos() Pos { return x.at }
+
+func (x *unaryExpr) eval(e *Env) (int64, error) {
+	v, err := x.x.eval(e)
+	if err != nil {
+		return 0, err
+	}
+
+	switch x.op {
+	case "-":
+		return -v, nil
+	case "~":
+		return ^v, nil
+	default:
+		return v, nil
+	}
+}
+
+type binaryExpr struct {
+	at   Pos
+	op   string
+	x, y Expr
+}
+
+func (x *binaryExpr) pos() Pos { return x.at }
+
+func (x *binaryExpr) eval(e *Env) (int64, error) {
+	a, err := x.x.eval(e)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := x.y.eval(e)
+	if err != nil {
+		return 0, err
+	}
+
+	switch x.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return 0, errorf(x.at, "division by zero")
+		}
+		return a / b, nil
+	case "&":
+		return a & b, nil
+	case "|":
+		return a | b, nil
+	case "^":
+		return a ^ b, nil
+	case "<<", ">>":
+		if b < 0 || b > 63 {
+			return 0, errorf(x.at, "invalid shift amount: %d", b)
+		}
+		if x.op == "<<" {
+			return a << b, nil
+		}
+		return a >> b, nil
+	default:
+		panic(fmt.Sprintf("unknown operator %q", x.op))
+	}
+}
+
+// Binary operators from lowest to highest precedence. There is no modulo
+// operator because % introduces registers.
+var precedence = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+// parseExpr parses an expression from the front of the tokens and returns
+// the remaining tokens.
+func parseExpr(tokens []token, at Pos) (Expr, []token, error) {
+	return parseBinary(tokens, at, 0)
+}
+
+func parseBinary(tokens []token, at Pos, level int) (Expr, []token, error) {
+	if level == len(precedence) {
+		return parseUnary(tokens, at)
+	}
+
+	x, tokens, err := parseBinary(tokens, at, level+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for len(tokens) > 0 && tokens[0].kind == tokenPunct {
+		op := tokens[0]
+		if !slices.Contains(precedence[level], op.text) {
+			break
+		}
+
+		var y Expr
+		y, tokens, err = parseBinary(tokens[1:], op.pos, level+1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		x = &binaryExpr{at: op.pos, op: op.text, x: x, y: y}
+	}
+
+	return x, tokens, nil
+}
+
+func parseUnary(tokens []token, at Pos) (Expr, []token, error) {
+	if len(tokens) == 0 {
+		return nil, nil, errorf(at, "missing expression")
+	}
+
+	t := tokens[0]
+	switch t.kind {
+	case tokenNumber:
+		return &numberExpr{at: t.pos, value: t.value}, tokens[1:], nil
+
+	case tokenIdent:
+		return &symbolExpr{at: t.pos, name: t.text}, tokens[1:], nil
+
+	case tokenPunct:
+		switch t.text {
+		case "-", "~", "+":
+			x, rest, err := parseUnary(tokens[1:], t.pos)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &unaryExpr{at: t.pos, op: t.text, x: x}, rest, nil
+
+		case "(":
+			x, rest, err := parseExpr(tokens[1:], t.pos)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 || rest[0].text != ")" || rest[0].kind != tokenPunct {
+				return nil, nil, errorf(t.pos, "missing closing parenthesis")
+			}
+			return x, rest[1:], nil
+		}
+	}
+
+	return nil, nil, errorf(t.pos, "unexpected %s in expression", describe(t))
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenRegister:
+		return fmt.Sprintf("register %%%s", t.text)
+	case tokenString:
+		return "string"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
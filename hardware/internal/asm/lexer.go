@@ -0,0 +1,246 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos is a position in a source file. Lines and columns start at 1.
+type Pos struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Error is an error at a position in a source file.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos Pos, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenRegister
+	tokenNumber
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	pos  Pos
+
+	// Identifiers and registers (without the % prefix), punctuation, and
+	// decoded string literals.
+	text string
+
+	// Value of numbers and character literals.
+	value int64
+}
+
+// lexLine splits a line of source code into tokens. Comments start with a
+// semicolon and extend to the end of the line.
+func lexLine(file string, line int, text string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		pos := Pos{File: file, Line: line, Column: i + 1}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case c == ';':
+			return tokens, nil
+
+		case isIdentStart(c):
+			start := i
+			for i < len(text) && isIdentPart(text[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, pos: pos, text: text[start:i]})
+
+		case c == '%':
+			start := i + 1
+			i++
+			for i < len(text) && isIdentPart(text[i]) {
+				i++
+			}
+			if i == start {
+				return nil, errorf(pos, "missing register name")
+			}
+			tokens = append(tokens, token{kind: tokenRegister, pos: pos, text: text[start:i]})
+
+		case isDigit(c):
+			start := i
+			for i < len(text) && isIdentPart(text[i]) {
+				i++
+			}
+			value, err := parseNumber(text[start:i])
+			if err != nil {
+				return nil, errorf(pos, "%v", err)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, pos: pos, text: text[start:i], value: value})
+
+		case c == '"':
+			s, n, err := unquote(text[i:], '"')
+			if err != nil {
+				return nil, errorf(pos, "%v", err)
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenString, pos: pos, text: s})
+
+		case c == '\'':
+			s, n, err := unquote(text[i:], '\'')
+			if err != nil {
+				return nil, errorf(pos, "%v", err)
+			}
+			if len(s) != 1 {
+				return nil, errorf(pos, "character literal must be a single byte")
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenNumber, pos: pos, text: text[i-n : i], value: int64(s[0])})
+
+		default:
+			op := string(c)
+			if i+1 < len(text) {
+				if two := text[i : i+2]; two == "<<" || two == ">>" {
+					op = two
+				}
+			}
+
+			if !strings.Contains(punctuation, op[:1]) {
+				return nil, errorf(pos, "unexpected character %q", c)
+			}
+
+			i += len(op)
+			tokens = append(tokens, token{kind: tokenPunct, pos: pos, text: op})
+		}
+	}
+
+	return tokens, nil
+}
+
+const punctuation = ",:=()+-*/&|^~<>"
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseNumber parses an unsigned integer literal in decimal, hexadecimal
+// (0x), binary (0b), or octal (0o). Underscores can separate digits.
+func parseNumber(s string) (int64, error) {
+	digits := strings.ReplaceAll(s, "_", "")
+	base := int64(10)
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+			digits = digits[2:]
+		case 'b', 'B':
+			base = 2
+			digits = digits[2:]
+		case 'o', 'O':
+			base = 8
+			digits = digits[2:]
+		}
+	}
+
+	var value int64
+	for _, c := range []byte(digits) {
+		var d int64
+		switch {
+		case c >= '0' && c <= '9':
+			d = int64(c - '0')
+		case c >= 'a' && c <= 'f':
+			d = int64(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			d = int64(c-'A') + 10
+		default:
+			d = base
+		}
+
+		if d >= base {
+			return 0, fmt.Errorf("invalid number %q", s)
+		}
+
+		value = value*base + d
+		if value > 1<<32 {
+			return 0, fmt.Errorf("number too large %q", s)
+		}
+	}
+
+	return value, nil
+}
+
+// unquote decodes a quoted literal at the start of s and returns the
+// decoded value and the number of bytes consumed.
+func unquote(s string, quote byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+
+		case c == '\\':
+			i++
+			if i >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape sequence")
+			}
+
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			case '\\', '\'', '"':
+				b.WriteByte(s[i])
+			case 'x':
+				if i+2 >= len(s) {
+					return "", 0, fmt.Errorf("invalid hexadecimal escape")
+				}
+				v, err := parseNumber("0x" + s[i+1:i+3])
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid hexadecimal escape")
+				}
+				b.WriteByte(byte(v))
+				i += 2
+			default:
+				return "", 0, fmt.Errorf("unknown escape sequence \\%c", s[i])
+			}
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated literal")
+}
@@ -0,0 +1,137 @@
+package asm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+)
+
+// Disassembler returns the assembly syntax of a 32-bit instruction.
+type Disassembler func(encoded uint32) string
+
+// List writes a listing of an executable of an architecture with 32-bit
+// little-endian instructions, loaded at the base address.
+//
+// Symbols label the addresses that they refer to. If the executable has a
+// debug line table, the source line of each instruction is shown whenever
+// it changes.
+func List(w io.Writer, f *exe.File, base uint16, disassemble Disassembler) error {
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(bw, "; entrypoint %04x\n", f.Entrypoint)
+
+	l := lister{w: bw, f: f, disassemble: disassemble}
+	address := int(base)
+	segments := []struct {
+		name string
+		data []byte
+		code bool
+	}{
+		{".text", f.Code, true},
+		{".rodata", f.ROData, false},
+		{".data", f.PIData, false},
+	}
+	for _, s := range segments {
+		if len(s.data) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(bw, "\n; %s\n", s.name)
+		if s.code {
+			l.code(address, s.data)
+		} else {
+			l.data(address, s.data)
+		}
+		address += len(s.data)
+	}
+
+	if f.ZIDataSize != 0 {
+		_, _ = fmt.Fprintf(bw, "\n; .bss\n")
+		l.labels(address)
+		_, _ = fmt.Fprintf(bw, "%04x  .space %d\n", address, f.ZIDataSize)
+	}
+
+	return bw.Flush()
+}
+
+type lister struct {
+	w           *bufio.Writer
+	f           *exe.File
+	disassemble Disassembler
+
+	// Last source line shown.
+	file string
+	line int
+}
+
+func (l *lister) code(base int, code []byte) {
+	offset := 0
+	for ; offset+4 <= len(code); offset += 4 {
+		address := base + offset
+		l.labels(address)
+
+		encoded := binary.LittleEndian.Uint32(code[offset:])
+		text := l.disassemble(encoded)
+		listing := fmt.Sprintf("%04x  %08x  %-28s%s", address, encoded, text, l.source(address))
+		_, _ = fmt.Fprintln(l.w, strings.TrimRight(listing, " "))
+	}
+
+	// Trailing bytes that do not make up a full instruction.
+	if offset < len(code) {
+		l.data(base+offset, code[offset:])
+	}
+}
+
+func (l *lister) data(base int, data []byte) {
+	const bytesPerLine = 8
+
+	for offset := 0; offset < len(data); {
+		address := base + offset
+		l.labels(address)
+
+		// Stop at the next label, so that it can be shown.
+		end := min(offset+bytesPerLine, len(data))
+		for i := offset + 1; i < end; i++ {
+			if len(l.symbolsAt(base+i)) != 0 {
+				end = i
+				break
+			}
+		}
+
+		_, _ = fmt.Fprintf(l.w, "%04x  % x\n", address, data[offset:end])
+		offset = end
+	}
+}
+
+// labels writes the symbols at the address.
+func (l *lister) labels(address int) {
+	for _, s := range l.symbolsAt(address) {
+		_, _ = fmt.Fprintf(l.w, "%s:\n", s.Name)
+	}
+}
+
+func (l *lister) symbolsAt(address int) []exe.Symbol {
+	var symbols []exe.Symbol
+	for _, s := range l.f.Symbols {
+		if int(s.Address) == address {
+			symbols = append(symbols, s)
+		}
+	}
+
+	return symbols
+}
+
+// source returns a comment with the source line of the address, unless it
+// is the same as the previous one.
+func (l *lister) source(address int) string {
+	file, line, ok := l.f.Lines.Line(uint16(address))
+	if !ok || file == l.file && line == l.line {
+		return ""
+	}
+
+	l.file, l.line = file, line
+	return fmt.Sprintf("; %s:%d", file, line)
+}
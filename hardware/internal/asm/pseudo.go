@@ -0,0 +1,59 @@
+package asm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Pseudo is a pseudo-instruction, which expands into a single operation
+// of type O.
+type Pseudo[O any] struct {
+	Operation O
+	Operands  int
+
+	// Expand returns the operands of the operation.
+	Expand Expansion
+}
+
+// Expansion returns the operands of the operation of a pseudo-instruction
+// from its own operands.
+type Expansion func(operands []Operand) []Operand
+
+// Resolve checks the number of operands of the pseudo-instruction, and
+// returns its operation and the operands of the operation.
+func (p *Pseudo[O]) Resolve(mnemonic string, operands []Operand) (O, []Operand, error) {
+	if len(operands) != p.Operands {
+		var zero O
+		return zero, nil, fmt.Errorf("%s takes %d operands, got %d",
+			mnemonic, p.Operands, len(operands))
+	}
+
+	return p.Operation, p.Expand(operands), nil
+}
+
+// Prepend registers to the operands.
+func Prepend(registers ...fmt.Stringer) Expansion {
+	return func(operands []Operand) []Operand {
+		var expanded []Operand
+		for _, r := range registers {
+			expanded = append(expanded, Operand{Register: r.String()})
+		}
+
+		return append(expanded, operands...)
+	}
+}
+
+// Fixed ignores the operands, which are empty, and uses the registers
+// followed by the immediate instead.
+func Fixed(imm int64, registers ...fmt.Stringer) Expansion {
+	return func([]Operand) []Operand {
+		return append(Prepend(registers...)(nil), Operand{Expr: Number(imm)})
+	}
+}
+
+// AppendImm appends an immediate to the operands.
+func AppendImm(imm int64) Expansion {
+	return func(operands []Operand) []Operand {
+		return append(slices.Clone(operands), Operand{Expr: Number(imm)})
+	}
+}
@@ -0,0 +1,52 @@
+package asm_test
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+type reg string
+
+func (r reg) String() string { return string(r) }
+
+func TestPseudo_Resolve(t *testing.T) {
+	x := asm.Operand{Register: "x"}
+	imm := asm.Operand{Expr: asm.Number(2)}
+
+	tests := []struct {
+		name     string
+		expand   asm.Expansion
+		operands []asm.Operand
+		expected []asm.Operand
+	}{
+		{"prepend", asm.Prepend(reg("z"), reg("y")), []asm.Operand{x}, []asm.Operand{
+			{Register: "z"}, {Register: "y"}, x,
+		}},
+		{"fixed", asm.Fixed(0, reg("z"), reg("y")), nil, []asm.Operand{
+			{Register: "z"}, {Register: "y"}, {Expr: asm.Number(0)},
+		}},
+		{"append immediate", asm.AppendImm(2), []asm.Operand{x}, []asm.Operand{x, imm}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pseudo := asm.Pseudo[string]{Operation: "op", Operands: len(tt.operands), Expand: tt.expand}
+			operation, operands, err := pseudo.Resolve("pseudo", tt.operands)
+			require.Success(t, err)
+			expect.Equal(t, "op", operation)
+			require.Equal(t, len(tt.expected), len(operands))
+			for i := range operands {
+				expect.Equal(t, tt.expected[i].Register, operands[i].Register)
+				expect.Equal(t, tt.expected[i].Expr == nil, operands[i].Expr == nil)
+			}
+		})
+	}
+}
+
+func TestPseudo_Resolve_operands(t *testing.T) {
+	pseudo := asm.Pseudo[string]{Operation: "op", Operands: 1, Expand: asm.AppendImm(1)}
+	_, _, err := pseudo.Resolve("pseudo", nil)
+	expect.Equal(t, "pseudo takes 1 operands, got 0", err.Error())
+}
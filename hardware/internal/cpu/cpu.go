@@ -0,0 +1,138 @@
+// Package cpu implements the execution logic shared by the 16-bit machines:
+// instruction fetch, traps, branch conditions and the ALU.
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/jespert/primordial/hardware/internal/memory"
+)
+
+// Trap is an error raised by the execution of an instruction.
+type Trap struct {
+	IP  memory.Address
+	Err error
+}
+
+func (t *Trap) Error() string {
+	return t.Err.Error()
+}
+
+func (t *Trap) Unwrap() error {
+	return t.Err
+}
+
+// Fetch the 32-bit instruction at the IP, which must be halfword-aligned.
+func Fetch(m *memory.Memory, ip memory.Address) (uint32, error) {
+	if ip%2 != 0 {
+		return 0, fmt.Errorf("unaligned IP: %04x", ip)
+	}
+
+	v, err := m.ReadW(ip)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch instruction at %04x: %w", ip, err)
+	}
+
+	return uint32(v), nil
+}
+
+// Condition of a branch, which compares two operands.
+type Condition uint8
+
+const (
+	Equal Condition = iota
+	NotEqual
+	LessSigned
+	GreaterEqualSigned
+	LessUnsigned
+	GreaterEqualUnsigned
+)
+
+// Holds reports whether the condition holds for the operands, in order.
+func (c Condition) Holds(a, b uint16) bool {
+	switch c {
+	case Equal:
+		return a == b
+	case NotEqual:
+		return a != b
+	case LessSigned:
+		return int16(a) < int16(b)
+	case GreaterEqualSigned:
+		return int16(a) >= int16(b)
+	case LessUnsigned:
+		return a < b
+	default:
+		return a >= b
+	}
+}
+
+// ALUB applies a byte operation to the least significant bytes of the
+// operands. The result is zero-extended to a halfword.
+//
+// The operation is identified by its function field, the four least
+// significant bits of the operation, which is shared by the register and
+// immediate variants.
+func ALUB(function uint16, a, b uint16) uint16 {
+	x, y := uint8(a), uint8(b)
+	var result uint8
+
+	// Shift amounts are taken modulo the operand size.
+	shift := y & 0x7
+
+	switch function & 0xf {
+	case 0x0:
+		result = x & y
+	case 0x1:
+		result = x | y
+	case 0x2:
+		result = x ^ y
+	case 0x3:
+		result = uint8(int8(x) >> shift)
+	case 0x4:
+		result = x >> shift
+	case 0x5:
+		result = x << shift
+	case 0x6:
+		result = x + y
+	default:
+		result = x - y
+	}
+
+	return uint16(result)
+}
+
+// ALUH applies a halfword operation to the operands.
+//
+// The operation is identified by its function field, like for ALUB.
+func ALUH(function uint16, x, y uint16) uint16 {
+	// Shift amounts are taken modulo the operand size.
+	shift := y & 0xf
+
+	switch function & 0xf {
+	case 0x0:
+		return x & y
+	case 0x1:
+		return x | y
+	case 0x2:
+		return x ^ y
+	case 0x3:
+		return uint16(int16(x) >> shift)
+	case 0x4:
+		return x >> shift
+	case 0x5:
+		return x << shift
+	case 0x6:
+		return x + y
+	default:
+		return x - y
+	}
+}
+
+// SetIf returns 1 if the condition is true, and 0 otherwise.
+func SetIf(condition bool) uint16 {
+	if condition {
+		return 1
+	}
+
+	return 0
+}
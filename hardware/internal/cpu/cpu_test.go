@@ -0,0 +1,104 @@
+package cpu_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/cpu"
+	"github.com/jespert/primordial/hardware/internal/memory"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestTrap(t *testing.T) {
+	err := errors.New("illegal instruction")
+	var trap error = &cpu.Trap{IP: 0x8000, Err: err}
+	expect.Equal(t, "illegal instruction", trap.Error())
+	expect.Equal(t, true, errors.Is(trap, err))
+}
+
+func TestFetch(t *testing.T) {
+	var m memory.Memory
+	require.Success(t, m.WriteW(0x8000, 0x12345678))
+
+	v, err := cpu.Fetch(&m, 0x8000)
+	require.Success(t, err)
+	expect.Equal(t, 0x12345678, v)
+
+	_, err = cpu.Fetch(&m, 0x8001)
+	expect.Equal(t, "unaligned IP: 8001", err.Error())
+}
+
+func TestCondition_Holds(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition cpu.Condition
+		a, b      uint16
+		expected  bool
+	}{
+		{"equal", cpu.Equal, 3, 3, true},
+		{"not equal", cpu.NotEqual, 3, 3, false},
+		{"less signed", cpu.LessSigned, 0xffff, 1, true},
+		{"greater or equal signed", cpu.GreaterEqualSigned, 0xffff, 1, false},
+		{"less unsigned", cpu.LessUnsigned, 0xffff, 1, false},
+		{"greater or equal unsigned", cpu.GreaterEqualUnsigned, 0xffff, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect.Equal(t, tt.expected, tt.condition.Holds(tt.a, tt.b))
+		})
+	}
+}
+
+func TestALUB(t *testing.T) {
+	tests := []struct {
+		name     string
+		function uint16
+		a, b     uint16
+		expected uint16
+	}{
+		{"and", 0x0, 0x1ff0, 0x3c, 0x30},
+		{"or", 0x1, 0x1f0, 0x0f, 0xff},
+		{"xor", 0x2, 0xff, 0x0f, 0xf0},
+		{"sra", 0x3, 0x80, 9, 0xc0},
+		{"srl", 0x4, 0x80, 1, 0x40},
+		{"sll", 0x5, 0x81, 1, 0x02},
+		{"add", 0x6, 0xff, 1, 0},
+		{"sub", 0x7, 0, 1, 0xff},
+		{"function field only", 0xe106, 0xff, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect.Equal(t, tt.expected, cpu.ALUB(tt.function, tt.a, tt.b))
+		})
+	}
+}
+
+func TestALUH(t *testing.T) {
+	tests := []struct {
+		name     string
+		function uint16
+		x, y     uint16
+		expected uint16
+	}{
+		{"and", 0x0, 0xff00, 0x0ff0, 0x0f00},
+		{"or", 0x1, 0xff00, 0x00ff, 0xffff},
+		{"xor", 0x2, 0xffff, 0x00ff, 0xff00},
+		{"sra", 0x3, 0x8000, 17, 0xc000},
+		{"srl", 0x4, 0x8000, 1, 0x4000},
+		{"sll", 0x5, 0x8001, 1, 0x0002},
+		{"add", 0x6, 0xffff, 1, 0},
+		{"sub", 0x7, 0, 1, 0xffff},
+		{"function field only", 0xf116, 0xffff, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect.Equal(t, tt.expected, cpu.ALUH(tt.function, tt.x, tt.y))
+		})
+	}
+}
+
+func TestSetIf(t *testing.T) {
+	expect.Equal(t, 1, cpu.SetIf(true))
+	expect.Equal(t, 0, cpu.SetIf(false))
+}
@@ -0,0 +1,158 @@
+// Package density measures the code density of benchmarks on several
+// targets, so that the encodings of the architectures can be compared.
+//
+// Every target has its own version of each benchmark, written with the
+// instructions that its emulator runs. A benchmark halts by jumping to
+// itself, like the programs of the toolchains.
+package density
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+)
+
+// Target is an architecture, or a variant of one, that benchmarks run on.
+//
+// The internal packages of each architecture cannot be imported from
+// outside of it, so each architecture has a package that implements this
+// interface.
+type Target interface {
+	// Name of the target, which is also the extension of the names of its
+	// benchmark sources.
+	Name() string
+
+	// Provisional reports whether the encodings of the target are
+	// provisional, in which case its results do not measure its design.
+	Provisional() bool
+
+	// Assemble the sources of a benchmark into an executable.
+	Assemble(sources ...asm.Source) (*exe.File, error)
+
+	// Lengths returns the length in bytes of every instruction of the code.
+	Lengths(code []byte) ([]int, error)
+
+	// Run the executable until it halts and return the number of
+	// instructions retired, including the jump that halts it.
+	Run(f *exe.File, steps int) (int, error)
+}
+
+// Result of a benchmark on a target.
+type Result struct {
+	Benchmark string
+	Target    string
+
+	// Whether the encodings of the target are provisional.
+	Provisional bool
+
+	// Static size of the code in bytes, and number of instructions in it.
+	CodeSize     int
+	Instructions int
+
+	// Dynamic number of instructions retired until the benchmark halted.
+	Retired int
+
+	// Number of instructions of the code by length in bytes.
+	Lengths map[int]int
+}
+
+// Measure assembles and runs the source of a benchmark on a target. The
+// run fails if it does not halt after the given number of steps.
+func Measure(t Target, benchmark string, source asm.Source, steps int) (*Result, error) {
+	f, err := t.Assemble(source)
+	if err != nil {
+		return nil, err
+	}
+
+	lengths, err := t.Lengths(f.Code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source.Name, err)
+	}
+
+	retired, err := t.Run(f, steps)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source.Name, err)
+	}
+
+	r := &Result{
+		Benchmark:    benchmark,
+		Target:       t.Name(),
+		Provisional:  t.Provisional(),
+		CodeSize:     len(f.Code),
+		Instructions: len(lengths),
+		Retired:      retired,
+		Lengths:      make(map[int]int),
+	}
+	for _, n := range lengths {
+		r.Lengths[n]++
+	}
+
+	return r, nil
+}
+
+// RunUntilHalt steps until the IP does not change, and returns the number
+// of instructions retired. It helps targets implement Run.
+func RunUntilHalt(steps int, ip func() uint64, step func() error) (int, error) {
+	for i := range steps {
+		before := ip()
+		if err := step(); err != nil {
+			return 0, err
+		}
+
+		if ip() == before {
+			return i + 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("did not halt after %d instructions", steps)
+}
+
+// WriteReport writes a table with a row per result. The histogram of
+// instruction lengths has a column for every length of any result.
+//
+// Targets with provisional encodings are marked with an asterisk, which a
+// note after the table explains.
+func WriteReport(w io.Writer, results []*Result) error {
+	var lengths []int
+	for _, r := range results {
+		for n := range r.Lengths {
+			if !slices.Contains(lengths, n) {
+				lengths = append(lengths, n)
+			}
+		}
+	}
+	slices.Sort(lengths)
+
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(bw, "%-10s  %-10s  %6s  %6s  %8s", "benchmark", "target", "bytes", "instrs", "retired")
+	for _, n := range lengths {
+		_, _ = fmt.Fprintf(bw, "  %4s", fmt.Sprintf("%dB", n))
+	}
+	_, _ = fmt.Fprint(bw, "\n")
+
+	provisional := false
+	for _, r := range results {
+		target := r.Target
+		if r.Provisional {
+			target += "*"
+			provisional = true
+		}
+
+		_, _ = fmt.Fprintf(bw, "%-10s  %-10s  %6d  %6d  %8d",
+			r.Benchmark, target, r.CodeSize, r.Instructions, r.Retired)
+		for _, n := range lengths {
+			_, _ = fmt.Fprintf(bw, "  %4d", r.Lengths[n])
+		}
+		_, _ = fmt.Fprint(bw, "\n")
+	}
+
+	if provisional {
+		_, _ = fmt.Fprint(bw, "\n* Provisional encodings: these results do not measure the design.\n")
+	}
+
+	return bw.Flush()
+}
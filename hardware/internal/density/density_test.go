@@ -0,0 +1,89 @@
+package density_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/density"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+// fakeTarget has instructions of one byte per byte of source, whose value
+// is their length, and runs for as many steps as the source has bytes.
+type fakeTarget struct{}
+
+func (fakeTarget) Name() string { return "fake" }
+
+func (fakeTarget) Provisional() bool { return false }
+
+func (fakeTarget) Assemble(sources ...asm.Source) (*exe.File, error) {
+	return &exe.File{Code: sources[0].Data}, nil
+}
+
+func (fakeTarget) Lengths(code []byte) ([]int, error) {
+	var lengths []int
+	for i := 0; i < len(code); i += int(code[i]) {
+		lengths = append(lengths, int(code[i]))
+	}
+
+	return lengths, nil
+}
+
+func (fakeTarget) Run(f *exe.File, steps int) (int, error) {
+	ip := 0
+	return density.RunUntilHalt(steps, func() uint64 { return uint64(ip) }, func() error {
+		ip = min(ip+1, len(f.Code)-1)
+		return nil
+	})
+}
+
+func TestMeasure(t *testing.T) {
+	source := asm.Source{Name: "bench.s", Data: []byte{2, 0, 1, 2, 0, 4, 0, 0, 0}}
+	r, err := density.Measure(fakeTarget{}, "bench", source, 100)
+	require.Success(t, err)
+	expect.Equal(t, 9, r.CodeSize)
+	expect.Equal(t, 4, r.Instructions)
+	expect.Equal(t, 9, r.Retired)
+	expect.Equal(t, 3, len(r.Lengths))
+	expect.Equal(t, 1, r.Lengths[1])
+	expect.Equal(t, 2, r.Lengths[2])
+	expect.Equal(t, 1, r.Lengths[4])
+
+	var b bytes.Buffer
+	require.Success(t, density.WriteReport(&b, []*density.Result{r}))
+	expected := "benchmark   target       bytes  instrs   retired    1B    2B    4B\n" +
+		"bench       fake             9       4         9     1     2     1\n"
+	expect.Equal(t, expected, b.String())
+}
+
+func TestWriteReport_provisional(t *testing.T) {
+	results := []*density.Result{
+		{Benchmark: "bench", Target: "final", CodeSize: 4, Instructions: 2, Retired: 2, Lengths: map[int]int{2: 2}},
+		{Benchmark: "bench", Target: "draft", Provisional: true, CodeSize: 4, Instructions: 1, Retired: 1, Lengths: map[int]int{4: 1}},
+	}
+
+	var b bytes.Buffer
+	require.Success(t, density.WriteReport(&b, results))
+	expected := "benchmark   target       bytes  instrs   retired    2B    4B\n" +
+		"bench       final            4       2         2     2     0\n" +
+		"bench       draft*           4       1         1     0     1\n" +
+		"\n* Provisional encodings: these results do not measure the design.\n"
+	expect.Equal(t, expected, b.String())
+}
+
+func TestRunUntilHalt_errors(t *testing.T) {
+	ip := uint64(0)
+	_, err := density.RunUntilHalt(3, func() uint64 { return ip }, func() error {
+		ip++
+		return nil
+	})
+	expect.Equal(t, "did not halt after 3 instructions", err.Error())
+
+	trap := errors.New("trap")
+	_, err = density.RunUntilHalt(3, func() uint64 { return ip }, func() error { return trap })
+	expect.Equal(t, trap, err)
+}
@@ -0,0 +1,195 @@
+// Package exe implements the executable format (EXE).
+//
+// Only version 1 for 16-bit architectures is supported so far.
+// See "doc/Executable format.md" for the specification.
+package exe
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// File is an executable or library in memory.
+type File struct {
+	Header Header
+
+	// Segments with initialised contents.
+	Code   []byte
+	ROData []byte
+	PIData []byte
+
+	// Size of the zero-initialised writable data segment.
+	ZIDataSize uint16
+
+	// Address where execution starts.
+	Entrypoint uint16
+
+	// Symbols, in the order of the file. The writer sorts them by address.
+	Symbols []Symbol
+
+	// Optional debug information that maps addresses to source lines.
+	Lines LineTable
+}
+
+// Header is the file header, common to all versions of the format.
+type Header struct {
+	Version    uint8
+	Size       Size
+	Endianness Endianness
+	Type       Type
+	Arch       Name
+	ABI        Name
+	ArchFlags  uint64
+	ABIFlags   uint64
+}
+
+// Size of the architecture.
+type Size uint8
+
+const (
+	Size8   Size = 0
+	Size16  Size = 1
+	Size32  Size = 2
+	Size64  Size = 3
+	Size128 Size = 4
+)
+
+// Endianness of the architecture, which also determines the endianness of
+// the file after the header.
+type Endianness uint8
+
+const (
+	LittleEndian Endianness = 0
+	BigEndian    Endianness = 1
+)
+
+// ByteOrder returns the byte order of the endianness.
+func (e Endianness) ByteOrder() ByteOrder {
+	if e == BigEndian {
+		return binary.BigEndian
+	}
+
+	return binary.LittleEndian
+}
+
+// ByteOrder can both decode and append multibyte values.
+type ByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// Type of file.
+type Type uint8
+
+const (
+	StaticExecutable Type = 0
+	StaticLibrary    Type = 1
+)
+
+// Name is a packed short string, such as an architecture or ABI name.
+// Names shorter than four bytes are padded with NUL.
+type Name [4]byte
+
+// PackName packs a string of up to four bytes into a Name.
+// Longer strings are truncated.
+func PackName(s string) Name {
+	var n Name
+	copy(n[:], s)
+	return n
+}
+
+// String unpacks the name, removing the padding.
+func (n Name) String() string {
+	return strings.TrimRight(string(n[:]), "\x00")
+}
+
+// Symbol is an entry of the symbol table.
+type Symbol struct {
+	Name    string
+	Address uint16
+	Type    SymbolType
+	Flags   uint16
+}
+
+// SymbolType classifies what a symbol refers to.
+type SymbolType uint16
+
+const (
+	SymbolNone     SymbolType = 0
+	SymbolFunction SymbolType = 1
+	SymbolObject   SymbolType = 2
+)
+
+// Segments returns the initialised segments concatenated in file order,
+// which is how they are laid out in memory.
+func (f *File) Segments() []byte {
+	data := make([]byte, 0, len(f.Code)+len(f.ROData)+len(f.PIData))
+	data = append(data, f.Code...)
+	data = append(data, f.ROData...)
+	data = append(data, f.PIData...)
+	return data
+}
+
+// SymbolAt returns the symbol with the highest address that is lower than
+// or equal to the given address. Only symbols of the given type are
+// considered, unless the type is SymbolNone.
+//
+// The symbols must be sorted by address.
+func SymbolAt(symbols []Symbol, address uint16, t SymbolType) (Symbol, bool) {
+	// Find the first symbol beyond the address.
+	i := sort.Search(len(symbols), func(i int) bool {
+		return symbols[i].Address > address
+	})
+
+	for i--; i >= 0; i-- {
+		if t == SymbolNone || symbols[i].Type == t {
+			return symbols[i], true
+		}
+	}
+
+	return Symbol{}, false
+}
+
+// LineEntry maps the addresses from its own up to the next entry's to a
+// source line. Line 0 means that the addresses have no source line, in
+// which case the file is ignored.
+type LineEntry struct {
+	Address uint16
+	File    string
+	Line    int
+}
+
+// LineTable is a debug line table, sorted by strictly increasing address.
+type LineTable []LineEntry
+
+// Line returns the source line of the address, if any.
+func (t LineTable) Line(address uint16) (file string, line int, ok bool) {
+	// Find the first entry beyond the address.
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].Address > address
+	})
+	if i == 0 || t[i-1].Line == 0 {
+		return "", 0, false
+	}
+
+	return t[i-1].File, t[i-1].Line, true
+}
+
+// Version of the format implemented by this package.
+const Version = 1
+
+var magic = [4]byte{'E', 'X', 'E', 0}
+
+const (
+	fileHeaderSize   = 32
+	mainHeaderSize   = 16
+	symbolEntrySize  = 8
+	stringsEntrySize = 2
+	linesHeaderSize  = 4
+	fileEntrySize    = 2
+	lineEntrySize    = 6
+
+	// Sizes and counts are S16, so they cannot exceed this value.
+	maxS16 = 0x7fff
+)
@@ -0,0 +1,279 @@
+package exe_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestFile_WriteTo_round_trip(t *testing.T) {
+	for _, endianness := range []exe.Endianness{exe.LittleEndian, exe.BigEndian} {
+		f := testFile()
+		f.Header.Endianness = endianness
+
+		var buffer bytes.Buffer
+		n, err := f.WriteTo(&buffer)
+		require.Success(t, err)
+		expect.Equal(t, int64(buffer.Len()), n)
+
+		actual, err := exe.Read(&buffer)
+		require.Success(t, err)
+
+		// Writing normalises the version and sorts the symbols.
+		expected := testFile()
+		expected.Header.Version = exe.Version
+		expected.Header.Size = exe.Size16
+		expected.Header.Endianness = endianness
+		expected.Symbols[0], expected.Symbols[1] = expected.Symbols[1], expected.Symbols[0]
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %+v, got %+v", expected, actual)
+		}
+	}
+}
+
+func TestFile_MarshalBinary_layout(t *testing.T) {
+	data, err := testFile().MarshalBinary()
+	require.Success(t, err)
+
+	expected := []byte{
+		// File header.
+		'E', 'X', 'E', 0, 1, 1, 0, 0,
+		'R', '1', '6', 0, 'P', 'R', 'I', 'M',
+		1, 0, 0, 0, 0, 0, 0, 0,
+		2, 0, 0, 0, 0, 0, 0, 0,
+		// Main header.
+		4, 0, 1, 0, 2, 0, 3, 0,
+		0x00, 0x80, 0, 0, 2, 0, 2, 0,
+		// Payload.
+		0xaa, 0xbb, 0xcc, 0xdd,
+		0xee,
+		0xf0, 0xf1,
+		// Symbol table.
+		0x00, 0x80, 1, 0, 0, 0, 1, 0,
+		0x04, 0x80, 2, 0, 3, 0, 0, 0,
+		// String table.
+		4, 0, 9, 0,
+		// String values.
+		'd', 'a', 't', 'a', 's', 't', 'a', 'r', 't',
+	}
+	expect.Equal(t, string(expected), string(data))
+}
+
+func TestParse_invalid(t *testing.T) {
+	valid, err := testFile().MarshalBinary()
+	require.Success(t, err)
+
+	testCases := []struct {
+		name   string
+		mutate func(data []byte) []byte
+	}{
+		{"empty", func(data []byte) []byte { return nil }},
+		{"magic", func(data []byte) []byte { data[3] = 'X'; return data }},
+		{"version", func(data []byte) []byte { data[4] = 2; return data }},
+		{"size", func(data []byte) []byte { data[5] = 2; return data }},
+		{"endianness", func(data []byte) []byte { data[6] = 2; return data }},
+		{"file type", func(data []byte) []byte { data[7] = 2; return data }},
+		{"negative size", func(data []byte) []byte { data[33] = 0x80; return data }},
+		{"relocations", func(data []byte) []byte { data[42] = 1; return data }},
+		{"truncated", func(data []byte) []byte { return data[:len(data)-1] }},
+		{"trailing data", func(data []byte) []byte { return append(data, 0) }},
+		{"string ID", func(data []byte) []byte { data[55+6] = 2; return data }},
+		{"unsorted strings", func(data []byte) []byte {
+			// Split the values as "datas" and "tart".
+			data[len(data)-13] = 5
+			return data
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.mutate(bytes.Clone(valid))
+			if _, err := exe.Parse(data); err == nil {
+				t.Error("expected invalid executable to fail")
+			}
+		})
+	}
+}
+
+func TestParse_unsorted_symbols(t *testing.T) {
+	data, err := testFile().MarshalBinary()
+	require.Success(t, err)
+
+	// Move the first symbol, start, after the second one.
+	data[55+1] = 0x90
+
+	f, err := exe.Parse(data)
+	require.Success(t, err)
+	require.Equal(t, 2, len(f.Symbols))
+	expect.Equal(t, exe.Symbol{Name: "start", Address: 0x9000, Type: exe.SymbolFunction}, f.Symbols[0])
+	expect.Equal(t, exe.Symbol{Name: "data", Address: 0x8004, Type: exe.SymbolObject, Flags: 3}, f.Symbols[1])
+}
+
+func TestFile_MarshalBinary_lines(t *testing.T) {
+	f := testFile()
+	f.Lines = testLines()
+
+	data, err := f.MarshalBinary()
+	require.Success(t, err)
+
+	plain, err := testFile().MarshalBinary()
+	require.Success(t, err)
+
+	// The file names are added to the string table, so only compare the
+	// trailing line table.
+	expected := []byte{
+		// Line table header.
+		2, 0, 4, 0,
+		// File table.
+		0, 0, 2, 0,
+		// Line entries.
+		0x00, 0x80, 0, 0, 1, 0,
+		0x02, 0x80, 0, 0, 2, 0,
+		0x04, 0x80, 1, 0, 7, 0,
+		0x05, 0x80, 0, 0, 0, 0,
+	}
+	expect.Equal(t, len(plain)+len("a.s")+len("lib.s")+4+len(expected), len(data))
+	expect.Equal(t, string(expected), string(data[len(data)-len(expected):]))
+
+	actual, err := exe.Parse(data)
+	require.Success(t, err)
+	if !reflect.DeepEqual(f.Lines, actual.Lines) {
+		t.Errorf("Expected %+v, got %+v", f.Lines, actual.Lines)
+	}
+}
+
+func TestParse_invalid_lines(t *testing.T) {
+	f := testFile()
+	f.Lines = testLines()
+	valid, err := f.MarshalBinary()
+	require.Success(t, err)
+
+	// Offsets from the end of the file.
+	const (
+		header = 4 + 4 + 4*6
+		files  = 4 + 4*6
+		lines  = 4 * 6
+	)
+
+	testCases := []struct {
+		name   string
+		mutate func(data []byte) []byte
+	}{
+		{"truncated", func(data []byte) []byte { return data[:len(data)-1] }},
+		{"trailing data", func(data []byte) []byte { return append(data, 0) }},
+		{"empty", func(data []byte) []byte {
+			data = data[:len(data)-files]
+			data[len(data)-2] = 0
+			return data
+		}},
+		{"negative size", func(data []byte) []byte {
+			data[len(data)-header+1] = 0x80
+			return data
+		}},
+		{"string ID", func(data []byte) []byte { data[len(data)-files] = 9; return data }},
+		{"duplicate file", func(data []byte) []byte { data[len(data)-files] = 2; return data }},
+		{"file index", func(data []byte) []byte { data[len(data)-lines+2] = 2; return data }},
+		{"negative line", func(data []byte) []byte { data[len(data)-lines+5] = 0x80; return data }},
+		{"unsorted", func(data []byte) []byte { data[len(data)-lines+6] = 0; return data }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.mutate(bytes.Clone(valid))
+			if _, err := exe.Parse(data); err == nil {
+				t.Error("expected invalid line table to fail")
+			}
+		})
+	}
+}
+
+func TestLineTable_Line(t *testing.T) {
+	lines := testLines()
+
+	testCases := []struct {
+		address uint16
+		file    string
+		line    int
+	}{
+		{0x7fff, "", 0},
+		{0x8000, "a.s", 1},
+		{0x8001, "a.s", 1},
+		{0x8003, "a.s", 2},
+		{0x8004, "lib.s", 7},
+		{0x8005, "", 0},
+		{0xffff, "", 0},
+	}
+
+	for _, tc := range testCases {
+		file, line, ok := lines.Line(tc.address)
+		expect.Equal(t, tc.line != 0, ok)
+		expect.Equal(t, tc.file, file)
+		expect.Equal(t, tc.line, line)
+	}
+}
+
+func TestSymbolAt(t *testing.T) {
+	symbols := []exe.Symbol{
+		{Name: "a", Address: 0x8000, Type: exe.SymbolFunction},
+		{Name: "b", Address: 0x8010, Type: exe.SymbolObject},
+		{Name: "c", Address: 0x8020, Type: exe.SymbolFunction},
+	}
+
+	testCases := []struct {
+		address  uint16
+		t        exe.SymbolType
+		expected string
+	}{
+		{0x7fff, exe.SymbolNone, ""},
+		{0x8000, exe.SymbolNone, "a"},
+		{0x8018, exe.SymbolNone, "b"},
+		{0x8018, exe.SymbolFunction, "a"},
+		{0xffff, exe.SymbolNone, "c"},
+	}
+
+	for _, tc := range testCases {
+		symbol, ok := exe.SymbolAt(symbols, tc.address, tc.t)
+		expect.Equal(t, tc.expected != "", ok)
+		expect.Equal(t, tc.expected, symbol.Name)
+	}
+}
+
+func TestName(t *testing.T) {
+	expect.Equal(t, exe.Name{'R', '1', '6', 0}, exe.PackName("R16"))
+	expect.Equal(t, "R16", exe.PackName("R16").String())
+	expect.Equal(t, "SR16", exe.PackName("SR16").String())
+}
+
+func testFile() *exe.File {
+	return &exe.File{
+		Header: exe.Header{
+			Type:      exe.StaticExecutable,
+			Arch:      exe.PackName("R16"),
+			ABI:       exe.PackName("PRIM"),
+			ArchFlags: 1,
+			ABIFlags:  2,
+		},
+		Code:       []byte{0xaa, 0xbb, 0xcc, 0xdd},
+		ROData:     []byte{0xee},
+		PIData:     []byte{0xf0, 0xf1},
+		ZIDataSize: 3,
+		Entrypoint: 0x8000,
+		Symbols: []exe.Symbol{
+			{Name: "data", Address: 0x8004, Type: exe.SymbolObject, Flags: 3},
+			{Name: "start", Address: 0x8000, Type: exe.SymbolFunction},
+		},
+	}
+}
+
+func testLines() exe.LineTable {
+	return exe.LineTable{
+		{Address: 0x8000, File: "a.s", Line: 1},
+		{Address: 0x8002, File: "a.s", Line: 2},
+		{Address: 0x8004, File: "lib.s", Line: 7},
+		{Address: 0x8005},
+	}
+}
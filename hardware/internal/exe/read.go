@@ -0,0 +1,322 @@
+package exe
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Read a file in the executable format.
+func Read(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read executable: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse a file in the executable format.
+//
+// The format is strict, so any inconsistency is an error, including
+// trailing data.
+func Parse(data []byte) (*File, error) {
+	p := parser{data: data}
+
+	var f File
+	if err := p.fileHeader(&f.Header); err != nil {
+		return nil, err
+	}
+
+	counts, err := p.mainHeader(&f)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Code, err = p.bytes("code", counts.codeSize); err != nil {
+		return nil, err
+	}
+	if f.ROData, err = p.bytes("ro_data", counts.roDataSize); err != nil {
+		return nil, err
+	}
+	if f.PIData, err = p.bytes("pi_data", counts.piDataSize); err != nil {
+		return nil, err
+	}
+
+	// Relocations are not specified yet.
+	if counts.numRelocs != 0 {
+		return nil, fmt.Errorf("relocations are not supported: %d", counts.numRelocs)
+	}
+
+	type rawSymbol struct {
+		symbol   Symbol
+		stringID int
+	}
+
+	rawSymbols := make([]rawSymbol, counts.numSymbols)
+	for i := range rawSymbols {
+		entry, err := p.bytes("symbol table", symbolEntrySize)
+		if err != nil {
+			return nil, err
+		}
+
+		rawSymbols[i] = rawSymbol{
+			symbol: Symbol{
+				Address: p.order.Uint16(entry[0:]),
+				Type:    SymbolType(p.order.Uint16(entry[2:])),
+				Flags:   p.order.Uint16(entry[4:]),
+			},
+			stringID: int(int16(p.order.Uint16(entry[6:]))),
+		}
+	}
+
+	strings, err := p.strings(counts.numStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Symbols = make([]Symbol, len(rawSymbols))
+	for i, raw := range rawSymbols {
+		if raw.stringID < 0 || raw.stringID >= len(strings) {
+			return nil, fmt.Errorf("invalid string ID of symbol %d: %d", i, raw.stringID)
+		}
+
+		f.Symbols[i] = raw.symbol
+		f.Symbols[i].Name = strings[raw.stringID]
+	}
+
+	// Anything after the string values must be a debug line table.
+	if len(p.data) != 0 {
+		if f.Lines, err = p.lines(strings); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.data) != 0 {
+		return nil, fmt.Errorf("trailing data: %d bytes", len(p.data))
+	}
+
+	return &f, nil
+}
+
+// parser consumes the data from the front.
+type parser struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type mainCounts struct {
+	codeSize   int
+	roDataSize int
+	piDataSize int
+	numRelocs  int
+	numSymbols int
+	numStrings int
+}
+
+func (p *parser) bytes(what string, n int) ([]byte, error) {
+	if len(p.data) < n {
+		return nil, fmt.Errorf("truncated %s: want %d bytes, got %d", what, n, len(p.data))
+	}
+
+	b := p.data[:n:n]
+	p.data = p.data[n:]
+	return b, nil
+}
+
+func (p *parser) fileHeader(h *Header) error {
+	b, err := p.bytes("file header", fileHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	if [4]byte(b[0:4]) != magic {
+		return fmt.Errorf("invalid magic: % x", b[0:4])
+	}
+
+	h.Version = b[4]
+	h.Size = Size(b[5])
+	h.Endianness = Endianness(b[6])
+	h.Type = Type(b[7])
+	h.Arch = Name(b[8:12])
+	h.ABI = Name(b[12:16])
+
+	if h.Version != Version {
+		return fmt.Errorf("unsupported version: %d", h.Version)
+	}
+
+	if h.Size != Size16 {
+		return fmt.Errorf("unsupported size: %d", h.Size)
+	}
+
+	if h.Endianness != LittleEndian && h.Endianness != BigEndian {
+		return fmt.Errorf("invalid endianness: %d", h.Endianness)
+	}
+
+	if h.Type != StaticExecutable && h.Type != StaticLibrary {
+		return fmt.Errorf("invalid file type: %d", h.Type)
+	}
+
+	// Only now do we know how to decode multibyte fields.
+	p.order = h.Endianness.ByteOrder()
+	h.ArchFlags = p.order.Uint64(b[16:])
+	h.ABIFlags = p.order.Uint64(b[24:])
+	return nil
+}
+
+func (p *parser) mainHeader(f *File) (mainCounts, error) {
+	b, err := p.bytes("main header", mainHeaderSize)
+	if err != nil {
+		return mainCounts{}, err
+	}
+
+	names := [...]string{
+		"code_size",
+		"ro_data_size",
+		"pi_data_size",
+		"zi_data_size",
+		"entrypoint",
+		"num_relocs",
+		"num_symbols",
+		"num_strings",
+	}
+
+	var values [len(names)]int
+	for i, name := range names {
+		v := p.order.Uint16(b[2*i:])
+		values[i] = int(v)
+
+		// All fields but the entrypoint are sizes and counts.
+		if name != "entrypoint" && v > maxS16 {
+			return mainCounts{}, fmt.Errorf("negative %s: %d", name, int16(v))
+		}
+	}
+
+	f.ZIDataSize = uint16(values[3])
+	f.Entrypoint = uint16(values[4])
+	return mainCounts{
+		codeSize:   values[0],
+		roDataSize: values[1],
+		piDataSize: values[2],
+		numRelocs:  values[5],
+		numSymbols: values[6],
+		numStrings: values[7],
+	}, nil
+}
+
+func (p *parser) strings(n int) ([]string, error) {
+	table, err := p.bytes("string table", stringsEntrySize*n)
+	if err != nil {
+		return nil, err
+	}
+
+	ends := make([]int, n)
+	for i := range ends {
+		ends[i] = int(p.order.Uint16(table[stringsEntrySize*i:]))
+	}
+
+	// The last end is the total size of the string values.
+	var total int
+	if n > 0 {
+		total = ends[n-1]
+	}
+
+	values, err := p.bytes("string values", total)
+	if err != nil {
+		return nil, err
+	}
+
+	strings := make([]string, n)
+	start := 0
+	for i, end := range ends {
+		if end < start {
+			return nil, fmt.Errorf("string %d ends before it starts: %d < %d", i, end, start)
+		}
+
+		strings[i] = string(values[start:end])
+		start = end
+
+		if i > 0 && compareShortlex(strings[i-1], strings[i]) >= 0 {
+			return nil, fmt.Errorf("strings are not sorted or unique: %q", strings[i])
+		}
+	}
+
+	return strings, nil
+}
+
+func (p *parser) lines(strings []string) (LineTable, error) {
+	header, err := p.bytes("line table header", linesHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	numFiles := int(p.order.Uint16(header[0:]))
+	numLines := int(p.order.Uint16(header[2:]))
+	if numFiles > maxS16 || numLines > maxS16 {
+		return nil, fmt.Errorf("negative line table size: %d files, %d lines",
+			int16(numFiles), int16(numLines))
+	}
+	if numLines == 0 || numFiles == 0 {
+		return nil, fmt.Errorf("empty line table: %d files, %d lines", numFiles, numLines)
+	}
+
+	fileTable, err := p.bytes("file table", fileEntrySize*numFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, numFiles)
+	seen := make(map[int]bool, numFiles)
+	for i := range files {
+		id := int(int16(p.order.Uint16(fileTable[fileEntrySize*i:])))
+		if id < 0 || id >= len(strings) {
+			return nil, fmt.Errorf("invalid string ID of file %d: %d", i, id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate file: %q", strings[id])
+		}
+
+		seen[id] = true
+		files[i] = strings[id]
+	}
+
+	lineTable, err := p.bytes("line table", lineEntrySize*numLines)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(LineTable, numLines)
+	for i := range lines {
+		entry := lineTable[lineEntrySize*i:]
+		address := p.order.Uint16(entry[0:])
+		file := int(int16(p.order.Uint16(entry[2:])))
+		line := int(int16(p.order.Uint16(entry[4:])))
+
+		if file < 0 || file >= len(files) {
+			return nil, fmt.Errorf("invalid file of line entry %d: %d", i, file)
+		}
+		if line < 0 {
+			return nil, fmt.Errorf("negative line of line entry %d: %d", i, line)
+		}
+		if i > 0 && address <= lines[i-1].Address {
+			return nil, fmt.Errorf("line entries are not sorted by address: %04x", address)
+		}
+
+		lines[i] = LineEntry{Address: address, Line: line}
+		if line != 0 {
+			lines[i].File = files[file]
+		}
+	}
+
+	return lines, nil
+}
+
+// compareShortlex orders strings by length first, then lexicographically.
+func compareShortlex(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+
+	return cmp.Compare(a, b)
+}
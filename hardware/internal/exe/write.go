@@ -0,0 +1,183 @@
+package exe
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+)
+
+// WriteTo writes the file in the executable format.
+//
+// The header only needs to identify the architecture and ABI: the version
+// and size are always set to the ones implemented by this package.
+// Symbols are sorted by address and the string table is generated. The
+// debug line table is only written if it has entries.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// MarshalBinary encodes the file in the executable format.
+func (f *File) MarshalBinary() ([]byte, error) {
+	h := f.Header
+	h.Version = Version
+	h.Size = Size16
+	order := h.Endianness.ByteOrder()
+
+	symbols := slices.Clone(f.Symbols)
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Address < symbols[j].Address
+	})
+
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+	}
+
+	// Files are numbered in order of first appearance. Entries without a
+	// line do not need a file.
+	var files []string
+	fileIndex := make(map[string]int)
+	for i, l := range f.Lines {
+		if i > 0 && l.Address <= f.Lines[i-1].Address {
+			return nil, fmt.Errorf("line entries are not sorted by address: %04x", l.Address)
+		}
+		if l.Line < 0 || l.Line > maxS16 {
+			return nil, fmt.Errorf("line out of range: %d", l.Line)
+		}
+
+		if _, ok := fileIndex[l.File]; !ok && l.Line != 0 {
+			fileIndex[l.File] = len(files)
+			files = append(files, l.File)
+		}
+	}
+	if len(f.Lines) > 0 && len(files) == 0 {
+		return nil, fmt.Errorf("line table without any lines")
+	}
+	names = append(names, files...)
+
+	strings := newStringTable(names)
+
+	sizes := []struct {
+		name  string
+		value int
+	}{
+		{"code", len(f.Code)},
+		{"ro_data", len(f.ROData)},
+		{"pi_data", len(f.PIData)},
+		{"zi_data", int(f.ZIDataSize)},
+		{"symbols", len(symbols)},
+		{"strings", len(strings.values)},
+		{"string values", strings.size()},
+		{"line entries", len(f.Lines)},
+	}
+	for _, size := range sizes {
+		if size.value > maxS16 {
+			return nil, fmt.Errorf("too many %s: %d", size.name, size.value)
+		}
+	}
+
+	var buf []byte
+
+	// File header.
+	buf = append(buf, magic[:]...)
+	buf = append(buf, h.Version, byte(h.Size), byte(h.Endianness), byte(h.Type))
+	buf = append(buf, h.Arch[:]...)
+	buf = append(buf, h.ABI[:]...)
+	buf = order.AppendUint64(buf, h.ArchFlags)
+	buf = order.AppendUint64(buf, h.ABIFlags)
+
+	// Main header.
+	buf = order.AppendUint16(buf, uint16(len(f.Code)))
+	buf = order.AppendUint16(buf, uint16(len(f.ROData)))
+	buf = order.AppendUint16(buf, uint16(len(f.PIData)))
+	buf = order.AppendUint16(buf, f.ZIDataSize)
+	buf = order.AppendUint16(buf, f.Entrypoint)
+	buf = order.AppendUint16(buf, 0) // Relocations are not specified yet.
+	buf = order.AppendUint16(buf, uint16(len(symbols)))
+	buf = order.AppendUint16(buf, uint16(len(strings.values)))
+
+	// Program payload.
+	buf = append(buf, f.Code...)
+	buf = append(buf, f.ROData...)
+	buf = append(buf, f.PIData...)
+
+	// Symbol table.
+	for _, s := range symbols {
+		buf = order.AppendUint16(buf, s.Address)
+		buf = order.AppendUint16(buf, uint16(s.Type))
+		buf = order.AppendUint16(buf, s.Flags)
+		buf = order.AppendUint16(buf, uint16(strings.id(s.Name)))
+	}
+
+	buf = strings.append(buf, order)
+
+	// Optional debug line table.
+	if len(f.Lines) == 0 {
+		return buf, nil
+	}
+
+	buf = order.AppendUint16(buf, uint16(len(files)))
+	buf = order.AppendUint16(buf, uint16(len(f.Lines)))
+	for _, file := range files {
+		buf = order.AppendUint16(buf, uint16(strings.id(file)))
+	}
+	for _, l := range f.Lines {
+		buf = order.AppendUint16(buf, l.Address)
+		buf = order.AppendUint16(buf, uint16(fileIndex[l.File])) // 0 if no line.
+		buf = order.AppendUint16(buf, uint16(l.Line))
+	}
+
+	return buf, nil
+}
+
+// stringTable is a set of unique strings sorted with shortlex.
+type stringTable struct {
+	values []string
+}
+
+func newStringTable(values []string) *stringTable {
+	values = slices.Clone(values)
+	slices.SortFunc(values, compareShortlex)
+	return &stringTable{values: slices.Compact(values)}
+}
+
+func (t *stringTable) id(s string) int {
+	i, found := slices.BinarySearchFunc(t.values, s, compareShortlex)
+	if !found {
+		panic(fmt.Sprintf("string not in table: %q", s))
+	}
+
+	return i
+}
+
+// size of the string values.
+func (t *stringTable) size() int {
+	var size int
+	for _, s := range t.values {
+		size += len(s)
+	}
+
+	return size
+}
+
+// append the string table and the string values to the buffer.
+func (t *stringTable) append(buf []byte, order ByteOrder) []byte {
+	end := 0
+	for _, s := range t.values {
+		end += len(s)
+		buf = order.AppendUint16(buf, uint16(end))
+	}
+
+	for _, s := range t.values {
+		buf = append(buf, s...)
+	}
+
+	return buf
+}
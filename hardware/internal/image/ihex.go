@@ -0,0 +1,140 @@
+package image
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Record types of Intel HEX.
+const (
+	ihexData            = 0x00
+	ihexEndOfFile       = 0x01
+	ihexExtendedSegment = 0x02
+	ihexStartSegment    = 0x03
+	ihexExtendedLinear  = 0x04
+	ihexStartLinear     = 0x05
+)
+
+// ihexMinimumRecordSize is the size of the count, address, type and checksum.
+const ihexMinimumRecordSize = 5
+
+// WriteIntelHex writes an image in Intel HEX with 16 data bytes per record.
+// The entrypoint, if any, is written as a start linear address record.
+func WriteIntelHex(w io.Writer, img *Image) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range img.Chunks {
+		for offset := 0; offset < len(c.Data); offset += recordSize {
+			data := c.Data[offset:min(offset+recordSize, len(c.Data))]
+			writeIntelHexRecord(bw, ihexData, int(c.Address)+offset, data)
+		}
+	}
+
+	if img.HasEntrypoint {
+		entrypoint := []byte{0, 0, byte(img.Entrypoint >> 8), byte(img.Entrypoint)}
+		writeIntelHexRecord(bw, ihexStartLinear, 0, entrypoint)
+	}
+
+	writeIntelHexRecord(bw, ihexEndOfFile, 0, nil)
+	return bw.Flush()
+}
+
+func writeIntelHexRecord(w *bufio.Writer, recordType byte, address int, data []byte) {
+	record := []byte{byte(len(data)), byte(address >> 8), byte(address), recordType}
+	record = append(record, data...)
+	record = append(record, ihexChecksum(record))
+	_, _ = fmt.Fprintf(w, ":%X\n", record)
+}
+
+// ihexChecksum is the two's complement of the sum of the bytes.
+func ihexChecksum(record []byte) byte {
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+
+	return -sum
+}
+
+// ReadIntelHex reads an image in Intel HEX.
+//
+// Extended address records are only accepted if they keep the addresses
+// within the 16-bit address space.
+func ReadIntelHex(r io.Reader) (*Image, error) {
+	img := &Image{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		done, err := img.addIntelHexRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if done {
+			return img, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("missing end of file record")
+}
+
+// addIntelHexRecord adds a record to the image and reports whether it is the
+// end of file.
+func (img *Image) addIntelHexRecord(line string) (bool, error) {
+	text, ok := strings.CutPrefix(line, ":")
+	if !ok {
+		return false, fmt.Errorf("record does not start with ':'")
+	}
+
+	record, err := hex.DecodeString(text)
+	if err != nil {
+		return false, fmt.Errorf("invalid record: %w", err)
+	}
+
+	if len(record) < ihexMinimumRecordSize || len(record) != ihexMinimumRecordSize+int(record[0]) {
+		return false, fmt.Errorf("invalid record length: %d bytes", len(record))
+	}
+
+	if ihexChecksum(record) != 0 {
+		return false, fmt.Errorf("invalid checksum: 0x%02x", record[len(record)-1])
+	}
+
+	address := uint16(record[1])<<8 | uint16(record[2])
+	data := record[4 : len(record)-1]
+	switch recordType := record[3]; recordType {
+	case ihexData:
+		return false, img.Add(address, data)
+	case ihexEndOfFile:
+		return true, nil
+	case ihexExtendedSegment, ihexExtendedLinear:
+		if len(data) != 2 {
+			return false, fmt.Errorf("invalid extended address record: %d bytes", len(data))
+		}
+		if data[0] != 0 || data[1] != 0 {
+			return false, fmt.Errorf("address beyond the 16-bit address space: % x", data)
+		}
+		return false, nil
+	case ihexStartSegment, ihexStartLinear:
+		if len(data) != 4 {
+			return false, fmt.Errorf("invalid start address record: %d bytes", len(data))
+		}
+		if recordType == ihexStartLinear && (data[0] != 0 || data[1] != 0) {
+			return false, fmt.Errorf("start address beyond the 16-bit address space: % x", data)
+		}
+		// For segment records, only the IP is kept.
+		img.Entrypoint = uint16(data[2])<<8 | uint16(data[3])
+		img.HasEntrypoint = true
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown record type: 0x%02x", recordType)
+	}
+}
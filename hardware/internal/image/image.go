@@ -0,0 +1,197 @@
+// Package image implements memory images in the formats used by EPROM
+// programmers, FPGA memory initialisers and other toolchains: Intel HEX,
+// Motorola S-records, Verilog $readmemh files and raw flat binaries.
+//
+// Only 16-bit address spaces are supported so far.
+package image
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+)
+
+// AddressSpaceSize is the size of the address space of images.
+const AddressSpaceSize = 1 << 16
+
+// Image is the contents of some ranges of memory.
+type Image struct {
+	// Chunks sorted by address. They neither overlap nor touch.
+	Chunks []Chunk
+
+	// Address where execution starts, if HasEntrypoint is set.
+	Entrypoint    uint16
+	HasEntrypoint bool
+}
+
+// Chunk is a contiguous range of memory.
+type Chunk struct {
+	Address uint16
+	Data    []byte
+}
+
+// End returns the address after the last byte of the chunk, which can be
+// AddressSpaceSize.
+func (c *Chunk) End() int {
+	return int(c.Address) + len(c.Data)
+}
+
+// Add data at an address. Data that touches existing chunks is merged with
+// them, but it must not overlap them or go beyond the address space.
+func (img *Image) Add(address uint16, data []byte) error {
+	end := int(address) + len(data)
+	if end > AddressSpaceSize {
+		return fmt.Errorf("data at 0x%04x goes beyond the address space: %d bytes", address, len(data))
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	// Index of the first chunk that ends after the address.
+	i := sort.Search(len(img.Chunks), func(i int) bool {
+		return img.Chunks[i].End() > int(address)
+	})
+	if i < len(img.Chunks) && int(img.Chunks[i].Address) < end {
+		return fmt.Errorf("data at 0x%04x overlaps data at 0x%04x", address, img.Chunks[i].Address)
+	}
+
+	// Merge with the previous chunk, the next one, or both.
+	mergePrevious := i > 0 && img.Chunks[i-1].End() == int(address)
+	mergeNext := i < len(img.Chunks) && int(img.Chunks[i].Address) == end
+	switch {
+	case mergePrevious && mergeNext:
+		previous := &img.Chunks[i-1]
+		previous.Data = append(append(previous.Data, data...), img.Chunks[i].Data...)
+		img.Chunks = append(img.Chunks[:i], img.Chunks[i+1:]...)
+	case mergePrevious:
+		previous := &img.Chunks[i-1]
+		previous.Data = append(previous.Data, data...)
+	case mergeNext:
+		next := &img.Chunks[i]
+		next.Data = append(append([]byte(nil), data...), next.Data...)
+		next.Address = address
+	default:
+		chunk := Chunk{Address: address, Data: append([]byte(nil), data...)}
+		img.Chunks = append(img.Chunks, Chunk{})
+		copy(img.Chunks[i+1:], img.Chunks[i:])
+		img.Chunks[i] = chunk
+	}
+
+	return nil
+}
+
+// FromExecutable returns the image of the initialised segments of an
+// executable loaded at the base address.
+//
+// The zero-initialised data segment is left out, so the loader must clear
+// it if memory does not start zeroed.
+func FromExecutable(f *exe.File, base uint16) (*Image, error) {
+	img := &Image{Entrypoint: f.Entrypoint, HasEntrypoint: true}
+	if err := img.Add(base, f.Segments()); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// Format of an image file.
+type Format uint8
+
+const (
+	IntelHex Format = iota
+	SRecord
+	ReadMemH
+	Raw
+)
+
+var formatNames = [...]string{
+	IntelHex: "ihex",
+	SRecord:  "srec",
+	ReadMemH: "memh",
+	Raw:      "bin",
+}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+
+	return fmt.Sprintf("Format(%d)", f)
+}
+
+// ParseFormat parses the name of a format, as returned by Format.String.
+func ParseFormat(name string) (Format, error) {
+	for f, n := range formatNames {
+		if n == name {
+			return Format(f), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown image format: %q", name)
+}
+
+// FormatOf guesses the format of an image file from its extension.
+func FormatOf(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihex", ".ihx":
+		return IntelHex, true
+	case ".srec", ".s19", ".mot":
+		return SRecord, true
+	case ".mem", ".memh":
+		return ReadMemH, true
+	case ".bin", ".raw":
+		return Raw, true
+	default:
+		return 0, false
+	}
+}
+
+// Options of the formats that need them.
+type Options struct {
+	// Address of raw binaries.
+	Base uint16
+
+	// Bytes per word of $readmemh files: 1, 2 or 4. Zero means 1.
+	Width int
+}
+
+// Read an image in the format.
+func Read(r io.Reader, format Format, options Options) (*Image, error) {
+	switch format {
+	case IntelHex:
+		return ReadIntelHex(r)
+	case SRecord:
+		return ReadSRecord(r)
+	case ReadMemH:
+		return ReadMemHex(r, options.Width)
+	case Raw:
+		return ReadRaw(r, options.Base)
+	default:
+		return nil, fmt.Errorf("unknown image format: %v", format)
+	}
+}
+
+// Write an image in the format.
+func Write(w io.Writer, img *Image, format Format, options Options) error {
+	switch format {
+	case IntelHex:
+		return WriteIntelHex(w, img)
+	case SRecord:
+		return WriteSRecord(w, img)
+	case ReadMemH:
+		return WriteMemHex(w, img, options.Width)
+	case Raw:
+		return WriteRaw(w, img)
+	default:
+		return fmt.Errorf("unknown image format: %v", format)
+	}
+}
+
+// recordSize is the number of data bytes per record or line of text
+// formats.
+const recordSize = 16
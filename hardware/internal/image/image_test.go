@@ -0,0 +1,268 @@
+package image_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/internal/image"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestImage_Add(t *testing.T) {
+	var img image.Image
+	require.Success(t, img.Add(0x10, []byte{3, 4}))
+	require.Success(t, img.Add(0x20, []byte{7}))
+	require.Success(t, img.Add(0x0e, []byte{1, 2}))
+	require.Success(t, img.Add(0x12, []byte{5}))
+	require.Success(t, img.Add(0x00, nil))
+	require.Success(t, img.Add(0xffff, []byte{9}))
+
+	expected := []image.Chunk{
+		{Address: 0x0e, Data: []byte{1, 2, 3, 4, 5}},
+		{Address: 0x20, Data: []byte{7}},
+		{Address: 0xffff, Data: []byte{9}},
+	}
+	if !reflect.DeepEqual(expected, img.Chunks) {
+		t.Errorf("Expected %v, got %v", expected, img.Chunks)
+	}
+
+	// Filling the gap merges both neighbours.
+	require.Success(t, img.Add(0x13, make([]byte, 0x0d)))
+	expect.Equal(t, 2, len(img.Chunks))
+	expect.Equal(t, 0x21-0x0e, len(img.Chunks[0].Data))
+
+	for _, address := range []uint16{0x0d, 0x12, 0x20} {
+		if err := img.Add(address, []byte{0, 0}); err == nil {
+			t.Errorf("Expected overlapping data at 0x%04x to fail", address)
+		}
+	}
+
+	if err := img.Add(0xfff0, make([]byte, 0x11)); err == nil {
+		t.Error("Expected data beyond the address space to fail")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	img := testImage(t)
+	verifier := approval.NewTextVerifier(t)
+	for _, format := range []image.Format{image.IntelHex, image.SRecord, image.ReadMemH} {
+		_, _ = fmt.Fprintf(verifier.Writer(), "; %v\n", format)
+		require.Success(t, image.Write(verifier.Writer(), img, format, image.Options{}))
+		_, _ = fmt.Fprintln(verifier.Writer())
+	}
+
+	for _, width := range []int{2, 4} {
+		_, _ = fmt.Fprintf(verifier.Writer(), "; memh, width %d\n", width)
+		require.Success(t, image.WriteMemHex(verifier.Writer(), img, width))
+		_, _ = fmt.Fprintln(verifier.Writer())
+	}
+
+	verifier.Verify()
+}
+
+func TestRead_round_trip(t *testing.T) {
+	testCases := []struct {
+		format  image.Format
+		options image.Options
+	}{
+		{image.IntelHex, image.Options{}},
+		{image.SRecord, image.Options{}},
+		{image.ReadMemH, image.Options{}},
+		{image.ReadMemH, image.Options{Width: 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v/%d", tc.format, tc.options.Width), func(t *testing.T) {
+			img := testImage(t)
+			var buffer bytes.Buffer
+			require.Success(t, image.Write(&buffer, img, tc.format, tc.options))
+
+			actual, err := image.Read(&buffer, tc.format, tc.options)
+			require.Success(t, err)
+
+			// Neither format keeps everything.
+			switch tc.format {
+			case image.ReadMemH:
+				img.HasEntrypoint = false
+				img.Entrypoint = 0
+				if tc.options.Width == 2 {
+					// The odd-sized chunk is padded to whole words.
+					img.Chunks[0].Data = append(img.Chunks[0].Data, 0)
+				}
+			}
+
+			if !reflect.DeepEqual(img, actual) {
+				t.Errorf("Expected %+v, got %+v", img, actual)
+			}
+		})
+	}
+}
+
+func TestRead_round_trip_without_entrypoint(t *testing.T) {
+	for _, format := range []image.Format{image.IntelHex, image.SRecord} {
+		t.Run(format.String(), func(t *testing.T) {
+			img := testImage(t)
+			img.Entrypoint, img.HasEntrypoint = 0, false
+			var buffer bytes.Buffer
+			require.Success(t, image.Write(&buffer, img, format, image.Options{}))
+
+			actual, err := image.Read(&buffer, format, image.Options{})
+			require.Success(t, err)
+			if !reflect.DeepEqual(img, actual) {
+				t.Errorf("Expected %+v, got %+v", img, actual)
+			}
+		})
+	}
+}
+
+func TestReadSRecord_termination(t *testing.T) {
+	// Without a termination record, or with a zero address, there is no
+	// entrypoint.
+	for _, text := range []string{"S104000001FA\n", "S104000001FA\nS9030000FC\n"} {
+		img, err := image.ReadSRecord(strings.NewReader(text))
+		require.Success(t, err)
+		expected := &image.Image{Chunks: []image.Chunk{{Address: 0, Data: []byte{1}}}}
+		if !reflect.DeepEqual(expected, img) {
+			t.Errorf("Expected %+v, got %+v", expected, img)
+		}
+	}
+
+	img, err := image.ReadSRecord(strings.NewReader("S90380007C\nS104000001FA\n"))
+	require.Success(t, err)
+	expect.Equal(t, true, img.HasEntrypoint)
+	expect.Equal(t, 0x8000, img.Entrypoint)
+	expect.Equal(t, 0, len(img.Chunks))
+}
+
+func TestWriteRaw(t *testing.T) {
+	var img image.Image
+	require.Success(t, img.Add(0x8004, []byte{3, 4}))
+	require.Success(t, img.Add(0x8000, []byte{1, 2}))
+
+	var buffer bytes.Buffer
+	require.Success(t, image.WriteRaw(&buffer, &img))
+	expect.Equal(t, "\x01\x02\x00\x00\x03\x04", buffer.String())
+}
+
+func TestReadRaw(t *testing.T) {
+	img, err := image.ReadRaw(strings.NewReader("\x01\x02"), 0x8000)
+	require.Success(t, err)
+	expected := &image.Image{
+		Chunks:        []image.Chunk{{Address: 0x8000, Data: []byte{1, 2}}},
+		Entrypoint:    0x8000,
+		HasEntrypoint: true,
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Expected %+v, got %+v", expected, img)
+	}
+
+	_, err = image.ReadRaw(bytes.NewReader(make([]byte, 0x8001)), 0x8000)
+	if err == nil {
+		t.Error("Expected raw binary beyond the address space to fail")
+	}
+}
+
+func TestReadMemHex_comments(t *testing.T) {
+	text := "// Header.\n@10 /* Start\nhere */ 12_34 // Word.\nabcd\n"
+	img, err := image.ReadMemHex(strings.NewReader(text), 2)
+	require.Success(t, err)
+	expected := &image.Image{Chunks: []image.Chunk{{Address: 0x20, Data: []byte{0x34, 0x12, 0xcd, 0xab}}}}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Expected %+v, got %+v", expected, img)
+	}
+}
+
+func TestRead_invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format image.Format
+		text   string
+	}{
+		{"ihex without end", image.IntelHex, ":0100000001FE\n"},
+		{"ihex without colon", image.IntelHex, "0100000001FE\n:00000001FF\n"},
+		{"ihex checksum", image.IntelHex, ":0100000001FF\n:00000001FF\n"},
+		{"ihex length", image.IntelHex, ":0200000001FD\n:00000001FF\n"},
+		{"ihex extended", image.IntelHex, ":020000040001F9\n:00000001FF\n"},
+		{"ihex type", image.IntelHex, ":00000006FA\n:00000001FF\n"},
+		{"ihex overlap", image.IntelHex, ":0100000001FE\n:0100000001FE\n:00000001FF\n"},
+		{"srec checksum", image.SRecord, "S104000001FF\nS9030000FC\n"},
+		{"srec type", image.SRecord, "S4030000FC\n"},
+		{"srec count", image.SRecord, "S104000001FA\nS5030002FA\nS9030000FC\n"},
+		{"srec address", image.SRecord, "S2050100000100F8\nS9030000FC\n"},
+		{"memh word", image.ReadMemH, "100\n"},
+		{"memh address", image.ReadMemH, "@10000 00\n"},
+		{"memh overlap", image.ReadMemH, "00 @0 00\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := image.Read(strings.NewReader(tc.text), tc.format, image.Options{})
+			if err == nil {
+				t.Error("Expected invalid image to fail")
+			}
+		})
+	}
+}
+
+func TestFromExecutable(t *testing.T) {
+	f := &exe.File{
+		Code:       []byte{1, 2, 3, 4},
+		ROData:     []byte{5},
+		PIData:     []byte{6, 7},
+		ZIDataSize: 8,
+		Entrypoint: 0x8004,
+	}
+
+	img, err := image.FromExecutable(f, 0x8000)
+	require.Success(t, err)
+	expected := &image.Image{
+		Chunks:        []image.Chunk{{Address: 0x8000, Data: []byte{1, 2, 3, 4, 5, 6, 7}}},
+		Entrypoint:    0x8004,
+		HasEntrypoint: true,
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Expected %+v, got %+v", expected, img)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	testCases := map[string]image.Format{
+		"a.hex":  image.IntelHex,
+		"a.S19":  image.SRecord,
+		"a.mem":  image.ReadMemH,
+		"a.bin":  image.Raw,
+		"a.srec": image.SRecord,
+	}
+
+	for path, expected := range testCases {
+		format, ok := image.FormatOf(path)
+		require.Equal(t, true, ok)
+		expect.Equal(t, expected, format)
+
+		parsed, err := image.ParseFormat(format.String())
+		require.Success(t, err)
+		expect.Equal(t, format, parsed)
+	}
+
+	_, ok := image.FormatOf("a.exe")
+	expect.Equal(t, false, ok)
+}
+
+func testImage(t *testing.T) *image.Image {
+	t.Helper()
+
+	img := &image.Image{Entrypoint: 0x8000, HasEntrypoint: true}
+	require.Success(t, img.Add(0x0100, []byte("Hello, world!")))
+	require.Success(t, img.Add(0x8000, []byte{
+		0x00, 0x10, 0x01, 0x50, 0x00, 0x00, 0x00, 0x70,
+		0x04, 0x80, 0x00, 0x40, 0xde, 0xad, 0xbe, 0xef,
+		0x12, 0x34,
+	}))
+	return img
+}
@@ -0,0 +1,169 @@
+package image
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteMemHex writes an image in the format of the Verilog $readmemh task,
+// which VHDL testbenches can also parse easily.
+//
+// Memory is organised in little-endian words of the width in bytes, which
+// must be 1, 2 or 4, and addresses count words. Each run of initialised
+// words starts with its address, and partially initialised words are
+// padded with zeroes. The entrypoint is not written.
+func WriteMemHex(w io.Writer, img *Image, width int) error {
+	width, err := memHexWidth(width)
+	if err != nil {
+		return err
+	}
+
+	var data [AddressSpaceSize]byte
+	var initialised [AddressSpaceSize]bool
+	for _, c := range img.Chunks {
+		copy(data[c.Address:], c.Data)
+		for i := range c.Data {
+			initialised[int(c.Address)+i] = true
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	wordsPerLine := recordSize / width
+	column := 0
+	for address := 0; address < AddressSpaceSize; address += width {
+		if !anyTrue(initialised[address : address+width]) {
+			if column > 0 {
+				_, _ = fmt.Fprintln(bw)
+			}
+			column = -1
+			continue
+		}
+
+		switch {
+		case column < 0 || address == 0:
+			_, _ = fmt.Fprintf(bw, "@%04x\n", address/width)
+			column = 0
+		case column == wordsPerLine:
+			_, _ = fmt.Fprintln(bw)
+			column = 0
+		case column != 0:
+			_, _ = fmt.Fprint(bw, " ")
+		}
+
+		var word uint32
+		for i := width - 1; i >= 0; i-- {
+			word = word<<8 | uint32(data[address+i])
+		}
+		_, _ = fmt.Fprintf(bw, "%0*x", 2*width, word)
+		column++
+	}
+
+	if column > 0 {
+		_, _ = fmt.Fprintln(bw)
+	}
+
+	return bw.Flush()
+}
+
+func anyTrue(values []bool) bool {
+	for _, v := range values {
+		if v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadMemHex reads an image in the format of the Verilog $readmemh task,
+// with little-endian words of the width in bytes: 1, 2 or 4.
+//
+// Words are hexadecimal and may contain underscores. Addresses start with
+// '@' and count words. Both // and /* */ comments are skipped.
+func ReadMemHex(r io.Reader, width int) (*Image, error) {
+	width, err := memHexWidth(width)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{}
+	address := 0
+	for _, token := range strings.Fields(stripComments(string(text))) {
+		if a, ok := strings.CutPrefix(token, "@"); ok {
+			v, err := strconv.ParseUint(strings.ReplaceAll(a, "_", ""), 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address: %q", token)
+			}
+			address = int(v) * width
+			continue
+		}
+
+		v, err := strconv.ParseUint(strings.ReplaceAll(token, "_", ""), 16, 8*width)
+		if err != nil {
+			return nil, fmt.Errorf("invalid word at @%x: %q", address/width, token)
+		}
+
+		word := make([]byte, width)
+		for i := range word {
+			word[i] = byte(v >> (8 * i))
+		}
+
+		if address+width > AddressSpaceSize {
+			return nil, fmt.Errorf("word beyond the 16-bit address space: @%x", address/width)
+		}
+		if err := img.Add(uint16(address), word); err != nil {
+			return nil, err
+		}
+		address += width
+	}
+
+	return img, nil
+}
+
+// stripComments replaces Verilog comments by spaces.
+func stripComments(text string) string {
+	var b strings.Builder
+	for len(text) > 0 {
+		switch {
+		case strings.HasPrefix(text, "//"):
+			end := strings.IndexByte(text, '\n')
+			if end < 0 {
+				end = len(text)
+			}
+			text = text[end:]
+			b.WriteByte(' ')
+		case strings.HasPrefix(text, "/*"):
+			end := strings.Index(text[2:], "*/")
+			if end < 0 {
+				text = ""
+			} else {
+				text = text[2+end+2:]
+			}
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(text[0])
+			text = text[1:]
+		}
+	}
+
+	return b.String()
+}
+
+func memHexWidth(width int) (int, error) {
+	switch width {
+	case 0:
+		return 1, nil
+	case 1, 2, 4:
+		return width, nil
+	default:
+		return 0, fmt.Errorf("unsupported word width: %d bytes", width)
+	}
+}
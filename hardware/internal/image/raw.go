@@ -0,0 +1,41 @@
+package image
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteRaw writes an image as a flat binary from the first to the last
+// initialised address, filling the gaps between chunks with zeroes.
+// The entrypoint is not written.
+func WriteRaw(w io.Writer, img *Image) error {
+	if len(img.Chunks) == 0 {
+		return nil
+	}
+
+	first := img.Chunks[0].Address
+	data := make([]byte, img.Chunks[len(img.Chunks)-1].End()-int(first))
+	for _, c := range img.Chunks {
+		copy(data[c.Address-first:], c.Data)
+	}
+
+	_, err := w.Write(data)
+	return err
+}
+
+// ReadRaw reads a flat binary loaded at the base address, which is also
+// the entrypoint.
+func ReadRaw(r io.Reader, base uint16) (*Image, error) {
+	// Read one more byte than fits to detect images that are too large.
+	data, err := io.ReadAll(io.LimitReader(r, int64(AddressSpaceSize-int(base))+1))
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{Entrypoint: base, HasEntrypoint: true}
+	if err := img.Add(base, data); err != nil {
+		return nil, fmt.Errorf("raw binary too large: %w", err)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,154 @@
+package image
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteSRecord writes an image in Motorola S-records with 16-bit addresses
+// (S19) and 16 data bytes per record. The file starts with a header record
+// and ends with a record count and, if the image has an entrypoint, a
+// termination record that holds it.
+//
+// Other tools write a termination record with address zero when there is
+// no entrypoint, so ReadSRecord reads it as none, and an entrypoint at
+// address zero does not survive a round trip.
+func WriteSRecord(w io.Writer, img *Image) error {
+	bw := bufio.NewWriter(w)
+	writeSRecord(bw, '0', 0, nil)
+
+	count := 0
+	for _, c := range img.Chunks {
+		for offset := 0; offset < len(c.Data); offset += recordSize {
+			data := c.Data[offset:min(offset+recordSize, len(c.Data))]
+			writeSRecord(bw, '1', int(c.Address)+offset, data)
+			count++
+		}
+	}
+
+	// Larger counts do not fit in an S5 record and are optional anyway.
+	if count <= 0xffff {
+		writeSRecord(bw, '5', count, nil)
+	}
+
+	if img.HasEntrypoint {
+		writeSRecord(bw, '9', int(img.Entrypoint), nil)
+	}
+
+	return bw.Flush()
+}
+
+func writeSRecord(w *bufio.Writer, recordType byte, address int, data []byte) {
+	record := []byte{byte(2 + len(data) + 1), byte(address >> 8), byte(address)}
+	record = append(record, data...)
+	record = append(record, srecChecksum(record))
+	_, _ = fmt.Fprintf(w, "S%c%X\n", recordType, record)
+}
+
+// srecChecksum is the ones' complement of the sum of the bytes.
+func srecChecksum(record []byte) byte {
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+
+	return ^sum
+}
+
+// ReadSRecord reads an image in Motorola S-records.
+//
+// Records with 24-bit and 32-bit addresses are accepted as long as the
+// addresses are within the 16-bit address space. The termination record
+// ends the file and sets the entrypoint, unless its address is zero. Files
+// without one have no entrypoint.
+func ReadSRecord(r io.Reader) (*Image, error) {
+	img := &Image{}
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		done, err := img.addSRecord(line, &count)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if done {
+			return img, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// addSRecord adds a record to the image, counting data records, and reports
+// whether it is the termination record.
+func (img *Image) addSRecord(line string, count *int) (bool, error) {
+	if len(line) < 2 || line[0] != 'S' {
+		return false, fmt.Errorf("record does not start with 'S'")
+	}
+
+	recordType := line[1]
+	var addressSize int
+	switch recordType {
+	case '0', '1', '5', '9':
+		addressSize = 2
+	case '2', '6', '8':
+		addressSize = 3
+	case '3', '7':
+		addressSize = 4
+	default:
+		return false, fmt.Errorf("unknown record type: S%c", recordType)
+	}
+
+	record, err := hex.DecodeString(line[2:])
+	if err != nil {
+		return false, fmt.Errorf("invalid record: %w", err)
+	}
+
+	if len(record) < 1+addressSize+1 || len(record) != 1+int(record[0]) {
+		return false, fmt.Errorf("invalid record length: %d bytes", len(record))
+	}
+
+	if srecChecksum(record) != 0 {
+		return false, fmt.Errorf("invalid checksum: 0x%02x", record[len(record)-1])
+	}
+
+	var address int
+	for _, b := range record[1 : 1+addressSize] {
+		address = address<<8 | int(b)
+	}
+	data := record[1+addressSize : len(record)-1]
+
+	switch recordType {
+	case '0':
+		return false, nil
+	case '1', '2', '3':
+		if address >= AddressSpaceSize {
+			return false, fmt.Errorf("address beyond the 16-bit address space: 0x%x", address)
+		}
+		*count++
+		return false, img.Add(uint16(address), data)
+	case '5', '6':
+		if address != *count {
+			return false, fmt.Errorf("record count mismatch: expected %d, got %d", *count, address)
+		}
+		return false, nil
+	default:
+		if address >= AddressSpaceSize {
+			return false, fmt.Errorf("start address beyond the 16-bit address space: 0x%x", address)
+		}
+		img.Entrypoint = uint16(address)
+		img.HasEntrypoint = address != 0
+		return true, nil
+	}
+}
@@ -0,0 +1,178 @@
+package isaspec
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Collision of the encodings of two instructions: some instruction word
+// selects both.
+type Collision struct {
+	First, Second *Instruction
+}
+
+// String returns the mnemonics of the instructions, as in "load and
+// store", which is how Check allows the collision.
+func (c Collision) String() string {
+	return c.First.Mnemonic + " and " + c.Second.Mnemonic
+}
+
+// Collisions returns the pairs of instructions whose encodings overlap, in
+// the order of the instructions. Pseudo-instructions share the encodings
+// of the instructions that they expand into, so they are left out.
+func (isa *ISA) Collisions() ([]Collision, error) {
+	var instructions []*Instruction
+	var patterns []pattern
+	for n := range isa.Instructions {
+		i := &isa.Instructions[n]
+		if i.Pseudo {
+			continue
+		}
+
+		p, err := isa.pattern(i)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, i)
+		patterns = append(patterns, p)
+	}
+
+	var collisions []Collision
+	for n := range patterns {
+		for m := n + 1; m < len(patterns); m++ {
+			if patterns[n].overlaps(patterns[m]) {
+				collisions = append(collisions, Collision{instructions[n], instructions[m]})
+			}
+		}
+	}
+
+	return collisions, nil
+}
+
+// Check returns an error if the encodings of two instructions collide, or
+// if an instruction has an opcode that is not allocated to its format.
+//
+// Collisions are only allowed if they are listed explicitly, as returned
+// by Collision.String.
+func (isa *ISA) Check(allowed ...string) error {
+	collisions, err := isa.Collisions()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range collisions {
+		if !slices.Contains(allowed, c.String()) {
+			errs = append(errs, fmt.Errorf("%s: %s collide", isa.Name, c))
+		}
+	}
+
+	return errors.Join(append(errs, isa.checkOpcodes())...)
+}
+
+// checkOpcodes returns an error if an instruction has an opcode that is
+// not allocated to its format, if the instruction set allocates opcodes.
+func (isa *ISA) checkOpcodes() error {
+	if len(isa.Opcodes) == 0 {
+		return nil
+	}
+
+	formats := map[uint32]string{}
+	for _, o := range isa.Opcodes {
+		if _, ok := formats[o.Value]; ok {
+			return fmt.Errorf("%s: opcode %#x is allocated twice", isa.Name, o.Value)
+		}
+		formats[o.Value] = o.Format
+	}
+
+	var errs []error
+	for _, i := range isa.Instructions {
+		opcode, ok := i.Values["opcode"]
+		if !ok {
+			continue
+		}
+
+		switch format, ok := formats[opcode]; {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%s: %s has opcode %#x, which is reserved", isa.Name, i.Mnemonic, opcode))
+		case format != i.Format:
+			errs = append(errs, fmt.Errorf(
+				"%s: %s has format %s, but opcode %#x is allocated to format %s",
+				isa.Name, i.Mnemonic, i.Format, opcode, format))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// pattern of the bits that select an instruction.
+type pattern struct {
+	mask, value uint64
+
+	// Masks of the fields that cannot be zero.
+	nonZero []uint64
+}
+
+func (isa *ISA) pattern(i *Instruction) (pattern, error) {
+	f, err := isa.Format(i.Format)
+	if err != nil {
+		return pattern{}, err
+	}
+
+	var p pattern
+	for _, field := range f.Fields {
+		v, ok := i.Values[field.Name]
+		if !ok {
+			continue
+		}
+		if uint64(v) >= 1<<field.Width {
+			return pattern{}, fmt.Errorf("%s: %s has %s %d, which does not fit in %d bits",
+				isa.Name, i.Mnemonic, field.Name, v, field.Width)
+		}
+
+		p.mask |= field.mask()
+		p.value |= uint64(v) << field.Offset
+	}
+
+	for name := range i.Values {
+		if _, ok := f.Field(name); !ok {
+			return pattern{}, fmt.Errorf("%s: %s has a value for %s, which format %s does not have",
+				isa.Name, i.Mnemonic, name, f.Name)
+		}
+	}
+
+	for _, name := range i.NonZero {
+		field, ok := f.Field(name)
+		if !ok {
+			return pattern{}, fmt.Errorf("%s: %s cannot have a zero %s, which format %s does not have",
+				isa.Name, i.Mnemonic, name, f.Name)
+		}
+		p.nonZero = append(p.nonZero, field.mask())
+	}
+
+	return p, nil
+}
+
+// overlaps reports whether some instruction word matches both patterns.
+func (p pattern) overlaps(q pattern) bool {
+	if (p.value^q.value)&p.mask&q.mask != 0 {
+		return false
+	}
+
+	// A field that cannot be zero separates the patterns if they fix all
+	// of its bits to zero between them.
+	mask, value := p.mask|q.mask, p.value|q.value
+	for _, nonZero := range append(slices.Clip(p.nonZero), q.nonZero...) {
+		if nonZero&^mask == 0 && value&nonZero == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mask of the bits of the field.
+func (f Field) mask() uint64 {
+	return (1<<f.Width - 1) << f.Offset
+}
@@ -0,0 +1,114 @@
+package isaspec_test
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+// toy returns an instruction set of 8-bit instructions: opcode 0 has
+// functions, and opcode 1 shares the value 0 of Z with another operation.
+func toy(instructions ...isaspec.Instruction) *isaspec.ISA {
+	opcode := isaspec.Field{Name: "opcode", Offset: 6, Width: 2}
+	return &isaspec.ISA{
+		Name: "toy",
+		Formats: []isaspec.Format{
+			{Name: "F", Bits: 8, Fields: []isaspec.Field{opcode, {Name: "func", Offset: 0, Width: 2}}},
+			{Name: "Z", Bits: 8, Fields: []isaspec.Field{opcode, {Name: "Z", Offset: 4, Width: 2}}},
+		},
+		Instructions: instructions,
+	}
+}
+
+var (
+	add  = isaspec.Instruction{Mnemonic: "add", Format: "F", Values: map[string]uint32{"opcode": 0, "func": 0}}
+	sub  = isaspec.Instruction{Mnemonic: "sub", Format: "F", Values: map[string]uint32{"opcode": 0, "func": 1}}
+	beqz = isaspec.Instruction{
+		Mnemonic: "beqz", Format: "Z", Values: map[string]uint32{"opcode": 1, "Z": 0},
+	}
+	load = isaspec.Instruction{
+		Mnemonic: "load", Format: "Z", Values: map[string]uint32{"opcode": 1}, NonZero: []string{"Z"},
+	}
+	store = isaspec.Instruction{Mnemonic: "store", Format: "Z", Values: map[string]uint32{"opcode": 1}}
+)
+
+func TestISA_Collisions(t *testing.T) {
+	nop := add
+	nop.Mnemonic, nop.Pseudo = "nop", true
+
+	isa := toy(add, nop, sub, beqz, load, store)
+	collisions, err := isa.Collisions()
+	require.Success(t, err)
+	require.Equal(t, 2, len(collisions))
+	expect.Equal(t, "beqz and store", collisions[0].String())
+	expect.Equal(t, "load and store", collisions[1].String())
+}
+
+func TestISA_Check(t *testing.T) {
+	expect.Success(t, toy(add, sub, beqz, load).Check())
+
+	isa := toy(add, sub, beqz, load, store)
+	expected := "toy: beqz and store collide\ntoy: load and store collide"
+	expect.Equal(t, expected, isa.Check().Error())
+
+	// Collisions are only allowed explicitly.
+	expect.Equal(t, "toy: load and store collide", isa.Check("beqz and store").Error())
+	expect.Success(t, isa.Check("beqz and store", "load and store"))
+
+	zero := load
+	zero.Mnemonic, zero.NonZero = "zero", nil
+	zero.Values = map[string]uint32{"opcode": 1, "Z": 2}
+	err := toy(load, zero).Check()
+	expect.Equal(t, "toy: load and zero collide", err.Error())
+}
+
+func TestISA_Check_opcodes(t *testing.T) {
+	isa := toy(add, beqz)
+	isa.Opcodes = []isaspec.Opcode{{Value: 0, Format: "F"}, {Value: 1, Format: "Z"}}
+	expect.Success(t, isa.Check())
+
+	isa.Opcodes = []isaspec.Opcode{{Value: 0, Format: "Z"}}
+	expected := "toy: add has format F, but opcode 0x0 is allocated to format Z\n" +
+		"toy: beqz has opcode 0x1, which is reserved"
+	expect.Equal(t, expected, isa.Check().Error())
+
+	isa.Opcodes = []isaspec.Opcode{{Value: 0, Format: "F"}, {Value: 0, Format: "Z"}}
+	expect.Equal(t, "toy: opcode 0x0 is allocated twice", isa.Check().Error())
+}
+
+func TestISA_Check_errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		instruction isaspec.Instruction
+		want        string
+	}{
+		{
+			name:        "unknown format",
+			instruction: isaspec.Instruction{Mnemonic: "foo", Format: "X"},
+			want:        "toy: unknown format X",
+		},
+		{
+			name:        "unknown field",
+			instruction: isaspec.Instruction{Mnemonic: "foo", Format: "F", Values: map[string]uint32{"Z": 0}},
+			want:        "toy: foo has a value for Z, which format F does not have",
+		},
+		{
+			name:        "unknown non-zero field",
+			instruction: isaspec.Instruction{Mnemonic: "foo", Format: "F", NonZero: []string{"Z"}},
+			want:        "toy: foo cannot have a zero Z, which format F does not have",
+		},
+		{
+			name:        "too wide",
+			instruction: isaspec.Instruction{Mnemonic: "foo", Format: "F", Values: map[string]uint32{"func": 4}},
+			want:        "toy: foo has func 4, which does not fit in 2 bits",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expect.Equal(t, tc.want, toy(tc.instruction).Check().Error())
+		})
+	}
+}
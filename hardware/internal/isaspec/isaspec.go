@@ -0,0 +1,107 @@
+// Package isaspec describes instruction sets as data, so that the tables
+// of their code and of their documentation are generated from a single
+// source.
+//
+// Every architecture has a spec package next to its isa package. The spec
+// package describes the instruction set with these types and renders the
+// Go tables and the markdown tables from them. The gen.go program of the
+// isa package writes them, and its tests check that the code and the
+// documentation match the spec.
+package isaspec
+
+import "fmt"
+
+// ISA is the specification of an instruction set.
+type ISA struct {
+	Name    string
+	Formats []Format
+
+	// Opcodes allocated to formats, if the formats share the opcode
+	// field. Opcodes that are not listed are reserved.
+	Opcodes []Opcode
+
+	Instructions []Instruction
+}
+
+// Format of the encoding of instructions.
+type Format struct {
+	Name string
+	Bits int
+
+	// Fields that select the instruction, such as the opcode and the
+	// function. Register and immediate fields are not needed.
+	Fields []Field
+}
+
+// Field of an encoding.
+type Field struct {
+	Name   string
+	Offset int
+	Width  int
+}
+
+// Opcode allocated to a format.
+type Opcode struct {
+	Value  uint32
+	Format string
+	Usage  string
+}
+
+// Instruction of an instruction set, or a pseudo-instruction.
+type Instruction struct {
+	// Name of the constant of the operation in code. Pseudo-instructions
+	// have none.
+	Name string
+
+	Mnemonic string
+
+	// Operands in assembly syntax, as documented.
+	Operands string
+
+	// Semantics in plain words.
+	Semantics string
+
+	// Format of the encoding, and values of its fields that select the
+	// instruction.
+	Format string
+	Values map[string]uint32
+
+	// Fields that cannot be zero, because the zero value selects another
+	// instruction.
+	NonZero []string
+
+	// Pseudo-instructions expand into the instruction whose encoding
+	// they share.
+	Pseudo bool
+}
+
+// Syntax returns the mnemonic followed by the operands, if any.
+func (i *Instruction) Syntax() string {
+	if i.Operands == "" {
+		return i.Mnemonic
+	}
+
+	return i.Mnemonic + " " + i.Operands
+}
+
+// Format returns the format with the given name.
+func (isa *ISA) Format(name string) (*Format, error) {
+	for n := range isa.Formats {
+		if isa.Formats[n].Name == name {
+			return &isa.Formats[n], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: unknown format %s", isa.Name, name)
+}
+
+// Field returns the field with the given name.
+func (f *Format) Field(name string) (Field, bool) {
+	for _, field := range f.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+
+	return Field{}, false
+}
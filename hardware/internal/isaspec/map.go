@@ -0,0 +1,221 @@
+package isaspec
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// WriteMap writes the utilisation of the encoding space of the instruction
+// set. Starting from the first field of the formats, it lists the values
+// of each field that select instructions, with their formats, and the
+// values that are free. The values that select a single instruction show
+// its mnemonic. Collisions are listed at the end.
+//
+// The formats must select instructions hierarchically: wherever
+// instructions share the values of the previous fields, their next fields
+// must have the same bits.
+func (isa *ISA) WriteMap(w io.Writer) error {
+	var entries []entry
+	for n := range isa.Instructions {
+		i := &isa.Instructions[n]
+		if i.Pseudo {
+			continue
+		}
+
+		f, err := isa.Format(i.Format)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{i, path(i, f)})
+	}
+
+	collisions, err := isa.Collisions()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(isa.Name + "\n")
+	if err := writeNode(&b, entries, 0, ""); err != nil {
+		return fmt.Errorf("%s: %w", isa.Name, err)
+	}
+
+	if len(collisions) > 0 {
+		b.WriteString("collisions:\n")
+		for _, c := range collisions {
+			_, _ = fmt.Fprintf(&b, "  %s\n", c)
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// entry of an instruction in the map, with the constraints of the fields
+// of its format, in order. Fields without constraints at the end are left
+// out.
+type entry struct {
+	instruction *Instruction
+	path        []step
+}
+
+// step of the path of an instruction through the fields of its format.
+type step struct {
+	field Field
+	kind  stepKind
+	value uint32
+}
+
+type stepKind uint8
+
+const (
+	fixedValue stepKind = iota
+	nonZeroValue
+	anyValue
+)
+
+func path(i *Instruction, f *Format) []step {
+	var steps []step
+	for _, field := range f.Fields {
+		s := step{field: field, kind: anyValue}
+		if v, ok := i.Values[field.Name]; ok {
+			s.kind, s.value = fixedValue, v
+		} else if slices.Contains(i.NonZero, field.Name) {
+			s.kind = nonZeroValue
+		}
+		steps = append(steps, s)
+	}
+
+	for len(steps) > 0 && steps[len(steps)-1].kind == anyValue {
+		steps = steps[:len(steps)-1]
+	}
+
+	return steps
+}
+
+// line of the map for a range of values of a field.
+type line struct {
+	first, last uint64
+	entries     []entry
+}
+
+// writeNode writes the values of the field at the given depth of the
+// paths of the entries, which share the values of the previous fields.
+func writeNode(b *strings.Builder, entries []entry, depth int, indent string) error {
+	field := entries[0].path[depth].field
+	var names []string
+	for _, e := range entries {
+		f := e.path[depth].field
+		if f.Offset != field.Offset || f.Width != field.Width {
+			return fmt.Errorf("%s and %s are selected by different fields after the same values",
+				entries[0].instruction.Mnemonic, e.instruction.Mnemonic)
+		}
+		if !slices.Contains(names, f.Name) {
+			names = append(names, f.Name)
+		}
+	}
+
+	size := uint64(1) << field.Width
+	fixed := map[uint64][]entry{}
+	var nonZero, all []entry
+	for _, e := range entries {
+		switch s := e.path[depth]; s.kind {
+		case fixedValue:
+			fixed[uint64(s.value)] = append(fixed[uint64(s.value)], e)
+		case nonZeroValue:
+			nonZero = append(nonZero, e)
+		case anyValue:
+			all = append(all, e)
+		}
+	}
+
+	var lines []line
+	for v, entries := range fixed {
+		lines = append(lines, line{v, v, entries})
+	}
+	if len(nonZero) > 0 {
+		lines = append(lines, line{1, size - 1, nonZero})
+	}
+	if len(all) > 0 {
+		lines = append(lines, line{0, size - 1, all})
+	}
+	slices.SortFunc(lines, func(x, y line) int {
+		return cmp.Or(cmp.Compare(x.first, y.first), cmp.Compare(x.last, y.last))
+	})
+
+	// The values that no line covers are free.
+	var free []line
+	next := uint64(0)
+	for _, l := range lines {
+		if l.first > next {
+			free = append(free, line{first: next, last: l.first - 1})
+		}
+		next = max(next, l.last+1)
+	}
+	if next < size {
+		free = append(free, line{first: next, last: size - 1})
+	}
+
+	used := size
+	for _, l := range free {
+		used -= l.last - l.first + 1
+	}
+
+	_, _ = fmt.Fprintf(b, "%s%s[%d..%d]: %d used, %d free\n",
+		indent, strings.Join(names, "/"), field.Offset, field.Offset+field.Width-1, used, size-used)
+
+	lines = append(lines, free...)
+	slices.SortStableFunc(lines, func(x, y line) int { return cmp.Compare(x.first, y.first) })
+	for _, l := range lines {
+		if err := writeLine(b, l, field, depth, indent+"  "); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeLine writes a range of values of the field, with the instructions
+// that they select, and the next field of the instructions that have one.
+func writeLine(b *strings.Builder, l line, field Field, depth int, indent string) error {
+	digits := (field.Width + 3) / 4
+	label := fmt.Sprintf("0x%0*x", digits, l.first)
+	if l.last != l.first {
+		label += fmt.Sprintf("..0x%0*x", digits, l.last)
+	}
+
+	if len(l.entries) == 0 {
+		_, _ = fmt.Fprintf(b, "%s%s free\n", indent, label)
+		return nil
+	}
+
+	var formats, mnemonics []string
+	var next []entry
+	for _, e := range l.entries {
+		if !slices.Contains(formats, e.instruction.Format) {
+			formats = append(formats, e.instruction.Format)
+		}
+
+		if len(e.path) > depth+1 {
+			next = append(next, e)
+			continue
+		}
+
+		mnemonics = append(mnemonics, e.instruction.Mnemonic)
+	}
+
+	_, _ = fmt.Fprintf(b, "%s%s %s", indent, label, strings.Join(formats, ", "))
+	if len(mnemonics) > 0 {
+		_, _ = fmt.Fprintf(b, ": %s", strings.Join(mnemonics, ", "))
+	}
+	b.WriteString("\n")
+
+	if len(next) == 0 {
+		return nil
+	}
+
+	return writeNode(b, next, depth+1, indent+"  ")
+}
@@ -0,0 +1,39 @@
+package isaspec_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestISA_WriteMap(t *testing.T) {
+	var b strings.Builder
+	require.Success(t, toy(add, sub, beqz, load, store).WriteMap(&b))
+
+	expected := "toy\n" +
+		"opcode[6..7]: 2 used, 2 free\n" +
+		"  0x0 F\n" +
+		"    func[0..1]: 2 used, 2 free\n" +
+		"      0x0 F: add\n" +
+		"      0x1 F: sub\n" +
+		"      0x2..0x3 free\n" +
+		"  0x1 Z: store\n" +
+		"    Z[4..5]: 4 used, 0 free\n" +
+		"      0x0 Z: beqz\n" +
+		"      0x1..0x3 Z: load\n" +
+		"  0x2..0x3 free\n" +
+		"collisions:\n" +
+		"  beqz and store\n" +
+		"  load and store\n"
+	expect.Equal(t, expected, b.String())
+}
+
+func TestISA_WriteMap_fields(t *testing.T) {
+	isa := toy(add, beqz)
+	isa.Instructions[1].Values = map[string]uint32{"opcode": 0, "Z": 0}
+
+	err := isa.WriteMap(&strings.Builder{})
+	expect.Equal(t, "toy: add and beqz are selected by different fields after the same values", err.Error())
+}
@@ -0,0 +1,131 @@
+package isaspec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table in markdown.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// String returns the table with its columns aligned.
+func (t *Table) String() string {
+	widths := make([]int, len(t.Header))
+	for _, row := range append([][]string{t.Header}, t.Rows...) {
+		for n, cell := range row {
+			widths[n] = max(widths[n], utf8.RuneCountInString(cell))
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		for n, cell := range row {
+			padding := widths[n] - utf8.RuneCountInString(cell)
+			_, _ = fmt.Fprintf(&b, "| %s%s ", cell, strings.Repeat(" ", padding))
+		}
+		b.WriteString("|\n")
+	}
+
+	writeRow(t.Header)
+	for _, width := range widths {
+		_, _ = fmt.Fprintf(&b, "|%s", strings.Repeat("-", width+2))
+	}
+	b.WriteString("|\n")
+	for _, row := range t.Rows {
+		writeRow(row)
+	}
+
+	return b.String()
+}
+
+// Sections of a markdown document are delimited by these comments, which
+// markdown renderers hide.
+const (
+	beginSection = "<!-- begin spec %s -->\n"
+	endSection   = "<!-- end spec %s -->\n"
+)
+
+// UpdateSections replaces the content of the sections of a markdown
+// document, by name. Every section must be in the document exactly once.
+func UpdateSections(doc string, sections map[string]string) (string, error) {
+	for name, content := range sections {
+		begin, end, err := findSection(doc, name)
+		if err != nil {
+			return "", err
+		}
+
+		doc = doc[:begin] + content + doc[end:]
+	}
+
+	return doc, nil
+}
+
+// CheckSections checks that the sections of a markdown document have the
+// given content, by name.
+func CheckSections(doc string, sections map[string]string) error {
+	for name, content := range sections {
+		begin, end, err := findSection(doc, name)
+		if err != nil {
+			return err
+		}
+
+		if doc[begin:end] != content {
+			return fmt.Errorf("section %s does not match the spec:\n%s\nwant:\n%s",
+				name, doc[begin:end], content)
+		}
+	}
+
+	return nil
+}
+
+// UpdateFile replaces the content of the sections of a markdown file.
+func UpdateFile(path string, sections map[string]string) error {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	updated, err := UpdateSections(string(doc), sections)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
+
+// CheckFile checks the content of the sections of a markdown file.
+func CheckFile(path string, sections map[string]string) error {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckSections(string(doc), sections); err != nil {
+		return fmt.Errorf("%s: %w (run go generate)", path, err)
+	}
+
+	return nil
+}
+
+// findSection returns the offsets of the content of a section.
+func findSection(doc string, name string) (int, int, error) {
+	begin := fmt.Sprintf(beginSection, name)
+	end := fmt.Sprintf(endSection, name)
+	if strings.Count(doc, begin) != 1 || strings.Count(doc, end) != 1 {
+		return 0, 0, fmt.Errorf("section %s must be delimited once by %q and %q",
+			name, strings.TrimSpace(begin), strings.TrimSpace(end))
+	}
+
+	b := strings.Index(doc, begin) + len(begin)
+	e := strings.Index(doc, end)
+	if e < b {
+		return 0, 0, fmt.Errorf("section %s ends before it begins", name)
+	}
+
+	return b, e, nil
+}
@@ -0,0 +1,99 @@
+package isaspec_test
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestTable_String(t *testing.T) {
+	table := isaspec.Table{
+		Header: []string{"Instruction", "Semantics"},
+		Rows: [][]string{
+			{"`bne %Y, %X`", "Branch if %Y ≠ %X"},
+			{"`ret`", "Return"},
+		},
+	}
+
+	expected := "" +
+		"| Instruction  | Semantics         |\n" +
+		"|--------------|-------------------|\n" +
+		"| `bne %Y, %X` | Branch if %Y ≠ %X |\n" +
+		"| `ret`        | Return            |\n"
+	expect.Equal(t, expected, table.String())
+}
+
+const doc = `# Title
+
+<!-- begin spec a -->
+old a
+<!-- end spec a -->
+
+Text.
+
+<!-- begin spec b -->
+<!-- end spec b -->
+`
+
+func TestUpdateSections(t *testing.T) {
+	updated, err := isaspec.UpdateSections(doc, map[string]string{"a": "new a\n", "b": "new b\n"})
+	require.Success(t, err)
+
+	expected := `# Title
+
+<!-- begin spec a -->
+new a
+<!-- end spec a -->
+
+Text.
+
+<!-- begin spec b -->
+new b
+<!-- end spec b -->
+`
+	expect.Equal(t, expected, updated)
+	expect.Success(t, isaspec.CheckSections(updated, map[string]string{"a": "new a\n", "b": "new b\n"}))
+}
+
+func TestCheckSections(t *testing.T) {
+	expect.Success(t, isaspec.CheckSections(doc, map[string]string{"a": "old a\n", "b": ""}))
+
+	err := isaspec.CheckSections(doc, map[string]string{"a": "new a\n"})
+	expect.Equal(t, "section a does not match the spec:\nold a\n\nwant:\nnew a\n", err.Error())
+}
+
+func TestSections_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{
+			name: "missing",
+			doc:  doc,
+			want: `section c must be delimited once by "<!-- begin spec c -->" and "<!-- end spec c -->"`,
+		},
+		{
+			name: "duplicated",
+			doc:  doc + "<!-- begin spec c -->\n<!-- end spec c -->\n<!-- begin spec c -->\n<!-- end spec c -->\n",
+			want: `section c must be delimited once by "<!-- begin spec c -->" and "<!-- end spec c -->"`,
+		},
+		{
+			name: "reversed",
+			doc:  doc + "<!-- end spec c -->\n<!-- begin spec c -->\n",
+			want: "section c ends before it begins",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := isaspec.UpdateSections(tc.doc, map[string]string{"c": ""})
+			expect.Equal(t, tc.want, err.Error())
+
+			err = isaspec.CheckSections(tc.doc, map[string]string{"c": ""})
+			expect.Equal(t, tc.want, err.Error())
+		})
+	}
+}
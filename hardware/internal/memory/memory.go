@@ -0,0 +1,182 @@
+// Package memory implements the flat 64 KiB memory shared by the 16-bit
+// machines.
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/image"
+)
+
+type Address uint16
+
+type Memory struct {
+	// Some memory ranges will not be used in practice due to MMIO,
+	// but it is easier to allocate the whole flat range.
+	data [Size]byte
+}
+
+func (m *Memory) ReadB(address Address) (byte, error) {
+	return m.data[address], nil
+}
+
+func (m *Memory) WriteB(address Address, value byte) error {
+	m.data[address] = value
+	return nil
+}
+
+func (m *Memory) ReadH(address Address) (int16, error) {
+	v := int16(m.data[address])
+	v |= int16(m.data[address+1]) << 8
+	return v, nil
+}
+
+func (m *Memory) WriteH(address Address, value int16) error {
+	m.data[address] = byte(value)
+	m.data[address+1] = byte(value >> 8)
+	return nil
+}
+
+func (m *Memory) ReadW(address Address) (int32, error) {
+	v := int32(m.data[address])
+	v |= int32(m.data[address+1]) << 8
+	v |= int32(m.data[address+2]) << 16
+	v |= int32(m.data[address+3]) << 24
+	return v, nil
+}
+
+func (m *Memory) WriteW(address Address, value int32) error {
+	m.data[address] = byte(value)
+	m.data[address+1] = byte(value >> 8)
+	m.data[address+2] = byte(value >> 16)
+	m.data[address+3] = byte(value >> 24)
+	return nil
+}
+
+func (m *Memory) WriteRaw(address Address, data []byte) {
+	copy(m.data[address:], data)
+}
+
+func (m *Memory) ReadRaw(address Address, data []byte) {
+	copy(data, m.data[address:])
+}
+
+// Image returns a copy of a range of memory, which must not go beyond the
+// end of memory.
+func (m *Memory) Image(address Address, size int) image.Chunk {
+	data := make([]byte, size)
+	m.ReadRaw(address, data)
+	return image.Chunk{Address: uint16(address), Data: data}
+}
+
+// LoadImage writes the chunks of an image into memory.
+func (m *Memory) LoadImage(img *image.Image) {
+	for _, c := range img.Chunks {
+		m.WriteRaw(Address(c.Address), c.Data)
+	}
+}
+
+func (m *Memory) Dump(w io.Writer) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	var zeroLine [bytesPerLine]byte
+	var numEmpty int
+	for i := 0; i < len(m.data)/bytesPerLine; i++ {
+		baseAddress := i * bytesPerLine
+		line := m.data[baseAddress : baseAddress+bytesPerLine]
+
+		if bytes.Compare(line, zeroLine[:]) == 0 {
+			numEmpty++
+			continue
+		} else if numEmpty != 0 {
+			if numEmpty == 1 {
+				_, _ = fmt.Fprint(w, "(1 empty line)\n")
+			} else {
+				_, _ = fmt.Fprintf(w, "(%d empty lines)\n", numEmpty)
+			}
+			numEmpty = 0
+		}
+
+		_, _ = fmt.Fprintf(w, "%04x  %s\n", baseAddress, formatLine(line))
+	}
+
+	if numEmpty > 0 {
+		_, _ = fmt.Fprintf(w, "(%d empty lines)\n", numEmpty)
+		numEmpty = 0
+	}
+}
+
+// LineDiff is a line of memory that differs between two states.
+type LineDiff struct {
+	Address Address
+	Old     [bytesPerLine]byte
+	New     [bytesPerLine]byte
+}
+
+// Diff returns the lines of memory that differ from a previous state, in
+// increasing address order.
+func (m *Memory) Diff(previous *Memory) []LineDiff {
+	var diffs []LineDiff
+	for baseAddress := 0; baseAddress < len(m.data); baseAddress += bytesPerLine {
+		end := baseAddress + bytesPerLine
+		if bytes.Equal(previous.data[baseAddress:end], m.data[baseAddress:end]) {
+			continue
+		}
+
+		diffs = append(diffs, LineDiff{
+			Address: Address(baseAddress),
+			Old:     [bytesPerLine]byte(previous.data[baseAddress:end]),
+			New:     [bytesPerLine]byte(m.data[baseAddress:end]),
+		})
+	}
+
+	return diffs
+}
+
+// DumpDiff shows the lines of memory that differ from a previous state,
+// with the old contents on the left and the new ones on the right.
+func (m *Memory) DumpDiff(w io.Writer, previous *Memory) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	diffs := m.Diff(previous)
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(w, "%04x  %s  ->  %s\n", d.Address, formatLine(d.Old[:]), formatLine(d.New[:]))
+	}
+
+	if len(diffs) == 0 {
+		_, _ = fmt.Fprint(w, "(none)\n")
+	}
+}
+
+// formatLine formats a line of memory in hexadecimal, split in halves,
+// followed by a gutter with the printable ASCII characters.
+func formatLine(line []byte) string {
+	var b strings.Builder
+	for i, v := range line {
+		if i == halfLine {
+			b.WriteByte(' ')
+		}
+		_, _ = fmt.Fprintf(&b, "%02x ", v)
+	}
+
+	b.WriteString(" |")
+	for _, v := range line {
+		if v >= 32 && v <= 126 {
+			b.WriteByte(v)
+		} else {
+			b.WriteByte('.')
+		}
+	}
+	b.WriteByte('|')
+
+	return b.String()
+}
+
+const (
+	bytesPerLine = 16
+	halfLine     = bytesPerLine / 2
+)
+
+// Size of the memory in bytes.
+const Size = 64 * 1024
@@ -0,0 +1,161 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/image"
+	"github.com/jespert/primordial/hardware/internal/memory"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMemory_B(t *testing.T) {
+	var m memory.Memory
+	for i := range memory.Size {
+		address := memory.Address(i)
+
+		// Memory is initially zero initialised.
+		original, err := m.ReadB(address)
+		require.Success(t, err)
+		require.Equal(t, 0, original)
+
+		// We assign a new value to the memory.
+		written := byte(i)
+		require.Success(t, m.WriteB(address, written))
+
+		// And we read it back. It should be the one we wrote.
+		actual, err := m.ReadB(address)
+		require.Success(t, err)
+		require.Equal(t, written, actual)
+	}
+
+	// Verify the final state of the memory.
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMemory_H(t *testing.T) {
+	var m memory.Memory
+	for i := 0; i < memory.Size; i += 2 {
+		address := memory.Address(i)
+
+		// Memory is initially zero initialised.
+		original, err := m.ReadH(address)
+		require.Success(t, err)
+		require.Equal(t, 0, original)
+
+		// We assign a new value to the memory.
+		written := int16(i)
+		require.Success(t, m.WriteH(address, written))
+
+		// And we read it back. It should be the one we wrote.
+		actual, err := m.ReadH(address)
+		require.Success(t, err)
+		require.Equal(t, written, actual)
+	}
+
+	// Verify the final state of the memory.
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMemory_W(t *testing.T) {
+	var m memory.Memory
+	for i := 0; i < memory.Size; i += 4 {
+		address := memory.Address(i)
+
+		// Memory is initially zero initialised.
+		original, err := m.ReadW(address)
+		require.Success(t, err)
+		require.Equal(t, 0, original)
+
+		// We assign a new value to the memory.
+		written := int32(i)
+		require.Success(t, m.WriteW(address, written))
+
+		// And we read it back. It should be the one we wrote.
+		actual, err := m.ReadW(address)
+		require.Success(t, err)
+		require.Equal(t, written, actual)
+	}
+
+	// Verify the final state of the memory.
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMemory_Dump_initial(t *testing.T) {
+	// Initially, the memory is empty.
+	var m memory.Memory
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMemory_Dump_empty_lines(t *testing.T) {
+	const lineSize = 16
+
+	var m memory.Memory
+	require.Success(t, m.WriteB(0, 1))
+	require.Success(t, m.WriteB(2*lineSize, 2))
+	require.Success(t, m.WriteB(5*lineSize, 3))
+	require.Success(t, m.WriteB(9*lineSize, 3))
+
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMemory_ReadRaw(t *testing.T) {
+	var m memory.Memory
+	m.WriteRaw(0xfffe, []byte{1, 2, 3})
+
+	// Raw accesses do not wrap around the end of the memory.
+	data := make([]byte, 4)
+	m.ReadRaw(0xfffd, data)
+	require.Equal(t, "\x00\x01\x02\x00", string(data))
+}
+
+func TestMemory_DumpDiff(t *testing.T) {
+	var previous memory.Memory
+	previous.WriteRaw(0x8000, []byte("Hello, world!"))
+
+	m := previous
+	m.WriteRaw(0x8007, []byte("there"))
+	require.Success(t, m.WriteH(0xfffe, -2))
+
+	verifier := approval.NewTextVerifier(t)
+	m.DumpDiff(verifier.Writer(), &previous)
+	_, _ = verifier.Writer().Write([]byte("\n"))
+	m.DumpDiff(verifier.Writer(), &m)
+	verifier.Verify()
+}
+
+func TestMemory_Diff(t *testing.T) {
+	var previous, m memory.Memory
+	require.Success(t, m.WriteB(0x1234, 0xaa))
+
+	diffs := m.Diff(&previous)
+	require.Equal(t, 1, len(diffs))
+	expect.Equal(t, memory.Address(0x1230), diffs[0].Address)
+	expect.Equal(t, byte(0), diffs[0].Old[4])
+	expect.Equal(t, byte(0xaa), diffs[0].New[4])
+}
+
+func TestMemory_Image(t *testing.T) {
+	var img image.Image
+	require.Success(t, img.Add(0x0100, []byte{1, 2}))
+	require.Success(t, img.Add(0xfffe, []byte{3, 4}))
+
+	var m memory.Memory
+	m.LoadImage(&img)
+
+	chunk := m.Image(0x00ff, 4)
+	expect.Equal(t, uint16(0x00ff), chunk.Address)
+	expect.Equal(t, "\x00\x01\x02\x00", string(chunk.Data))
+	expect.Equal(t, "\x03\x04", string(m.Image(0xfffe, 2).Data))
+}
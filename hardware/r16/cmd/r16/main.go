@@ -1,3 +1,446 @@
+// Command r16 is the toolchain of the r16 architecture.
+//
+// Usage:
+//
+//	r16 asm [-o output] source...                  Assemble sources into an executable.
+//	r16 disasm executable                          List an executable.
+//	r16 run [-steps n] [-format f] program         Run a program until it halts.
+//	r16 convert [-to f] [-o output] program        Convert a program to a memory image.
+//	r16 vectors [-steps n] [-o output] program     Write HDL test vectors of a run.
+//	r16 cosim [-steps n] [-model cmd] program      Check a model against the emulator.
+//
+// Programs are executables or memory images in the formats of package
+// image: ihex (Intel HEX), srec (S-records), memh ($readmemh) and bin (raw,
+// loaded at the program base address). The format of images is guessed from
+// their extension unless given with -format, and -width sets the bytes per
+// word of memh images.
+//
+// Programs halt by jumping to themselves, and then the registers are shown.
+// If they trap instead, the backtrace is shown.
+//
+// Test vectors describe the effects of each instruction in the format of
+// machine.VectorTracer. Together with the memory image of the program in
+// memh format, they let HDL testbenches check an implementation against
+// the emulator.
+//
+// Co-simulation runs a model in lockstep with the emulator and reports the
+// first divergence. The model is either the emulator with its decode cache
+// or a command that speaks the protocol of cosim.PipeModel on its standard
+// input and output.
 package main
 
-func main() {}
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/internal/image"
+	"github.com/jespert/primordial/hardware/r16/internal/asm"
+	"github.com/jespert/primordial/hardware/r16/internal/cosim"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type command func(args []string, stdout, stderr io.Writer) error
+
+var commands = map[string]command{
+	"asm":     assemble,
+	"disasm":  disassemble,
+	"run":     runProgram,
+	"convert": convert,
+	"vectors": vectors,
+	"cosim":   cosimulate,
+}
+
+var (
+	// errUsage is returned for invalid command lines, once reported.
+	errUsage = errors.New("invalid usage")
+
+	// errReported is returned for failures that were already reported.
+	errReported = errors.New("failed")
+)
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || commands[args[0]] == nil {
+		_, _ = fmt.Fprintln(stderr, "usage: r16 asm [-o output] source...")
+		_, _ = fmt.Fprintln(stderr, "       r16 disasm executable")
+		_, _ = fmt.Fprintln(stderr, "       r16 run [-steps n] [-format f] program")
+		_, _ = fmt.Fprintln(stderr, "       r16 convert [-to f] [-o output] program")
+		_, _ = fmt.Fprintln(stderr, "       r16 vectors [-steps n] [-o output] program")
+		_, _ = fmt.Fprintln(stderr, "       r16 cosim [-steps n] [-model cmd] program")
+		return 2
+	}
+
+	if err := commands[args[0]](args[1:], stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		if errors.Is(err, errReported) {
+			return 1
+		}
+
+		_, _ = fmt.Fprintf(stderr, "r16 %s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func assemble(args []string, _, stderr io.Writer) error {
+	flags := flag.NewFlagSet("asm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file (default: first source with .exe extension)")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var sources []sharedasm.Source
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, sharedasm.Source{Name: path, Data: data})
+	}
+
+	f, err := asm.Assemble(sources...)
+	if err != nil {
+		return err
+	}
+
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		first := flags.Arg(0)
+		*output = strings.TrimSuffix(first, filepath.Ext(first)) + ".exe"
+	}
+
+	return os.WriteFile(*output, data, 0o644)
+}
+
+func disassemble(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		_, _ = fmt.Fprintln(stderr, "usage: r16 disasm executable")
+		return errUsage
+	}
+
+	f, err := readExecutable(args[0])
+	if err != nil {
+		return err
+	}
+
+	return asm.Disassemble(stdout, f)
+}
+
+func runProgram(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	steps := flags.Int("steps", 1_000_000, "maximum number of instructions to execute")
+	var program programFlags
+	program.register(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	m, err := program.load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	retired, err := runUntilHalt(m, m, *steps, "run", stderr)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "halted at %04x after %d instructions\n", m.IP(), retired)
+	registers := m.Registers()
+	registers.DumpNamed(stdout)
+	return nil
+}
+
+func vectors(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("vectors", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	steps := flags.Int("steps", 1_000_000, "maximum number of instructions to execute")
+	output := flags.String("o", "", "output file (default: standard output)")
+	var program programFlags
+	program.register(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	m, err := program.load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	m.SetTracer(machine.NewVectorTracer(&buffer))
+	if _, err := runUntilHalt(m, m, *steps, "vectors", stderr); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err := stdout.Write(buffer.Bytes())
+		return err
+	}
+
+	return os.WriteFile(*output, buffer.Bytes(), 0o644)
+}
+
+func cosimulate(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("cosim", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	steps := flags.Int("steps", 1_000_000, "maximum number of instructions to execute")
+	model := flags.String("model", "", "command of an external model (default: the emulator with the decode cache)")
+	var program programFlags
+	program.register(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	reference, err := program.load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	reference.SetDecodeCache(false)
+
+	f, img, err := program.read(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if f != nil {
+		if img, err = image.FromExecutable(f, uint16(machine.ProgramBase)); err != nil {
+			return err
+		}
+	}
+
+	var candidate cosim.Model = cosim.NewEmulator(machine.New())
+	if *model != "" {
+		command := strings.Fields(*model)
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stderr = stderr
+		requests, err := cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+		responses, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		defer func() {
+			_ = requests.Close()
+			_ = cmd.Wait()
+		}()
+
+		candidate = cosim.NewPipeModel(responses, requests)
+	}
+
+	if err := cosim.Load(candidate, img); err != nil {
+		return err
+	}
+
+	lockstep := cosim.NewLockstep(reference, candidate)
+	retired, err := runUntilHalt(lockstep, reference, *steps, "cosim", stderr)
+	if err == nil {
+		err = lockstep.CheckMemory()
+	}
+
+	var divergence *cosim.Divergence
+	if errors.As(err, &divergence) {
+		divergence.Report(stderr)
+		return errReported
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "no divergence after %d instructions\n", retired)
+	return nil
+}
+
+// stepper executes instructions.
+type stepper interface {
+	Step() error
+}
+
+// runUntilHalt steps until the machine jumps to itself and returns the
+// number of instructions retired. The stepper is usually the machine, but
+// it can also drive it. Traps are reported with a backtrace.
+func runUntilHalt(s stepper, m *machine.Machine, steps int, name string, stderr io.Writer) (int, error) {
+	for i := range steps {
+		ip := m.IP()
+		if err := s.Step(); err != nil {
+			var trap *machine.Trap
+			if !errors.As(err, &trap) {
+				return 0, err
+			}
+
+			_, _ = fmt.Fprintf(stderr, "r16 %s: %v\nbacktrace:\n%v", name, trap, trap.Backtrace)
+			return 0, errReported
+		}
+
+		if m.IP() == ip {
+			return i + 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("did not halt after %d instructions", steps)
+}
+
+func convert(args []string, _, stderr io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file (default: program with the extension of the output format)")
+	outputFormat := flags.String("to", "", "output format (default: from the output extension, or ihex)")
+	var program programFlags
+	program.register(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	format := image.IntelHex
+	if *outputFormat != "" {
+		var err error
+		if format, err = image.ParseFormat(*outputFormat); err != nil {
+			return err
+		}
+	} else if f, ok := image.FormatOf(*output); ok {
+		format = f
+	}
+
+	f, img, err := program.read(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if f != nil {
+		if img, err = image.FromExecutable(f, uint16(machine.ProgramBase)); err != nil {
+			return err
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := image.Write(&buffer, img, format, program.options()); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		input := flags.Arg(0)
+		*output = strings.TrimSuffix(input, filepath.Ext(input)) + extensions[format]
+	}
+
+	return os.WriteFile(*output, buffer.Bytes(), 0o644)
+}
+
+// extensions of the output files of each image format.
+var extensions = map[image.Format]string{
+	image.IntelHex: ".hex",
+	image.SRecord:  ".srec",
+	image.ReadMemH: ".mem",
+	image.Raw:      ".bin",
+}
+
+// programFlags select how programs are read.
+type programFlags struct {
+	format string
+	width  int
+}
+
+func (p *programFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&p.format, "format", "", "format of memory images: ihex, srec, memh or bin (default: from the extension)")
+	flags.IntVar(&p.width, "width", 1, "bytes per word of memh images")
+}
+
+func (p *programFlags) options() image.Options {
+	return image.Options{Base: uint16(machine.ProgramBase), Width: p.width}
+}
+
+// load creates a machine with a program loaded.
+func (p *programFlags) load(path string) (*machine.Machine, error) {
+	f, img, err := p.read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := machine.New()
+	if f != nil {
+		if err := m.LoadExecutable(f); err != nil {
+			return nil, err
+		}
+	} else {
+		m.LoadImage(img)
+	}
+
+	return m, nil
+}
+
+// read reads a program, which is either an executable or a memory image.
+func (p *programFlags) read(path string) (*exe.File, *image.Image, error) {
+	format, isImage := image.FormatOf(path)
+	if p.format != "" {
+		var err error
+		if format, err = image.ParseFormat(p.format); err != nil {
+			return nil, nil, err
+		}
+		isImage = true
+	}
+
+	if !isImage {
+		f, err := readExecutable(path)
+		return f, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	img, err := image.Read(file, format, p.options())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil, img, nil
+}
+
+func readExecutable(path string) (*exe.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := exe.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if f.Header.Arch != asm.Arch {
+		return nil, fmt.Errorf("%s: not an r16 executable: %s", path, f.Header.Arch)
+	}
+
+	return f, nil
+}
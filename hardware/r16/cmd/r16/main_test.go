@@ -1,5 +1,174 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
 
 func TestOK(t *testing.T) {}
+
+func TestRun_asm_disasm(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "loop.s")
+	program := "\t.func start\nstart:\tadd.hi %a0, %a0, 1\n\tjump start\n"
+	require.Success(t, os.WriteFile(source, []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", source}, &stdout, &stderr))
+	expect.Equal(t, "", stderr.String())
+
+	// Source paths depend on the temporary directory, so run from there.
+	t.Chdir(dir)
+	expect.Equal(t, 0, run([]string{"asm", "-o", "out.exe", "loop.s"}, &stdout, &stderr))
+
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 0, run([]string{"disasm", "out.exe"}, verifier.Writer(), &stderr))
+	verifier.Verify()
+
+	_, err := os.Stat(filepath.Join(dir, "loop.exe"))
+	require.Success(t, err)
+}
+
+func TestRun_run_halt(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "\tadd.hi %a0, %zr, 1\nhalt:\tjump halt\n"
+	require.Success(t, os.WriteFile("halt.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "halt.s"}, &stdout, &stderr))
+	expect.Equal(t, 0, run([]string{"run", "halt.exe"}, &stdout, &stderr))
+	expected := "halted at 8004 after 2 instructions\na0: 0x0001 S:1 U:1 (arg)\n"
+	expect.Equal(t, expected, stdout.String())
+
+	stdout.Reset()
+	expect.Equal(t, 1, run([]string{"run", "-steps", "1", "halt.exe"}, &stdout, &stderr))
+}
+
+func TestRun_convert(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "main:\tload.h %a0, %zr, data\nhalt:\tjump halt\n\t.data\ndata:\t.half 7\n"
+	require.Success(t, os.WriteFile("halt.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "halt.s"}, &stdout, &stderr))
+	expect.Equal(t, 0, run([]string{"run", "halt.exe"}, &stdout, &stderr))
+	expected := stdout.String()
+
+	testCases := [][]string{
+		{"convert", "halt.exe"},
+		{"convert", "-o", "halt.s19", "halt.hex"},
+		{"convert", "-to", "memh", "-width", "4", "halt.s19"},
+		{"convert", "-o", "halt.raw", "halt.exe"},
+	}
+	for _, args := range testCases {
+		require.Equal(t, 0, run(args, &stdout, &stderr))
+	}
+
+	for _, args := range [][]string{
+		{"run", "halt.hex"},
+		{"run", "halt.s19"},
+		{"run", "-width", "4", "halt.mem"},
+		{"run", "-format", "ihex", "halt.hex"},
+		{"run", "halt.raw"},
+	} {
+		stdout.Reset()
+		expect.Equal(t, 0, run(args, &stdout, &stderr))
+		expect.Equal(t, expected, stdout.String())
+	}
+	expect.Equal(t, "", stderr.String())
+}
+
+func TestRun_vectors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "main:\tadd.hi %a0, %zr, 0x1234\n\tstore.h %a0, %zr, 0x0100\nhalt:\tjump halt\n"
+	require.Success(t, os.WriteFile("vectors.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "vectors.s"}, &stdout, &stderr))
+
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 0, run([]string{"vectors", "vectors.exe"}, verifier.Writer(), &stderr))
+	verifier.Verify()
+
+	expect.Equal(t, 0, run([]string{"vectors", "-o", "vectors.vec", "vectors.exe"}, &stdout, &stderr))
+	_, err := os.Stat("vectors.vec")
+	require.Success(t, err)
+}
+
+func TestRun_cosim(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "main:\tadd.hi %a0, %zr, 0x1234\n\tstore.h %a0, %zr, 0x0100\nhalt:\tjump halt\n"
+	require.Success(t, os.WriteFile("cosim.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "cosim.s"}, &stdout, &stderr))
+	expect.Equal(t, 0, run([]string{"cosim", "cosim.exe"}, &stdout, &stderr))
+	expect.Equal(t, "no divergence after 3 instructions\n", stdout.String())
+	expect.Equal(t, "", stderr.String())
+
+	expect.Equal(t, 1, run([]string{"cosim", "-model", "./missing", "cosim.exe"}, &stdout, &stderr))
+}
+
+func TestRun_run_trap(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := `
+	.func main, leaf
+main:	add.hi %sp, %sp, -4
+	store.h %rp, %sp, 2
+	store.h %fp, %sp, 0
+	add.hi %fp, %sp, 0
+	call leaf
+	jump main
+leaf:	illegal
+`
+	require.Success(t, os.WriteFile("trap.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "trap.s"}, &stdout, &stderr))
+
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 1, run([]string{"run", "trap.exe"}, &stdout, verifier.Writer()))
+	verifier.Verify()
+}
+
+func TestRun_errors(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "bad.s")
+	require.Success(t, os.WriteFile(source, []byte("\tfoo\n"), 0o644))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"no command", nil, 2},
+		{"unknown command", []string{"foo"}, 2},
+		{"asm without sources", []string{"asm"}, 2},
+		{"asm invalid source", []string{"asm", source}, 1},
+		{"disasm without executable", []string{"disasm"}, 2},
+		{"disasm invalid executable", []string{"disasm", source}, 1},
+		{"run without executable", []string{"run"}, 2},
+		{"run invalid executable", []string{"run", source}, 1},
+		{"run invalid image", []string{"run", "-format", "ihex", source}, 1},
+		{"run unknown format", []string{"run", "-format", "foo", source}, 1},
+		{"convert without program", []string{"convert"}, 2},
+		{"vectors without program", []string{"vectors"}, 2},
+		{"cosim without program", []string{"cosim"}, 2},
+		{"vectors invalid program", []string{"vectors", source}, 1},
+		{"convert unknown format", []string{"convert", "-to", "foo", source}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			expect.Equal(t, tc.expected, run(tc.args, &stdout, &stderr))
+			expect.Equal(t, true, stderr.Len() > 0)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Package asm assembles and disassembles r16 programs.
+//
+// The syntax of the instructions follows the README. The rest of the syntax
+// and the directives are shared with other architectures; see the
+// hardware/internal/asm package.
+package asm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+)
+
+// Arch is the name of the architecture in executables.
+var Arch = exe.PackName("R16")
+
+// Assemble the sources into an executable that is loaded at the program
+// base address. The executable includes a debug line table.
+func Assemble(sources ...asm.Source) (*exe.File, error) {
+	return asm.Assemble(asm.Config{
+		Arch: arch{},
+		Header: exe.Header{
+			Endianness: exe.LittleEndian,
+			Type:       exe.StaticExecutable,
+			Arch:       Arch,
+		},
+		Base: uint16(isa.ProgramBase),
+	}, sources...)
+}
+
+type arch struct{}
+
+func (arch) Instruction(mnemonic string, operands []asm.Operand) (int, asm.Encoder, error) {
+	if pseudo, ok := pseudoInstructions[mnemonic]; ok {
+		o, expanded, err := pseudo.Resolve(mnemonic, operands)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return instruction(o, expanded)
+	}
+
+	o, ok := isa.ParseOperation(mnemonic)
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+
+	return instruction(o, operands)
+}
+
+// instruction checks the operands of an operation and returns its encoder.
+func instruction(o isa.Operation, operands []asm.Operand) (int, asm.Encoder, error) {
+	// The format determines the operands, as in the disassembler.
+	var fields []string
+	switch {
+	case o == isa.ILLEGAL:
+	case o>>14 == 0:
+		fields = []string{"Z", "Y", "X"}
+	case o>>14 == 1:
+		fields = []string{"Y", "X", "imm"}
+	default:
+		fields = []string{"Z", "X", "imm"}
+	}
+
+	if len(operands) != len(fields) {
+		return 0, nil, fmt.Errorf("%s takes %d operands, got %d", o, len(fields), len(operands))
+	}
+
+	d := isa.DecodedInstruction{Operation: o}
+	var imm asm.Expr
+	for i, field := range fields {
+		operand := &operands[i]
+		if field == "imm" {
+			if operand.IsRegister() {
+				msg := fmt.Sprintf("expected immediate, got register %%%s", operand.Register)
+				return 0, nil, &asm.Error{Pos: operand.Pos, Msg: msg}
+			}
+			imm = operand.Expr
+			continue
+		}
+
+		r, err := register(operand)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch field {
+		case "Z":
+			d.Z = r
+		case "Y":
+			d.Y = r
+		default:
+			d.X = r
+		}
+	}
+
+	return 4, func(e *asm.Env) ([]byte, error) {
+		if imm != nil {
+			var err error
+			if d.Imm, err = e.EvalU16(imm); err != nil {
+				return nil, err
+			}
+		}
+
+		return binary.LittleEndian.AppendUint32(nil, uint32(isa.Encode(d))), nil
+	}, nil
+}
+
+func register(o *asm.Operand) (isa.Register, error) {
+	if !o.IsRegister() {
+		return 0, &asm.Error{Pos: o.Pos, Msg: "expected register"}
+	}
+
+	r, ok := isa.ParseRegister(o.Register)
+	if !ok {
+		return 0, &asm.Error{Pos: o.Pos, Msg: fmt.Sprintf("unknown register %%%s", o.Register)}
+	}
+
+	return r, nil
+}
+
+// pseudoInstructions by mnemonic. Each expands into a single operation.
+var pseudoInstructions = map[string]asm.Pseudo[isa.Operation]{
+	"call":  {Operation: isa.JAL, Operands: 1, Expand: asm.Prepend(isa.RP, isa.ZR)},
+	"mcall": {Operation: isa.JAL, Operands: 1, Expand: asm.Prepend(isa.T0, isa.ZR)},
+	"rcall": {Operation: isa.JAL, Operands: 2, Expand: asm.Prepend(isa.RP)},
+	"jump":  {Operation: isa.JAL, Operands: 1, Expand: asm.Prepend(isa.ZR, isa.ZR)},
+	"rjump": {Operation: isa.JAL, Operands: 2, Expand: asm.Prepend(isa.ZR)},
+	"ret":   {Operation: isa.JAL, Operands: 0, Expand: asm.Fixed(0, isa.ZR, isa.RP)},
+	"mret":  {Operation: isa.JAL, Operands: 0, Expand: asm.Fixed(0, isa.ZR, isa.T0)},
+	"inv.b": {Operation: isa.XORBI, Operands: 2, Expand: asm.AppendImm(-1)},
+	"not.b": {Operation: isa.XORBI, Operands: 2, Expand: asm.AppendImm(1)},
+	"inv.h": {Operation: isa.XORHI, Operands: 2, Expand: asm.AppendImm(-1)},
+	"not.h": {Operation: isa.XORHI, Operands: 2, Expand: asm.AppendImm(1)},
+}
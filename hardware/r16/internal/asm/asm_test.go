@@ -0,0 +1,140 @@
+package asm_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/r16/internal/asm"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+const testProgram = `
+; Sums the numbers from 1 to n.
+n = 5
+
+	.entry main
+	.func main, sum, inc
+
+main:	add.hi %a0, %zr, n
+	call sum
+	store.h %a1, %zr, result
+halt:	jump halt
+
+sum:	add.hi %a1, %zr, 0
+loop:	mcall inc
+	add.hi %a0, %a0, -1
+	bne %zr, %a0, loop
+	ret
+
+inc:	add.h %a1, %a1, %a0
+	mret
+
+	.data
+	.object result
+result:	.half 0
+	.bss
+stack:	.space 32
+`
+
+func TestDisassemble(t *testing.T) {
+	f, err := asm.Assemble(sharedasm.Source{Name: "sum.s", Data: []byte(testProgram)})
+	require.Success(t, err)
+
+	verifier := approval.NewTextVerifier(t)
+	require.Success(t, asm.Disassemble(verifier.Writer(), f))
+	verifier.Verify()
+}
+
+// The disassembly of every operation can be assembled again.
+func TestAssemble_round_trip(t *testing.T) {
+	for i := range 0x10000 {
+		o := isa.Operation(i)
+		if !o.Known() {
+			continue
+		}
+
+		d := isa.DecodedInstruction{Operation: o}
+		if o != isa.ILLEGAL {
+			d.Z, d.Y, d.X, d.Imm = isa.A0, isa.S6, isa.SP, 0xfedc
+			d = isa.Decode(isa.Encode(clearUnused(d)))
+		}
+
+		text := isa.Disassemble(d)
+		f, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(text)})
+		require.Success(t, err)
+		expect.Equal(t, uint32(isa.Encode(d)), binary.LittleEndian.Uint32(f.Code))
+	}
+}
+
+func TestAssemble_pseudo_instructions(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{"call 0x1234", "jal %rp, %zr, 0x1234"},
+		{"mcall 0x1234", "jal %t0, %zr, 0x1234"},
+		{"rcall %a2, 8", "jal %rp, %a2, 0x0008"},
+		{"jump 0x1234", "jal %zr, %zr, 0x1234"},
+		{"rjump %a2, 8", "jal %zr, %a2, 0x0008"},
+		{"ret", "jal %zr, %rp, 0x0000"},
+		{"mret", "jal %zr, %t0, 0x0000"},
+		{"inv.b %a0, %fp", "xor.bi %a0, %s0, 0xffff"},
+		{"not.b %a0, %a1", "xor.bi %a0, %a1, 0x0001"},
+		{"inv.h %a0, %a1", "xor.hi %a0, %a1, 0xffff"},
+		{"not.h %a0, %a1", "xor.hi %a0, %a1, 0x0001"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			f, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(tc.source)})
+			require.Success(t, err)
+
+			encoded := isa.EncodedInstruction(binary.LittleEndian.Uint32(f.Code))
+			expect.Equal(t, tc.expected, isa.Disassemble(isa.Decode(encoded)))
+		})
+	}
+}
+
+func TestAssemble_errors(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{"\tfoo", "test.s:1:2: unknown instruction foo"},
+		{"\tadd.h %a0, %a1", "test.s:1:2: add.h takes 3 operands, got 2"},
+		{"\tret %a0", "test.s:1:2: ret takes 0 operands, got 1"},
+		{"\tadd.h %a0, %a1, 1", "test.s:1:18: expected register"},
+		{"\tadd.hi %a0, %a1, %a2", "test.s:1:19: expected immediate, got register %a2"},
+		{"\tadd.hi %a0, %r16, 1", "test.s:1:14: unknown register %r16"},
+		{"\tadd.hi %a0, %a1, 0x10000", "test.s:1:19: value out of range [-32768, 65535]: 65536"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			_, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(tc.source)})
+			if err == nil {
+				t.Fatal("expected invalid source to fail")
+			}
+			expect.Equal(t, tc.expected, err.Error())
+		})
+	}
+}
+
+// clearUnused clears the fields that the format of the operation does not
+// use, as required by the encoding.
+func clearUnused(d isa.DecodedInstruction) isa.DecodedInstruction {
+	switch d.Operation >> 14 {
+	case 0:
+		d.Imm = 0
+	case 1:
+		d.Z = 0
+	default:
+		d.Y = 0
+	}
+
+	return d
+}
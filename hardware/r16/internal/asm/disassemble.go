@@ -0,0 +1,21 @@
+package asm
+
+import (
+	"io"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+)
+
+// Disassemble writes a listing of an executable loaded at the program base
+// address.
+//
+// Symbols label the addresses that they refer to. If the executable has a
+// debug line table, the source line of each instruction is shown whenever
+// it changes.
+func Disassemble(w io.Writer, f *exe.File) error {
+	return asm.List(w, f, uint16(isa.ProgramBase), func(encoded uint32) string {
+		return isa.Disassemble(isa.Decode(isa.EncodedInstruction(encoded)))
+	})
+}
@@ -0,0 +1,313 @@
+// Package cosim runs implementations of r16 in lockstep with the reference
+// machine to find where they diverge.
+//
+// The candidate can be another configuration of machine.Machine, such as
+// one with the decode cache enabled, or an external model that speaks the
+// pipe protocol of PipeModel.
+package cosim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/jespert/primordial/hardware/internal/image"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Model is an implementation of r16 that can be checked against the
+// reference machine.
+type Model interface {
+	// WriteMemory copies data into memory at the address.
+	WriteMemory(address state.Address, data []byte) error
+
+	// SetIP moves the IP.
+	SetIP(ip state.Address) error
+
+	// Step executes the instruction at the IP. Traps are reported as errors.
+	Step() error
+
+	// State returns the IP and the registers.
+	State() (State, error)
+
+	// ReadMemory copies memory from the address into data.
+	ReadMemory(address state.Address, data []byte) error
+}
+
+// Load a memory image into a model, on top of its current memory, and move
+// the IP to its entrypoint, or to the program base address if it has none.
+func Load(model Model, img *image.Image) error {
+	for _, c := range img.Chunks {
+		if err := model.WriteMemory(state.Address(c.Address), c.Data); err != nil {
+			return err
+		}
+	}
+
+	ip := state.Address(machine.ProgramBase)
+	if img.HasEntrypoint {
+		ip = state.Address(img.Entrypoint)
+	}
+
+	return model.SetIP(ip)
+}
+
+// State is the architectural state of a model apart from memory.
+type State struct {
+	IP        state.Address
+	Registers state.Registers
+}
+
+// Emulator adapts a machine to the Model interface.
+type Emulator struct {
+	machine *machine.Machine
+}
+
+// NewEmulator creates a model backed by the machine.
+func NewEmulator(m *machine.Machine) *Emulator {
+	return &Emulator{machine: m}
+}
+
+func (e *Emulator) WriteMemory(address state.Address, data []byte) error {
+	return e.machine.LoadProgram(address, data)
+}
+
+func (e *Emulator) SetIP(ip state.Address) error {
+	e.machine.SetIP(ip)
+	return nil
+}
+
+func (e *Emulator) Step() error {
+	return e.machine.Step()
+}
+
+func (e *Emulator) State() (State, error) {
+	return State{IP: e.machine.IP(), Registers: e.machine.Registers()}, nil
+}
+
+func (e *Emulator) ReadMemory(address state.Address, data []byte) error {
+	e.machine.ReadMemory(address, data)
+	return nil
+}
+
+// DefaultContext is the number of instructions shown before a divergence.
+const DefaultContext = 8
+
+// Lockstep steps a candidate model and the reference machine together and
+// compares their architectural state after every instruction.
+//
+// The IP and the registers are compared in full, but memory is only
+// compared where the reference wrote it, because comparing all of it after
+// every instruction would be too slow. CheckMemory compares all of it, and
+// should be called at least once the program ends.
+type Lockstep struct {
+	reference *machine.Machine
+	candidate Model
+
+	// Retired instructions.
+	steps int
+
+	// Memory written by the last instruction of the reference.
+	written []machine.MemoryAccess
+
+	// Most recent records of the reference, oldest first.
+	recent  []machine.Record
+	context int
+}
+
+// NewLockstep creates a lockstep harness. Both models must already have
+// the same program loaded.
+//
+// The harness traces the reference to compare memory writes and to show
+// the instructions that led to a divergence, so it replaces its tracer.
+func NewLockstep(reference *machine.Machine, candidate Model) *Lockstep {
+	l := &Lockstep{
+		reference: reference,
+		candidate: candidate,
+		context:   DefaultContext,
+	}
+	reference.SetTracer(l)
+	return l
+}
+
+// SetContext sets the number of instructions shown before a divergence.
+func (l *Lockstep) SetContext(instructions int) {
+	l.context = instructions
+	if len(l.recent) > instructions {
+		l.recent = l.recent[len(l.recent)-instructions:]
+	}
+}
+
+// Steps returns the number of instructions retired by both models.
+func (l *Lockstep) Steps() int {
+	return l.steps
+}
+
+// Trace implements machine.Tracer by keeping the memory written by the
+// reference and deep copies of its most recent records.
+func (l *Lockstep) Trace(record *machine.Record) error {
+	l.written = l.written[:0]
+	for _, m := range record.Memory {
+		if m.Write {
+			l.written = append(l.written, m)
+		}
+	}
+
+	if l.context == 0 {
+		return nil
+	}
+
+	r := *record
+	r.Registers = append([]machine.RegisterWrite(nil), record.Registers...)
+	r.Memory = append([]machine.MemoryAccess(nil), record.Memory...)
+	if len(l.recent) == l.context {
+		l.recent = append(l.recent[:0], l.recent[1:]...)
+	}
+	l.recent = append(l.recent, r)
+	return nil
+}
+
+// Step executes an instruction in both models and compares them.
+//
+// It returns a *Divergence if they disagree. If both trap, the trap of the
+// reference is returned. Other errors come from the candidate.
+func (l *Lockstep) Step() error {
+	ip := l.reference.IP()
+	referenceErr := l.reference.Step()
+	candidateErr := l.candidate.Step()
+	switch {
+	case referenceErr != nil && candidateErr != nil:
+		return referenceErr
+	case referenceErr != nil:
+		return l.diverge(ip, "trap", referenceErr.Error(), "retired")
+	case candidateErr != nil:
+		return l.diverge(ip, "trap", "retired", candidateErr.Error())
+	}
+
+	s, err := l.candidate.State()
+	if err != nil {
+		return err
+	}
+
+	if reference := l.reference.IP(); s.IP != reference {
+		return l.diverge(ip, "ip", fmt.Sprintf("0x%04x", reference), fmt.Sprintf("0x%04x", s.IP))
+	}
+
+	registers := l.reference.Registers()
+	for i := range state.NumRegisters {
+		register := isa.Register(i)
+		reference, candidate := registers.Read(register), s.Registers.Read(register)
+		if reference != candidate {
+			what := fmt.Sprintf("register %%%s", register)
+			return l.diverge(ip, what, fmt.Sprintf("0x%04x", reference), fmt.Sprintf("0x%04x", candidate))
+		}
+	}
+
+	for _, m := range l.written {
+		var candidate [1]byte
+		if err := l.candidate.ReadMemory(m.Address, candidate[:]); err != nil {
+			return err
+		}
+		if candidate[0] != m.New {
+			what := fmt.Sprintf("memory [%04x]", m.Address)
+			return l.diverge(ip, what, fmt.Sprintf("0x%02x", m.New), fmt.Sprintf("0x%02x", candidate[0]))
+		}
+	}
+
+	l.steps++
+	return nil
+}
+
+// Run steps both models until they have retired the given number of
+// instructions or an error occurs.
+func (l *Lockstep) Run(steps int) error {
+	for range steps {
+		if err := l.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckMemory compares all memory and returns a *Divergence at the first
+// byte that differs.
+func (l *Lockstep) CheckMemory() error {
+	var reference, candidate [state.MemorySize]byte
+	l.reference.ReadMemory(0, reference[:])
+	if err := l.candidate.ReadMemory(0, candidate[:]); err != nil {
+		return err
+	}
+
+	if i := mismatch(reference[:], candidate[:]); i >= 0 {
+		what := fmt.Sprintf("memory [%04x]", i)
+		return l.diverge(l.reference.IP(), what, fmt.Sprintf("0x%02x", reference[i]), fmt.Sprintf("0x%02x", candidate[i]))
+	}
+
+	return nil
+}
+
+func mismatch(a, b []byte) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (l *Lockstep) diverge(ip state.Address, what, reference, candidate string) *Divergence {
+	return &Divergence{
+		Steps:     l.steps,
+		IP:        ip,
+		What:      what,
+		Reference: reference,
+		Candidate: candidate,
+		Trace:     append([]machine.Record(nil), l.recent...),
+	}
+}
+
+// Divergence is the first disagreement between the models.
+type Divergence struct {
+	// Instructions retired by both models before the divergence.
+	Steps int
+
+	// Address of the instruction that diverged.
+	IP state.Address
+
+	// What diverged, such as "ip", "register %a0" or "memory [0100]", and
+	// the values of each model.
+	What      string
+	Reference string
+	Candidate string
+
+	// Most recent instructions of the reference, oldest first. The last one
+	// is the instruction that diverged, unless the reference trapped.
+	Trace []machine.Record
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf(
+		"divergence at %04x after %d instructions: %s: reference %s, candidate %s",
+		d.IP,
+		d.Steps,
+		d.What,
+		d.Reference,
+		d.Candidate,
+	)
+}
+
+// Report writes the divergence followed by the trace of the reference.
+func (d *Divergence) Report(w io.Writer) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	var b bytes.Buffer
+	_, _ = fmt.Fprintf(&b, "%v\ntrace:\n", d)
+	tracer := machine.NewTextTracer(&b)
+	for i := range d.Trace {
+		_ = tracer.Trace(&d.Trace[i])
+	}
+
+	_, _ = w.Write(b.Bytes())
+}
@@ -0,0 +1,212 @@
+package cosim_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/image"
+	"github.com/jespert/primordial/hardware/r16/internal/asm"
+	"github.com/jespert/primordial/hardware/r16/internal/cosim"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+// testProgram stores the numbers from 5 down to 1 and then halts.
+const testProgram = `
+	.entry main
+main:	add.hi %a0, %zr, 5
+	add.hi %a1, %zr, table
+loop:	store.h %a0, %a1, 0
+	add.hi %a1, %a1, 2
+	add.hi %a0, %a0, -1
+	bne %zr, %a0, loop
+halt:	jump halt
+
+	.bss
+table:	.space 10
+`
+
+// Instructions retired by testProgram until it reaches the halt loop.
+const testProgramSteps = 2 + 5*4
+
+func TestLockstep_decode_cache(t *testing.T) {
+	reference, candidate := newMachine(t), newMachine(t)
+	reference.SetDecodeCache(false)
+
+	lockstep := cosim.NewLockstep(reference, cosim.NewEmulator(candidate))
+	require.Success(t, lockstep.Run(testProgramSteps+1))
+	require.Success(t, lockstep.CheckMemory())
+	expect.Equal(t, testProgramSteps+1, lockstep.Steps())
+}
+
+func TestLockstep_divergence(t *testing.T) {
+	// The faults replace the instruction at the IP of the candidate.
+	testCases := []struct {
+		name        string
+		step        int
+		instruction isa.DecodedInstruction
+	}{
+		// Instead of add.hi %a1, %a1, 2.
+		{"register", 12, isa.DecodedInstruction{Operation: isa.ADDHI, Z: isa.A1, X: isa.A1, Imm: 3}},
+		// Instead of store.h %a0, %a1, 0.
+		{"memory", 11, isa.DecodedInstruction{Operation: isa.STOREH, Y: isa.A1, X: isa.A1}},
+		// Instead of bne %zr, %a0, loop.
+		{"ip", 14, isa.DecodedInstruction{Operation: isa.BEQ, Y: isa.ZR, X: isa.A0, Imm: 0x8008}},
+	}
+
+	verifier := approval.NewTextVerifier(t)
+	for _, tc := range testCases {
+		candidate := newMachine(t)
+		faulty := &faulty{Emulator: cosim.NewEmulator(candidate), step: tc.step, fault: func() {
+			var data [4]byte
+			binary.LittleEndian.PutUint32(data[:], uint32(isa.Encode(tc.instruction)))
+			patch(candidate, candidate.IP(), data[:])
+		}}
+
+		lockstep := cosim.NewLockstep(newMachine(t), faulty)
+		lockstep.SetContext(3)
+
+		err := lockstep.Run(testProgramSteps)
+		var divergence *cosim.Divergence
+		require.Equal(t, true, errors.As(err, &divergence))
+		_, _ = fmt.Fprintf(verifier.Writer(), "; %s\n", tc.name)
+		divergence.Report(verifier.Writer())
+		_, _ = fmt.Fprintln(verifier.Writer())
+	}
+
+	verifier.Verify()
+}
+
+func TestLockstep_CheckMemory(t *testing.T) {
+	reference, candidate := newMachine(t), newMachine(t)
+	lockstep := cosim.NewLockstep(reference, cosim.NewEmulator(candidate))
+	require.Success(t, lockstep.Run(2))
+
+	// A write that the reference did not make is only caught by a full
+	// comparison.
+	patch(candidate, 0x1234, []byte{1})
+	require.Success(t, lockstep.Run(1))
+
+	err := lockstep.CheckMemory()
+	var divergence *cosim.Divergence
+	require.Equal(t, true, errors.As(err, &divergence))
+	expect.Equal(t, "memory [1234]", divergence.What)
+}
+
+func TestLockstep_traps(t *testing.T) {
+	reference, candidate := machine.New(), machine.New()
+	lockstep := cosim.NewLockstep(reference, cosim.NewEmulator(candidate))
+
+	// Zero-initialised memory traps in both.
+	var trap *machine.Trap
+	require.Equal(t, true, errors.As(lockstep.Step(), &trap))
+
+	// Only the candidate traps.
+	reference, candidate = newMachine(t), machine.New()
+	lockstep = cosim.NewLockstep(reference, cosim.NewEmulator(candidate))
+	var divergence *cosim.Divergence
+	require.Equal(t, true, errors.As(lockstep.Step(), &divergence))
+	expect.Equal(t, "trap", divergence.What)
+	expect.Equal(t, "retired", divergence.Reference)
+}
+
+func TestPipeModel(t *testing.T) {
+	requests, requestWriter := io.Pipe()
+	responseReader, responses := io.Pipe()
+	served := make(chan error)
+	go func() {
+		served <- cosim.Serve(requests, responses, cosim.NewEmulator(machine.New()))
+		_ = responses.Close()
+	}()
+
+	candidate := cosim.NewPipeModel(responseReader, requestWriter)
+	require.Success(t, cosim.Load(candidate, testImage(t)))
+
+	lockstep := cosim.NewLockstep(newMachine(t), candidate)
+	require.Success(t, lockstep.Run(testProgramSteps+1))
+	require.Success(t, lockstep.CheckMemory())
+
+	require.Success(t, requestWriter.Close())
+	require.Success(t, <-served)
+}
+
+func TestServe(t *testing.T) {
+	requests := strings.Join([]string{
+		"write 8000 050060fa",
+		"ip 8000",
+		"step",
+		"state",
+		"read 8000 4",
+		"step",
+		"foo",
+		"",
+		"write 8000 f",
+		"read fffe 4",
+		"write ffff 0102",
+		// Writes keep the IP where it was.
+		"write 0000 01",
+		"state",
+	}, "\n")
+
+	var responses bytes.Buffer
+	require.Success(t, cosim.Serve(strings.NewReader(requests), &responses, cosim.NewEmulator(machine.New())))
+
+	verifier := approval.NewTextVerifier(t)
+	_, _ = verifier.Writer().Write(responses.Bytes())
+	verifier.Verify()
+}
+
+// faulty runs a fault before the given step.
+type faulty struct {
+	*cosim.Emulator
+
+	steps int
+	step  int
+	fault func()
+}
+
+func (f *faulty) Step() error {
+	f.steps++
+	if f.steps == f.step {
+		f.fault()
+	}
+
+	return f.Emulator.Step()
+}
+
+// patch writes to the memory of the machine without moving its IP.
+func patch(m *machine.Machine, address state.Address, data []byte) {
+	img := &image.Image{Entrypoint: uint16(m.IP()), HasEntrypoint: true}
+	if err := img.Add(uint16(address), data); err != nil {
+		panic(err)
+	}
+
+	m.LoadImage(img)
+}
+
+func newMachine(t *testing.T) *machine.Machine {
+	t.Helper()
+	m := machine.New()
+	m.LoadImage(testImage(t))
+	return m
+}
+
+func testImage(t *testing.T) *image.Image {
+	t.Helper()
+	f, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(testProgram)})
+	require.Success(t, err)
+
+	img, err := image.FromExecutable(f, uint16(machine.ProgramBase))
+	require.Success(t, err)
+	return img
+}
@@ -0,0 +1,246 @@
+package cosim
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// PipeModel is a model in another process that speaks a line-based text
+// protocol over a pair of pipes, such as its standard input and output.
+//
+// Each request is answered by a single line that starts with "ok", maybe
+// followed by values, or with "error" followed by a message. Numbers are
+// hexadecimal without prefix, and so is data, two digits per byte:
+//
+//	write <address> <data>   Write data to memory.      ok
+//	ip <address>             Move the IP.               ok
+//	step                     Execute an instruction.    ok
+//	state                    Report the state.          ok <ip> <r0> ... <r15>
+//	read <address> <size>    Read memory.               ok <data>
+//
+// Errors answering step are traps. The model must start with its memory
+// and registers cleared, and it should exit when its input is closed.
+// Serve implements the protocol for any other model.
+type PipeModel struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// NewPipeModel creates a model that sends requests to w and reads the
+// responses from r.
+func NewPipeModel(r io.Reader, w io.Writer) *PipeModel {
+	return &PipeModel{r: bufio.NewReader(r), w: w}
+}
+
+// maxWriteSize is the largest write request, to keep lines short.
+const maxWriteSize = 256
+
+func (p *PipeModel) WriteMemory(address state.Address, data []byte) error {
+	for offset := 0; offset < len(data); offset += maxWriteSize {
+		chunk := data[offset:min(offset+maxWriteSize, len(data))]
+		if _, err := p.request("write %04x %x", int(address)+offset, chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PipeModel) SetIP(ip state.Address) error {
+	_, err := p.request("ip %04x", ip)
+	return err
+}
+
+func (p *PipeModel) Step() error {
+	_, err := p.request("step")
+	return err
+}
+
+func (p *PipeModel) State() (State, error) {
+	values, err := p.request("state")
+	if err != nil {
+		return State{}, err
+	}
+
+	fields := strings.Fields(values)
+	if len(fields) != 1+state.NumRegisters {
+		return State{}, fmt.Errorf("invalid state: %q", values)
+	}
+
+	var numbers [1 + state.NumRegisters]uint16
+	for i, f := range fields {
+		v, err := strconv.ParseUint(f, 16, 16)
+		if err != nil {
+			return State{}, fmt.Errorf("invalid state: %q", values)
+		}
+		numbers[i] = uint16(v)
+	}
+
+	s := State{IP: state.Address(numbers[0])}
+	for i := 1; i < state.NumRegisters; i++ {
+		s.Registers.Write(isa.Register(i), numbers[1+i])
+	}
+
+	// ZR is included to keep the register file trivially indexable, but
+	// the reference would report any other value as a divergence.
+	if numbers[1] != 0 {
+		return State{}, fmt.Errorf("invalid state: non-zero ZR: 0x%04x", numbers[1])
+	}
+
+	return s, nil
+}
+
+func (p *PipeModel) ReadMemory(address state.Address, data []byte) error {
+	response, err := p.request("read %04x %x", address, len(data))
+	if err != nil {
+		return err
+	}
+
+	decoded, err := hex.DecodeString(response)
+	if err != nil || len(decoded) != len(data) {
+		return fmt.Errorf("invalid memory read of %d bytes at %04x", len(data), address)
+	}
+
+	copy(data, decoded)
+	return nil
+}
+
+// request sends a request and returns the values of the response.
+func (p *PipeModel) request(format string, args ...any) (string, error) {
+	if _, err := fmt.Fprintf(p.w, format+"\n", args...); err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+
+	line, err := p.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return "", errors.New(message)
+	}
+
+	if line == "ok" {
+		return "", nil
+	}
+
+	if values, ok := strings.CutPrefix(line, "ok "); ok {
+		return values, nil
+	}
+
+	return "", fmt.Errorf("invalid response: %q", line)
+}
+
+// Serve answers the requests of the pipe protocol of PipeModel from r with
+// the model, until r is exhausted.
+//
+// This lets other implementations be tested over pipes, and documents the
+// protocol by example.
+func Serve(r io.Reader, w io.Writer, model Model) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for {
+		line, err := br.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		response, err := serve(model, strings.Fields(line))
+		if err != nil {
+			_, _ = fmt.Fprintf(bw, "error %v\n", err)
+		} else if response == "" {
+			_, _ = fmt.Fprintln(bw, "ok")
+		} else {
+			_, _ = fmt.Fprintf(bw, "ok %s\n", response)
+		}
+
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func serve(model Model, request []string) (string, error) {
+	if len(request) == 0 {
+		return "", errors.New("empty request")
+	}
+
+	switch command, args := request[0], request[1:]; {
+	case command == "write" && len(args) == 2:
+		address, err := parseAddress(args[0])
+		if err != nil {
+			return "", err
+		}
+
+		data, err := hex.DecodeString(args[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid data: %w", err)
+		}
+
+		if int(address)+len(data) > state.MemorySize {
+			return "", fmt.Errorf("invalid data: %d bytes at %04x", len(data), address)
+		}
+
+		return "", model.WriteMemory(address, data)
+	case command == "ip" && len(args) == 1:
+		address, err := parseAddress(args[0])
+		if err != nil {
+			return "", err
+		}
+
+		return "", model.SetIP(address)
+	case command == "step" && len(args) == 0:
+		return "", model.Step()
+	case command == "state" && len(args) == 0:
+		s, err := model.State()
+		if err != nil {
+			return "", err
+		}
+
+		var b strings.Builder
+		_, _ = fmt.Fprintf(&b, "%04x", s.IP)
+		for i := range state.NumRegisters {
+			_, _ = fmt.Fprintf(&b, " %04x", s.Registers.Read(isa.Register(i)))
+		}
+		return b.String(), nil
+	case command == "read" && len(args) == 2:
+		address, err := parseAddress(args[0])
+		if err != nil {
+			return "", err
+		}
+
+		size, err := strconv.ParseUint(args[1], 16, 32)
+		if err != nil || int(address)+int(size) > state.MemorySize {
+			return "", fmt.Errorf("invalid size: %s", args[1])
+		}
+
+		data := make([]byte, size)
+		if err := model.ReadMemory(address, data); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(data), nil
+	default:
+		return "", fmt.Errorf("invalid request: %s", strings.Join(request, " "))
+	}
+}
+
+func parseAddress(s string) (state.Address, error) {
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address: %s", s)
+	}
+
+	return state.Address(v), nil
+}
@@ -0,0 +1,218 @@
+// Package coverage reports the code coverage of r16 guests.
+//
+// Coverage is collected by the machine. This package maps it back to the
+// instructions of a program and, when line information exists, to the
+// assembler source lines that produced them.
+package coverage
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Lines maps addresses to source lines.
+type Lines interface {
+	Line(address uint16) (file string, line int, ok bool)
+}
+
+// Report of the coverage of a program.
+type Report struct {
+	Instructions []Instruction
+
+	// Source lines, sorted by file and line. Only available if the report
+	// was created with line information.
+	Lines []Line
+}
+
+// Instruction is the coverage of a single instruction.
+type Instruction struct {
+	Address  state.Address
+	Decoded  isa.DecodedInstruction
+	Executed uint64
+
+	// Only meaningful for branches.
+	Taken    uint64
+	NotTaken uint64
+}
+
+// IsBranch reports whether the instruction is a conditional branch.
+func (i *Instruction) IsBranch() bool {
+	op := i.Decoded.Operation
+	return op&0xf000 == isa.BEQ&0xf000 && op.Known()
+}
+
+// Edges returns the number of branch edges of the instruction and how many
+// of them were covered. Instructions that are not branches have no edges.
+func (i *Instruction) Edges() (covered, total int) {
+	if !i.IsBranch() {
+		return 0, 0
+	}
+
+	if i.Taken > 0 {
+		covered++
+	}
+	if i.NotTaken > 0 {
+		covered++
+	}
+
+	return covered, 2
+}
+
+// Line is the coverage of a source line.
+type Line struct {
+	File         string
+	Line         int
+	Instructions []Instruction
+}
+
+// Status of the coverage of a line.
+type Status int
+
+const (
+	// None of the instructions of the line were executed.
+	Missed Status = iota
+
+	// Some instructions were executed, or some branch edges were not taken.
+	Partial
+
+	// All instructions were executed and all branch edges were taken.
+	Covered
+)
+
+func (s Status) String() string {
+	switch s {
+	case Missed:
+		return "missed"
+	case Partial:
+		return "partial"
+	default:
+		return "covered"
+	}
+}
+
+// Status of the coverage of the line.
+func (l *Line) Status() Status {
+	var executed, edgesCovered, edgesTotal int
+	for _, i := range l.Instructions {
+		if i.Executed > 0 {
+			executed++
+		}
+
+		covered, total := i.Edges()
+		edgesCovered += covered
+		edgesTotal += total
+	}
+
+	switch {
+	case executed == 0:
+		return Missed
+	case executed < len(l.Instructions) || edgesCovered < edgesTotal:
+		return Partial
+	default:
+		return Covered
+	}
+}
+
+// Executed returns the maximum number of times that any instruction of the
+// line was executed.
+func (l *Line) Executed() uint64 {
+	var executed uint64
+	for _, i := range l.Instructions {
+		executed = max(executed, i.Executed)
+	}
+
+	return executed
+}
+
+// NewReport creates a report of the coverage of the code loaded at the base
+// address. Line information is optional.
+//
+// The code is assumed to contain only instructions. Trailing bytes that do
+// not make up a full instruction are ignored.
+func NewReport(c *machine.Coverage, base state.Address, code []byte, lines Lines) *Report {
+	var r Report
+	for offset := 0; offset+4 <= len(code); offset += 4 {
+		address := base + state.Address(offset)
+		encoded := isa.EncodedInstruction(binary.LittleEndian.Uint32(code[offset:]))
+		r.Instructions = append(r.Instructions, Instruction{
+			Address:  address,
+			Decoded:  isa.Decode(encoded),
+			Executed: c.Executed(address),
+			Taken:    c.Taken(address),
+			NotTaken: c.NotTaken(address),
+		})
+	}
+
+	if lines == nil {
+		return &r
+	}
+
+	type key struct {
+		file string
+		line int
+	}
+
+	index := make(map[key]int)
+	for _, i := range r.Instructions {
+		file, line, ok := lines.Line(uint16(i.Address))
+		if !ok {
+			continue
+		}
+
+		k := key{file, line}
+		j, ok := index[k]
+		if !ok {
+			j = len(r.Lines)
+			index[k] = j
+			r.Lines = append(r.Lines, Line{File: file, Line: line})
+		}
+
+		r.Lines[j].Instructions = append(r.Lines[j].Instructions, i)
+	}
+
+	slices.SortFunc(r.Lines, func(a, b Line) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+
+	return &r
+}
+
+// Summary of a report.
+type Summary struct {
+	InstructionsCovered int
+	InstructionsTotal   int
+	EdgesCovered        int
+	EdgesTotal          int
+	LinesCovered        int
+	LinesTotal          int
+}
+
+// Summary counts the covered instructions, branch edges and lines.
+// Partially covered lines do not count as covered.
+func (r *Report) Summary() Summary {
+	var s Summary
+	for _, i := range r.Instructions {
+		s.InstructionsTotal++
+		if i.Executed > 0 {
+			s.InstructionsCovered++
+		}
+
+		covered, total := i.Edges()
+		s.EdgesCovered += covered
+		s.EdgesTotal += total
+	}
+
+	for _, l := range r.Lines {
+		s.LinesTotal++
+		if l.Status() == Covered {
+			s.LinesCovered++
+		}
+	}
+
+	return s
+}
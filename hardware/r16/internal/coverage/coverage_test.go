@@ -0,0 +1,105 @@
+package coverage_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jespert/primordial/hardware/r16/internal/coverage"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestReport_WriteText_without_lines(t *testing.T) {
+	code, c := run(t)
+	report := coverage.NewReport(c, machine.ProgramBase, code, nil)
+
+	verifier := approval.NewTextVerifier(t)
+	require.Success(t, report.WriteText(verifier.Writer()))
+	verifier.Verify()
+}
+
+func TestReport_WriteText_with_lines(t *testing.T) {
+	code, c := run(t)
+	report := coverage.NewReport(c, machine.ProgramBase, code, testLines)
+
+	verifier := approval.NewTextVerifier(t)
+	require.Success(t, report.WriteText(verifier.Writer()))
+	verifier.Verify()
+}
+
+func TestReport_WriteHTML(t *testing.T) {
+	code, c := run(t)
+	report := coverage.NewReport(c, machine.ProgramBase, code, testLines)
+	sources := fstest.MapFS{
+		"loop.s": &fstest.MapFile{Data: []byte(testSource)},
+	}
+
+	verifier := approval.NewTextVerifier(t)
+	require.Success(t, report.WriteHTML(verifier.Writer(), sources))
+	verifier.Verify()
+}
+
+// Source of the test program, for illustration only.
+const testSource = `; Count down from 2.
+    add.hi %a0, %zr, 2
+loop:
+    add.hi %a0, %a0, -1
+    bne %zr, %a0, loop
+    beq %zr, %a0, done
+    illegal
+done:
+    jump done
+`
+
+// Maps the test program to testSource.
+var testLines = lineMap{
+	0x8000: 2,
+	0x8004: 4,
+	0x8008: 5,
+	0x800c: 6,
+	0x8010: 7,
+	0x8014: 9,
+}
+
+type lineMap map[uint16]int
+
+func (m lineMap) Line(address uint16) (string, int, bool) {
+	line, ok := m[address]
+	return "loop.s", line, ok
+}
+
+func run(t *testing.T) ([]byte, *machine.Coverage) {
+	t.Helper()
+
+	program := []isa.DecodedInstruction{
+		{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 2},
+		{Operation: isa.ADDHI, Z: isa.A0, X: isa.A0, Imm: 0xffff},
+		{Operation: isa.BNE, Y: isa.ZR, X: isa.A0, Imm: 0x8004},
+		{Operation: isa.BEQ, Y: isa.ZR, X: isa.A0, Imm: 0x8014},
+		{Operation: isa.ILLEGAL},
+		{Operation: isa.JAL, Z: isa.ZR, X: isa.ZR, Imm: 0x8014},
+	}
+
+	var code bytes.Buffer
+	for _, instruction := range program {
+		encoded := isa.Encode(instruction)
+		code.Write([]byte{
+			byte(encoded),
+			byte(encoded >> 8),
+			byte(encoded >> 16),
+			byte(encoded >> 24),
+		})
+	}
+
+	m := machine.New()
+	require.Success(t, m.LoadProgram(machine.ProgramBase, code.Bytes()))
+	m.EnableCoverage()
+	for range 8 {
+		require.Success(t, m.Step())
+	}
+
+	return code.Bytes(), m.Coverage()
+}
@@ -0,0 +1,202 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+)
+
+// WriteText writes a plain-text report.
+//
+// It starts with a summary, followed by the coverage of every source line
+// if line information is available, and then by the coverage of every
+// instruction.
+func (r *Report) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	s := r.Summary()
+
+	_, _ = fmt.Fprintf(bw, "Instructions: %s\n", ratio(s.InstructionsCovered, s.InstructionsTotal))
+	_, _ = fmt.Fprintf(bw, "Branch edges: %s\n", ratio(s.EdgesCovered, s.EdgesTotal))
+	if r.Lines != nil {
+		_, _ = fmt.Fprintf(bw, "Lines: %s\n", ratio(s.LinesCovered, s.LinesTotal))
+
+		_, _ = fmt.Fprint(bw, "\nLines:\n")
+		for _, l := range r.Lines {
+			_, _ = fmt.Fprintf(bw, "%s:%d  %-7s  %d\n", l.File, l.Line, l.Status(), l.Executed())
+		}
+	}
+
+	_, _ = fmt.Fprint(bw, "\nInstructions:\n")
+	for _, i := range r.Instructions {
+		_, _ = fmt.Fprintf(bw, "%04x  %8d  %s", i.Address, i.Executed, isa.Disassemble(i.Decoded))
+		if i.IsBranch() {
+			_, _ = fmt.Fprintf(bw, "  (taken %d, not taken %d)", i.Taken, i.NotTaken)
+		}
+		_, _ = fmt.Fprint(bw, "\n")
+	}
+
+	return bw.Flush()
+}
+
+// WriteHTML writes a self-contained HTML report.
+//
+// If line information is available and the sources are provided, the
+// report shows every line of the source files highlighted according to
+// its coverage. Otherwise, it shows the disassembled instructions.
+// The sources are looked up by the file names of the line information.
+func (r *Report) WriteHTML(w io.Writer, sources fs.FS) error {
+	data := htmlReport{Summary: r.Summary()}
+
+	for _, i := range r.Instructions {
+		row := htmlRow{
+			Location: fmt.Sprintf("%04x", i.Address),
+			Executed: i.Executed,
+			Text:     isa.Disassemble(i.Decoded),
+			Class:    instructionStatus(&i).String(),
+		}
+		if i.IsBranch() {
+			row.Note = fmt.Sprintf("taken %d, not taken %d", i.Taken, i.NotTaken)
+		}
+
+		data.Instructions = append(data.Instructions, row)
+	}
+
+	for _, l := range r.Lines {
+		if len(data.Files) == 0 || data.Files[len(data.Files)-1].Name != l.File {
+			data.Files = append(data.Files, htmlFile{Name: l.File})
+		}
+		file := &data.Files[len(data.Files)-1]
+		file.Rows = append(file.Rows, htmlRow{
+			Location: fmt.Sprint(l.Line),
+			Executed: l.Executed(),
+			Class:    l.Status().String(),
+			line:     l.Line,
+		})
+	}
+
+	for i := range data.Files {
+		if sources != nil {
+			data.Files[i].Rows = withSource(sources, &data.Files[i])
+		}
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// withSource returns a row per line of the source file, merging the
+// coverage of the lines that have instructions. If the source cannot be
+// read, the rows are returned unchanged.
+func withSource(sources fs.FS, file *htmlFile) []htmlRow {
+	source, err := fs.ReadFile(sources, file.Name)
+	if err != nil {
+		return file.Rows
+	}
+
+	covered := make(map[int]htmlRow)
+	for _, row := range file.Rows {
+		covered[row.line] = row
+	}
+
+	var rows []htmlRow
+	text := strings.TrimSuffix(string(bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n"))), "\n")
+	for i, line := range strings.Split(text, "\n") {
+		row, ok := covered[i+1]
+		if !ok {
+			row = htmlRow{Location: fmt.Sprint(i + 1), Class: "none"}
+		}
+		row.Text = line
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func instructionStatus(i *Instruction) Status {
+	covered, total := i.Edges()
+	switch {
+	case i.Executed == 0:
+		return Missed
+	case covered < total:
+		return Partial
+	default:
+		return Covered
+	}
+}
+
+func ratio(covered, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+
+	return fmt.Sprintf("%d/%d (%.1f%%)", covered, total, 100*float64(covered)/float64(total))
+}
+
+type htmlReport struct {
+	Summary      Summary
+	Files        []htmlFile
+	Instructions []htmlRow
+}
+
+type htmlFile struct {
+	Name string
+	Rows []htmlRow
+}
+
+type htmlRow struct {
+	Location string
+	Executed uint64
+	Text     string
+	Note     string
+	Class    string
+
+	// Source line number, if any.
+	line int
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>R16 coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 0.5em; white-space: pre; }
+td.count { text-align: right; color: #666; }
+tr.covered { background: #d7f5d7; }
+tr.partial { background: #f5ecc4; }
+tr.missed { background: #f5d0d0; }
+</style>
+</head>
+<body>
+<h1>R16 coverage</h1>
+<ul>
+<li>Instructions: {{.Summary.InstructionsCovered}}/{{.Summary.InstructionsTotal}}</li>
+<li>Branch edges: {{.Summary.EdgesCovered}}/{{.Summary.EdgesTotal}}</li>
+{{- if .Files}}
+<li>Lines: {{.Summary.LinesCovered}}/{{.Summary.LinesTotal}}</li>
+{{- end}}
+</ul>
+{{- range .Files}}
+<h2>{{.Name}}</h2>
+<table>
+{{- range .Rows}}
+<tr class="{{.Class}}"><td class="count">{{.Location}}</td><td class="count">{{if ne .Class "none"}}{{.Executed}}{{end}}</td><td>{{.Text}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<h2>Instructions</h2>
+<table>
+{{- range .Instructions}}
+<tr class="{{.Class}}"><td class="count">{{.Location}}</td><td class="count">{{.Executed}}</td><td>{{.Text}}</td><td>{{.Note}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,68 @@
+package isa
+
+import "fmt"
+
+// String returns the mnemonic of the operation, or its hexadecimal
+// representation if the operation is unknown.
+func (o Operation) String() string {
+	if info, ok := operations[o]; ok {
+		return info.mnemonic
+	}
+
+	return fmt.Sprintf("0x%04x", uint16(o))
+}
+
+// ParseOperation returns the operation with the given mnemonic.
+// Pseudo-instructions are not operations, so they are not recognised.
+func ParseOperation(mnemonic string) (Operation, bool) {
+	for o, info := range operations {
+		if info.mnemonic == mnemonic {
+			return o, true
+		}
+	}
+
+	return 0, false
+}
+
+// Known reports whether the operation is defined by the architecture.
+func (o Operation) Known() bool {
+	_, ok := operations[o]
+	return ok
+}
+
+// Disassemble instruction into assembly syntax.
+//
+// Unknown operations are disassembled as a raw data word so that the output
+// can always be reassembled into the same encoding.
+func Disassemble(d DecodedInstruction) string {
+	info, ok := operations[d.Operation]
+	if !ok {
+		return fmt.Sprintf(".word 0x%08x", uint32(Encode(d)))
+	}
+
+	switch info.operands {
+	case operandsZXImm:
+		return fmt.Sprintf("%s %%%s, %%%s, 0x%04x", info.mnemonic, d.Z, d.X, d.Imm)
+	case operandsYXImm:
+		return fmt.Sprintf("%s %%%s, %%%s, 0x%04x", info.mnemonic, d.Y, d.X, d.Imm)
+	case operandsZYX:
+		return fmt.Sprintf("%s %%%s, %%%s, %%%s", info.mnemonic, d.Z, d.Y, d.X)
+	default:
+		return info.mnemonic
+	}
+}
+
+// operands describes which fields of an instruction are meaningful.
+type operands uint8
+
+const (
+	operandsNone operands = iota
+	operandsZXImm
+	operandsYXImm
+	operandsZYX
+)
+
+type operationInfo struct {
+	mnemonic string
+	operands operands
+}
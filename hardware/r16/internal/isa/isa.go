@@ -1,6 +1,11 @@
 // Package isa implements the R16 instruction set.
+//
+// The operations are generated from package spec, which also generates the
+// tables of the README.
 package isa
 
+//go:generate go run gen.go
+
 import "github.com/jespert/primordial/internal/quality/assert"
 
 // Register is a register number.
@@ -25,13 +30,6 @@ const (
 	SP Register = 15
 )
 
-// Operation code (opcode + function).
-type Operation uint16
-
-const (
-	JAL Operation = 0x8001
-)
-
 type DecodedInstruction struct {
 	Operation Operation
 	Z         Register
@@ -141,3 +139,9 @@ const (
 	offsetX = 16
 	offsetW = 12
 )
+
+// ProgramBase is the address at which programs are loaded.
+//
+// R16 code is not position-independent, so the assembler and the emulator
+// must agree on it.
+const ProgramBase = 0x8000
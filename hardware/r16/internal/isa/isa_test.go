@@ -1,10 +1,16 @@
 package isa_test
 
 import (
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/jespert/primordial/hardware/internal/isaspec"
 	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/isa/spec"
 	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
 )
 
 func TestDecode(t *testing.T) {
@@ -39,6 +45,148 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+// Every word decodes, and encoding it again yields the same word, so the
+// encoding has no redundant representations.
+func FuzzDecode(f *testing.F) {
+	for _, tc := range encodingTestCases {
+		f.Add(uint32(tc.encoded))
+	}
+
+	f.Fuzz(func(t *testing.T, encoded uint32) {
+		d := isa.Decode(isa.EncodedInstruction(encoded))
+		if reencoded := isa.Encode(d); uint32(reencoded) != encoded {
+			t.Fatalf("Expected 0x%08x, got 0x%08x", encoded, reencoded)
+		}
+
+		expect.Equal(t, d, isa.Decode(isa.Encode(d)))
+		_ = isa.Disassemble(d)
+	})
+}
+
+func TestParseOperation(t *testing.T) {
+	for i := range 0x10000 {
+		o := isa.Operation(i)
+		if !o.Known() {
+			continue
+		}
+
+		parsed, ok := isa.ParseOperation(o.String())
+		expect.Equal(t, true, ok)
+		expect.Equal(t, o, parsed)
+	}
+
+	// Pseudo-instructions are not operations.
+	_, ok := isa.ParseOperation("call")
+	expect.Equal(t, false, ok)
+}
+
+func TestParseRegister(t *testing.T) {
+	for r := range isa.Register(16) {
+		for _, alias := range r.Aliases() {
+			parsed, ok := isa.ParseRegister(alias)
+			expect.Equal(t, true, ok)
+			expect.Equal(t, r, parsed)
+		}
+
+		parsed, ok := isa.ParseRegister(fmt.Sprintf("r%d", r))
+		expect.Equal(t, true, ok)
+		expect.Equal(t, r, parsed)
+	}
+
+	testCases := []struct {
+		name     string
+		expected isa.Register
+		ok       bool
+	}{
+		{"FP", isa.S0, true},
+		{"Sp", isa.SP, true},
+		{"r15", isa.SP, true},
+		{"r16", 0, false},
+		{"r01", 0, false},
+		{"r", 0, false},
+		{"%a0", 0, false},
+	}
+
+	for _, tc := range testCases {
+		parsed, ok := isa.ParseRegister(tc.name)
+		expect.Equal(t, tc.ok, ok)
+		expect.Equal(t, tc.expected, parsed)
+	}
+}
+
+func TestRegister_Group(t *testing.T) {
+	var groups []string
+	for r := range isa.Register(16) {
+		groups = append(groups, r.String()+":"+r.Group().String())
+	}
+
+	expected := "zr:zero s6:saved s5:saved s4:saved s3:saved s2:saved s1:saved s0:saved " +
+		"t0:temp t1:temp a0:arg a1:arg a2:arg a3:arg rp:return sp:stack"
+	expect.Equal(t, expected, strings.Join(groups, " "))
+	expect.Equal(t, isa.GroupUnknown, isa.Register(16).Group())
+	expect.Equal(t, "unknown", isa.Register(255).Group().String())
+}
+
+func TestDisassemble(t *testing.T) {
+	testCases := []struct {
+		name     string
+		decoded  isa.DecodedInstruction
+		expected string
+	}{
+		{
+			name:     "illegal",
+			decoded:  isa.DecodedInstruction{Operation: isa.ILLEGAL},
+			expected: "illegal",
+		},
+		{
+			name: "R",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.ADDH,
+				Z:         isa.A0,
+				Y:         isa.A1,
+				X:         isa.SP,
+			},
+			expected: "add.h %a0, %a1, %sp",
+		},
+		{
+			name: "B",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.BGEU,
+				Y:         isa.S0,
+				X:         isa.T1,
+				Imm:       0x8010,
+			},
+			expected: "bge.u %s0, %t1, 0x8010",
+		},
+		{
+			name: "A",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.JAL,
+				Z:         isa.RP,
+				X:         isa.A2,
+				Imm:       0x1234,
+			},
+			expected: "jal %rp, %a2, 0x1234",
+		},
+		{
+			name: "unknown",
+			decoded: isa.DecodedInstruction{
+				Operation: 0x8003,
+				Z:         0xa,
+				X:         0xc,
+				Imm:       0x6789,
+			},
+			expected: ".word 0x8a3c6789",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expect.Equal(t, tc.expected, isa.Disassemble(tc.decoded))
+		})
+	}
+}
+
 var encodingTestCases = []struct {
 	name    string
 	decoded isa.DecodedInstruction
@@ -86,3 +234,16 @@ var encodingTestCases = []struct {
 		encoded: 0x8e1c1234,
 	},
 }
+
+// TestSpec checks that the generated code and the tables of the README
+// match the spec. Run go generate to update them.
+func TestSpec(t *testing.T) {
+	expected, err := spec.Go()
+	require.Success(t, err)
+
+	actual, err := os.ReadFile("operations.go")
+	require.Success(t, err)
+	expect.Equal(t, string(expected), string(actual))
+
+	expect.Success(t, isaspec.CheckFile("../../README.md", spec.Markdown()))
+}
@@ -0,0 +1,118 @@
+package isa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Group of registers that share a role in the calling convention.
+type Group uint8
+
+const (
+	GroupZero Group = iota
+	GroupSaved
+	GroupTemporary
+	GroupArgument
+	GroupReturnPointer
+	GroupStackPointer
+
+	// GroupUnknown is the group of registers that do not exist.
+	GroupUnknown
+)
+
+func (g Group) String() string {
+	switch g {
+	case GroupZero:
+		return "zero"
+	case GroupSaved:
+		return "saved"
+	case GroupTemporary:
+		return "temp"
+	case GroupArgument:
+		return "arg"
+	case GroupReturnPointer:
+		return "return"
+	case GroupStackPointer:
+		return "stack"
+	case GroupUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("group(%d)", uint8(g))
+	}
+}
+
+// String returns the lowercase alias of the register.
+func (r Register) String() string {
+	if int(r) < len(registers) {
+		return registers[r].aliases[0]
+	}
+
+	return "r" + strconv.Itoa(int(r))
+}
+
+// Aliases returns the lowercase aliases of the register, the preferred one
+// first. For example, S0 is also FP.
+func (r Register) Aliases() []string {
+	if int(r) < len(registers) {
+		return registers[r].aliases
+	}
+
+	return []string{r.String()}
+}
+
+// Group returns the role of the register in the calling convention, or
+// GroupUnknown if the register does not exist.
+func (r Register) Group() Group {
+	if int(r) < len(registers) {
+		return registers[r].group
+	}
+
+	return GroupUnknown
+}
+
+// ParseRegister returns the register with the given alias or number, such
+// as "a0", "fp" or "r10", without the % prefix. Letter case is ignored.
+func ParseRegister(name string) (Register, bool) {
+	name = strings.ToLower(name)
+	for i, info := range registers {
+		for _, alias := range info.aliases {
+			if alias == name {
+				return Register(i), true
+			}
+		}
+	}
+
+	if number, ok := strings.CutPrefix(name, "r"); ok {
+		n, err := strconv.ParseUint(number, 10, 8)
+		if err == nil && n < uint64(len(registers)) && number == strconv.FormatUint(n, 10) {
+			return Register(n), true
+		}
+	}
+
+	return 0, false
+}
+
+type registerInfo struct {
+	aliases []string
+	group   Group
+}
+
+var registers = [...]registerInfo{
+	ZR: {[]string{"zr"}, GroupZero},
+	S6: {[]string{"s6"}, GroupSaved},
+	S5: {[]string{"s5"}, GroupSaved},
+	S4: {[]string{"s4"}, GroupSaved},
+	S3: {[]string{"s3"}, GroupSaved},
+	S2: {[]string{"s2"}, GroupSaved},
+	S1: {[]string{"s1"}, GroupSaved},
+	S0: {[]string{"s0", "fp"}, GroupSaved},
+	T0: {[]string{"t0"}, GroupTemporary},
+	T1: {[]string{"t1"}, GroupTemporary},
+	A0: {[]string{"a0"}, GroupArgument},
+	A1: {[]string{"a1"}, GroupArgument},
+	A2: {[]string{"a2"}, GroupArgument},
+	A3: {[]string{"a3"}, GroupArgument},
+	RP: {[]string{"rp"}, GroupReturnPointer},
+	SP: {[]string{"sp"}, GroupStackPointer},
+}
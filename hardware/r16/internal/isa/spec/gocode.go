@@ -0,0 +1,101 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"go/format"
+	"slices"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+)
+
+// Go returns the source of operations.go of package isa, which has the
+// constants of the operations and their mnemonics and operands. It fails
+// if the encodings of two instructions collide.
+func Go() ([]byte, error) {
+	if err := ISA.Check(); err != nil {
+		return nil, err
+	}
+
+	blocks := codeBlocks()
+
+	var b strings.Builder
+	b.WriteString("// Code generated by gen.go from package spec; DO NOT EDIT.\n\n")
+	b.WriteString("package isa\n\n")
+	b.WriteString("// Operation code (opcode + function).\n")
+	b.WriteString("type Operation uint16\n\n")
+	b.WriteString("const (\n")
+	for n, blk := range blocks {
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		_, _ = fmt.Fprintf(&b, "\t// %s\n", blk.comment)
+		for _, i := range blk.instructions {
+			_, _ = fmt.Fprintf(&b, "\t%s Operation = 0x%04x\n", i.Name, value(&i))
+		}
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("var operations = map[Operation]operationInfo{\n")
+	for n, blk := range blocks {
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		for _, i := range blk.instructions {
+			kind, err := operands(&i)
+			if err != nil {
+				return nil, err
+			}
+			_, _ = fmt.Fprintf(&b, "\t%s: {%q, %s},\n", i.Name, i.Mnemonic, kind)
+		}
+	}
+	b.WriteString("}\n")
+
+	return format.Source([]byte(b.String()))
+}
+
+// codeBlocks returns the blocks without pseudo-instructions, sorted by the
+// value of their operations.
+func codeBlocks() []block {
+	var blocks []block
+	for _, t := range tables {
+		for _, blk := range t.blocks {
+			blk.instructions = slices.DeleteFunc(slices.Clone(blk.instructions),
+				func(i isaspec.Instruction) bool { return i.Pseudo })
+			slices.SortFunc(blk.instructions, func(x, y isaspec.Instruction) int {
+				return cmp.Compare(value(&x), value(&y))
+			})
+			blocks = append(blocks, blk)
+		}
+	}
+
+	slices.SortFunc(blocks, func(x, y block) int {
+		return cmp.Compare(value(&x.instructions[0]), value(&y.instructions[0]))
+	})
+
+	return blocks
+}
+
+// operands returns the constant of the operands of an instruction, which
+// are the register fields that it names in order, and whether it has an
+// immediate, an offset or a target.
+func operands(i *isaspec.Instruction) (string, error) {
+	if i.Operands == "" {
+		return "operandsNone", nil
+	}
+
+	kind := "operands"
+	for _, operand := range strings.Split(i.Operands, ", ") {
+		switch operand {
+		case "%Z", "%Y", "%X":
+			kind += operand[1:]
+		case "imm", "offset", "target":
+			kind += "Imm"
+		default:
+			return "", fmt.Errorf("%s: unknown operand %s", i.Mnemonic, operand)
+		}
+	}
+
+	return kind, nil
+}
@@ -0,0 +1,213 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/cpu"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Trap is the error returned by Step when an instruction cannot be fetched
+// or executed. The machine is left at the trapping instruction.
+type Trap struct {
+	cpu.Trap
+	Backtrace Backtrace
+}
+
+// FrameKind is how a frame of a backtrace was found.
+type FrameKind uint8
+
+const (
+	// The innermost frame, at the IP.
+	FrameIP FrameKind = iota
+
+	// Called by millicode call, which left the return address in T0.
+	FrameMillicode
+
+	// Called by a function that has not saved the return address in RP,
+	// such as a leaf function.
+	FrameLink
+
+	// Found by following the chain of frame records.
+	FrameRecord
+)
+
+func (k FrameKind) String() string {
+	switch k {
+	case FrameIP:
+		return "ip"
+	case FrameMillicode:
+		return "t0"
+	case FrameLink:
+		return "rp"
+	default:
+		return "fp"
+	}
+}
+
+// Frame of a backtrace.
+type Frame struct {
+	// Address of the instruction that the frame is executing: the IP for the
+	// innermost frame and the call instruction for the others.
+	Address state.Address
+
+	Kind FrameKind
+
+	// Function that contains the address and the offset into it, if the
+	// machine has symbols.
+	Function string
+	Offset   uint16
+
+	// Source line of the address, if the machine has a line table.
+	File string
+	Line int
+}
+
+func (f *Frame) String() string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "%04x", f.Address)
+	if f.Function != "" {
+		_, _ = fmt.Fprintf(&b, " %s+0x%x", f.Function, f.Offset)
+	}
+	if f.File != "" {
+		_, _ = fmt.Fprintf(&b, " %s:%d", f.File, f.Line)
+	}
+	_, _ = fmt.Fprintf(&b, " [%s]", f.Kind)
+	return b.String()
+}
+
+// Backtrace is a call stack, innermost frame first.
+type Backtrace []Frame
+
+// String formats the backtrace with one numbered frame per line.
+func (b Backtrace) String() string {
+	var s strings.Builder
+	for i, f := range b {
+		_, _ = fmt.Fprintf(&s, "#%d  %v\n", i, &f)
+	}
+
+	return s.String()
+}
+
+// maxFrames bounds the unwinding of corrupt stacks.
+const maxFrames = 256
+
+// Backtrace unwinds the call stack using the conventions of the README:
+//
+//   - Calls store the return address in RP, or in T0 for millicode.
+//   - Functions that call others save a frame record at FP (S0): the
+//     caller's FP at FP+0 and the return address at FP+2.
+//   - The stack grows downwards, so outer frame records are at higher
+//     addresses. The chain ends with a null FP.
+//
+// Return addresses in T0 and RP are only trusted if they follow a call that
+// links through that register and, when the machine has symbols, if the
+// call targets the function being executed. Without symbols, a stale RP
+// can add a spurious frame.
+func (m *Machine) Backtrace() Backtrace {
+	b := Backtrace{m.frame(m.ip, FrameIP)}
+	pc := m.ip
+
+	if t0 := state.Address(m.registers.Read(isa.T0)); m.returnsTo(t0, isa.T0, pc) {
+		pc = t0 - instructionSize
+		b = append(b, m.frame(pc, FrameMillicode))
+	}
+
+	fp := state.Address(m.registers.Read(isa.S0))
+	saved, hasRecord := m.frameRecord(fp)
+
+	// A function that saved RP in a frame record is unwound by the chain.
+	rp := state.Address(m.registers.Read(isa.RP))
+	if m.returnsTo(rp, isa.RP, pc) && !(hasRecord && saved.returnAddress == rp) {
+		b = append(b, m.frame(rp-instructionSize, FrameLink))
+	}
+
+	for hasRecord && len(b) < maxFrames {
+		if saved.returnAddress == 0 {
+			break
+		}
+
+		b = append(b, m.frame(saved.returnAddress-instructionSize, FrameRecord))
+
+		// Outer records must be at higher addresses, which also guarantees
+		// termination.
+		if saved.fp <= fp {
+			break
+		}
+
+		fp = saved.fp
+		saved, hasRecord = m.frameRecord(fp)
+	}
+
+	return b
+}
+
+type frameRecord struct {
+	fp            state.Address
+	returnAddress state.Address
+}
+
+// frameRecord reads the frame record at the frame pointer, if it is
+// plausible.
+func (m *Machine) frameRecord(fp state.Address) (frameRecord, bool) {
+	sp := state.Address(m.registers.Read(isa.SP))
+	if fp == 0 || fp%2 != 0 || fp < sp {
+		return frameRecord{}, false
+	}
+
+	savedFP, err := m.memory.ReadH(fp)
+	if err != nil {
+		return frameRecord{}, false
+	}
+
+	returnAddress, err := m.memory.ReadH(fp + 2)
+	if err != nil {
+		return frameRecord{}, false
+	}
+
+	return frameRecord{fp: state.Address(savedFP), returnAddress: state.Address(returnAddress)}, true
+}
+
+// returnsTo reports whether the return address follows a call that links
+// through the register and that could have called the code at pc.
+func (m *Machine) returnsTo(returnAddress state.Address, link isa.Register, pc state.Address) bool {
+	if returnAddress < instructionSize || returnAddress%2 != 0 {
+		return false
+	}
+
+	encoded, err := m.memory.ReadW(returnAddress - instructionSize)
+	if err != nil {
+		return false
+	}
+
+	call := isa.Decode(isa.EncodedInstruction(encoded))
+	if call.Operation != isa.JAL || call.Z != link {
+		return false
+	}
+
+	// Indirect calls cannot be checked.
+	if call.X != isa.ZR || m.symbols == nil {
+		return true
+	}
+
+	callee, ok := exe.SymbolAt(m.symbols, call.Imm, exe.SymbolFunction)
+	current, ok2 := exe.SymbolAt(m.symbols, uint16(pc), exe.SymbolFunction)
+	return ok && ok2 && callee.Address == current.Address
+}
+
+func (m *Machine) frame(address state.Address, kind FrameKind) Frame {
+	f := Frame{Address: address, Kind: kind}
+	if s, ok := exe.SymbolAt(m.symbols, uint16(address), exe.SymbolFunction); ok {
+		f.Function = s.Name
+		f.Offset = uint16(address) - s.Address
+	}
+
+	if file, line, ok := m.lines.Line(uint16(address)); ok {
+		f.File, f.Line = file, line
+	}
+
+	return f
+}
@@ -0,0 +1,158 @@
+package machine
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_Backtrace(t *testing.T) {
+	m := New()
+	require.Success(t, m.LoadExecutable(backtraceExecutable()))
+	trap := runUntilTrap(t, m)
+
+	verifier := approval.NewTextVerifier(t)
+	_, _ = verifier.Writer().Write([]byte(trap.Error() + "\n"))
+	_, _ = verifier.Writer().Write([]byte(trap.Backtrace.String()))
+	verifier.Verify()
+}
+
+// Executables do not need to sort their symbols.
+func TestMachine_Backtrace_unsorted_symbols(t *testing.T) {
+	sorted := New()
+	require.Success(t, sorted.LoadExecutable(backtraceExecutable()))
+
+	f := backtraceExecutable()
+	slices.Reverse(f.Symbols)
+	unsorted := New()
+	require.Success(t, unsorted.LoadExecutable(f))
+
+	expected := runUntilTrap(t, sorted).Backtrace.String()
+	expect.Equal(t, expected, runUntilTrap(t, unsorted).Backtrace.String())
+}
+
+func TestMachine_Backtrace_without_symbols(t *testing.T) {
+	f := backtraceExecutable()
+	m := New()
+	require.Success(t, m.LoadProgram(ProgramBase, f.Code))
+	trap := runUntilTrap(t, m)
+
+	// The frames are found, but not symbolised.
+	expected := []Frame{
+		{Address: 0x8050, Kind: FrameIP},
+		{Address: 0x8040, Kind: FrameMillicode},
+		{Address: 0x8030, Kind: FrameLink},
+		{Address: 0x8010, Kind: FrameRecord},
+	}
+	expect.Equal(t, len(expected), len(trap.Backtrace))
+	for i := range min(len(expected), len(trap.Backtrace)) {
+		expect.Equal(t, expected[i], trap.Backtrace[i])
+	}
+}
+
+// After a call returns, the stale RP points into the current function, so
+// it must not be mistaken for a return address.
+func TestMachine_Backtrace_stale_RP(t *testing.T) {
+	m := New()
+	require.Success(t, m.LoadExecutable(backtraceExecutable()))
+	for m.IP() != 0x8040 {
+		require.Success(t, m.Step())
+	}
+
+	// Pretend that g returned.
+	m.ip = 0x8034
+
+	// f is framed, so RP is ignored and the record leads to main.
+	var addresses []string
+	for _, f := range m.Backtrace() {
+		addresses = append(addresses, f.String())
+	}
+	expected := "8034 f+0x14 [ip], 8010 main+0x10 test.s:5 [fp]"
+	expect.Equal(t, expected, strings.Join(addresses, ", "))
+}
+
+func TestLoadExecutable_invalid(t *testing.T) {
+	f := backtraceExecutable()
+	f.Header.Arch = exe.PackName("SR16")
+	if err := New().LoadExecutable(f); err == nil {
+		t.Error("expected executable of another architecture to fail")
+	}
+}
+
+func runUntilTrap(t *testing.T, m *Machine) *Trap {
+	t.Helper()
+	for range 100 {
+		if err := m.Step(); err != nil {
+			var trap *Trap
+			require.Equal(t, true, errors.As(err, &trap))
+			expect.Equal(t, m.IP(), trap.IP)
+			return trap
+		}
+	}
+
+	t.Fatal("expected the program to trap")
+	return nil
+}
+
+// backtraceExecutable has main and f with frame records, a leaf g, and
+// millicode m that traps.
+func backtraceExecutable() *exe.File {
+	prologue := []isa.DecodedInstruction{
+		{Operation: isa.ADDHI, Z: isa.SP, X: isa.SP, Imm: 0xfffc},
+		{Operation: isa.STOREH, Y: isa.RP, X: isa.SP, Imm: 2},
+		{Operation: isa.STOREH, Y: isa.S0, X: isa.SP, Imm: 0},
+		{Operation: isa.ADDHI, Z: isa.S0, X: isa.SP, Imm: 0},
+	}
+
+	functions := []struct {
+		address      int
+		instructions []isa.DecodedInstruction
+	}{
+		// main calls f.
+		{0x8000, append(slices.Clone(prologue), call(isa.RP, 0x8020))},
+		// f calls g.
+		{0x8020, append(slices.Clone(prologue), call(isa.RP, 0x8040))},
+		// g calls m.
+		{0x8040, []isa.DecodedInstruction{call(isa.T0, 0x8050)}},
+		// m traps.
+		{0x8050, []isa.DecodedInstruction{{Operation: isa.ILLEGAL}}},
+	}
+
+	// Gaps between functions are filled with illegal instructions.
+	var code []byte
+	for _, function := range functions {
+		code = append(code, make([]byte, function.address-int(ProgramBase)-len(code))...)
+		for _, instruction := range function.instructions {
+			code = binary.LittleEndian.AppendUint32(code, uint32(isa.Encode(instruction)))
+		}
+	}
+
+	return &exe.File{
+		Header:     exe.Header{Arch: exe.PackName("R16")},
+		Code:       code,
+		Entrypoint: 0x8000,
+		Symbols: []exe.Symbol{
+			{Name: "main", Address: 0x8000, Type: exe.SymbolFunction},
+			{Name: "f", Address: 0x8020, Type: exe.SymbolFunction},
+			{Name: "g", Address: 0x8040, Type: exe.SymbolFunction},
+			{Name: "m", Address: 0x8050, Type: exe.SymbolFunction},
+		},
+		Lines: exe.LineTable{
+			{Address: 0x8000, File: "test.s", Line: 1},
+			{Address: 0x8010, File: "test.s", Line: 5},
+			{Address: 0x8014},
+		},
+	}
+}
+
+func call(link isa.Register, target uint16) isa.DecodedInstruction {
+	return isa.DecodedInstruction{Operation: isa.JAL, Z: link, X: isa.ZR, Imm: target}
+}
@@ -0,0 +1,44 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+)
+
+func BenchmarkMachine_Step(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		benchmarkStep(b, false)
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		benchmarkStep(b, true)
+	})
+}
+
+func benchmarkStep(b *testing.B, cached bool) {
+	// A tight loop that never terminates.
+	program := []isa.DecodedInstruction{
+		// 8000: Count down from 0x7fff.
+		{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 0x7fff},
+		// 8004: Loop body.
+		{Operation: isa.ADDHI, Z: isa.A0, X: isa.A0, Imm: 0xffff},
+		{Operation: isa.STOREH, Y: isa.A0, X: isa.SP, Imm: 0x0100},
+		{Operation: isa.LOADH, Z: isa.A1, X: isa.SP, Imm: 0x0100},
+		{Operation: isa.BNE, Y: isa.ZR, X: isa.A0, Imm: 0x8004},
+		// 8014: Start again.
+		{Operation: isa.JAL, Z: isa.ZR, X: isa.ZR, Imm: 0x8000},
+	}
+
+	m := withProgram(b, program...)
+	m.SetDecodeCache(cached)
+
+	b.ResetTimer()
+	for range b.N {
+		if err := m.Step(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "instructions/s")
+}
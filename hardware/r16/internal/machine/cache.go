@@ -0,0 +1,58 @@
+package machine
+
+import (
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// SetDecodeCache enables or disables the decoded-instruction cache.
+//
+// The cache is enabled by default. It is transparent to the guest because
+// every write to memory invalidates the instructions that overlap it, so
+// self-modifying code keeps working. Disabling it is only useful to
+// measure its impact or to cross-check it against the reference path.
+func (m *Machine) SetDecodeCache(enabled bool) {
+	switch {
+	case enabled && m.cache == nil:
+		m.cache = &decodeCache{}
+	case !enabled:
+		m.cache = nil
+	}
+}
+
+// decodeCache memoises decoded instructions by address.
+//
+// Instructions are aligned to 16 bits, so there is one entry per halfword.
+type decodeCache struct {
+	entries [state.MemorySize / 2]cacheEntry
+}
+
+type cacheEntry struct {
+	encoded isa.EncodedInstruction
+	decoded isa.DecodedInstruction
+	valid   bool
+}
+
+func (c *decodeCache) lookup(address state.Address) *cacheEntry {
+	return &c.entries[address/2]
+}
+
+// invalidate every instruction that overlaps the byte at the address.
+func (c *decodeCache) invalidate(address state.Address) {
+	if c == nil {
+		return
+	}
+
+	// An instruction is four bytes long and aligned to 16 bits, so only two
+	// of them can overlap a given byte. Wrapping around the address space
+	// is intended, because instruction fetches wrap around too.
+	aligned := address &^ 1
+	c.entries[aligned/2].valid = false
+	c.entries[(aligned-2)/2].valid = false
+}
+
+func (c *decodeCache) clear() {
+	if c != nil {
+		c.entries = [len(c.entries)]cacheEntry{}
+	}
+}
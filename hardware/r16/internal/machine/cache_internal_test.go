@@ -0,0 +1,60 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+// A program that patches the immediate of its first instruction.
+var selfModifyingProgram = []isa.DecodedInstruction{
+	// 8000: Accumulate the immediate.
+	{Operation: isa.ADDHI, Z: isa.A0, X: isa.A0, Imm: 1},
+	// 8004: Overwrite the immediate with %a1.
+	{Operation: isa.STOREH, Y: isa.A1, X: isa.ZR, Imm: 0x8000},
+	// 8008: Start again.
+	{Operation: isa.JAL, Z: isa.ZR, X: isa.ZR, Imm: 0x8000},
+}
+
+func TestMachine_Step_self_modifying_code(t *testing.T) {
+	m := withProgram(t, selfModifyingProgram...)
+	m.registers.Write(isa.A1, 5)
+	for range 4 {
+		require.Success(t, m.Step())
+	}
+
+	// The second execution of the first instruction must see the patch.
+	expect.Equal(t, 1+5, m.registers.Read(isa.A0))
+}
+
+func TestMachine_StepBack_invalidates_cache(t *testing.T) {
+	m := withProgram(t, selfModifyingProgram...)
+	m.EnableHistory(0)
+	m.registers.Write(isa.A1, 5)
+	for range 4 {
+		require.Success(t, m.Step())
+	}
+
+	// Revert everything, including the patch, and then execute the first
+	// instruction again.
+	for range 4 {
+		require.Success(t, m.StepBack())
+	}
+	require.Success(t, m.Step())
+
+	expect.Equal(t, 1, m.registers.Read(isa.A0))
+}
+
+func TestMachine_SetDecodeCache(t *testing.T) {
+	// Both paths must produce the same results.
+	cached := withProgram(t, testProgram...)
+	uncached := withProgram(t, testProgram...)
+	uncached.SetDecodeCache(false)
+	for range testProgramSteps {
+		require.Success(t, cached.Step())
+		require.Success(t, uncached.Step())
+		expect.Equal(t, dump(cached), dump(uncached))
+	}
+}
@@ -0,0 +1,146 @@
+package machine
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Counters of retired instructions.
+//
+// Only instructions that complete successfully are counted. Reverting
+// instructions or restoring snapshots does not modify the counters,
+// because they measure the work done by the host.
+type Counters struct {
+	// Instructions retired.
+	Retired uint64
+
+	// Estimated cycles according to the cost model.
+	Cycles uint64
+
+	// Instructions retired by class of operation.
+	Loads            uint64
+	Stores           uint64
+	ALU              uint64
+	Jumps            uint64
+	BranchesTaken    uint64
+	BranchesNotTaken uint64
+}
+
+// CostModel is the number of cycles that each class of operation takes.
+type CostModel struct {
+	Load           uint64
+	Store          uint64
+	ALU            uint64
+	Jump           uint64
+	BranchTaken    uint64
+	BranchNotTaken uint64
+}
+
+// DefaultCostModel assumes that every instruction takes a single cycle.
+var DefaultCostModel = CostModel{
+	Load:           1,
+	Store:          1,
+	ALU:            1,
+	Jump:           1,
+	BranchTaken:    1,
+	BranchNotTaken: 1,
+}
+
+// Counters returns the performance counters.
+func (m *Machine) Counters() Counters {
+	return m.counters
+}
+
+// ResetCounters sets all performance counters to zero.
+func (m *Machine) ResetCounters() {
+	m.counters = Counters{}
+}
+
+// SetCostModel changes the cost model used to estimate cycles from now on.
+func (m *Machine) SetCostModel(costs CostModel) {
+	m.costs = costs
+}
+
+// MapCounters exposes the counters to the guest at the given address.
+//
+// The counters appear in the order of the Counters struct as read-only
+// 64-bit little-endian values, so they take CountersSize bytes. They hold
+// the values before the instruction that reads them, and the guest must
+// tolerate them changing between the loads of a single counter.
+// Writes to them trap.
+//
+// By convention, MMIO belongs in the bottom half of the memory.
+func (m *Machine) MapCounters(base state.Address) error {
+	if int(base)+CountersSize > state.MemorySize {
+		return fmt.Errorf("counters do not fit in memory at %04x", base)
+	}
+
+	m.countersBase = base
+	m.countersMapped = true
+	return nil
+}
+
+// UnmapCounters hides the counters from the guest.
+func (m *Machine) UnmapCounters() {
+	m.countersMapped = false
+}
+
+// CountersSize is the number of bytes that mapped counters take in memory.
+const CountersSize = 8 * 8
+
+// opClass is the class of an operation for accounting purposes.
+type opClass uint8
+
+const (
+	classALU opClass = iota
+	classLoad
+	classStore
+	classJump
+	classBranchTaken
+	classBranchNotTaken
+)
+
+func (c *Counters) retire(class opClass, costs *CostModel) {
+	c.Retired++
+
+	switch class {
+	case classLoad:
+		c.Loads++
+		c.Cycles += costs.Load
+	case classStore:
+		c.Stores++
+		c.Cycles += costs.Store
+	case classJump:
+		c.Jumps++
+		c.Cycles += costs.Jump
+	case classBranchTaken:
+		c.BranchesTaken++
+		c.Cycles += costs.BranchTaken
+	case classBranchNotTaken:
+		c.BranchesNotTaken++
+		c.Cycles += costs.BranchNotTaken
+	default:
+		c.ALU++
+		c.Cycles += costs.ALU
+	}
+}
+
+// readB reads a byte of the memory-mapped representation of the counters.
+func (c *Counters) readB(offset state.Address) byte {
+	values := [...]uint64{
+		c.Retired,
+		c.Cycles,
+		c.Loads,
+		c.Stores,
+		c.ALU,
+		c.Jumps,
+		c.BranchesTaken,
+		c.BranchesNotTaken,
+	}
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], values[offset/8])
+	return buf[offset%8]
+}
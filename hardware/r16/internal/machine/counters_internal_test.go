@@ -0,0 +1,105 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_Counters(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.SetCostModel(CostModel{
+		Load:           3,
+		Store:          5,
+		ALU:            1,
+		Jump:           2,
+		BranchTaken:    7,
+		BranchNotTaken: 11,
+	})
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	expect.Equal(t, Counters{
+		Retired:       8,
+		Cycles:        4*1 + 3 + 5 + 2 + 7,
+		Loads:         1,
+		Stores:        1,
+		ALU:           4,
+		Jumps:         1,
+		BranchesTaken: 1,
+	}, m.Counters())
+
+	m.ResetCounters()
+	expect.Equal(t, Counters{}, m.Counters())
+}
+
+func TestMachine_Counters_failures_are_not_retired(t *testing.T) {
+	m := New()
+	if err := m.Step(); err == nil {
+		t.Fatal("expected illegal instruction to fail")
+	}
+
+	expect.Equal(t, Counters{}, m.Counters())
+}
+
+func TestMachine_MapCounters(t *testing.T) {
+	const base = 0x0200
+	m := withProgram(
+		t,
+		isa.DecodedInstruction{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 1},
+		isa.DecodedInstruction{Operation: isa.BEQ, Y: isa.ZR, X: isa.ZR, Imm: 0x8008},
+		// Read the number of retired instructions.
+		isa.DecodedInstruction{Operation: isa.LOADH, Z: isa.A1, X: isa.ZR, Imm: base},
+		// Read the least significant byte of the number of taken branches.
+		isa.DecodedInstruction{Operation: isa.LOADUB, Z: isa.A2, X: isa.ZR, Imm: base + 48},
+		// Writes to the counters trap.
+		isa.DecodedInstruction{Operation: isa.STOREB, Y: isa.A0, X: isa.ZR, Imm: base + 1},
+	)
+	require.Success(t, m.MapCounters(base))
+
+	for range 4 {
+		require.Success(t, m.Step())
+	}
+	if err := m.Step(); err == nil {
+		t.Error("expected write to counters to fail")
+	}
+
+	expect.Equal(t, 2, m.registers.Read(isa.A1))
+	expect.Equal(t, 1, m.registers.Read(isa.A2))
+
+	// Once unmapped, the memory behind the counters is visible again.
+	m.UnmapCounters()
+	v, err := m.readB(base)
+	require.Success(t, err)
+	expect.Equal(t, 0, v)
+}
+
+// A halfword that overlaps the counters must not be partially written.
+func TestMachine_MapCounters_overlapping_store(t *testing.T) {
+	const base = 0x0200
+	m := withProgram(
+		t,
+		isa.DecodedInstruction{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 0x1234},
+		isa.DecodedInstruction{Operation: isa.STOREH, Y: isa.A0, X: isa.ZR, Imm: base - 1},
+	)
+	require.Success(t, m.MapCounters(base))
+
+	require.Success(t, m.Step())
+	if err := m.Step(); err == nil {
+		t.Fatal("expected write to counters to fail")
+	}
+
+	v, err := m.memory.ReadB(base - 1)
+	require.Success(t, err)
+	expect.Equal(t, 0, v)
+}
+
+func TestMachine_MapCounters_out_of_bounds(t *testing.T) {
+	m := New()
+	if err := m.MapCounters(0xfff0); err == nil {
+		t.Error("expected counters beyond the end of the memory to fail")
+	}
+}
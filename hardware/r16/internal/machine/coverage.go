@@ -0,0 +1,60 @@
+package machine
+
+import "github.com/jespert/primordial/hardware/r16/internal/state"
+
+// Coverage of guest code: how many times each instruction was retired and,
+// for branches, how many times they were taken or not.
+//
+// Instructions are aligned to 16 bits, so counters are kept per halfword.
+// Like the performance counters, coverage is monotonic: reverting
+// instructions does not modify it.
+type Coverage struct {
+	executed [state.MemorySize / 2]uint64
+	taken    [state.MemorySize / 2]uint64
+	notTaken [state.MemorySize / 2]uint64
+}
+
+// EnableCoverage starts collecting coverage from scratch.
+func (m *Machine) EnableCoverage() {
+	m.coverage = &Coverage{}
+}
+
+// DisableCoverage stops collecting coverage and discards it.
+func (m *Machine) DisableCoverage() {
+	m.coverage = nil
+}
+
+// Coverage returns the coverage collected so far, or nil if it is disabled.
+func (m *Machine) Coverage() *Coverage {
+	return m.coverage
+}
+
+// Executed returns the number of times that the instruction at the address
+// was retired.
+func (c *Coverage) Executed(address state.Address) uint64 {
+	return c.executed[address/2]
+}
+
+// Taken returns the number of times that the branch at the address was
+// taken.
+func (c *Coverage) Taken(address state.Address) uint64 {
+	return c.taken[address/2]
+}
+
+// NotTaken returns the number of times that the branch at the address was
+// not taken.
+func (c *Coverage) NotTaken(address state.Address) uint64 {
+	return c.notTaken[address/2]
+}
+
+func (c *Coverage) record(address state.Address, class opClass) {
+	i := address / 2
+	c.executed[i]++
+
+	switch class {
+	case classBranchTaken:
+		c.taken[i]++
+	case classBranchNotTaken:
+		c.notTaken[i]++
+	}
+}
@@ -0,0 +1,38 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_Coverage(t *testing.T) {
+	m := withProgram(
+		t,
+		// 8000: Count down from 2.
+		isa.DecodedInstruction{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 2},
+		// 8004: Loop body.
+		isa.DecodedInstruction{Operation: isa.ADDHI, Z: isa.A0, X: isa.A0, Imm: 0xffff},
+		isa.DecodedInstruction{Operation: isa.BNE, Y: isa.ZR, X: isa.A0, Imm: 0x8004},
+	)
+	expect.Equal(t, nil, m.Coverage())
+
+	m.EnableCoverage()
+	for range 5 {
+		require.Success(t, m.Step())
+	}
+
+	c := m.Coverage()
+	expect.Equal(t, 1, c.Executed(0x8000))
+	expect.Equal(t, 2, c.Executed(0x8004))
+	expect.Equal(t, 2, c.Executed(0x8008))
+	expect.Equal(t, 0, c.Executed(0x800c))
+	expect.Equal(t, 1, c.Taken(0x8008))
+	expect.Equal(t, 1, c.NotTaken(0x8008))
+	expect.Equal(t, 0, c.Taken(0x8004))
+
+	m.DisableCoverage()
+	expect.Equal(t, nil, m.Coverage())
+}
@@ -0,0 +1,41 @@
+package machine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+// Arbitrary programs never crash the host, only trap, and run the same with
+// and without the decode cache, even if they modify themselves.
+func FuzzMachine_Step(f *testing.F) {
+	f.Add(encodeProgram(testProgram))
+	f.Add([]byte{})
+
+	const steps = 256
+	f.Fuzz(func(t *testing.T, program []byte) {
+		cached, uncached := New(), New()
+		uncached.SetDecodeCache(false)
+		program = program[:min(len(program), state.MemorySize-int(ProgramBase))]
+		require.Success(t, cached.LoadProgram(ProgramBase, program))
+		require.Success(t, uncached.LoadProgram(ProgramBase, program))
+
+		for range steps {
+			err := cached.Step()
+			expect.Equal(t, err == nil, uncached.Step() == nil)
+			if err != nil {
+				var trap *Trap
+				expect.Equal(t, true, errors.As(err, &trap))
+				break
+			}
+
+			expect.Equal(t, cached.ip, uncached.ip)
+			expect.Equal(t, cached.registers, uncached.registers)
+		}
+
+		expect.Equal(t, dump(cached), dump(uncached))
+	})
+}
@@ -0,0 +1,162 @@
+package machine
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// ErrHistoryExhausted is returned when there is no history left to rewind.
+var ErrHistoryExhausted = errors.New("no more history")
+
+// EnableHistory records an undo log of retired instructions so that they
+// can be reverted with StepBack and ReverseContinue.
+//
+// The limit is the maximum number of instructions that can be reverted.
+// The oldest entries are discarded once it is exceeded. A limit of zero
+// means no limit, which is affordable because instructions only touch a
+// handful of registers and bytes.
+//
+// Loading a program or restoring a snapshot clears the history.
+func (m *Machine) EnableHistory(limit int) {
+	m.history = &history{limit: limit}
+	m.updateRecording()
+}
+
+// DisableHistory discards the undo log and stops recording it.
+func (m *Machine) DisableHistory() {
+	m.history = nil
+	m.updateRecording()
+}
+
+// StepBack reverts the most recently retired instruction.
+//
+// Only the registers, the memory and the IP are reverted. The performance
+// counters and the coverage are monotonic: they measure the work done by
+// the host, so they keep counting the reverted instructions.
+func (m *Machine) StepBack() error {
+	record, ok := m.history.pop()
+	if !ok {
+		return ErrHistoryExhausted
+	}
+
+	m.undo(&record)
+	return nil
+}
+
+// ReverseContinue reverts instructions until the IP reaches one of the
+// breakpoints. At least one instruction is reverted, so that repeated calls
+// keep moving backwards.
+//
+// If no breakpoint is reached, the machine is left at the oldest recorded
+// state and ErrHistoryExhausted is returned. Like StepBack, it does not
+// revert the performance counters or the coverage.
+func (m *Machine) ReverseContinue(breakpoints ...state.Address) error {
+	for {
+		if err := m.StepBack(); err != nil {
+			return err
+		}
+
+		if slices.Contains(breakpoints, m.ip) {
+			return nil
+		}
+	}
+}
+
+// LastWrite finds the most recent instruction in the history that wrote to
+// the given address. It reports false if there is none.
+func (m *Machine) LastWrite(address state.Address) (Record, bool) {
+	if m.history == nil {
+		return Record{}, false
+	}
+
+	records := m.history.entries()
+	for i := len(records) - 1; i >= 0; i-- {
+		for _, access := range records[i].Memory {
+			if access.Write && access.Address == address {
+				return records[i], true
+			}
+		}
+	}
+
+	return Record{}, false
+}
+
+// undo the effects of a retired instruction, in reverse program order.
+func (m *Machine) undo(record *Record) {
+	for i := len(record.Memory) - 1; i >= 0; i-- {
+		access := record.Memory[i]
+		if access.Write {
+			// Writes can only fail for addresses that could not be written
+			// in the first place.
+			_ = m.memory.WriteB(access.Address, access.Old)
+			m.cache.invalidate(access.Address)
+		}
+	}
+
+	for i := len(record.Registers) - 1; i >= 0; i-- {
+		write := record.Registers[i]
+		m.registers.Write(write.Register, write.Old)
+	}
+
+	m.ip = record.IP
+}
+
+// history is an undo log of retired instructions, oldest first.
+type history struct {
+	records []Record
+	limit   int
+}
+
+func (h *history) push(record *Record) {
+	if h.limit > 0 && len(h.records) >= 2*h.limit {
+		// Discard the oldest entries in bulk to keep pushes cheap.
+		n := copy(h.records, h.records[len(h.records)-h.limit:])
+		clear(h.records[n:])
+		h.records = h.records[:n]
+	}
+
+	// The record is reused by the machine, so we need our own copy.
+	h.records = append(h.records, Record{
+		IP:        record.IP,
+		Encoded:   record.Encoded,
+		Decoded:   record.Decoded,
+		Registers: slices.Clone(record.Registers),
+		Memory:    slices.Clone(record.Memory),
+	})
+}
+
+// entries returns the records that are within the limit, oldest first.
+func (h *history) entries() []Record {
+	if h.limit > 0 && len(h.records) > h.limit {
+		return h.records[len(h.records)-h.limit:]
+	}
+
+	return h.records
+}
+
+func (h *history) pop() (Record, bool) {
+	if h == nil || len(h.records) == 0 {
+		return Record{}, false
+	}
+
+	// Entries beyond the limit must not be reachable by rewinding.
+	if entries := h.entries(); len(entries) < len(h.records) {
+		n := copy(h.records, entries)
+		clear(h.records[n:])
+		h.records = h.records[:n]
+	}
+
+	last := len(h.records) - 1
+	record := h.records[last]
+	h.records[last] = Record{}
+	h.records = h.records[:last]
+	return record, true
+}
+
+func (h *history) clear() {
+	if h != nil {
+		h.records = h.records[:0]
+	}
+}
@@ -0,0 +1,103 @@
+package machine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_StepBack(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.EnableHistory(0)
+
+	// Remember the state before every step.
+	var states []string
+	for range testProgramSteps {
+		states = append(states, dump(m))
+		require.Success(t, m.Step())
+	}
+
+	// Rewinding must visit the same states in reverse order.
+	for i := len(states) - 1; i >= 0; i-- {
+		require.Success(t, m.StepBack())
+		expect.Equal(t, states[i], dump(m))
+	}
+
+	expect.Equal(t, true, errors.Is(m.StepBack(), ErrHistoryExhausted))
+}
+
+func TestMachine_StepBack_monotonic(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.EnableHistory(0)
+	m.EnableCoverage()
+	ip := m.ip
+	require.Success(t, m.Step())
+	require.Success(t, m.StepBack())
+
+	// The reverted instruction was still retired by the host.
+	expect.Equal(t, ip, m.ip)
+	expect.Equal(t, uint64(1), m.Counters().Retired)
+	expect.Equal(t, uint64(1), m.Coverage().Executed(ip))
+}
+
+func TestMachine_StepBack_limit(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.EnableHistory(2)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	require.Success(t, m.StepBack())
+	require.Success(t, m.StepBack())
+	expect.Equal(t, true, errors.Is(m.StepBack(), ErrHistoryExhausted))
+	expect.Equal(t, state.Address(0x8020), m.ip)
+}
+
+func TestMachine_ReverseContinue(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.EnableHistory(0)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	// Stops at the breakpoint.
+	require.Success(t, m.ReverseContinue(0x8008, 0x800c))
+	expect.Equal(t, state.Address(0x800c), m.ip)
+
+	// Moves past the breakpoint it is currently stopped at.
+	require.Success(t, m.ReverseContinue(0x8008, 0x800c))
+	expect.Equal(t, state.Address(0x8008), m.ip)
+
+	// Stops at the beginning of the history if there are no breakpoints.
+	expect.Equal(t, true, errors.Is(m.ReverseContinue(0x800c), ErrHistoryExhausted))
+	expect.Equal(t, state.Address(ProgramBase), m.ip)
+}
+
+func TestMachine_LastWrite(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.EnableHistory(0)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	record, ok := m.LastWrite(0x0101)
+	expect.Equal(t, true, ok)
+	expect.Equal(t, state.Address(0x8004), record.IP)
+
+	// Reads do not count as writes.
+	_, ok = m.LastWrite(0x0102)
+	expect.Equal(t, false, ok)
+}
+
+func TestMachine_Restore_clears_history(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	m.EnableHistory(0)
+	snapshot := m.Snapshot()
+	require.Success(t, m.Step())
+
+	m.Restore(snapshot)
+	expect.Equal(t, true, errors.Is(m.StepBack(), ErrHistoryExhausted))
+}
@@ -2,9 +2,14 @@
 package machine
 
 import (
+	"cmp"
 	"fmt"
 	"io"
+	"slices"
 
+	"github.com/jespert/primordial/hardware/internal/cpu"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/internal/image"
 	"github.com/jespert/primordial/hardware/r16/internal/isa"
 	"github.com/jespert/primordial/hardware/r16/internal/state"
 )
@@ -16,15 +21,61 @@ type Machine struct {
 
 	// Instruction pointer.
 	ip state.Address
+
+	// Optional memoisation of decoded instructions.
+	cache *decodeCache
+
+	// Decoded instruction when the cache is disabled.
+	uncached cacheEntry
+
+	// Performance counters and the cost model used to estimate cycles.
+	counters Counters
+	costs    CostModel
+
+	// Base address of the counters in memory, if they are mapped.
+	countersBase   state.Address
+	countersMapped bool
+
+	// Optional collection of code coverage.
+	coverage *Coverage
+
+	// Optional hook that observes every retired instruction.
+	tracer Tracer
+
+	// Optional undo log that enables reverse execution.
+	history *history
+
+	// Optional debug information of the loaded executable, used to
+	// symbolise backtraces. The symbols are sorted by address.
+	symbols []exe.Symbol
+	lines   exe.LineTable
+
+	// Effects of the instruction being executed. Only collected if there
+	// is a tracer or a history.
+	record    Record
+	recording bool
 }
 
 // New creates a new Machine.
 func New() *Machine {
 	return &Machine{
-		ip: ProgramBase,
+		ip:    ProgramBase,
+		cache: &decodeCache{},
+		costs: DefaultCostModel,
 	}
 }
 
+// SetTracer installs a tracer that observes every retired instruction.
+// A nil tracer disables tracing.
+func (m *Machine) SetTracer(tracer Tracer) {
+	m.tracer = tracer
+	m.updateRecording()
+}
+
+func (m *Machine) updateRecording() {
+	m.recording = m.tracer != nil || m.history != nil
+}
+
 // Dump the state in human-friendly string representation to the given writer.
 func (m *Machine) Dump(w io.Writer) {
 	// There is nothing we can do on IO failure, so we just ignore errors.
@@ -36,47 +87,391 @@ func (m *Machine) Dump(w io.Writer) {
 	m.memory.Dump(w)
 }
 
+// IP returns the instruction pointer.
+func (m *Machine) IP() state.Address {
+	return m.ip
+}
+
+// SetIP moves the instruction pointer.
+func (m *Machine) SetIP(ip state.Address) {
+	m.ip = ip
+}
+
+// Registers returns a copy of the register file.
+func (m *Machine) Registers() state.Registers {
+	return m.registers
+}
+
+// ReadMemory copies memory from the address into data, without the side
+// effects of memory-mapped devices.
+func (m *Machine) ReadMemory(address state.Address, data []byte) {
+	m.memory.ReadRaw(address, data)
+}
+
+// Step executes the instruction at the IP. If the instruction traps, the
+// error is a *Trap with a backtrace.
 func (m *Machine) Step() error {
-	encodedInstruction, err := m.fetchNextInstruction()
+	entry, err := m.fetchAndDecode()
 	if err != nil {
-		return err
+		return m.trap(err)
 	}
 
-	nextIP := m.ip + 2
-	instruction := isa.Decode(encodedInstruction)
+	instruction := &entry.decoded
+	if m.recording {
+		m.record = Record{
+			IP:        m.ip,
+			Encoded:   entry.encoded,
+			Decoded:   *instruction,
+			Registers: m.record.Registers[:0],
+			Memory:    m.record.Memory[:0],
+		}
+	}
 
-	switch instruction.Operation {
-	case isa.JAL:
-		returnPointer := nextIP
-		x := m.registers.Read(instruction.X)
-		nextIP = state.Address(x) + state.Address(instruction.Imm)
-		m.registers.Write(instruction.Z, uint16(returnPointer))
+	nextIP, err := m.execute(instruction)
+	if err != nil {
+		return m.trap(fmt.Errorf("failed to execute instruction at %04x: %w", m.ip, err))
 	}
 
 	m.ip = nextIP
+	if m.recording {
+		m.record.NextIP = nextIP
+	}
+
+	if m.history != nil {
+		m.history.push(&m.record)
+	}
+
+	if m.tracer != nil {
+		if err := m.tracer.Trace(&m.record); err != nil {
+			return fmt.Errorf("failed to trace instruction: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// execute the instruction and return the address of the next one.
+func (m *Machine) execute(instruction *isa.DecodedInstruction) (state.Address, error) {
+	nextIP := m.ip + instructionSize
+
+	y := m.registers.Read(instruction.Y)
+	x := m.registers.Read(instruction.X)
+	imm := instruction.Imm
+	address := state.Address(x + imm)
+	class := classALU
+
+	switch op := instruction.Operation; op {
+	case isa.ILLEGAL:
+		return 0, fmt.Errorf("illegal instruction")
+
+	case isa.JAL:
+		class = classJump
+		returnPointer := nextIP
+		nextIP = state.Address(x) + state.Address(imm)
+		m.writeRegister(instruction.Z, uint16(returnPointer))
+
+	case isa.BEQ, isa.BNE, isa.BLTS, isa.BGES, isa.BLTU, isa.BGEU:
+		class = classBranchNotTaken
+		if condition(op).Holds(x, y) {
+			class = classBranchTaken
+			nextIP = state.Address(imm)
+		}
+
+	case isa.STOREB:
+		class = classStore
+		if err := m.writeB(address, byte(y)); err != nil {
+			return 0, err
+		}
+
+	case isa.STOREH:
+		class = classStore
+		if err := m.writeH(address, int16(y)); err != nil {
+			return 0, err
+		}
+
+	case isa.LOADSB:
+		class = classLoad
+		v, err := m.readB(address)
+		if err != nil {
+			return 0, err
+		}
+		m.writeRegister(instruction.Z, uint16(int8(v)))
+
+	case isa.LOADUB:
+		class = classLoad
+		v, err := m.readB(address)
+		if err != nil {
+			return 0, err
+		}
+		m.writeRegister(instruction.Z, uint16(v))
+
+	case isa.LOADH:
+		class = classLoad
+		v, err := m.readH(address)
+		if err != nil {
+			return 0, err
+		}
+		m.writeRegister(instruction.Z, uint16(v))
+
+	case isa.SLTSI:
+		m.writeRegister(instruction.Z, cpu.SetIf(int16(x) < int16(imm)))
+
+	case isa.SLTUI:
+		m.writeRegister(instruction.Z, cpu.SetIf(x < imm))
+
+	case isa.SLTS:
+		m.writeRegister(instruction.Z, cpu.SetIf(int16(y) < int16(x)))
+
+	case isa.SLTU:
+		m.writeRegister(instruction.Z, cpu.SetIf(y < x))
+
+	case isa.ANDBI, isa.ORBI, isa.XORBI, isa.SRABI, isa.SRLBI, isa.SLLBI, isa.ADDBI:
+		m.writeRegister(instruction.Z, cpu.ALUB(uint16(op), x, imm))
+
+	case isa.ANDHI, isa.ORHI, isa.XORHI, isa.SRAHI, isa.SRLHI, isa.SLLHI, isa.ADDHI:
+		m.writeRegister(instruction.Z, cpu.ALUH(uint16(op), x, imm))
+
+	case isa.ANDB, isa.ORB, isa.XORB, isa.SRAB, isa.SRLB, isa.SLLB, isa.ADDB, isa.SUBB:
+		m.writeRegister(instruction.Z, cpu.ALUB(uint16(op), y, x))
+
+	case isa.ANDH, isa.ORH, isa.XORH, isa.SRAH, isa.SRLH, isa.SLLH, isa.ADDH, isa.SUBH:
+		m.writeRegister(instruction.Z, cpu.ALUH(uint16(op), y, x))
+
+	default:
+		return 0, fmt.Errorf("unknown operation: %04x", uint16(op))
+	}
+
+	m.counters.retire(class, &m.costs)
+	if m.coverage != nil {
+		m.coverage.record(m.ip, class)
+	}
+
+	return nextIP, nil
+}
+
+func (m *Machine) trap(err error) *Trap {
+	return &Trap{Trap: cpu.Trap{IP: m.ip, Err: err}, Backtrace: m.Backtrace()}
+}
+
+// LoadProgram copies raw code and data into memory. Any debug information
+// of a previously loaded executable is discarded.
 func (m *Machine) LoadProgram(base state.Address, data []byte) error {
 	if len(data)+int(base) > state.MemorySize {
 		return fmt.Errorf("program too large for memory: %d bytes", len(data))
 	}
 
-	m.memory.WriteRaw(ProgramBase, data)
+	m.memory.WriteRaw(base, data)
+	m.cache.clear()
+	m.history.clear()
+	m.symbols = nil
+	m.lines = nil
+	return nil
+}
+
+// LoadExecutable loads an r16 executable at the program base address,
+// clears its zero-initialised data and moves the IP to its entrypoint.
+// Its symbols and line table are kept to symbolise backtraces.
+func (m *Machine) LoadExecutable(f *exe.File) error {
+	h := f.Header
+	if h.Arch != exe.PackName("R16") || h.Endianness != exe.LittleEndian {
+		return fmt.Errorf("not a little-endian r16 executable: %s", h.Arch)
+	}
+
+	data := f.Segments()
+	if err := m.LoadProgram(ProgramBase, data); err != nil {
+		return err
+	}
+
+	zeroes := make([]byte, f.ZIDataSize)
+	if err := m.LoadProgram(ProgramBase+state.Address(len(data)), zeroes); err != nil {
+		return err
+	}
+
+	m.ip = state.Address(f.Entrypoint)
+	m.symbols = slices.SortedStableFunc(slices.Values(f.Symbols), func(x, y exe.Symbol) int {
+		return cmp.Compare(x.Address, y.Address)
+	})
+	m.lines = f.Lines
 	return nil
 }
 
+// LoadImage loads a memory image and moves the IP to its entrypoint, or to
+// the program base address if it has none.
+func (m *Machine) LoadImage(img *image.Image) {
+	m.memory.LoadImage(img)
+	m.cache.clear()
+	m.history.clear()
+	m.symbols = nil
+	m.lines = nil
+
+	m.ip = ProgramBase
+	if img.HasEntrypoint {
+		m.ip = state.Address(img.Entrypoint)
+	}
+}
+
+// fetchAndDecode the next instruction, using the cache if enabled.
+//
+// The result is returned by reference to avoid copying it on the hot path.
+// It is only valid until the next call.
+func (m *Machine) fetchAndDecode() (*cacheEntry, error) {
+	entry := &m.uncached
+	if m.cache != nil && m.ip%2 == 0 {
+		entry = m.cache.lookup(m.ip)
+		if entry.valid {
+			return entry, nil
+		}
+	}
+
+	encoded, err := m.fetchNextInstruction()
+	if err != nil {
+		return nil, err
+	}
+
+	*entry = cacheEntry{
+		encoded: encoded,
+		decoded: isa.Decode(encoded),
+		valid:   m.cache != nil,
+	}
+
+	return entry, nil
+}
+
 func (m *Machine) fetchNextInstruction() (isa.EncodedInstruction, error) {
-	if m.ip%2 != 0 {
-		return 0, fmt.Errorf("unaligned IP: %04x", m.ip)
+	v, err := cpu.Fetch(&m.memory, m.ip)
+	return isa.EncodedInstruction(v), err
+}
+
+func (m *Machine) writeRegister(register isa.Register, value uint16) {
+	if m.recording && register != isa.ZR {
+		m.record.Registers = append(m.record.Registers, RegisterWrite{
+			Register: register,
+			Old:      m.registers.Read(register),
+			New:      value,
+		})
+	}
+
+	m.registers.Write(register, value)
+}
+
+func (m *Machine) readB(address state.Address) (byte, error) {
+	var v byte
+	if m.countersMapped && address-m.countersBase < CountersSize {
+		v = m.counters.readB(address - m.countersBase)
+	} else {
+		var err error
+		v, err = m.memory.ReadB(address)
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	v, err := m.memory.ReadW(m.ip)
+	if m.recording {
+		m.record.Memory = append(m.record.Memory, MemoryAccess{
+			Address: address,
+			Old:     v,
+			New:     v,
+		})
+	}
+
+	return v, nil
+}
+
+// checkWrite returns an error if the byte at the address is read-only.
+func (m *Machine) checkWrite(address state.Address) error {
+	if m.countersMapped && address-m.countersBase < CountersSize {
+		return fmt.Errorf("write to read-only counter at %04x", address)
+	}
+
+	return nil
+}
+
+func (m *Machine) writeB(address state.Address, value byte) error {
+	if err := m.checkWrite(address); err != nil {
+		return err
+	}
+
+	var old byte
+	if m.recording {
+		var err error
+		old, err = m.memory.ReadB(address)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := m.memory.WriteB(address, value); err != nil {
+		return err
+	}
+
+	m.cache.invalidate(address)
+
+	if m.recording {
+		m.record.Memory = append(m.record.Memory, MemoryAccess{
+			Address: address,
+			Old:     old,
+			New:     value,
+			Write:   true,
+		})
+	}
+
+	return nil
+}
+
+// readH reads a little-endian halfword one byte at a time, so that every
+// byte touched is observable.
+func (m *Machine) readH(address state.Address) (int16, error) {
+	lo, err := m.readB(address)
+	if err != nil {
+		return 0, err
+	}
+
+	hi, err := m.readB(address + 1)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch instruction at %04x: %w", m.ip, err)
+		return 0, err
+	}
+
+	return int16(lo) | int16(hi)<<8, nil
+}
+
+// writeH writes a little-endian halfword one byte at a time, so that every
+// byte touched is observable. Both bytes are checked first, so that a
+// failed write leaves memory untouched.
+func (m *Machine) writeH(address state.Address, value int16) error {
+	if err := m.checkWrite(address); err != nil {
+		return err
+	}
+	if err := m.checkWrite(address + 1); err != nil {
+		return err
+	}
+
+	if err := m.writeB(address, byte(value)); err != nil {
+		return err
 	}
 
-	return isa.EncodedInstruction(v), nil
+	return m.writeB(address+1, byte(value>>8))
 }
 
-const ProgramBase = 0x8000
+// condition of a branch operation, which compares X with Y.
+func condition(op isa.Operation) cpu.Condition {
+	switch op {
+	case isa.BEQ:
+		return cpu.Equal
+	case isa.BNE:
+		return cpu.NotEqual
+	case isa.BLTS:
+		return cpu.LessSigned
+	case isa.BGES:
+		return cpu.GreaterEqualSigned
+	case isa.BLTU:
+		return cpu.LessUnsigned
+	default:
+		return cpu.GreaterEqualUnsigned
+	}
+}
+
+// ProgramBase is the address at which programs are loaded.
+const ProgramBase = isa.ProgramBase
+
+// All instructions are 32 bits long.
+const instructionSize = 4
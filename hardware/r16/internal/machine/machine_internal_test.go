@@ -30,6 +30,23 @@ func TestMachine_Step_JAL(t *testing.T) {
 	verify(t, m)
 }
 
+func TestMachine_Step_program(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verify(t, m)
+}
+
+func TestMachine_Step_illegal(t *testing.T) {
+	// Zero-initialised memory traps.
+	m := New()
+	if err := m.Step(); err == nil {
+		t.Error("expected illegal instruction to fail")
+	}
+}
+
 func verify(t *testing.T, m *Machine) {
 	t.Helper()
 	verifier := approval.NewTextVerifier(t)
@@ -37,7 +54,13 @@ func verify(t *testing.T, m *Machine) {
 	verifier.Verify()
 }
 
-func withProgram(t *testing.T, instructions ...isa.DecodedInstruction) *Machine {
+func withProgram(t testing.TB, instructions ...isa.DecodedInstruction) *Machine {
+	m := New()
+	require.Success(t, m.LoadProgram(ProgramBase, encodeProgram(instructions)))
+	return m
+}
+
+func encodeProgram(instructions []isa.DecodedInstruction) []byte {
 	var buffer bytes.Buffer
 	for _, instruction := range instructions {
 		encoded := isa.Encode(instruction)
@@ -47,7 +70,28 @@ func withProgram(t *testing.T, instructions ...isa.DecodedInstruction) *Machine
 		buffer.WriteByte(byte(encoded >> 24))
 	}
 
-	m := New()
-	require.Success(t, m.LoadProgram(0, buffer.Bytes()))
-	return m
+	return buffer.Bytes()
+}
+
+// A short program that exercises every class of operation.
+var testProgram = []isa.DecodedInstruction{
+	// 8000: Materialise a constant.
+	{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 0x1234},
+	// 8004: Store it and load back its most significant byte.
+	{Operation: isa.STOREH, Y: isa.A0, X: isa.ZR, Imm: 0x0100},
+	{Operation: isa.LOADSB, Z: isa.A1, X: isa.ZR, Imm: 0x0101},
+	// 800c: Byte arithmetic on registers.
+	{Operation: isa.ADDB, Z: isa.A2, Y: isa.A0, X: isa.A1},
+	// 8010: Skip the next instruction.
+	{Operation: isa.BNE, Y: isa.ZR, X: isa.A2, Imm: 0x8018},
+	{Operation: isa.ADDHI, Z: isa.T0, X: isa.ZR, Imm: 1},
+	// 8018: Call.
+	{Operation: isa.JAL, Z: isa.RP, X: isa.ZR, Imm: 0x8020},
+	{Operation: isa.ILLEGAL},
+	// 8020: Halfword arithmetic on registers.
+	{Operation: isa.SUBH, Z: isa.A3, Y: isa.A1, X: isa.A0},
+	{Operation: isa.SLTS, Z: isa.T1, Y: isa.A3, X: isa.ZR},
 }
+
+// Number of instructions retired by testProgram.
+const testProgramSteps = 8
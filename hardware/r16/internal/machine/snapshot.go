@@ -0,0 +1,127 @@
+package machine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Snapshot of the architectural state of a machine (registers, memory, and
+// instruction pointer).
+//
+// Snapshots are independent copies: modifying the machine does not modify
+// its snapshots and vice versa.
+type Snapshot struct {
+	memory    state.Memory
+	registers state.Registers
+	ip        state.Address
+}
+
+// Snapshot takes a checkpoint of the state of the machine.
+func (m *Machine) Snapshot() *Snapshot {
+	return &Snapshot{
+		memory:    m.memory,
+		registers: m.registers,
+		ip:        m.ip,
+	}
+}
+
+// Restore the state of the machine from a checkpoint.
+// Hooks, such as the tracer, are left untouched, but the history is
+// cleared because it no longer leads to the current state.
+func (m *Machine) Restore(s *Snapshot) {
+	m.memory = s.memory
+	m.registers = s.registers
+	m.ip = s.ip
+	m.cache.clear()
+	m.history.clear()
+}
+
+// DumpDiff shows how the state changed since a previous snapshot: the
+// instruction pointer, the registers, and the lines of memory that differ.
+func (s *Snapshot) DumpDiff(w io.Writer, previous *Snapshot) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	if s.ip == previous.ip {
+		_, _ = fmt.Fprintf(w, "ip: 0x%04x\n", s.ip)
+	} else {
+		_, _ = fmt.Fprintf(w, "ip: 0x%04x -> 0x%04x\n", previous.ip, s.ip)
+	}
+
+	_, _ = fmt.Fprint(w, "\nregisters:\n")
+	s.registers.DumpDiff(w, &previous.registers)
+
+	_, _ = fmt.Fprint(w, "\nmemory:\n")
+	s.memory.DumpDiff(w, &previous.memory)
+}
+
+// WriteTo writes the snapshot in the save-state format.
+//
+// The format starts with the magic "R16S" followed by a version byte.
+// The rest of version 1 is encoded in little-endian as:
+//
+//	ip        U16
+//	registers U16[16]
+//	memory    U8[65536]
+//
+// ZR is included to keep the register file trivially indexable,
+// but it must be zero.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, saveStateSize)
+	buf = append(buf, saveStateHeader[:]...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(s.ip))
+	for i := range state.NumRegisters {
+		buf = binary.LittleEndian.AppendUint16(buf, s.registers.Read(isa.Register(i)))
+	}
+
+	memory := buf[len(buf) : len(buf)+state.MemorySize]
+	s.memory.ReadRaw(0, memory)
+	buf = buf[:len(buf)+state.MemorySize]
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadSnapshot reads a snapshot in the save-state format.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	buf := make([]byte, saveStateSize)
+	if _, err := io.ReadFull(r, buf[:len(saveStateHeader)]); err != nil {
+		return nil, fmt.Errorf("failed to read save-state header: %w", err)
+	}
+
+	if magic := buf[:4]; string(magic) != string(saveStateHeader[:4]) {
+		return nil, fmt.Errorf("invalid save-state magic: % x", magic)
+	}
+
+	if version := buf[4]; version != saveStateHeader[4] {
+		return nil, fmt.Errorf("unsupported save-state version: %d", version)
+	}
+
+	if _, err := io.ReadFull(r, buf[len(saveStateHeader):]); err != nil {
+		return nil, fmt.Errorf("failed to read save-state: %w", err)
+	}
+
+	var s Snapshot
+	data := buf[len(saveStateHeader):]
+	s.ip = state.Address(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+
+	if zr := binary.LittleEndian.Uint16(data); zr != 0 {
+		return nil, fmt.Errorf("invalid save-state: non-zero ZR: 0x%04x", zr)
+	}
+
+	for i := 1; i < state.NumRegisters; i++ {
+		s.registers.Write(isa.Register(i), binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	data = data[2*state.NumRegisters:]
+
+	s.memory.WriteRaw(0, data)
+	return &s, nil
+}
+
+// Version 1 of the save-state format.
+var saveStateHeader = [5]byte{'R', '1', '6', 'S', 1}
+
+const saveStateSize = len(saveStateHeader) + 2 + 2*state.NumRegisters + state.MemorySize
@@ -0,0 +1,93 @@
+package machine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_Restore(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	require.Success(t, m.Step())
+	snapshot := m.Snapshot()
+	before := dump(m)
+
+	// Modifying the machine must not affect the snapshot.
+	for range testProgramSteps - 1 {
+		require.Success(t, m.Step())
+	}
+
+	m.Restore(snapshot)
+	expect.Equal(t, before, dump(m))
+
+	// Modifying the restored machine must not affect the snapshot either.
+	require.Success(t, m.Step())
+	m.Restore(snapshot)
+	expect.Equal(t, before, dump(m))
+}
+
+func TestSnapshot_DumpDiff(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	before := m.Snapshot()
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verifier := approval.NewTextVerifier(t)
+	m.Snapshot().DumpDiff(verifier.Writer(), before)
+	verifier.Verify()
+}
+
+func TestSnapshot_WriteTo_round_trip(t *testing.T) {
+	m := withProgram(t, testProgram...)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	var saved bytes.Buffer
+	n, err := m.Snapshot().WriteTo(&saved)
+	require.Success(t, err)
+	expect.Equal(t, int64(saveStateSize), n)
+	expect.Equal(t, saveStateSize, saved.Len())
+
+	snapshot, err := ReadSnapshot(&saved)
+	require.Success(t, err)
+
+	restored := New()
+	restored.Restore(snapshot)
+	expect.Equal(t, dump(m), dump(restored))
+}
+
+func TestReadSnapshot_invalid(t *testing.T) {
+	var valid bytes.Buffer
+	_, err := New().Snapshot().WriteTo(&valid)
+	require.Success(t, err)
+
+	testCases := []struct {
+		name   string
+		mutate func(data []byte) []byte
+	}{
+		{"magic", func(data []byte) []byte { data[0] = 'X'; return data }},
+		{"version", func(data []byte) []byte { data[4] = 2; return data }},
+		{"ZR", func(data []byte) []byte { data[7] = 1; return data }},
+		{"truncated", func(data []byte) []byte { return data[:len(data)-1] }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.mutate(bytes.Clone(valid.Bytes()))
+			if _, err := ReadSnapshot(bytes.NewReader(data)); err == nil {
+				t.Error("expected invalid save-state to fail")
+			}
+		})
+	}
+}
+
+func dump(m *Machine) string {
+	var buffer bytes.Buffer
+	m.Dump(&buffer)
+	return buffer.String()
+}
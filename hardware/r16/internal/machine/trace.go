@@ -0,0 +1,246 @@
+package machine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Tracer observes the effects of every retired instruction.
+//
+// The record is only valid for the duration of the call, so tracers must
+// copy anything they want to keep.
+type Tracer interface {
+	Trace(record *Record) error
+}
+
+// Record describes the effects of a retired instruction.
+type Record struct {
+	// Address of the instruction.
+	IP state.Address
+
+	Encoded isa.EncodedInstruction
+	Decoded isa.DecodedInstruction
+
+	// Address of the next instruction. It is not stored in binary traces,
+	// where it is the IP of the following record.
+	NextIP state.Address
+
+	// Registers written, in program order. Writes to ZR are discarded by
+	// the hardware, so they are not recorded.
+	Registers []RegisterWrite
+
+	// Memory bytes touched, in program order.
+	Memory []MemoryAccess
+}
+
+// RegisterWrite describes the change of value of a register.
+type RegisterWrite struct {
+	Register isa.Register
+	Old      uint16
+	New      uint16
+}
+
+// MemoryAccess describes a single byte read or written.
+// Reads have identical old and new values.
+type MemoryAccess struct {
+	Address state.Address
+	Old     byte
+	New     byte
+	Write   bool
+}
+
+// TextTracer writes a human-friendly trace that can be diffed and used in
+// golden files.
+//
+// Each instruction is printed on its own line, followed by one indented
+// line per effect:
+//
+//	8000  8e1a1234  jal %rp, %a0, 0x1234
+//	      %rp: 0x0000 -> 0x8004
+//	      [1234] R 0x12
+//	      [1235] W 0x00 -> 0x34
+type TextTracer struct {
+	w io.Writer
+}
+
+// NewTextTracer creates a tracer that writes text to the given writer.
+func NewTextTracer(w io.Writer) *TextTracer {
+	return &TextTracer{w: w}
+}
+
+func (t *TextTracer) Trace(record *Record) error {
+	_, err := fmt.Fprintf(
+		t.w,
+		"%04x  %08x  %s\n",
+		record.IP,
+		uint32(record.Encoded),
+		isa.Disassemble(record.Decoded),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range record.Registers {
+		_, err := fmt.Fprintf(t.w, "      %%%s: 0x%04x -> 0x%04x\n", r.Register, r.Old, r.New)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range record.Memory {
+		var err error
+		if m.Write {
+			_, err = fmt.Fprintf(t.w, "      [%04x] W 0x%02x -> 0x%02x\n", m.Address, m.Old, m.New)
+		} else {
+			_, err = fmt.Fprintf(t.w, "      [%04x] R 0x%02x\n", m.Address, m.Old)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BinaryTracer writes a compact binary trace.
+//
+// The trace starts with a header made of the magic "R16T" followed by a
+// version byte. Each record is then encoded in little-endian as:
+//
+//	ip            U16
+//	encoded       U32
+//	num_registers U8
+//	num_memory    U8
+//	registers     (register U8, old U16, new U16)[num_registers]
+//	memory        (address U16, write U8, old U8, new U8)[num_memory]
+//
+// The decoded instruction is not stored because it can be recomputed.
+type BinaryTracer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewBinaryTracer creates a tracer that writes the binary format to the
+// given writer. The header is written immediately.
+func NewBinaryTracer(w io.Writer) (*BinaryTracer, error) {
+	if _, err := w.Write(binaryTraceHeader[:]); err != nil {
+		return nil, err
+	}
+
+	return &BinaryTracer{w: w}, nil
+}
+
+func (t *BinaryTracer) Trace(record *Record) error {
+	// Each instruction touches very few registers and bytes, so the counts
+	// fit comfortably in a byte.
+	numRegisters := len(record.Registers)
+	numMemory := len(record.Memory)
+	if numRegisters > 0xff || numMemory > 0xff {
+		return fmt.Errorf("too many effects at %04x", record.IP)
+	}
+
+	buf := t.buf[:0]
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(record.IP))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(record.Encoded))
+	buf = append(buf, byte(numRegisters), byte(numMemory))
+
+	for _, r := range record.Registers {
+		buf = append(buf, byte(r.Register))
+		buf = binary.LittleEndian.AppendUint16(buf, r.Old)
+		buf = binary.LittleEndian.AppendUint16(buf, r.New)
+	}
+
+	for _, m := range record.Memory {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(m.Address))
+		var write byte
+		if m.Write {
+			write = 1
+		}
+		buf = append(buf, write, m.Old, m.New)
+	}
+
+	t.buf = buf
+	_, err := t.w.Write(buf)
+	return err
+}
+
+// BinaryTraceReader reads traces written by BinaryTracer.
+type BinaryTraceReader struct {
+	r *bufio.Reader
+}
+
+// NewBinaryTraceReader creates a reader and validates the trace header.
+func NewBinaryTraceReader(r io.Reader) (*BinaryTraceReader, error) {
+	br := bufio.NewReader(r)
+
+	var header [len(binaryTraceHeader)]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read trace header: %w", err)
+	}
+
+	if header != binaryTraceHeader {
+		return nil, fmt.Errorf("invalid trace header: % x", header)
+	}
+
+	return &BinaryTraceReader{r: br}, nil
+}
+
+// Read the next record. Returns io.EOF when there are no more records.
+func (t *BinaryTraceReader) Read() (Record, error) {
+	var fixed [8]byte
+	if _, err := io.ReadFull(t.r, fixed[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, fmt.Errorf("truncated trace record: %w", err)
+		}
+		return Record{}, err
+	}
+
+	encoded := isa.EncodedInstruction(binary.LittleEndian.Uint32(fixed[2:]))
+	record := Record{
+		IP:      state.Address(binary.LittleEndian.Uint16(fixed[0:])),
+		Encoded: encoded,
+		Decoded: isa.Decode(encoded),
+	}
+
+	numRegisters := int(fixed[6])
+	numMemory := int(fixed[7])
+	effects := make([]byte, 5*numRegisters+5*numMemory)
+	if _, err := io.ReadFull(t.r, effects); err != nil {
+		return Record{}, fmt.Errorf("truncated trace record at %04x: %w", record.IP, err)
+	}
+
+	for i := range numRegisters {
+		e := effects[5*i:]
+		register := isa.Register(e[0])
+		if register >= state.NumRegisters {
+			return Record{}, fmt.Errorf("invalid register in trace at %04x: %d", record.IP, e[0])
+		}
+
+		record.Registers = append(record.Registers, RegisterWrite{
+			Register: register,
+			Old:      binary.LittleEndian.Uint16(e[1:]),
+			New:      binary.LittleEndian.Uint16(e[3:]),
+		})
+	}
+
+	for i := range numMemory {
+		e := effects[5*numRegisters+5*i:]
+		record.Memory = append(record.Memory, MemoryAccess{
+			Address: state.Address(binary.LittleEndian.Uint16(e[0:])),
+			Write:   e[2] != 0,
+			Old:     e[3],
+			New:     e[4],
+		})
+	}
+
+	return record, nil
+}
+
+// Version 1 of the binary trace format.
+var binaryTraceHeader = [5]byte{'R', '1', '6', 'T', 1}
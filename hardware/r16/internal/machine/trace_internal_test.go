@@ -0,0 +1,66 @@
+package machine
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestTextTracer(t *testing.T) {
+	verifier := approval.NewTextVerifier(t)
+	m := withProgram(t, testProgram...)
+	m.SetTracer(NewTextTracer(verifier.Writer()))
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verifier.Verify()
+}
+
+func TestBinaryTracer_round_trip(t *testing.T) {
+	// Trace the same program in both formats.
+	var text bytes.Buffer
+	m := withProgram(t, testProgram...)
+	m.SetTracer(NewTextTracer(&text))
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	var binary bytes.Buffer
+	tracer, err := NewBinaryTracer(&binary)
+	require.Success(t, err)
+	m = withProgram(t, testProgram...)
+	m.SetTracer(tracer)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	// Converting the binary trace to text must yield the same result.
+	reader, err := NewBinaryTraceReader(&binary)
+	require.Success(t, err)
+
+	var converted bytes.Buffer
+	textTracer := NewTextTracer(&converted)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.Success(t, err)
+		require.Success(t, textTracer.Trace(&record))
+	}
+
+	expect.Equal(t, text.String(), converted.String())
+}
+
+func TestNewBinaryTraceReader_invalid_header(t *testing.T) {
+	_, err := NewBinaryTraceReader(bytes.NewReader([]byte("R16X\x01")))
+	if err == nil {
+		t.Error("expected invalid header to fail")
+	}
+}
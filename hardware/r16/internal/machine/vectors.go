@@ -0,0 +1,85 @@
+package machine
+
+import (
+	"fmt"
+	"io"
+)
+
+// VectorTracer writes test vectors that let HDL testbenches check an
+// implementation of r16 against the machine, one retired instruction at a
+// time.
+//
+// Each instruction is a line of nine hexadecimal fields of fixed width,
+// separated by single spaces, so that testbenches can parse them with
+// $fscanf or textio:
+//
+//	ip        4 digits  Address of the instruction.
+//	encoded   8 digits  Encoded instruction.
+//	next_ip   4 digits  Address of the next instruction.
+//	reg_we    1 digit   1 if a register is written, otherwise 0.
+//	reg       1 digit   Register written, or 0.
+//	reg_data  4 digits  Value written to the register, or 0.
+//	mem_be    1 digit   Byte enables of the halfword written at mem_addr:
+//	                    bit 0 for mem_addr and bit 1 for mem_addr+1.
+//	mem_addr  4 digits  Address of the first byte written, or 0.
+//	mem_data  4 digits  Halfword written, in little-endian, or 0.
+//
+// For example, a halfword store followed by a call:
+//
+//	8004 51a00100 8008 0 0 0000 3 0100 1234
+//	8018 8e108020 8020 1 e 801c 0 0000 0000
+//
+// Writes to ZR are discarded by the hardware, so they are not written
+// either. Memory that is initialised before the first instruction can be
+// exported with package image in $readmemh format.
+type VectorTracer struct {
+	w io.Writer
+}
+
+// NewVectorTracer creates a tracer that writes test vectors to the writer.
+func NewVectorTracer(w io.Writer) *VectorTracer {
+	return &VectorTracer{w: w}
+}
+
+func (t *VectorTracer) Trace(record *Record) error {
+	var regWE, reg, regValue uint16
+	switch len(record.Registers) {
+	case 0:
+	case 1:
+		r := record.Registers[0]
+		regWE, reg, regValue = 1, uint16(r.Register), r.New
+	default:
+		return fmt.Errorf("too many register writes for a test vector at %04x", record.IP)
+	}
+
+	var memBE, memAddress, memData uint16
+	for _, m := range record.Memory {
+		if !m.Write {
+			continue
+		}
+
+		switch {
+		case memBE == 0:
+			memBE, memAddress, memData = 1, uint16(m.Address), uint16(m.New)
+		case memBE == 1 && uint16(m.Address) == memAddress+1:
+			memBE, memData = 3, memData|uint16(m.New)<<8
+		default:
+			return fmt.Errorf("memory writes do not fit in a test vector at %04x", record.IP)
+		}
+	}
+
+	_, err := fmt.Fprintf(
+		t.w,
+		"%04x %08x %04x %x %x %04x %x %04x %04x\n",
+		record.IP,
+		uint32(record.Encoded),
+		record.NextIP,
+		regWE,
+		reg,
+		regValue,
+		memBE,
+		memAddress,
+		memData,
+	)
+	return err
+}
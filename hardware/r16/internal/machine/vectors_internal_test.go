@@ -0,0 +1,39 @@
+package machine
+
+import (
+	"io"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestVectorTracer(t *testing.T) {
+	verifier := approval.NewTextVerifier(t)
+	m := withProgram(t, testProgram...)
+	m.SetTracer(NewVectorTracer(verifier.Writer()))
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verifier.Verify()
+}
+
+func TestVectorTracer_invalid(t *testing.T) {
+	testCases := map[string]Record{
+		"registers": {Registers: []RegisterWrite{{Register: isa.A0}, {Register: isa.A1}}},
+		"memory": {Memory: []MemoryAccess{
+			{Address: 0x0100, Write: true},
+			{Address: 0x0102, Write: true},
+		}},
+	}
+
+	for name, record := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if err := NewVectorTracer(io.Discard).Trace(&record); err == nil {
+				t.Error("expected record that does not fit to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// WriteTo writes the profile in the gzipped protocol buffer format of
+// pprof, which `go tool pprof` can read.
+//
+// The only sample type is the number of instructions retired.
+func (p *Profiler) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	zw := gzip.NewWriter(cw)
+	if _, err := zw.Write(p.encode()); err != nil {
+		return cw.n, err
+	}
+
+	err := zw.Close()
+	return cw.n, err
+}
+
+// encode the profile as a perftools.profiles.Profile message.
+func (p *Profiler) encode() []byte {
+	var strings stringTable
+	strings.id("")
+
+	var b protoBuffer
+
+	// Profile.sample_type
+	b.message(1, func(b *protoBuffer) {
+		b.int64(1, strings.id("instructions"))
+		b.int64(2, strings.id("count"))
+	})
+
+	// Locations and functions are identified by their position, starting
+	// at 1, because 0 is reserved.
+	locations := make(map[uint64]uint64)
+	functions := make(map[string]uint64)
+	var locationOrder []uint64
+	var functionOrder []string
+
+	// Profile.sample
+	for _, key := range slices.Sorted(maps.Keys(p.counts)) {
+		var ids []uint64
+		for _, address := range decodeKey(key) {
+			a := uint64(address)
+			if _, ok := locations[a]; !ok {
+				locations[a] = uint64(len(locations) + 1)
+				locationOrder = append(locationOrder, a)
+			}
+			ids = append(ids, locations[a])
+		}
+
+		b.message(2, func(b *protoBuffer) {
+			b.packedUint64(1, ids)
+			b.packedUint64(2, []uint64{uint64(p.counts[key])})
+		})
+	}
+
+	// Profile.mapping: the whole address space.
+	b.message(3, func(b *protoBuffer) {
+		b.uint64(1, 1)
+		b.uint64(3, 1<<16)
+		b.bool(7, true)
+	})
+
+	// Profile.location
+	for _, address := range locationOrder {
+		name := p.function(state.Address(address))
+		if _, ok := functions[name]; !ok {
+			functions[name] = uint64(len(functions) + 1)
+			functionOrder = append(functionOrder, name)
+		}
+
+		b.message(4, func(b *protoBuffer) {
+			b.uint64(1, locations[address])
+			b.uint64(2, 1)
+			b.uint64(3, address)
+			b.message(4, func(b *protoBuffer) {
+				b.uint64(1, functions[name])
+			})
+		})
+	}
+
+	// Profile.function
+	for _, name := range functionOrder {
+		b.message(5, func(b *protoBuffer) {
+			b.uint64(1, functions[name])
+			b.int64(2, strings.id(name))
+			b.int64(3, strings.id(name))
+		})
+	}
+
+	// Profile.period_type and Profile.period
+	b.message(11, func(b *protoBuffer) {
+		b.int64(1, strings.id("instructions"))
+		b.int64(2, strings.id("count"))
+	})
+	b.int64(12, 1)
+
+	// Profile.string_table goes last because it is built along the way.
+	// Unlike other fields, empty strings must be present.
+	for _, s := range strings.values {
+		b.bytes(6, []byte(s))
+	}
+
+	return b.data
+}
+
+// stringTable deduplicates strings, preserving insertion order.
+type stringTable struct {
+	values []string
+	ids    map[string]int64
+}
+
+func (t *stringTable) id(s string) int64 {
+	if t.ids == nil {
+		t.ids = make(map[string]int64)
+	}
+
+	if id, ok := t.ids[s]; ok {
+		return id
+	}
+
+	id := int64(len(t.values))
+	t.ids[s] = id
+	t.values = append(t.values, s)
+	return id
+}
+
+// protoBuffer encodes protocol buffer messages. Zero scalars are omitted,
+// as in proto3.
+type protoBuffer struct {
+	data []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) tag(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x != 0 {
+		b.tag(field, wireVarint)
+		b.varint(x)
+	}
+}
+
+func (b *protoBuffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protoBuffer) bool(field int, x bool) {
+	if x {
+		b.uint64(field, 1)
+	}
+}
+
+func (b *protoBuffer) bytes(field int, x []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(x)))
+	b.data = append(b.data, x...)
+}
+
+func (b *protoBuffer) packedUint64(field int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, packed.data)
+}
+
+func (b *protoBuffer) message(field int, fn func(b *protoBuffer)) {
+	var nested protoBuffer
+	fn(&nested)
+	b.bytes(field, nested.data)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
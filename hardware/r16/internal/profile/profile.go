@@ -0,0 +1,152 @@
+// Package profile attributes the instructions retired by r16 guests to
+// their functions.
+//
+// Profiles are exact rather than sampled: every retired instruction is
+// counted. Call stacks are reconstructed from the link register of jal:
+// linking to RP is a call and linking to T0 is a millicode call. A frame
+// ends when execution reaches its return address, regardless of which
+// instruction got it there.
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+)
+
+// Profiler collects a profile by observing retired instructions.
+// It can be installed as the tracer of a machine.
+type Profiler struct {
+	// Function symbols sorted by address.
+	symbols []exe.Symbol
+
+	// Current call stack, outermost first.
+	stack []frame
+
+	// Number of instructions retired by call stack. The key is the
+	// sequence of addresses of the stack, innermost first.
+	counts map[string]int64
+}
+
+var _ machine.Tracer = &Profiler{}
+
+type frame struct {
+	callSite      state.Address
+	returnAddress state.Address
+}
+
+// New creates a profiler that symbolizes functions with the given symbols.
+// Only symbols of type function are used.
+func New(symbols []exe.Symbol) *Profiler {
+	var functions []exe.Symbol
+	for _, s := range symbols {
+		if s.Type == exe.SymbolFunction {
+			functions = append(functions, s)
+		}
+	}
+
+	sort.SliceStable(functions, func(i, j int) bool {
+		return functions[i].Address < functions[j].Address
+	})
+
+	return &Profiler{
+		symbols: functions,
+		counts:  make(map[string]int64),
+	}
+}
+
+func (p *Profiler) Trace(record *machine.Record) error {
+	// Returning to a caller ends the frame of the callee, and also those of
+	// any frames it did not return from.
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].returnAddress == record.IP {
+			p.stack = p.stack[:i]
+			break
+		}
+	}
+
+	p.counts[p.key(record.IP)]++
+
+	instruction := record.Decoded
+	if instruction.Operation == isa.JAL && (instruction.Z == isa.RP || instruction.Z == isa.T0) {
+		// Guests that never return would grow the stack without bounds.
+		if len(p.stack) == maxDepth {
+			p.stack = slices.Delete(p.stack, 0, 1)
+		}
+
+		p.stack = append(p.stack, frame{
+			callSite:      record.IP,
+			returnAddress: record.IP + 4,
+		})
+	}
+
+	return nil
+}
+
+// key encodes the call stack of the instruction as a map key.
+func (p *Profiler) key(ip state.Address) string {
+	var b strings.Builder
+	b.WriteByte(byte(ip))
+	b.WriteByte(byte(ip >> 8))
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		b.WriteByte(byte(p.stack[i].callSite))
+		b.WriteByte(byte(p.stack[i].callSite >> 8))
+	}
+
+	return b.String()
+}
+
+// stack decodes a map key into addresses, innermost first.
+func decodeKey(key string) []state.Address {
+	addresses := make([]state.Address, len(key)/2)
+	for i := range addresses {
+		addresses[i] = state.Address(key[2*i]) | state.Address(key[2*i+1])<<8
+	}
+
+	return addresses
+}
+
+// function returns the name of the function that contains the address.
+func (p *Profiler) function(address state.Address) string {
+	if s, ok := exe.SymbolAt(p.symbols, uint16(address), exe.SymbolFunction); ok {
+		return s.Name
+	}
+
+	return fmt.Sprintf("0x%04x", address)
+}
+
+// WriteFolded writes the profile in the folded stacks format used by flame
+// graph tools. Each line contains the functions of a call stack, outermost
+// first and separated by semicolons, followed by the number of
+// instructions retired.
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	folded := make(map[string]int64)
+	for key, count := range p.counts {
+		addresses := decodeKey(key)
+		names := make([]string, len(addresses))
+		for i, address := range addresses {
+			names[len(names)-1-i] = p.function(address)
+		}
+
+		folded[strings.Join(names, ";")] += count
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, stack := range slices.Sorted(maps.Keys(folded)) {
+		_, _ = fmt.Fprintf(bw, "%s %d\n", stack, folded[stack])
+	}
+
+	return bw.Flush()
+}
+
+// Deep enough for any reasonable guest.
+const maxDepth = 1024
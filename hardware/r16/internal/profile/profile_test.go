@@ -0,0 +1,278 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/hardware/r16/internal/profile"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestProfiler_WriteFolded(t *testing.T) {
+	p := run(t)
+
+	verifier := approval.NewTextVerifier(t)
+	require.Success(t, p.WriteFolded(verifier.Writer()))
+	verifier.Verify()
+}
+
+func TestProfiler_WriteTo(t *testing.T) {
+	p := run(t)
+
+	var buffer bytes.Buffer
+	n, err := p.WriteTo(&buffer)
+	require.Success(t, err)
+	expect.Equal(t, int64(buffer.Len()), n)
+
+	decoded := decodeProfile(t, &buffer)
+	expect.Equal(t, "instructions count", decoded.sampleType)
+
+	// Samples are by address, so several of them can have the same
+	// functions. Their stacks are innermost first.
+	counts := make(map[string]int64)
+	for _, sample := range decoded.samples {
+		counts[strings.Join(sample.stack, ";")] += sample.count
+	}
+
+	expected := map[string]int64{
+		"0x8000":        1,
+		"main":          3,
+		"loop;main":     10,
+		"inc;loop;main": 6,
+	}
+	expect.Equal(t, len(expected), len(counts))
+	for stack, count := range expected {
+		expect.Equal(t, count, counts[stack])
+	}
+}
+
+func run(t *testing.T) *profile.Profiler {
+	t.Helper()
+
+	// The data symbol must be ignored, and the prologue is not in any
+	// function.
+	symbols := []exe.Symbol{
+		{Name: "main", Address: 0x8004, Type: exe.SymbolFunction},
+		{Name: "loop", Address: 0x8010, Type: exe.SymbolFunction},
+		{Name: "inc", Address: 0x8020, Type: exe.SymbolFunction},
+		{Name: "data", Address: 0x8024, Type: exe.SymbolObject},
+	}
+
+	program := []isa.DecodedInstruction{
+		// 8000: Prologue.
+		{Operation: isa.ADDHI, Z: isa.A0, X: isa.ZR, Imm: 3},
+		// 8004: main calls loop and then hangs.
+		{Operation: isa.JAL, Z: isa.RP, X: isa.ZR, Imm: 0x8010},
+		{Operation: isa.JAL, Z: isa.ZR, X: isa.ZR, Imm: 0x8008},
+		{Operation: isa.ILLEGAL},
+		// 8010: loop calls the millicode inc %a0 times.
+		{Operation: isa.JAL, Z: isa.T0, X: isa.ZR, Imm: 0x8020},
+		{Operation: isa.ADDHI, Z: isa.A0, X: isa.A0, Imm: 0xffff},
+		{Operation: isa.BNE, Y: isa.ZR, X: isa.A0, Imm: 0x8010},
+		{Operation: isa.JAL, Z: isa.ZR, X: isa.RP, Imm: 0},
+		// 8020: inc increments %a1.
+		{Operation: isa.ADDHI, Z: isa.A1, X: isa.A1, Imm: 1},
+		{Operation: isa.JAL, Z: isa.ZR, X: isa.T0, Imm: 0},
+	}
+
+	var code bytes.Buffer
+	for _, instruction := range program {
+		encoded := isa.Encode(instruction)
+		code.Write([]byte{
+			byte(encoded),
+			byte(encoded >> 8),
+			byte(encoded >> 16),
+			byte(encoded >> 24),
+		})
+	}
+
+	m := machine.New()
+	require.Success(t, m.LoadProgram(machine.ProgramBase, code.Bytes()))
+
+	p := profile.New(symbols)
+	m.SetTracer(p)
+
+	// Run until main hangs, plus a couple of iterations.
+	for range 2 + 3*5 + 1 + 2 {
+		require.Success(t, m.Step())
+	}
+
+	return p
+}
+
+// decodedProfile has the parts of a pprof profile that the tests check.
+type decodedProfile struct {
+	// Type and unit of the only sample type, separated by a space.
+	sampleType string
+
+	samples []decodedSample
+}
+
+type decodedSample struct {
+	// Function names, innermost first.
+	stack []string
+	count int64
+}
+
+// decodeProfile decodes the few fields of a gzipped
+// perftools.profiles.Profile message that the tests check.
+func decodeProfile(t *testing.T, r io.Reader) decodedProfile {
+	t.Helper()
+
+	zr, err := gzip.NewReader(r)
+	require.Success(t, err)
+	data, err := io.ReadAll(zr)
+	require.Success(t, err)
+
+	var strs []string
+	var sampleTypes, samples [][]byte
+	functionNames := make(map[uint64]uint64)
+	locationFunctions := make(map[uint64]uint64)
+	for _, f := range decodeMessage(t, data) {
+		switch f.number {
+		case 1: // Profile.sample_type
+			sampleTypes = append(sampleTypes, f.bytes)
+
+		case 2: // Profile.sample
+			samples = append(samples, f.bytes)
+
+		case 4: // Profile.location
+			var id, function uint64
+			for _, lf := range decodeMessage(t, f.bytes) {
+				switch lf.number {
+				case 1:
+					id = lf.varint
+				case 4: // Location.line, of which only one is expected.
+					for _, line := range decodeMessage(t, lf.bytes) {
+						if line.number == 1 {
+							function = line.varint
+						}
+					}
+				}
+			}
+			locationFunctions[id] = function
+
+		case 5: // Profile.function
+			var id, name uint64
+			for _, ff := range decodeMessage(t, f.bytes) {
+				switch ff.number {
+				case 1:
+					id = ff.varint
+				case 2:
+					name = ff.varint
+				}
+			}
+			functionNames[id] = name
+
+		case 6: // Profile.string_table
+			strs = append(strs, string(f.bytes))
+		}
+	}
+
+	lookup := func(id uint64) string {
+		t.Helper()
+		require.Equal(t, true, id < uint64(len(strs)))
+		return strs[id]
+	}
+
+	var p decodedProfile
+	require.Equal(t, 1, len(sampleTypes))
+	var typ, unit uint64
+	for _, f := range decodeMessage(t, sampleTypes[0]) {
+		switch f.number {
+		case 1:
+			typ = f.varint
+		case 2:
+			unit = f.varint
+		}
+	}
+	p.sampleType = lookup(typ) + " " + lookup(unit)
+
+	for _, data := range samples {
+		var sample decodedSample
+		for _, f := range decodeMessage(t, data) {
+			switch f.number {
+			case 1: // Sample.location_id
+				for _, id := range packedVarints(t, f) {
+					function, ok := locationFunctions[id]
+					require.Equal(t, true, ok)
+					sample.stack = append(sample.stack, lookup(functionNames[function]))
+				}
+			case 2: // Sample.value
+				values := packedVarints(t, f)
+				require.Equal(t, 1, len(values))
+				sample.count = int64(values[0])
+			}
+		}
+		p.samples = append(p.samples, sample)
+	}
+
+	return p
+}
+
+// protoField is a field of a protocol buffer message. Only varint and
+// length-delimited fields are supported.
+type protoField struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+func decodeMessage(t *testing.T, data []byte) []protoField {
+	t.Helper()
+
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		require.Equal(t, true, n > 0)
+		data = data[n:]
+
+		f := protoField{number: int(tag >> 3)}
+		value, n := binary.Uvarint(data)
+		require.Equal(t, true, n > 0)
+		data = data[n:]
+
+		switch tag & 7 {
+		case 0:
+			f.varint = value
+		case 2:
+			require.Equal(t, true, value <= uint64(len(data)))
+			f.bytes = data[:value]
+			data = data[value:]
+		default:
+			t.Fatalf("unsupported wire type %d", tag&7)
+		}
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// packedVarints returns the values of a repeated integer field, which may
+// or may not be packed.
+func packedVarints(t *testing.T, f protoField) []uint64 {
+	t.Helper()
+
+	if f.bytes == nil {
+		return []uint64{f.varint}
+	}
+
+	var values []uint64
+	for data := f.bytes; len(data) > 0; {
+		v, n := binary.Uvarint(data)
+		require.Equal(t, true, n > 0)
+		values = append(values, v)
+		data = data[n:]
+	}
+
+	return values
+}
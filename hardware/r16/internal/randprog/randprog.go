@@ -0,0 +1,117 @@
+// Package randprog generates random r16 programs for fuzzing and
+// co-simulation.
+//
+// Programs only use operations defined by the architecture and are
+// constrained so that they never trap: loads and stores stay within a data
+// range, and branches and calls land on instructions of the program. They
+// can loop forever, so they must be run for a bounded number of steps.
+package randprog
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+)
+
+// Config constrains random programs.
+type Config struct {
+	// Address where the program is loaded, which must be aligned.
+	Base uint16
+
+	// Number of random instructions. They are followed by an instruction
+	// that jumps to itself, so that the program can halt.
+	Instructions int
+
+	// Memory that loads and stores may touch, which must not overlap the
+	// program. It must be at least two bytes long.
+	DataBase uint16
+	DataSize int
+}
+
+// DefaultConfig generates short programs at the program base address with
+// data in the bottom half of memory, below the program.
+var DefaultConfig = Config{
+	Base:         0x8000,
+	Instructions: 64,
+	DataBase:     0x4000,
+	DataSize:     0x100,
+}
+
+// Generate a random program.
+func Generate(r *rand.Rand, config Config) []isa.DecodedInstruction {
+	program := make([]isa.DecodedInstruction, 0, config.Instructions+1)
+	for range config.Instructions {
+		program = append(program, instruction(r, config))
+	}
+
+	halt := config.Base + uint16(4*config.Instructions)
+	program = append(program, isa.DecodedInstruction{Operation: isa.JAL, Imm: halt})
+	return program
+}
+
+// Encode a program in little-endian, as it is laid out in memory.
+func Encode(program []isa.DecodedInstruction) []byte {
+	data := make([]byte, 0, 4*len(program))
+	for _, d := range program {
+		data = binary.LittleEndian.AppendUint32(data, uint32(isa.Encode(d)))
+	}
+
+	return data
+}
+
+func instruction(r *rand.Rand, config Config) isa.DecodedInstruction {
+	d := isa.DecodedInstruction{Operation: operations[r.IntN(len(operations))]}
+	switch d.Operation >> 12 {
+	case 0x0, 0x1, 0x2, 0x3:
+		d.Z, d.Y, d.X = register(r), register(r), register(r)
+	case 0x4:
+		d.Y, d.X, d.Imm = register(r), register(r), target(r, config)
+	case 0x5:
+		d.Y, d.Imm = register(r), dataAddress(r, config, d.Operation)
+	case 0x8:
+		d.Z, d.Imm = register(r), target(r, config)
+	case 0x9:
+		d.Z, d.Imm = register(r), dataAddress(r, config, d.Operation)
+	default:
+		d.Z, d.X, d.Imm = register(r), register(r), uint16(r.Uint32())
+	}
+
+	return d
+}
+
+// register returns any register, including ZR to check that writes to it
+// are discarded.
+func register(r *rand.Rand) isa.Register {
+	return isa.Register(r.IntN(16))
+}
+
+// target returns the address of an instruction of the program, including
+// the final one.
+func target(r *rand.Rand, config Config) uint16 {
+	return config.Base + uint16(4*r.IntN(config.Instructions+1))
+}
+
+// dataAddress returns an absolute address for a load or store, which uses
+// ZR as the base register, so that the whole access is within the data.
+func dataAddress(r *rand.Rand, config Config, o isa.Operation) uint16 {
+	size := 1
+	if o == isa.LOADH || o == isa.STOREH {
+		size = 2
+	}
+
+	return config.DataBase + uint16(r.IntN(config.DataSize-size+1))
+}
+
+// operations that can be generated: every known one but ILLEGAL.
+var operations = func() []isa.Operation {
+	var operations []isa.Operation
+	for i := range 0x10000 {
+		o := isa.Operation(i)
+		if o.Known() && o != isa.ILLEGAL {
+			operations = append(operations, o)
+		}
+	}
+
+	return operations
+}()
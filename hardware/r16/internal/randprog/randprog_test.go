@@ -0,0 +1,62 @@
+package randprog_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/r16/internal/cosim"
+	"github.com/jespert/primordial/hardware/r16/internal/isa"
+	"github.com/jespert/primordial/hardware/r16/internal/machine"
+	"github.com/jespert/primordial/hardware/r16/internal/randprog"
+	"github.com/jespert/primordial/hardware/r16/internal/state"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestGenerate(t *testing.T) {
+	config := randprog.DefaultConfig
+	config.Instructions = 16
+	program := randprog.Generate(rand.New(rand.NewPCG(1, 2)), config)
+
+	verifier := approval.NewTextVerifier(t)
+	for i, d := range program {
+		_, _ = fmt.Fprintf(verifier.Writer(), "%04x  %s\n", int(config.Base)+4*i, isa.Disassemble(d))
+	}
+	verifier.Verify()
+}
+
+// Random programs run the same with and without the decode cache, never
+// trap, never leave the program and never change ZR.
+func FuzzGenerate(f *testing.F) {
+	for seed := range uint64(16) {
+		f.Add(seed)
+	}
+
+	const steps = 1000
+	config := randprog.DefaultConfig
+	end := state.Address(config.Base) + state.Address(4*config.Instructions)
+	f.Fuzz(func(t *testing.T, seed uint64) {
+		program := randprog.Encode(randprog.Generate(rand.New(rand.NewPCG(seed, 0)), config))
+		reference, candidate := machine.New(), machine.New()
+		reference.SetDecodeCache(false)
+		require.Success(t, reference.LoadProgram(state.Address(config.Base), program))
+		require.Success(t, candidate.LoadProgram(state.Address(config.Base), program))
+
+		lockstep := cosim.NewLockstep(reference, cosim.NewEmulator(candidate))
+		for range steps {
+			require.Success(t, lockstep.Step())
+
+			registers := reference.Registers()
+			if zr := registers.Read(isa.ZR); zr != 0 {
+				t.Fatalf("ZR changed to 0x%04x", zr)
+			}
+
+			if ip := reference.IP(); ip < state.Address(config.Base) || ip > end || ip%4 != 0 {
+				t.Fatalf("IP left the program: %04x", ip)
+			}
+		}
+
+		require.Success(t, lockstep.CheckMemory())
+	})
+}
@@ -1,113 +1,12 @@
 package state
 
-import (
-	"bytes"
-	"fmt"
-	"io"
-)
-
-type Address uint16
-
-type Memory struct {
-	// Some memory ranges will not be used in practice due to MMIO,
-	// but it is easier to allocate the whole flat range.
-	data [MemorySize]byte
-}
-
-func (m *Memory) ReadB(address Address) (byte, error) {
-	return m.data[address], nil
-}
-
-func (m *Memory) WriteB(address Address, value byte) error {
-	m.data[address] = value
-	return nil
-}
-
-func (m *Memory) ReadH(address Address) (int16, error) {
-	v := int16(m.data[address])
-	v |= int16(m.data[address+1]) << 8
-	return v, nil
-}
-
-func (m *Memory) WriteH(address Address, value int16) error {
-	m.data[address] = byte(value)
-	m.data[address+1] = byte(value >> 8)
-	return nil
-}
-
-func (m *Memory) ReadW(address Address) (int32, error) {
-	v := int32(m.data[address])
-	v |= int32(m.data[address+1]) << 8
-	v |= int32(m.data[address+2]) << 16
-	v |= int32(m.data[address+3]) << 24
-	return v, nil
-}
-
-func (m *Memory) WriteW(address Address, value int32) error {
-	m.data[address] = byte(value)
-	m.data[address+1] = byte(value >> 8)
-	m.data[address+2] = byte(value >> 16)
-	m.data[address+3] = byte(value >> 24)
-	return nil
-}
-
-func (m *Memory) WriteRaw(address Address, data []byte) {
-	copy(m.data[address:], data)
-}
-
-func (m *Memory) Dump(w io.Writer) {
-	// There is nothing we can do on IO failure, so we just ignore errors.
-	const bytesPerLine = 16
-	const halfLine = bytesPerLine / 2
+import "github.com/jespert/primordial/hardware/internal/memory"
 
-	var zeroLine [16]byte
-	var numEmpty int
-	for i := 0; i < len(m.data)/bytesPerLine; i++ {
-		baseAddress := i * bytesPerLine
-		line := m.data[baseAddress : baseAddress+bytesPerLine]
-
-		if bytes.Compare(line, zeroLine[:]) == 0 {
-			numEmpty++
-			continue
-		} else if numEmpty != 0 {
-			if numEmpty == 1 {
-				_, _ = fmt.Fprint(w, "(1 empty line)\n")
-			} else {
-				_, _ = fmt.Fprintf(w, "(%d empty lines)\n", numEmpty)
-			}
-			numEmpty = 0
-		}
-
-		_, _ = fmt.Fprintf(w, "%04x  ", baseAddress)
-
-		for i := 0; i < halfLine; i++ {
-			_, _ = fmt.Fprintf(w, "%02x ", m.data[baseAddress+i])
-		}
-
-		_, _ = fmt.Fprint(w, " ")
-
-		for i := halfLine; i < bytesPerLine; i++ {
-			_, _ = fmt.Fprintf(w, "%02x ", m.data[baseAddress+i])
-		}
-
-		_, _ = fmt.Fprint(w, " |")
-
-		for i := 0; i < bytesPerLine; i++ {
-			v := m.data[baseAddress+i]
-			if v >= 32 && v <= 126 {
-				_, _ = fmt.Fprintf(w, "%c", v)
-			} else {
-				_, _ = fmt.Fprint(w, ".")
-			}
-		}
-
-		_, _ = fmt.Fprint(w, "|\n")
-	}
-
-	if numEmpty > 0 {
-		_, _ = fmt.Fprintf(w, "(%d empty lines)\n", numEmpty)
-		numEmpty = 0
-	}
-}
+// The memory of r16 is the flat memory shared with other machines.
+type (
+	Address  = memory.Address
+	Memory   = memory.Memory
+	LineDiff = memory.LineDiff
+)
 
-const MemorySize = 64 * 1024
+const MemorySize = memory.Size
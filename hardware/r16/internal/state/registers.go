@@ -64,4 +64,61 @@ func (r *Registers) DumpNonZero(w io.Writer) {
 	}
 }
 
+// DumpNamed is like DumpNonZero, but it shows the registers by their
+// aliases and annotates them with their role in the calling convention.
+func (r *Registers) DumpNamed(w io.Writer) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	allZero := true
+	for i := range NumRegisters {
+		register := isa.Register(i)
+		v := r.Read(register)
+		if v == 0 {
+			continue
+		}
+
+		allZero = false
+		_, _ = fmt.Fprintf(
+			w,
+			"%s: 0x%04x S:%d U:%d (%s)\n",
+			register,
+			v,
+			int16(v),
+			v,
+			register.Group(),
+		)
+	}
+
+	if allZero {
+		_, _ = fmt.Fprint(w, "(none)\n")
+	}
+}
+
+// DumpDiff shows the registers that differ from a previous register file,
+// with their aliases and roles.
+func (r *Registers) DumpDiff(w io.Writer, previous *Registers) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	unchanged := true
+	for i := range NumRegisters {
+		register := isa.Register(i)
+		old, v := previous.Read(register), r.Read(register)
+		if old == v {
+			continue
+		}
+
+		unchanged = false
+		_, _ = fmt.Fprintf(
+			w,
+			"%s: 0x%04x -> 0x%04x (%s)\n",
+			register,
+			old,
+			v,
+			register.Group(),
+		)
+	}
+
+	if unchanged {
+		_, _ = fmt.Fprint(w, "(none)\n")
+	}
+}
+
 const NumRegisters = 16
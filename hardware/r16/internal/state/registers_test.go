@@ -56,3 +56,31 @@ func TestRegisters_DumpNonZero_all_non_zero(t *testing.T) {
 	registers.DumpNonZero(verifier.Writer())
 	verifier.Verify()
 }
+
+func TestRegisters_DumpNamed(t *testing.T) {
+	var registers state.Registers
+	registers.Write(isa.S0, 0xfff0)
+	registers.Write(isa.A0, 10)
+	registers.Write(isa.RP, 0x8004)
+	registers.Write(isa.SP, 0xffee)
+
+	verifier := approval.NewTextVerifier(t)
+	registers.DumpNamed(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestRegisters_DumpDiff(t *testing.T) {
+	var previous state.Registers
+	previous.Write(isa.A0, 1)
+	previous.Write(isa.A1, 2)
+
+	registers := previous
+	registers.Write(isa.A0, 0xffff)
+	registers.Write(isa.T0, 0x8010)
+
+	verifier := approval.NewTextVerifier(t)
+	registers.DumpDiff(verifier.Writer(), &previous)
+	_, _ = verifier.Writer().Write([]byte("\n"))
+	registers.DumpDiff(verifier.Writer(), &registers)
+	verifier.Verify()
+}
@@ -0,0 +1,8 @@
+// Package isamap provides the r16 instruction set to the encoding map tool,
+// which cannot import the internal packages of r16.
+package isamap
+
+import "github.com/jespert/primordial/hardware/r16/internal/isa/spec"
+
+// ISA is the specification of the instruction set of r16.
+var ISA = spec.ISA
@@ -0,0 +1,225 @@
+// Command sr16 is the toolchain of the sr16 architecture.
+//
+// Usage:
+//
+//	sr16 asm [-o output] source...                  Assemble sources into an executable.
+//	sr16 disasm executable                          List an executable.
+//	sr16 run [-steps n] [-base address] executable  Run an executable until it halts.
+//	sr16 dump [-steps n] [-base address] executable Run an executable and dump the machine.
+//
+// Executables are position-independent: they are loaded at the base address,
+// which is the program base address unless given with -base, and BP points
+// to it while they run.
+//
+// Programs halt by jumping to themselves. Then run shows the registers and
+// dump shows the whole state of the machine, including memory.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/internal/memory"
+	"github.com/jespert/primordial/hardware/sr16/internal/asm"
+	"github.com/jespert/primordial/hardware/sr16/internal/machine"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+type command func(args []string, stdout, stderr io.Writer) error
+
+var commands = map[string]command{
+	"asm":    assemble,
+	"disasm": disassemble,
+	"run":    runProgram,
+	"dump":   dump,
+}
+
+var (
+	// errUsage is returned for invalid command lines, once reported.
+	errUsage = errors.New("invalid usage")
+
+	// errReported is returned for failures that were already reported.
+	errReported = errors.New("failed")
+)
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || commands[args[0]] == nil {
+		_, _ = fmt.Fprintln(stderr, "usage: sr16 asm [-o output] source...")
+		_, _ = fmt.Fprintln(stderr, "       sr16 disasm executable")
+		_, _ = fmt.Fprintln(stderr, "       sr16 run [-steps n] [-base address] executable")
+		_, _ = fmt.Fprintln(stderr, "       sr16 dump [-steps n] [-base address] executable")
+		return 2
+	}
+
+	if err := commands[args[0]](args[1:], stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		if errors.Is(err, errReported) {
+			return 1
+		}
+
+		_, _ = fmt.Fprintf(stderr, "sr16 %s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func assemble(args []string, _, stderr io.Writer) error {
+	flags := flag.NewFlagSet("asm", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file (default: first source with .exe extension)")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	var sources []sharedasm.Source
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, sharedasm.Source{Name: path, Data: data})
+	}
+
+	f, err := asm.Assemble(sources...)
+	if err != nil {
+		return err
+	}
+
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		first := flags.Arg(0)
+		*output = strings.TrimSuffix(first, filepath.Ext(first)) + ".exe"
+	}
+
+	return os.WriteFile(*output, data, 0o644)
+}
+
+func disassemble(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		_, _ = fmt.Fprintln(stderr, "usage: sr16 disasm executable")
+		return errUsage
+	}
+
+	f, err := readExecutable(args[0])
+	if err != nil {
+		return err
+	}
+
+	return asm.Disassemble(stdout, f)
+}
+
+func runProgram(args []string, stdout, stderr io.Writer) error {
+	m, retired, err := runFlags("run", args, stderr)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "halted at %04x after %d instructions\n", m.IP(), retired)
+	integers, pointers := m.IntegerRegisters(), m.PointerRegisters()
+	integers.DumpNamed(stdout)
+	pointers.DumpNamed(stdout)
+	return nil
+}
+
+func dump(args []string, stdout, stderr io.Writer) error {
+	m, _, err := runFlags("dump", args, stderr)
+	if err != nil {
+		return err
+	}
+
+	m.Dump(stdout)
+	return nil
+}
+
+// runFlags parses the flags shared by the commands that run an executable,
+// and runs it until it halts.
+func runFlags(name string, args []string, stderr io.Writer) (*machine.Machine, int, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	steps := flags.Int("steps", 1_000_000, "maximum number of instructions to execute")
+	base := flags.Uint("base", machine.ProgramBase, "load address of the executable")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return nil, 0, errUsage
+	}
+
+	if *base >= memory.Size || *base%2 != 0 {
+		return nil, 0, fmt.Errorf("invalid base address: 0x%x", *base)
+	}
+
+	f, err := readExecutable(flags.Arg(0))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m := machine.New()
+	if err := m.LoadExecutable(f, memory.Address(*base)); err != nil {
+		return nil, 0, err
+	}
+
+	retired, err := runUntilHalt(m, *steps, name, stderr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return m, retired, nil
+}
+
+// runUntilHalt steps until the machine jumps to itself and returns the
+// number of instructions retired. Traps are reported with their address.
+func runUntilHalt(m *machine.Machine, steps int, name string, stderr io.Writer) (int, error) {
+	for i := range steps {
+		ip := m.IP()
+		if err := m.Step(); err != nil {
+			var trap *machine.Trap
+			if !errors.As(err, &trap) {
+				return 0, err
+			}
+
+			_, _ = fmt.Fprintf(stderr, "sr16 %s: trap at %04x: %v\n", name, trap.IP, trap)
+			return 0, errReported
+		}
+
+		if m.IP() == ip {
+			return i + 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("did not halt after %d instructions", steps)
+}
+
+func readExecutable(path string) (*exe.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := exe.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if f.Header.Arch != asm.Arch {
+		return nil, fmt.Errorf("%s: not an sr16 executable: %s", path, f.Header.Arch)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestRun_asm_disasm(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "loop.s")
+	program := "\t.func start\nstart:\tadd.hi %x0, %x0, 1\n\tjump start\n"
+	require.Success(t, os.WriteFile(source, []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", source}, &stdout, &stderr))
+	expect.Equal(t, "", stderr.String())
+
+	// Source paths depend on the temporary directory, so run from there.
+	t.Chdir(dir)
+	expect.Equal(t, 0, run([]string{"asm", "-o", "out.exe", "loop.s"}, &stdout, &stderr))
+
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 0, run([]string{"disasm", "out.exe"}, verifier.Writer(), &stderr))
+	verifier.Verify()
+
+	_, err := os.Stat(filepath.Join(dir, "loop.exe"))
+	require.Success(t, err)
+}
+
+func TestRun_run_halt(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "\tadd.hi %x0, %zr, 1\nhalt:\tjump halt\n"
+	require.Success(t, os.WriteFile("halt.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "halt.s"}, &stdout, &stderr))
+	expect.Equal(t, 0, run([]string{"run", "halt.exe"}, &stdout, &stderr))
+	expected := "halted at 8004 after 2 instructions\nx0: 0x0001 S:1 U:1 (arg)\nbp: 0x8000 (base)\n"
+	expect.Equal(t, expected, stdout.String())
+
+	// The executable is position-independent.
+	stdout.Reset()
+	expect.Equal(t, 0, run([]string{"run", "-base", "0x9000", "halt.exe"}, &stdout, &stderr))
+	expected = "halted at 9004 after 2 instructions\nx0: 0x0001 S:1 U:1 (arg)\nbp: 0x9000 (base)\n"
+	expect.Equal(t, expected, stdout.String())
+	expect.Equal(t, "", stderr.String())
+
+	expect.Equal(t, 1, run([]string{"run", "-steps", "1", "halt.exe"}, &stdout, &stderr))
+	expect.Equal(t, 1, run([]string{"run", "-base", "0x9001", "halt.exe"}, &stdout, &stderr))
+}
+
+func TestRun_dump(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "main:\tadd.hi %x0, %zr, 0x1234\n\tadd.ai %a0, %bp, data\n\tstore.h %x0, %a0, 0\n" +
+		"halt:\tjump halt\n\t.data\ndata:\t.half 0\n"
+	require.Success(t, os.WriteFile("dump.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "dump.s"}, &stdout, &stderr))
+
+	verifier := approval.NewTextVerifier(t)
+	expect.Equal(t, 0, run([]string{"dump", "dump.exe"}, verifier.Writer(), &stderr))
+	verifier.Verify()
+}
+
+func TestRun_run_trap(t *testing.T) {
+	t.Chdir(t.TempDir())
+	program := "\tadd.hi %x0, %zr, 1\n\tillegal\n"
+	require.Success(t, os.WriteFile("trap.s", []byte(program), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	expect.Equal(t, 0, run([]string{"asm", "trap.s"}, &stdout, &stderr))
+	expect.Equal(t, 1, run([]string{"run", "trap.exe"}, &stdout, &stderr))
+	expected := "sr16 run: trap at 8004: failed to execute instruction at 8004: illegal instruction\n"
+	expect.Equal(t, expected, stderr.String())
+}
+
+func TestRun_errors(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// An executable of another architecture.
+	f := exe.File{Header: exe.Header{Version: 1, Size: exe.Size16, Arch: exe.PackName("R16")}}
+	data, err := f.MarshalBinary()
+	require.Success(t, err)
+	require.Success(t, os.WriteFile("r16.exe", data, 0o644))
+
+	testCases := []struct {
+		args     []string
+		expected int
+	}{
+		{nil, 2},
+		{[]string{"foo"}, 2},
+		{[]string{"disasm"}, 2},
+		{[]string{"run"}, 2},
+		{[]string{"dump", "missing.exe"}, 1},
+		{[]string{"run", "r16.exe"}, 1},
+	}
+
+	for _, tc := range testCases {
+		var stdout, stderr bytes.Buffer
+		expect.Equal(t, tc.expected, run(tc.args, &stdout, &stderr))
+	}
+
+	var stdout, stderr bytes.Buffer
+	run([]string{"disasm", "r16.exe"}, &stdout, &stderr)
+	expect.Equal(t, "sr16 disasm: r16.exe: not an sr16 executable: R16\n", stderr.String())
+}
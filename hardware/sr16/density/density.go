@@ -0,0 +1,47 @@
+// Package density is the sr16 target of the code density tool, which cannot
+// import the internal packages of sr16.
+package density
+
+import (
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	shareddensity "github.com/jespert/primordial/hardware/internal/density"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/sr16/internal/asm"
+	"github.com/jespert/primordial/hardware/sr16/internal/machine"
+)
+
+// Target runs benchmarks on sr16.
+var Target shareddensity.Target = target{}
+
+type target struct{}
+
+func (target) Name() string {
+	return "sr16"
+}
+
+func (target) Provisional() bool {
+	return false
+}
+
+func (target) Assemble(sources ...sharedasm.Source) (*exe.File, error) {
+	return asm.Assemble(sources...)
+}
+
+// Lengths of sr16 instructions, which are all 32 bits long.
+func (target) Lengths(code []byte) ([]int, error) {
+	lengths := make([]int, len(code)/4)
+	for i := range lengths {
+		lengths[i] = 4
+	}
+
+	return lengths, nil
+}
+
+func (target) Run(f *exe.File, steps int) (int, error) {
+	m := machine.New()
+	if err := m.LoadExecutable(f, machine.ProgramBase); err != nil {
+		return 0, err
+	}
+
+	return shareddensity.RunUntilHalt(steps, func() uint64 { return uint64(m.IP()) }, m.Step)
+}
@@ -0,0 +1,157 @@
+// Package asm assembles and disassembles sr16 programs.
+//
+// The syntax of the instructions follows the README. The rest of the syntax
+// and the directives are shared with other architectures; see the
+// hardware/internal/asm package.
+//
+// Programs are position-independent, so they are assembled at address zero
+// and every address is relative to BP. For example, "call f" jumps to BP+f.
+package asm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+)
+
+// Arch is the name of the architecture in executables.
+var Arch = exe.PackName("SR16")
+
+// Assemble the sources into a position-independent executable. The
+// executable includes a debug line table.
+func Assemble(sources ...asm.Source) (*exe.File, error) {
+	return asm.Assemble(asm.Config{
+		Arch: arch{},
+		Header: exe.Header{
+			Endianness: exe.LittleEndian,
+			Type:       exe.StaticExecutable,
+			Arch:       Arch,
+		},
+	}, sources...)
+}
+
+type arch struct{}
+
+func (arch) Instruction(mnemonic string, operands []asm.Operand) (int, asm.Encoder, error) {
+	if pseudo, ok := pseudoInstructions[mnemonic]; ok {
+		o, expanded, err := pseudo.Resolve(mnemonic, operands)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return instruction(o, expanded)
+	}
+
+	o, ok := isa.ParseOperation(mnemonic)
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+
+	return instruction(o, operands)
+}
+
+// instruction checks the operands of an operation and returns its encoder.
+func instruction(o isa.Operation, operands []asm.Operand) (int, asm.Encoder, error) {
+	// The signature determines the operands, as in the disassembler.
+	signature, _ := o.Signature()
+	var slots []int
+	for slot, bank := range signature.Slots {
+		if bank != isa.BankNone {
+			slots = append(slots, slot)
+		}
+	}
+
+	n := len(slots)
+	if signature.Immediate {
+		n++
+	}
+
+	if len(operands) != n {
+		return 0, nil, fmt.Errorf("%s takes %d operands, got %d", o, n, len(operands))
+	}
+
+	d := isa.DecodedInstruction{Operation: o}
+	for i, slot := range slots {
+		if err := setRegister(&d, slot, signature.Slots[slot], &operands[i]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var imm asm.Expr
+	if signature.Immediate {
+		operand := &operands[len(slots)]
+		if operand.IsRegister() {
+			msg := fmt.Sprintf("expected immediate, got register %%%s", operand.Register)
+			return 0, nil, &asm.Error{Pos: operand.Pos, Msg: msg}
+		}
+		imm = operand.Expr
+	}
+
+	return 4, func(e *asm.Env) ([]byte, error) {
+		if imm != nil {
+			var err error
+			if d.Imm, err = e.EvalU16(imm); err != nil {
+				return nil, err
+			}
+		}
+
+		return binary.LittleEndian.AppendUint32(nil, uint32(isa.Encode(d))), nil
+	}, nil
+}
+
+// setRegister parses the register of a slot, which must be of the bank that
+// the operation expects.
+func setRegister(d *isa.DecodedInstruction, slot int, bank isa.Bank, o *asm.Operand) error {
+	if !o.IsRegister() {
+		return &asm.Error{Pos: o.Pos, Msg: fmt.Sprintf("expected %s register", bank)}
+	}
+
+	integer, isInteger := isa.ParseIntegerRegister(o.Register)
+	pointer, isPointer := isa.ParsePointerRegister(o.Register)
+	switch {
+	case !isInteger && !isPointer:
+		return &asm.Error{Pos: o.Pos, Msg: fmt.Sprintf("unknown register %%%s", o.Register)}
+	case bank == isa.BankInteger && !isInteger:
+		msg := fmt.Sprintf("expected integer register, got pointer register %%%s", o.Register)
+		return &asm.Error{Pos: o.Pos, Msg: msg}
+	case bank == isa.BankPointer && !isPointer:
+		msg := fmt.Sprintf("expected pointer register, got integer register %%%s", o.Register)
+		return &asm.Error{Pos: o.Pos, Msg: msg}
+	}
+
+	switch {
+	case bank == isa.BankPointer && slot == 0:
+		d.C = pointer
+	case bank == isa.BankPointer && slot == 1:
+		d.B = pointer
+	case bank == isa.BankPointer:
+		d.A = pointer
+	case slot == 0:
+		d.Z = integer
+	case slot == 1:
+		d.Y = integer
+	default:
+		d.X = integer
+	}
+
+	return nil
+}
+
+// pseudoInstructions by mnemonic. Each expands into a single operation.
+var pseudoInstructions = map[string]asm.Pseudo[isa.Operation]{
+	"jump":   {Operation: isa.JALZ, Operands: 1, Expand: asm.Prepend(isa.ZR, isa.BP)},
+	"rjump":  {Operation: isa.JALZ, Operands: 2, Expand: asm.Prepend(isa.ZR)},
+	"ret":    {Operation: isa.JALZ, Operands: 0, Expand: asm.Fixed(0, isa.ZR, isa.RP)},
+	"mret":   {Operation: isa.JALZ, Operands: 0, Expand: asm.Fixed(0, isa.ZR, isa.MP)},
+	"call":   {Operation: isa.JAL, Operands: 1, Expand: asm.Prepend(isa.RP, isa.BP)},
+	"mcall":  {Operation: isa.JAL, Operands: 1, Expand: asm.Prepend(isa.MP, isa.BP)},
+	"rcall":  {Operation: isa.JAL, Operands: 2, Expand: asm.Prepend(isa.RP)},
+	"rmcall": {Operation: isa.JAL, Operands: 2, Expand: asm.Prepend(isa.MP)},
+	"inv.b":  {Operation: isa.XORBI, Operands: 2, Expand: asm.AppendImm(-1)},
+	"not.b":  {Operation: isa.XORBI, Operands: 2, Expand: asm.AppendImm(1)},
+	"inv.h":  {Operation: isa.XORHI, Operands: 2, Expand: asm.AppendImm(-1)},
+	"not.h":  {Operation: isa.XORHI, Operands: 2, Expand: asm.AppendImm(1)},
+}
@@ -0,0 +1,177 @@
+package asm_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/memory"
+	"github.com/jespert/primordial/hardware/sr16/internal/asm"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+	"github.com/jespert/primordial/hardware/sr16/internal/machine"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+const testProgram = `
+; Sums the numbers from 1 to n.
+n = 5
+
+	.entry main
+	.func main, sum, inc
+
+main:	add.hi %x0, %zr, n
+	add.ai %a0, %bp, result
+	call sum
+	store.h %x1, %a0, 0
+halt:	jump halt
+
+sum:	add.hi %x1, %zr, 0
+loop:	mcall inc
+	add.hi %x0, %x0, -1
+	bne %zr, %x0, loop
+	ret
+
+inc:	add.h %x1, %x1, %x0
+	mret
+
+	.data
+	.object result
+result:	.half 0
+	.bss
+stack:	.space 32
+`
+
+func TestDisassemble(t *testing.T) {
+	f, err := asm.Assemble(sharedasm.Source{Name: "sum.s", Data: []byte(testProgram)})
+	require.Success(t, err)
+
+	verifier := approval.NewTextVerifier(t)
+	require.Success(t, asm.Disassemble(verifier.Writer(), f))
+	verifier.Verify()
+}
+
+// Assembled programs run wherever they are loaded.
+func TestAssemble_position_independent(t *testing.T) {
+	f, err := asm.Assemble(sharedasm.Source{Name: "sum.s", Data: []byte(testProgram)})
+	require.Success(t, err)
+
+	for _, base := range []memory.Address{0x8000, 0x9002} {
+		m := machine.New()
+		require.Success(t, m.LoadExecutable(f, base))
+		for range 32 {
+			require.Success(t, m.Step())
+		}
+
+		var result [2]byte
+		m.ReadMemory(base+memory.Address(len(f.Code)), result[:])
+		expect.Equal(t, 15, binary.LittleEndian.Uint16(result[:]))
+	}
+}
+
+// The disassembly of every operation can be assembled again.
+func TestAssemble_round_trip(t *testing.T) {
+	for i := range 0x10000 {
+		o := isa.Operation(i)
+		if !o.Known() {
+			continue
+		}
+
+		d := isa.DecodedInstruction{Operation: o}
+		if o != isa.ILLEGAL {
+			d = isa.Decode(isa.Encode(clearUnused(isa.DecodedInstruction{
+				Operation: o,
+				Z:         0xa,
+				Y:         0x6,
+				X:         0xf,
+				Imm:       0xfedc,
+			})))
+		}
+
+		text := isa.Disassemble(d)
+		f, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(text)})
+		require.Success(t, err)
+		expect.Equal(t, uint32(isa.Encode(d)), binary.LittleEndian.Uint32(f.Code))
+	}
+}
+
+func TestAssemble_pseudo_instructions(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{"jump 0x1234", "jalz %zr, %bp, 0x1234"},
+		{"rjump %a2, 8", "jalz %zr, %a2, 0x0008"},
+		{"ret", "jalz %zr, %rp, 0x0000"},
+		{"mret", "jalz %zr, %b0, 0x0000"},
+		{"call 0x1234", "jal %rp, %bp, 0x1234"},
+		{"mcall 0x1234", "jal %b0, %bp, 0x1234"},
+		{"rcall %a2, 8", "jal %rp, %a2, 0x0008"},
+		{"rmcall %fp, 8", "jal %b0, %c0, 0x0008"},
+		{"inv.b %x0, %z0", "xor.bi %x0, %z0, 0xffff"},
+		{"not.b %x0, %x1", "xor.bi %x0, %x1, 0x0001"},
+		{"inv.h %x0, %x1", "xor.hi %x0, %x1, 0xffff"},
+		{"not.h %x0, %x1", "xor.hi %x0, %x1, 0x0001"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			f, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(tc.source)})
+			require.Success(t, err)
+
+			encoded := isa.EncodedInstruction(binary.LittleEndian.Uint32(f.Code))
+			expect.Equal(t, tc.expected, isa.Disassemble(isa.Decode(encoded)))
+		})
+	}
+}
+
+func TestAssemble_errors(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{"\tfoo", "test.s:1:2: unknown instruction foo"},
+		{"\tadd.h %x0, %x1", "test.s:1:2: add.h takes 3 operands, got 2"},
+		{"\tbzr.a %a0, %a1, 8", "test.s:1:2: bzr.a takes 2 operands, got 3"},
+		{"\tret %rp", "test.s:1:2: ret takes 0 operands, got 1"},
+		{"\tadd.hi %c1, %x0, 1", "test.s:1:9: expected integer register, got pointer register %c1"},
+		{"\tload.a %z0, %a0, 0", "test.s:1:9: expected pointer register, got integer register %z0"},
+		{"\tcall %a0", "test.s:1:7: expected immediate, got register %a0"},
+		{"\trcall %x0, 0", "test.s:1:8: expected pointer register, got integer register %x0"},
+		{"\tadd.h %x0, %x1, 1", "test.s:1:18: expected integer register"},
+		{"\tadd.hi %x0, %r1, 1", "test.s:1:14: unknown register %r1"},
+		{"\tadd.ai %a0, %bp, 0x10000", "test.s:1:19: value out of range [-32768, 65535]: 65536"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			_, err := asm.Assemble(sharedasm.Source{Name: "test.s", Data: []byte(tc.source)})
+			if err == nil {
+				t.Fatal("expected invalid source to fail")
+			}
+			expect.Equal(t, tc.expected, err.Error())
+		})
+	}
+}
+
+// clearUnused clears the fields that the operation does not use, as
+// required by the encoding.
+func clearUnused(d isa.DecodedInstruction) isa.DecodedInstruction {
+	signature, _ := d.Operation.Signature()
+	if !signature.Immediate {
+		d.Imm = 0
+	}
+
+	if signature.Slots[0] == isa.BankNone {
+		d.Z = 0
+	}
+	if signature.Slots[1] == isa.BankNone {
+		d.Y = 0
+	}
+	if signature.Slots[2] == isa.BankNone {
+		d.X = 0
+	}
+
+	return d
+}
@@ -0,0 +1,121 @@
+package isa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns the mnemonic of the operation, or its hexadecimal
+// representation if the operation is unknown.
+func (o Operation) String() string {
+	if info, ok := operations[o]; ok {
+		return info.mnemonic
+	}
+
+	return fmt.Sprintf("0x%04x", uint16(o))
+}
+
+// ParseOperation returns the operation with the given mnemonic.
+// Pseudo-instructions are not operations, so they are not recognised.
+func ParseOperation(mnemonic string) (Operation, bool) {
+	for o, info := range operations {
+		if info.mnemonic == mnemonic {
+			return o, true
+		}
+	}
+
+	return 0, false
+}
+
+// Known reports whether the operation is defined by the architecture.
+func (o Operation) Known() bool {
+	_, ok := operations[o]
+	return ok
+}
+
+// Bank of registers that a register slot of an instruction names.
+type Bank uint8
+
+const (
+	BankNone Bank = iota
+	BankInteger
+	BankPointer
+)
+
+func (b Bank) String() string {
+	switch b {
+	case BankNone:
+		return "none"
+	case BankInteger:
+		return "integer"
+	case BankPointer:
+		return "pointer"
+	default:
+		return fmt.Sprintf("bank(%d)", uint8(b))
+	}
+}
+
+// Number of register slots in an encoding, excluding W, which no operation
+// uses.
+const numSlots = 3
+
+// Signature describes the operands of an operation.
+//
+// Slots are the register slots of the encoding from the most significant:
+// Z or C at bits 24-27, Y or B at bits 20-23, and X or A at bits 16-19. In
+// assembly syntax, the registers of the used slots come in this order,
+// followed by the immediate, if any.
+type Signature struct {
+	Slots     [numSlots]Bank
+	Immediate bool
+}
+
+// Signature returns the operands of the operation, if it is known.
+func (o Operation) Signature() (Signature, bool) {
+	info, ok := operations[o]
+	return info.signature, ok
+}
+
+// Disassemble instruction into assembly syntax.
+//
+// Unknown operations are disassembled as a raw data word so that the output
+// can always be reassembled into the same encoding.
+func Disassemble(d DecodedInstruction) string {
+	info, ok := operations[d.Operation]
+	if !ok {
+		return fmt.Sprintf(".word 0x%08x", uint32(Encode(d)))
+	}
+
+	var operands []string
+	integers := [numSlots]IntegerRegister{d.Z, d.Y, d.X}
+	pointers := [numSlots]PointerRegister{d.C, d.B, d.A}
+	for slot, bank := range info.signature.Slots {
+		switch bank {
+		case BankInteger:
+			operands = append(operands, "%"+integers[slot].String())
+		case BankPointer:
+			operands = append(operands, "%"+pointers[slot].String())
+		}
+	}
+
+	if info.signature.Immediate {
+		operands = append(operands, fmt.Sprintf("0x%04x", d.Imm))
+	}
+
+	if len(operands) == 0 {
+		return info.mnemonic
+	}
+
+	return info.mnemonic + " " + strings.Join(operands, ", ")
+}
+
+type operationInfo struct {
+	mnemonic  string
+	signature Signature
+}
+
+const (
+	none    = BankNone
+	integer = BankInteger
+	pointer = BankPointer
+)
@@ -0,0 +1,28 @@
+//go:build ignore
+
+// Gen writes the code and the tables of the README that are generated from
+// package spec.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa/spec"
+)
+
+func main() {
+	src, err := spec.Go()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile("operations.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := isaspec.UpdateFile("../../README.md", spec.Markdown()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package isa implements the SR16 instruction set.
+//
+// SR16 splits the register file into an integer bank and a pointer bank.
+// Registers of each bank have their own type, so that a register of the
+// wrong bank cannot be put in an instruction by mistake.
+//
+// The operations are generated from package spec, which also generates the
+// tables of the README.
+package isa
+
+//go:generate go run gen.go
+
+import "github.com/jespert/primordial/internal/quality/assert"
+
+// IntegerRegister is a register number in the integer bank.
+type IntegerRegister uint8
+
+const (
+	ZR IntegerRegister = 0
+	Z6 IntegerRegister = 1
+	Z5 IntegerRegister = 2
+	Z4 IntegerRegister = 3
+	Z3 IntegerRegister = 4
+	Z2 IntegerRegister = 5
+	Z1 IntegerRegister = 6
+	Z0 IntegerRegister = 7
+	Y0 IntegerRegister = 8
+	Y1 IntegerRegister = 9
+	X0 IntegerRegister = 10
+	X1 IntegerRegister = 11
+	X2 IntegerRegister = 12
+	X3 IntegerRegister = 13
+	X4 IntegerRegister = 14
+	X5 IntegerRegister = 15
+)
+
+// PointerRegister is a register number in the pointer bank.
+type PointerRegister uint8
+
+const (
+	BP PointerRegister = 0
+	C6 PointerRegister = 1
+	C5 PointerRegister = 2
+	C4 PointerRegister = 3
+	C3 PointerRegister = 4
+	C2 PointerRegister = 5
+	C1 PointerRegister = 6
+	C0 PointerRegister = 7
+	B0 PointerRegister = 8
+	B1 PointerRegister = 9
+	A0 PointerRegister = 10
+	A1 PointerRegister = 11
+	A2 PointerRegister = 12
+	A3 PointerRegister = 13
+	RP PointerRegister = 14
+	SP PointerRegister = 15
+
+	// Aliases.
+	FP = C0
+	MP = B0
+)
+
+// DecodedInstruction has a field for every register that an instruction
+// can name. The operation determines the bank of each register slot of
+// the encoding, so at most one of Z and C, Y and B, and X and A is used.
+type DecodedInstruction struct {
+	Operation Operation
+
+	// Integer registers.
+	Z IntegerRegister
+	Y IntegerRegister
+	X IntegerRegister
+	W IntegerRegister
+
+	// Pointer registers.
+	C PointerRegister
+	B PointerRegister
+	A PointerRegister
+
+	Imm uint16
+}
+
+type EncodedInstruction uint32
+
+// Decode instruction.
+//
+// Register slots are decoded into the pointer fields if the operation
+// uses them for pointers, and into the integer fields otherwise, even if
+// the operation does not use them. This keeps every bit of the encoding,
+// so that Encode can reproduce it.
+func Decode(e EncodedInstruction) DecodedInstruction {
+	// The four MSBs of the encoded instruction will be the four
+	// MSBs of the operation. The LSBs of the operation will be
+	// filled with the function field, if any.
+	opcode := Operation((e >> 28) << 12)
+
+	// Fields that are common to at least two formats.
+	slots := [numSlots]uint8{
+		uint8(e>>offsetZ) & 0xf,
+		uint8(e>>offsetY) & 0xf,
+		uint8(e>>offsetX) & 0xf,
+	}
+	imm := uint16(e)
+
+	var d DecodedInstruction
+
+	// The two MSBs encode determine the instruction format.
+	switch fmt := e >> 30; fmt {
+	case 0:
+		// R-type
+		d.Operation = opcode | (Operation(e) & 0x0fff)
+		d.W = IntegerRegister(e>>offsetW) & 0xf
+
+	case 1:
+		// B-type
+		d.Operation = opcode | Operation(slots[0])
+		d.Imm = imm
+		slots[0] = 0
+
+	default:
+		// A-type
+		d.Operation = opcode | Operation(slots[1])
+		d.Imm = imm
+		slots[1] = 0
+	}
+
+	signature, _ := d.Operation.Signature()
+	for slot, v := range slots {
+		d.setSlot(slot, signature.Slots[slot], v)
+	}
+
+	return d
+}
+
+func (d *DecodedInstruction) setSlot(slot int, bank Bank, v uint8) {
+	switch {
+	case bank == BankPointer && slot == 0:
+		d.C = PointerRegister(v)
+	case bank == BankPointer && slot == 1:
+		d.B = PointerRegister(v)
+	case bank == BankPointer:
+		d.A = PointerRegister(v)
+	case slot == 0:
+		d.Z = IntegerRegister(v)
+	case slot == 1:
+		d.Y = IntegerRegister(v)
+	default:
+		d.X = IntegerRegister(v)
+	}
+}
+
+// Encode instruction.
+func Encode(d DecodedInstruction) EncodedInstruction {
+	// The four MSBs of the operation will be the four MSBs of the
+	// encoded instruction. The LSBs of the operation will be filled
+	// with the function field, if any.
+	opcode := EncodedInstruction(d.Operation&0xf000) << 16
+
+	// The OR operation provides correct results even when a field is
+	// unused because it would be zero. Only one register of each bank
+	// can be used per slot.
+	assert.True(d.Z == 0 || d.C == 0)
+	assert.True(d.Y == 0 || d.B == 0)
+	assert.True(d.X == 0 || d.A == 0)
+	z := EncodedInstruction(uint8(d.Z)|uint8(d.C)) << offsetZ
+	y := EncodedInstruction(uint8(d.Y)|uint8(d.B)) << offsetY
+	x := EncodedInstruction(uint8(d.X)|uint8(d.A)) << offsetX
+	w := EncodedInstruction(d.W) << offsetW
+	imm := EncodedInstruction(d.Imm)
+
+	// Encode everything but the function field.
+	allButFunction := opcode | z | y | x | w | imm
+
+	// The two MSBs encode determine the instruction format.
+	var function EncodedInstruction
+	switch fmt := d.Operation >> 14; fmt {
+	case 0:
+		// R-type
+		assert.Equal(0, imm)
+		function = EncodedInstruction(d.Operation & 0xfff)
+
+	case 1:
+		// B-type
+		assert.Equal(0, z)
+		assert.Equal(0, w)
+		assert.Equal(0, d.Operation&0x0ff0)
+		function = EncodedInstruction(d.Operation&0xf) << offsetZ
+
+	default:
+		// A-type
+		assert.Equal(0, y)
+		assert.Equal(0, w)
+		assert.Equal(0, d.Operation&0x0ff0)
+		function = EncodedInstruction(d.Operation&0xf) << offsetY
+	}
+
+	return allButFunction | function
+}
+
+const (
+	offsetZ = 24
+	offsetY = 20
+	offsetX = 16
+	offsetW = 12
+)
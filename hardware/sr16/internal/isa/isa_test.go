@@ -0,0 +1,369 @@
+package isa_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa/spec"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tc := range encodingTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Use a bespoke test to print failures in hexadecimal.
+			actual := isa.Decode(tc.encoded)
+			if tc.decoded != actual {
+				t.Errorf("Expected %+v, got %+v", tc.decoded, actual)
+			}
+
+			// Check reversibility.
+			reversed := isa.Encode(actual)
+			expect.Equal(t, tc.encoded, reversed)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range encodingTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Use a bespoke test to print failures in hexadecimal.
+			actual := isa.Encode(tc.decoded)
+			if tc.encoded != actual {
+				t.Errorf("Expected 0x%08x, got 0x%08x", tc.encoded, actual)
+			}
+
+			// Check reversibility.
+			reversed := isa.Decode(actual)
+			expect.Equal(t, tc.decoded, reversed)
+		})
+	}
+}
+
+// Every word decodes, and encoding it again yields the same word, so the
+// encoding has no redundant representations.
+func FuzzDecode(f *testing.F) {
+	for _, tc := range encodingTestCases {
+		f.Add(uint32(tc.encoded))
+	}
+
+	f.Fuzz(func(t *testing.T, encoded uint32) {
+		d := isa.Decode(isa.EncodedInstruction(encoded))
+		if reencoded := isa.Encode(d); uint32(reencoded) != encoded {
+			t.Fatalf("Expected 0x%08x, got 0x%08x", encoded, reencoded)
+		}
+
+		expect.Equal(t, d, isa.Decode(isa.Encode(d)))
+		_ = isa.Disassemble(d)
+	})
+}
+
+func TestParseOperation(t *testing.T) {
+	for i := range 0x10000 {
+		o := isa.Operation(i)
+		if !o.Known() {
+			continue
+		}
+
+		parsed, ok := isa.ParseOperation(o.String())
+		expect.Equal(t, true, ok)
+		expect.Equal(t, o, parsed)
+	}
+
+	// Pseudo-instructions are not operations.
+	_, ok := isa.ParseOperation("rcall")
+	expect.Equal(t, false, ok)
+}
+
+func TestParseIntegerRegister(t *testing.T) {
+	for r := range isa.IntegerRegister(16) {
+		for _, alias := range r.Aliases() {
+			parsed, ok := isa.ParseIntegerRegister(alias)
+			expect.Equal(t, true, ok)
+			expect.Equal(t, r, parsed)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		expected isa.IntegerRegister
+		ok       bool
+	}{
+		{"ZR", isa.ZR, true},
+		{"x5", isa.X5, true},
+		{"a0", 0, false},
+		{"sp", 0, false},
+		{"r1", 0, false},
+		{"%x0", 0, false},
+	}
+
+	for _, tc := range testCases {
+		parsed, ok := isa.ParseIntegerRegister(tc.name)
+		expect.Equal(t, tc.ok, ok)
+		expect.Equal(t, tc.expected, parsed)
+	}
+}
+
+func TestParsePointerRegister(t *testing.T) {
+	for r := range isa.PointerRegister(16) {
+		for _, alias := range r.Aliases() {
+			parsed, ok := isa.ParsePointerRegister(alias)
+			expect.Equal(t, true, ok)
+			expect.Equal(t, r, parsed)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		expected isa.PointerRegister
+		ok       bool
+	}{
+		{"FP", isa.C0, true},
+		{"mp", isa.B0, true},
+		{"Bp", isa.BP, true},
+		{"zr", 0, false},
+		{"x0", 0, false},
+		{"%a0", 0, false},
+	}
+
+	for _, tc := range testCases {
+		parsed, ok := isa.ParsePointerRegister(tc.name)
+		expect.Equal(t, tc.ok, ok)
+		expect.Equal(t, tc.expected, parsed)
+	}
+}
+
+func TestRegister_Group(t *testing.T) {
+	var groups []string
+	for r := range isa.IntegerRegister(16) {
+		groups = append(groups, r.String()+":"+r.Group().String())
+	}
+
+	expected := "zr:zero z6:saved z5:saved z4:saved z3:saved z2:saved z1:saved z0:saved " +
+		"y0:temp y1:temp x0:arg x1:arg x2:arg x3:arg x4:arg x5:arg"
+	expect.Equal(t, expected, strings.Join(groups, " "))
+
+	groups = nil
+	for r := range isa.PointerRegister(16) {
+		groups = append(groups, r.String()+":"+r.Group().String())
+	}
+
+	expected = "bp:base c6:saved c5:saved c4:saved c3:saved c2:saved c1:saved c0:saved " +
+		"b0:temp b1:temp a0:arg a1:arg a2:arg a3:arg rp:return sp:stack"
+	expect.Equal(t, expected, strings.Join(groups, " "))
+	expect.Equal(t, isa.GroupUnknown, isa.IntegerRegister(16).Group())
+	expect.Equal(t, isa.GroupUnknown, isa.PointerRegister(255).Group())
+}
+
+func TestDisassemble(t *testing.T) {
+	testCases := []struct {
+		name     string
+		decoded  isa.DecodedInstruction
+		expected string
+	}{
+		{
+			name:     "illegal",
+			decoded:  isa.DecodedInstruction{Operation: isa.ILLEGAL},
+			expected: "illegal",
+		},
+		{
+			name: "R",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.ADDH,
+				Z:         isa.X0,
+				Y:         isa.X1,
+				X:         isa.Y1,
+			},
+			expected: "add.h %x0, %x1, %y1",
+		},
+		{
+			name: "R pointer",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.ADDA,
+				C:         isa.SP,
+				B:         isa.SP,
+				X:         isa.X2,
+			},
+			expected: "add.a %sp, %sp, %x2",
+		},
+		{
+			name: "R mixed",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.DIFF,
+				Z:         isa.X0,
+				B:         isa.A1,
+				A:         isa.A0,
+			},
+			expected: "diff %x0, %a1, %a0",
+		},
+		{
+			name: "R conversion",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.ZTOP,
+				C:         isa.A0,
+				X:         isa.X0,
+			},
+			expected: "ztop %a0, %x0",
+		},
+		{
+			name: "B",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.BGEU,
+				Y:         isa.Z0,
+				X:         isa.Y1,
+				Imm:       0x8010,
+			},
+			expected: "bge.u %z0, %y1, 0x8010",
+		},
+		{
+			name: "B single pointer",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.BZRA,
+				B:         isa.A0,
+				Imm:       0x0010,
+			},
+			expected: "bzr.a %a0, 0x0010",
+		},
+		{
+			name: "A",
+			decoded: isa.DecodedInstruction{
+				Operation: isa.JAL,
+				C:         isa.RP,
+				A:         isa.A2,
+				Imm:       0x1234,
+			},
+			expected: "jal %rp, %a2, 0x1234",
+		},
+		{
+			name: "unknown",
+			decoded: isa.DecodedInstruction{
+				Operation: 0x8003,
+				Z:         0xa,
+				X:         0xc,
+				Imm:       0x6789,
+			},
+			expected: ".word 0x8a3c6789",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expect.Equal(t, tc.expected, isa.Disassemble(tc.decoded))
+		})
+	}
+}
+
+var encodingTestCases = []struct {
+	name    string
+	decoded isa.DecodedInstruction
+	encoded isa.EncodedInstruction
+}{
+	{
+		name: "R",
+		decoded: isa.DecodedInstruction{
+			Operation: 0x0123,
+			Z:         0xa,
+			Y:         0xb,
+			X:         0xc,
+			W:         0xd,
+		},
+		encoded: 0x0abcd123,
+	},
+	{
+		name: "B",
+		decoded: isa.DecodedInstruction{
+			Operation: 0x4003,
+			Y:         0xb,
+			X:         0xc,
+			Imm:       0x6789,
+		},
+		encoded: 0x43bc6789,
+	},
+	{
+		name: "A",
+		decoded: isa.DecodedInstruction{
+			Operation: 0x8003,
+			Z:         0xa,
+			X:         0xc,
+			Imm:       0x6789,
+		},
+		encoded: 0x8a3c6789,
+	},
+	{
+		name: "sub.a",
+		decoded: isa.DecodedInstruction{
+			Operation: isa.SUBA,
+			C:         isa.SP,
+			B:         isa.SP,
+			X:         isa.X1,
+		},
+		encoded: 0x0ffb0209,
+	},
+	{
+		name: "ptoz",
+		decoded: isa.DecodedInstruction{
+			Operation: isa.PTOZ,
+			Z:         isa.X0,
+			Y:         0x3,
+			A:         isa.A1,
+		},
+		encoded: 0x0a3b0211,
+	},
+	{
+		name: "bne.a",
+		decoded: isa.DecodedInstruction{
+			Operation: isa.BNEA,
+			B:         isa.A0,
+			A:         isa.BP,
+			Imm:       0xfff8,
+		},
+		encoded: 0x49a0fff8,
+	},
+	{
+		name: "store.a",
+		decoded: isa.DecodedInstruction{
+			Operation: isa.STOREA,
+			B:         isa.RP,
+			A:         isa.SP,
+			Imm:       0x0002,
+		},
+		encoded: 0x59ef0002,
+	},
+	{
+		name: "jal",
+		decoded: isa.DecodedInstruction{
+			Operation: isa.JAL,
+			C:         isa.RP,
+			A:         isa.A2,
+			Imm:       0x1234,
+		},
+		encoded: 0x8e1c1234,
+	},
+	{
+		name: "load.h",
+		decoded: isa.DecodedInstruction{
+			Operation: isa.LOADH,
+			Z:         isa.X0,
+			A:         isa.FP,
+			Imm:       0xfffe,
+		},
+		encoded: 0x9a17fffe,
+	},
+}
+
+// TestSpec checks that the generated code and the tables of the README
+// match the spec. Run go generate to update them.
+func TestSpec(t *testing.T) {
+	expected, err := spec.Go()
+	require.Success(t, err)
+
+	actual, err := os.ReadFile("operations.go")
+	require.Success(t, err)
+	expect.Equal(t, string(expected), string(actual))
+
+	expect.Success(t, isaspec.CheckFile("../../README.md", spec.Markdown()))
+}
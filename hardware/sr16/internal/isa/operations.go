@@ -0,0 +1,189 @@
+// Code generated by gen.go from package spec; DO NOT EDIT.
+
+package isa
+
+// Operation code (opcode + function).
+type Operation uint16
+
+const (
+	// Special operations.
+	ILLEGAL Operation = 0x0000
+
+	// Operations on bytes in registers.
+	ANDB Operation = 0x0100
+	ORB  Operation = 0x0101
+	XORB Operation = 0x0102
+	SRAB Operation = 0x0103
+	SRLB Operation = 0x0104
+	SLLB Operation = 0x0105
+	ADDB Operation = 0x0106
+	SUBB Operation = 0x0107
+
+	// Operations on halfwords in registers.
+	ANDH Operation = 0x0110
+	ORH  Operation = 0x0111
+	XORH Operation = 0x0112
+	SRAH Operation = 0x0113
+	SRLH Operation = 0x0114
+	SLLH Operation = 0x0115
+	ADDH Operation = 0x0116
+	SUBH Operation = 0x0117
+
+	// Comparisons of full registers.
+	SLTS Operation = 0x0200
+	SLTU Operation = 0x0201
+
+	// Pointer arithmetic on registers.
+	ADDA Operation = 0x0208
+	SUBA Operation = 0x0209
+	DIFF Operation = 0x0210
+	PTOZ Operation = 0x0211
+	ZTOP Operation = 0x0212
+
+	// Conditional control flow on integers.
+	BEQ  Operation = 0x4000
+	BNE  Operation = 0x4001
+	BLTS Operation = 0x4004
+	BLTU Operation = 0x4005
+	BGES Operation = 0x4006
+	BGEU Operation = 0x4007
+
+	// Conditional control flow on pointers.
+	BEQA Operation = 0x4008
+	BNEA Operation = 0x4009
+	BZRA Operation = 0x400a
+	BNZA Operation = 0x400b
+	BLTA Operation = 0x400c
+	BGEA Operation = 0x400e
+
+	// Store to memory.
+	STOREB Operation = 0x5000
+	STOREH Operation = 0x5001
+	STOREA Operation = 0x5009
+
+	// Unconditional control flow.
+	JALZ Operation = 0x8000
+	JAL  Operation = 0x8001
+
+	// Load from memory.
+	LOADSB Operation = 0x9000
+	LOADH  Operation = 0x9001
+	LOADUB Operation = 0x9004
+	LOADA  Operation = 0x9009
+
+	// Comparisons and pointer arithmetic with immediates.
+	SLTSI Operation = 0xb000
+	SLTUI Operation = 0xb001
+	ADDAI Operation = 0xb008
+
+	// Byte arithmetic with immediates.
+	ANDBI Operation = 0xe000
+	ORBI  Operation = 0xe001
+	XORBI Operation = 0xe002
+	SRABI Operation = 0xe003
+	SRLBI Operation = 0xe004
+	SLLBI Operation = 0xe005
+	ADDBI Operation = 0xe006
+
+	// Halfword arithmetic with immediates.
+	ANDHI Operation = 0xf000
+	ORHI  Operation = 0xf001
+	XORHI Operation = 0xf002
+	SRAHI Operation = 0xf003
+	SRLHI Operation = 0xf004
+	SLLHI Operation = 0xf005
+	ADDHI Operation = 0xf006
+)
+
+var (
+	signatureNone = Signature{}
+	signatureZYX  = Signature{Slots: [numSlots]Bank{integer, integer, integer}}
+	signatureCBX  = Signature{Slots: [numSlots]Bank{pointer, pointer, integer}}
+	signatureZBA  = Signature{Slots: [numSlots]Bank{integer, pointer, pointer}}
+	signatureZA   = Signature{Slots: [numSlots]Bank{integer, none, pointer}}
+	signatureCX   = Signature{Slots: [numSlots]Bank{pointer, none, integer}}
+	signatureYXI  = Signature{Slots: [numSlots]Bank{none, integer, integer}, Immediate: true}
+	signatureBAI  = Signature{Slots: [numSlots]Bank{none, pointer, pointer}, Immediate: true}
+	signatureBI   = Signature{Slots: [numSlots]Bank{none, pointer, none}, Immediate: true}
+	signatureYAI  = Signature{Slots: [numSlots]Bank{none, integer, pointer}, Immediate: true}
+	signatureZAI  = Signature{Slots: [numSlots]Bank{integer, none, pointer}, Immediate: true}
+	signatureCAI  = Signature{Slots: [numSlots]Bank{pointer, none, pointer}, Immediate: true}
+	signatureZXI  = Signature{Slots: [numSlots]Bank{integer, none, integer}, Immediate: true}
+)
+
+var operations = map[Operation]operationInfo{
+	ILLEGAL: {"illegal", signatureNone},
+
+	ANDB: {"and.b", signatureZYX},
+	ORB:  {"or.b", signatureZYX},
+	XORB: {"xor.b", signatureZYX},
+	SRAB: {"sra.b", signatureZYX},
+	SRLB: {"srl.b", signatureZYX},
+	SLLB: {"sll.b", signatureZYX},
+	ADDB: {"add.b", signatureZYX},
+	SUBB: {"sub.b", signatureZYX},
+
+	ANDH: {"and.h", signatureZYX},
+	ORH:  {"or.h", signatureZYX},
+	XORH: {"xor.h", signatureZYX},
+	SRAH: {"sra.h", signatureZYX},
+	SRLH: {"srl.h", signatureZYX},
+	SLLH: {"sll.h", signatureZYX},
+	ADDH: {"add.h", signatureZYX},
+	SUBH: {"sub.h", signatureZYX},
+
+	SLTS: {"slt.s", signatureZYX},
+	SLTU: {"slt.u", signatureZYX},
+
+	ADDA: {"add.a", signatureCBX},
+	SUBA: {"sub.a", signatureCBX},
+	DIFF: {"diff", signatureZBA},
+	PTOZ: {"ptoz", signatureZA},
+	ZTOP: {"ztop", signatureCX},
+
+	BEQ:  {"beq", signatureYXI},
+	BNE:  {"bne", signatureYXI},
+	BLTS: {"blt.s", signatureYXI},
+	BLTU: {"blt.u", signatureYXI},
+	BGES: {"bge.s", signatureYXI},
+	BGEU: {"bge.u", signatureYXI},
+
+	BEQA: {"beq.a", signatureBAI},
+	BNEA: {"bne.a", signatureBAI},
+	BZRA: {"bzr.a", signatureBI},
+	BNZA: {"bnz.a", signatureBI},
+	BLTA: {"blt.a", signatureBAI},
+	BGEA: {"bge.a", signatureBAI},
+
+	STOREB: {"store.b", signatureYAI},
+	STOREH: {"store.h", signatureYAI},
+	STOREA: {"store.a", signatureBAI},
+
+	JALZ: {"jalz", signatureZAI},
+	JAL:  {"jal", signatureCAI},
+
+	LOADSB: {"load.sb", signatureZAI},
+	LOADH:  {"load.h", signatureZAI},
+	LOADUB: {"load.ub", signatureZAI},
+	LOADA:  {"load.a", signatureCAI},
+
+	SLTSI: {"slt.si", signatureZXI},
+	SLTUI: {"slt.ui", signatureZXI},
+	ADDAI: {"add.ai", signatureCAI},
+
+	ANDBI: {"and.bi", signatureZXI},
+	ORBI:  {"or.bi", signatureZXI},
+	XORBI: {"xor.bi", signatureZXI},
+	SRABI: {"sra.bi", signatureZXI},
+	SRLBI: {"srl.bi", signatureZXI},
+	SLLBI: {"sll.bi", signatureZXI},
+	ADDBI: {"add.bi", signatureZXI},
+
+	ANDHI: {"and.hi", signatureZXI},
+	ORHI:  {"or.hi", signatureZXI},
+	XORHI: {"xor.hi", signatureZXI},
+	SRAHI: {"sra.hi", signatureZXI},
+	SRLHI: {"srl.hi", signatureZXI},
+	SLLHI: {"sll.hi", signatureZXI},
+	ADDHI: {"add.hi", signatureZXI},
+}
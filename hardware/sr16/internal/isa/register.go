@@ -0,0 +1,173 @@
+package isa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Group of registers that share a role in the calling convention.
+type Group uint8
+
+const (
+	GroupZero Group = iota
+	GroupSaved
+	GroupTemporary
+	GroupArgument
+	GroupReturnPointer
+	GroupStackPointer
+	GroupBasePointer
+
+	// GroupUnknown is the group of registers that do not exist.
+	GroupUnknown
+)
+
+func (g Group) String() string {
+	switch g {
+	case GroupZero:
+		return "zero"
+	case GroupSaved:
+		return "saved"
+	case GroupTemporary:
+		return "temp"
+	case GroupArgument:
+		return "arg"
+	case GroupReturnPointer:
+		return "return"
+	case GroupStackPointer:
+		return "stack"
+	case GroupBasePointer:
+		return "base"
+	case GroupUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("group(%d)", uint8(g))
+	}
+}
+
+// String returns the lowercase alias of the register.
+func (r IntegerRegister) String() string {
+	if int(r) < len(integerRegisters) {
+		return integerRegisters[r].aliases[0]
+	}
+
+	return fmt.Sprintf("integer(%d)", uint8(r))
+}
+
+// Aliases returns the lowercase aliases of the register, the preferred one
+// first.
+func (r IntegerRegister) Aliases() []string {
+	if int(r) < len(integerRegisters) {
+		return integerRegisters[r].aliases
+	}
+
+	return []string{r.String()}
+}
+
+// Group returns the role of the register in the calling convention, or
+// GroupUnknown if the register does not exist.
+func (r IntegerRegister) Group() Group {
+	if int(r) < len(integerRegisters) {
+		return integerRegisters[r].group
+	}
+
+	return GroupUnknown
+}
+
+// String returns the lowercase alias of the register.
+func (r PointerRegister) String() string {
+	if int(r) < len(pointerRegisters) {
+		return pointerRegisters[r].aliases[0]
+	}
+
+	return fmt.Sprintf("pointer(%d)", uint8(r))
+}
+
+// Aliases returns the lowercase aliases of the register, the preferred one
+// first. For example, C0 is also FP.
+func (r PointerRegister) Aliases() []string {
+	if int(r) < len(pointerRegisters) {
+		return pointerRegisters[r].aliases
+	}
+
+	return []string{r.String()}
+}
+
+// Group returns the role of the register in the calling convention, or
+// GroupUnknown if the register does not exist.
+func (r PointerRegister) Group() Group {
+	if int(r) < len(pointerRegisters) {
+		return pointerRegisters[r].group
+	}
+
+	return GroupUnknown
+}
+
+// ParseIntegerRegister returns the integer register with the given alias,
+// such as "x0" or "zr", without the % prefix. Letter case is ignored.
+func ParseIntegerRegister(name string) (IntegerRegister, bool) {
+	i, ok := parseRegister(integerRegisters[:], name)
+	return IntegerRegister(i), ok
+}
+
+// ParsePointerRegister returns the pointer register with the given alias,
+// such as "a0", "fp" or "sp", without the % prefix. Letter case is ignored.
+func ParsePointerRegister(name string) (PointerRegister, bool) {
+	i, ok := parseRegister(pointerRegisters[:], name)
+	return PointerRegister(i), ok
+}
+
+func parseRegister(registers []registerInfo, name string) (uint8, bool) {
+	name = strings.ToLower(name)
+	for i, info := range registers {
+		for _, alias := range info.aliases {
+			if alias == name {
+				return uint8(i), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+type registerInfo struct {
+	aliases []string
+	group   Group
+}
+
+var integerRegisters = [...]registerInfo{
+	ZR: {[]string{"zr"}, GroupZero},
+	Z6: {[]string{"z6"}, GroupSaved},
+	Z5: {[]string{"z5"}, GroupSaved},
+	Z4: {[]string{"z4"}, GroupSaved},
+	Z3: {[]string{"z3"}, GroupSaved},
+	Z2: {[]string{"z2"}, GroupSaved},
+	Z1: {[]string{"z1"}, GroupSaved},
+	Z0: {[]string{"z0"}, GroupSaved},
+	Y0: {[]string{"y0"}, GroupTemporary},
+	Y1: {[]string{"y1"}, GroupTemporary},
+	X0: {[]string{"x0"}, GroupArgument},
+	X1: {[]string{"x1"}, GroupArgument},
+	X2: {[]string{"x2"}, GroupArgument},
+	X3: {[]string{"x3"}, GroupArgument},
+	X4: {[]string{"x4"}, GroupArgument},
+	X5: {[]string{"x5"}, GroupArgument},
+}
+
+var pointerRegisters = [...]registerInfo{
+	BP: {[]string{"bp"}, GroupBasePointer},
+	C6: {[]string{"c6"}, GroupSaved},
+	C5: {[]string{"c5"}, GroupSaved},
+	C4: {[]string{"c4"}, GroupSaved},
+	C3: {[]string{"c3"}, GroupSaved},
+	C2: {[]string{"c2"}, GroupSaved},
+	C1: {[]string{"c1"}, GroupSaved},
+	C0: {[]string{"c0", "fp"}, GroupSaved},
+	B0: {[]string{"b0", "mp"}, GroupTemporary},
+	B1: {[]string{"b1"}, GroupTemporary},
+	A0: {[]string{"a0"}, GroupArgument},
+	A1: {[]string{"a1"}, GroupArgument},
+	A2: {[]string{"a2"}, GroupArgument},
+	A3: {[]string{"a3"}, GroupArgument},
+	RP: {[]string{"rp"}, GroupReturnPointer},
+	SP: {[]string{"sp"}, GroupStackPointer},
+}
@@ -0,0 +1,135 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"go/format"
+	"slices"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+)
+
+// Go returns the source of operations.go of package isa, which has the
+// constants of the operations and their mnemonics and signatures. It
+// fails if the encodings of two instructions collide.
+func Go() ([]byte, error) {
+	if err := ISA.Check(); err != nil {
+		return nil, err
+	}
+
+	blocks := codeBlocks()
+
+	var b strings.Builder
+	b.WriteString("// Code generated by gen.go from package spec; DO NOT EDIT.\n\n")
+	b.WriteString("package isa\n\n")
+	b.WriteString("// Operation code (opcode + function).\n")
+	b.WriteString("type Operation uint16\n\n")
+	b.WriteString("const (\n")
+	for n, blk := range blocks {
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		_, _ = fmt.Fprintf(&b, "\t// %s\n", blk.comment)
+		for _, i := range blk.instructions {
+			_, _ = fmt.Fprintf(&b, "\t%s Operation = 0x%04x\n", i.Name, value(&i))
+		}
+	}
+	b.WriteString(")\n\n")
+
+	// Signatures are declared in the order of their first use.
+	var names []string
+	signatures := map[string]string{}
+	for _, blk := range blocks {
+		for _, i := range blk.instructions {
+			name, definition, err := signature(&i)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := signatures[name]; !ok {
+				names = append(names, name)
+				signatures[name] = definition
+			}
+		}
+	}
+
+	b.WriteString("var (\n")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(&b, "\t%s = %s\n", name, signatures[name])
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("var operations = map[Operation]operationInfo{\n")
+	for n, blk := range blocks {
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		for _, i := range blk.instructions {
+			name, _, _ := signature(&i)
+			_, _ = fmt.Fprintf(&b, "\t%s: {%q, %s},\n", i.Name, i.Mnemonic, name)
+		}
+	}
+	b.WriteString("}\n")
+
+	return format.Source([]byte(b.String()))
+}
+
+// codeBlocks returns the blocks without pseudo-instructions, sorted by the
+// value of their operations.
+func codeBlocks() []block {
+	var blocks []block
+	for _, t := range tables {
+		for _, blk := range t.blocks {
+			blk.instructions = slices.DeleteFunc(slices.Clone(blk.instructions),
+				func(i isaspec.Instruction) bool { return i.Pseudo })
+			slices.SortFunc(blk.instructions, func(x, y isaspec.Instruction) int {
+				return cmp.Compare(value(&x), value(&y))
+			})
+			blocks = append(blocks, blk)
+		}
+	}
+
+	slices.SortFunc(blocks, func(x, y block) int {
+		return cmp.Compare(value(&x.instructions[0]), value(&y.instructions[0]))
+	})
+
+	return blocks
+}
+
+// signature returns the name and the definition of the signature of an
+// instruction. The name has the registers that the instruction names, in
+// order, and an I suffix if it has an immediate, an offset or a target.
+func signature(i *isaspec.Instruction) (string, string, error) {
+	if i.Operands == "" {
+		return "signatureNone", "Signature{}", nil
+	}
+
+	name := "signature"
+	banks := []string{"none", "none", "none"}
+	immediate := false
+	for _, operand := range strings.Split(i.Operands, ", ") {
+		slot, bank := 0, ""
+		switch operand {
+		case "%Z", "%Y", "%X":
+			slot, bank = strings.Index("ZYX", operand[1:]), "integer"
+		case "%C", "%B", "%A":
+			slot, bank = strings.Index("CBA", operand[1:]), "pointer"
+		case "imm", "offset", "target":
+			immediate = true
+			continue
+		default:
+			return "", "", fmt.Errorf("%s: unknown operand %s", i.Mnemonic, operand)
+		}
+
+		name += operand[1:]
+		banks[slot] = bank
+	}
+
+	definition := fmt.Sprintf("Signature{Slots: [numSlots]Bank{%s}}", strings.Join(banks, ", "))
+	if immediate {
+		name += "I"
+		definition = strings.TrimSuffix(definition, "}") + ", Immediate: true}"
+	}
+
+	return name, definition, nil
+}
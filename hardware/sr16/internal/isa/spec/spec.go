@@ -0,0 +1,273 @@
+// Package spec is the specification of the SR16 instruction set, from which
+// the operations of package isa and the tables of the README are generated.
+package spec
+
+import (
+	"fmt"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+)
+
+// ISA is the specification of the instruction set.
+var ISA = newISA()
+
+// table of the README, in the order of the README.
+type table struct {
+	name string
+
+	// Whether the table shows the function in binary.
+	binary bool
+
+	blocks []block
+}
+
+// block of instructions that share a comment in code.
+type block struct {
+	comment      string
+	instructions []isaspec.Instruction
+}
+
+// The opcode determines the format, and the format the function field.
+var opcodeField = isaspec.Field{Name: "opcode", Offset: 28, Width: 4}
+
+var formats = []isaspec.Format{
+	{Name: "A", Bits: 32, Fields: []isaspec.Field{opcodeField, {Name: "func", Offset: 20, Width: 4}}},
+	{Name: "B", Bits: 32, Fields: []isaspec.Field{opcodeField, {Name: "func", Offset: 24, Width: 4}}},
+	{Name: "R", Bits: 32, Fields: []isaspec.Field{opcodeField, {Name: "func", Offset: 0, Width: 12}}},
+}
+
+var opcodes = []isaspec.Opcode{
+	{Value: 0x0, Format: "R", Usage: "Operations on registers only and special operations"},
+	{Value: 0x4, Format: "B", Usage: "Conditional control flow: branch"},
+	{Value: 0x5, Format: "B", Usage: "Store to memory"},
+	{Value: 0x8, Format: "A", Usage: "Unconditional control flow: call, jump, return"},
+	{Value: 0x9, Format: "A", Usage: "Load from memory"},
+	{Value: 0xb, Format: "A", Usage: "Generic arithmetic with immediates"},
+	{Value: 0xe, Format: "A", Usage: "Byte arithmetic with immediates"},
+	{Value: 0xf, Format: "A", Usage: "Halfword arithmetic with immediates"},
+}
+
+var (
+	jalz  = op("JALZ", "jalz", "%Z, %A, offset", 0x8, 0x0, "Jump and link saving to integer register %Z")
+	jal   = op("JAL", "jal", "%C, %A, offset", 0x8, 0x1, "Jump and link")
+	xorbi = op("XORBI", "xor.bi", "%Z, %X, imm", 0xe, 0x2, "Bitwise XOR")
+	xorhi = op("XORHI", "xor.hi", "%Z, %X, imm", 0xf, 0x2, "Bitwise XOR")
+)
+
+var tables = []table{
+	{name: "unconditional", blocks: []block{{"Unconditional control flow.", []isaspec.Instruction{
+		jalz,
+		pseudo(jalz, "rjump", "%A, offset", "Pseudo-instruction: `jalz %zr, %A, offset`"),
+		pseudo(jalz, "jump", "target", "Pseudo-instruction: `jalz %zr, %bp, target`"),
+		pseudo(jalz, "ret", "", "Pseudo-instruction: `jalz %zr, %rp, 0`"),
+		pseudo(jalz, "mret", "", "Pseudo-instruction: `jalz %zr, %mp, 0` (millicode)"),
+		jal,
+		pseudo(jal, "rcall", "%A, offset", "Pseudo-instruction: `jal %rp, %A, offset`"),
+		pseudo(jal, "rmcall", "%A, offset", "Pseudo-instruction: `jal %mp, %A, offset`"),
+		pseudo(jal, "call", "target", "Pseudo-instruction: `jal %rp, %bp, target`"),
+		pseudo(jal, "mcall", "target", "Pseudo-instruction: `jal %mp, %bp, target` (millicode)"),
+	}}}},
+
+	{name: "conditional", binary: true, blocks: []block{
+		{"Conditional control flow on integers.", []isaspec.Instruction{
+			op("BEQ", "beq", "%Y, %X, target", 0x4, 0x0, "Branch to target if %Y = %X"),
+			op("BNE", "bne", "%Y, %X, target", 0x4, 0x1, "Branch to target if %Y ≠ %X"),
+			op("BLTS", "blt.s", "%Y, %X, target", 0x4, 0x4, "Branch to target if %Y < %X (signed)"),
+			op("BLTU", "blt.u", "%Y, %X, target", 0x4, 0x5, "Branch to target if %Y < %X (unsigned)"),
+			op("BGES", "bge.s", "%Y, %X, target", 0x4, 0x6, "Branch to target if %Y ≥ %X (signed)"),
+			op("BGEU", "bge.u", "%Y, %X, target", 0x4, 0x7, "Branch to target if %Y ≥ %X (unsigned)"),
+		}},
+		{"Conditional control flow on pointers.", []isaspec.Instruction{
+			op("BEQA", "beq.a", "%B, %A, target", 0x4, 0x8, "Branch to target if %B = %A"),
+			op("BNEA", "bne.a", "%B, %A, target", 0x4, 0x9, "Branch to target if %B ≠ %A"),
+			op("BZRA", "bzr.a", "%B, target", 0x4, 0xa, "Branch to target if %B = 0"),
+			op("BNZA", "bnz.a", "%B, target", 0x4, 0xb, "Branch to target if %B ≠ 0"),
+			op("BLTA", "blt.a", "%B, %A, target", 0x4, 0xc, "Branch to target if %B < %A"),
+			op("BGEA", "bge.a", "%B, %A, target", 0x4, 0xe, "Branch to target if %B ≥ %A"),
+		}},
+	}},
+
+	{name: "loads", binary: true, blocks: []block{{"Load from memory.", []isaspec.Instruction{
+		op("LOADSB", "load.sb", "%Z, %A, offset", 0x9, 0x0, "Read byte at [%A+offset], sign extend, and write to %Z"),
+		op("LOADH", "load.h", "%Z, %A, offset", 0x9, 0x1, "Read half at [%A+offset] and write to %Z"),
+		op("LOADUB", "load.ub", "%Z, %A, offset", 0x9, 0x4, "Read byte at [%A+offset], zero extend, and write to %Z"),
+		op("LOADA", "load.a", "%C, %A, offset", 0x9, 0x9, "Read address at [%A+offset] and write to %C"),
+	}}}},
+
+	{name: "stores", binary: true, blocks: []block{{"Store to memory.", []isaspec.Instruction{
+		op("STOREB", "store.b", "%Y, %A, offset", 0x5, 0x0, "Read byte at %Y and write to [%A+offset]"),
+		op("STOREH", "store.h", "%Y, %A, offset", 0x5, 0x1, "Read half at %Y and write to [%A+offset]"),
+		op("STOREA", "store.a", "%B, %A, offset", 0x5, 0x9, "Read address at %B and write to [%A+offset]"),
+	}}}},
+
+	{name: "byte-immediates", blocks: []block{{"Byte arithmetic with immediates.", []isaspec.Instruction{
+		op("ANDBI", "and.bi", "%Z, %X, imm", 0xe, 0x0, "Bitwise AND / Logical AND"),
+		op("ORBI", "or.bi", "%Z, %X, imm", 0xe, 0x1, "Bitwise OR / Logical OR"),
+		xorbi,
+		pseudo(xorbi, "inv.b", "%Z, %X", "Pseudo-instruction: bitwise NOT (`xor.bi %Z, %X, -1`)"),
+		pseudo(xorbi, "not.b", "%Z, %X", "Pseudo-instruction: logical NOT (`xor.bi %Z, %X, 1`)"),
+		op("SRABI", "sra.bi", "%Z, %X, imm", 0xe, 0x3, "Shift right (arithmetic)"),
+		op("SRLBI", "srl.bi", "%Z, %X, imm", 0xe, 0x4, "Shift right (logic)"),
+		op("SLLBI", "sll.bi", "%Z, %X, imm", 0xe, 0x5, "Shift left (logic)"),
+		op("ADDBI", "add.bi", "%Z, %X, imm", 0xe, 0x6, "Addition"),
+	}}}},
+
+	{name: "half-immediates", blocks: []block{{"Halfword arithmetic with immediates.", []isaspec.Instruction{
+		op("ANDHI", "and.hi", "%Z, %X, imm", 0xf, 0x0, "Bitwise AND / Logical AND"),
+		op("ORHI", "or.hi", "%Z, %X, imm", 0xf, 0x1, "Bitwise OR / Logical OR"),
+		xorhi,
+		pseudo(xorhi, "inv.h", "%Z, %X", "Pseudo-instruction: bitwise NOT (`xor.hi %Z, %X, -1`)"),
+		pseudo(xorhi, "not.h", "%Z, %X", "Pseudo-instruction: logical NOT (`xor.hi %Z, %X, 1`)"),
+		op("SRAHI", "sra.hi", "%Z, %X, imm", 0xf, 0x3, "Shift right (arithmetic)"),
+		op("SRLHI", "srl.hi", "%Z, %X, imm", 0xf, 0x4, "Shift right (logic)"),
+		op("SLLHI", "sll.hi", "%Z, %X, imm", 0xf, 0x5, "Shift left (logic)"),
+		op("ADDHI", "add.hi", "%Z, %X, imm", 0xf, 0x6, "Addition"),
+	}}}},
+
+	{name: "immediates", blocks: []block{{"Comparisons and pointer arithmetic with immediates.", []isaspec.Instruction{
+		op("SLTSI", "slt.si", "%Z, %X, imm", 0xb, 0x0, "Set %Z to 1 if %X < imm (signed), else 0"),
+		op("SLTUI", "slt.ui", "%Z, %X, imm", 0xb, 0x1, "Set %Z to 1 if %X < imm (unsigned), else 0"),
+		op("ADDAI", "add.ai", "%C, %A, imm", 0xb, 0x8, "Add integer to pointer"),
+	}}}},
+
+	{name: "special", blocks: []block{{"Special operations.", []isaspec.Instruction{
+		op("ILLEGAL", "illegal", "", 0x0, 0x000, "Traps on execution of zero-initialised memory"),
+	}}}},
+
+	{name: "byte-registers", blocks: []block{{"Operations on bytes in registers.", []isaspec.Instruction{
+		op("ANDB", "and.b", "%Z, %Y, %X", 0x0, 0x100, "Bitwise AND / Logical AND"),
+		op("ORB", "or.b", "%Z, %Y, %X", 0x0, 0x101, "Bitwise OR / Logical OR"),
+		op("XORB", "xor.b", "%Z, %Y, %X", 0x0, 0x102, "Bitwise XOR"),
+		op("SRAB", "sra.b", "%Z, %Y, %X", 0x0, 0x103, "Shift right (arithmetic)"),
+		op("SRLB", "srl.b", "%Z, %Y, %X", 0x0, 0x104, "Shift right (logical)"),
+		op("SLLB", "sll.b", "%Z, %Y, %X", 0x0, 0x105, "Shift left (logical)"),
+		op("ADDB", "add.b", "%Z, %Y, %X", 0x0, 0x106, "Addition"),
+		op("SUBB", "sub.b", "%Z, %Y, %X", 0x0, 0x107, "Subtraction"),
+	}}}},
+
+	{name: "half-registers", blocks: []block{{"Operations on halfwords in registers.", []isaspec.Instruction{
+		op("ANDH", "and.h", "%Z, %Y, %X", 0x0, 0x110, "Bitwise AND / Logical AND"),
+		op("ORH", "or.h", "%Z, %Y, %X", 0x0, 0x111, "Bitwise OR / Logical OR"),
+		op("XORH", "xor.h", "%Z, %Y, %X", 0x0, 0x112, "Bitwise XOR"),
+		op("SRAH", "sra.h", "%Z, %Y, %X", 0x0, 0x113, "Shift right (arithmetic)"),
+		op("SRLH", "srl.h", "%Z, %Y, %X", 0x0, 0x114, "Shift right (logical)"),
+		op("SLLH", "sll.h", "%Z, %Y, %X", 0x0, 0x115, "Shift left (logical)"),
+		op("ADDH", "add.h", "%Z, %Y, %X", 0x0, 0x116, "Addition"),
+		op("SUBH", "sub.h", "%Z, %Y, %X", 0x0, 0x117, "Subtraction"),
+	}}}},
+
+	{name: "registers", blocks: []block{
+		{"Comparisons of full registers.", []isaspec.Instruction{
+			op("SLTS", "slt.s", "%Z, %Y, %X", 0x0, 0x200, "Set %Z to 1 if %Y < %X (signed), else 0"),
+			op("SLTU", "slt.u", "%Z, %Y, %X", 0x0, 0x201, "Set %Z to 1 if %Y < %X (unsigned), else 0"),
+		}},
+		{"Pointer arithmetic on registers.", []isaspec.Instruction{
+			op("ADDA", "add.a", "%C, %B, %X", 0x0, 0x208, "Add integer offset to pointer"),
+			op("SUBA", "sub.a", "%C, %B, %X", 0x0, 0x209, "Subtract integer offset from pointer"),
+			op("DIFF", "diff", "%Z, %B, %A", 0x0, 0x210, "Difference between two pointers"),
+			op("PTOZ", "ptoz", "%Z, %A", 0x0, 0x211, "Pointer to integer conversion"),
+			op("ZTOP", "ztop", "%C, %X", 0x0, 0x212, "Integer to pointer conversion"),
+		}},
+	}},
+}
+
+// op returns an instruction of the format of its opcode.
+func op(name, mnemonic, operands string, opcode, function uint32, semantics string) isaspec.Instruction {
+	format := ""
+	for _, o := range opcodes {
+		if o.Value == opcode {
+			format = o.Format
+		}
+	}
+
+	return isaspec.Instruction{
+		Name:      name,
+		Mnemonic:  mnemonic,
+		Operands:  operands,
+		Semantics: semantics,
+		Format:    format,
+		Values:    map[string]uint32{"opcode": opcode, "func": function},
+	}
+}
+
+// pseudo returns a pseudo-instruction that expands into the instruction.
+func pseudo(i isaspec.Instruction, mnemonic, operands, semantics string) isaspec.Instruction {
+	i.Name = ""
+	i.Mnemonic, i.Operands, i.Semantics = mnemonic, operands, semantics
+	i.Pseudo = true
+	return i
+}
+
+func newISA() *isaspec.ISA {
+	isa := &isaspec.ISA{Name: "SR16", Formats: formats, Opcodes: opcodes}
+	for _, t := range tables {
+		for _, b := range t.blocks {
+			isa.Instructions = append(isa.Instructions, b.instructions...)
+		}
+	}
+
+	return isa
+}
+
+// value of the operation of an instruction: the opcode in the four most
+// significant bits and the function in the others.
+func value(i *isaspec.Instruction) uint16 {
+	return uint16(i.Values["opcode"]<<12 | i.Values["func"])
+}
+
+// Markdown returns the tables of the README by name.
+func Markdown() map[string]string {
+	sections := map[string]string{"opcodes": opcodeTable().String()}
+	for _, t := range tables {
+		sections[t.name] = t.markdown().String()
+	}
+
+	return sections
+}
+
+func opcodeTable() *isaspec.Table {
+	t := &isaspec.Table{Header: []string{"Hexadecimal", "Binary", "Format", "Usage"}}
+	for _, o := range opcodes {
+		t.Rows = append(t.Rows, []string{
+			fmt.Sprintf("%x", o.Value), fmt.Sprintf("%04b", o.Value), o.Format, o.Usage,
+		})
+	}
+
+	return t
+}
+
+func (t *table) markdown() *isaspec.Table {
+	header := []string{"Instruction", "Opcode", "Func", "Semantics"}
+	if t.binary {
+		header = []string{"Instruction", "Opcode", "Func", "Binary", "Semantics"}
+	}
+
+	// Mnemonics are padded to align the operands.
+	width := 0
+	for _, b := range t.blocks {
+		for _, i := range b.instructions {
+			width = max(width, len(i.Mnemonic))
+		}
+	}
+
+	markdown := &isaspec.Table{Header: header}
+	for _, b := range t.blocks {
+		for _, i := range b.instructions {
+			syntax := "`" + i.Mnemonic + "`"
+			if i.Operands != "" {
+				syntax = fmt.Sprintf("`%-*s %s`", width, i.Mnemonic, i.Operands)
+			}
+			if i.Name == "ILLEGAL" {
+				syntax = "Illegal instruction"
+			}
+
+			row := []string{syntax, fmt.Sprintf("%x", i.Values["opcode"]), fmt.Sprintf("%x", i.Values["func"])}
+			if t.binary {
+				row = append(row, fmt.Sprintf("%04b", i.Values["func"]))
+			}
+			markdown.Rows = append(markdown.Rows, append(row, i.Semantics))
+		}
+	}
+
+	return markdown
+}
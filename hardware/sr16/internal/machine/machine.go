@@ -0,0 +1,316 @@
+// Package machine represents an sr16 machine.
+//
+// All sr16 code is position-independent: the loader puts the start of the
+// program in BP, and the targets of branches, jumps and calls are relative
+// to it. The same program can therefore be loaded at any address.
+package machine
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jespert/primordial/hardware/internal/cpu"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/internal/image"
+	"github.com/jespert/primordial/hardware/internal/memory"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+	"github.com/jespert/primordial/hardware/sr16/internal/state"
+)
+
+// Machine of the machine (registers and memory).
+type Machine struct {
+	memory   memory.Memory
+	integers state.IntegerRegisters
+	pointers state.PointerRegisters
+
+	// Instruction pointer.
+	ip memory.Address
+}
+
+// New creates a new Machine with the program base address in BP and IP.
+func New() *Machine {
+	m := &Machine{ip: ProgramBase}
+	m.pointers.Write(isa.BP, ProgramBase)
+	return m
+}
+
+// Trap is an error raised by the execution of an instruction.
+type Trap = cpu.Trap
+
+// Dump the state in human-friendly string representation to the given writer.
+func (m *Machine) Dump(w io.Writer) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	_, _ = fmt.Fprintf(w, "IP: 0x%04x\n", m.ip)
+	_, _ = fmt.Fprint(w, "\nNon-zero integer registers:\n")
+	m.integers.DumpNamed(w)
+
+	_, _ = fmt.Fprint(w, "\nNon-null pointer registers:\n")
+	m.pointers.DumpNamed(w)
+
+	_, _ = fmt.Fprint(w, "\nMemory:\n")
+	m.memory.Dump(w)
+}
+
+// IP returns the instruction pointer.
+func (m *Machine) IP() memory.Address {
+	return m.ip
+}
+
+// IntegerRegisters returns a copy of the integer register file.
+func (m *Machine) IntegerRegisters() state.IntegerRegisters {
+	return m.integers
+}
+
+// PointerRegisters returns a copy of the pointer register file.
+func (m *Machine) PointerRegisters() state.PointerRegisters {
+	return m.pointers
+}
+
+// ReadMemory copies memory from the address into data.
+func (m *Machine) ReadMemory(address memory.Address, data []byte) {
+	m.memory.ReadRaw(address, data)
+}
+
+// Step executes the instruction at the IP. If the instruction traps, the
+// error is a *Trap.
+func (m *Machine) Step() error {
+	encoded, err := m.fetchNextInstruction()
+	if err != nil {
+		return m.trap(err)
+	}
+
+	instruction := isa.Decode(encoded)
+	nextIP, err := m.execute(&instruction)
+	if err != nil {
+		return m.trap(fmt.Errorf("failed to execute instruction at %04x: %w", m.ip, err))
+	}
+
+	m.ip = nextIP
+	return nil
+}
+
+// execute the instruction and return the address of the next one.
+func (m *Machine) execute(instruction *isa.DecodedInstruction) (memory.Address, error) {
+	nextIP := m.ip + instructionSize
+
+	// Operands are read from both banks: the operation determines which
+	// ones are meaningful, and the others are harmless.
+	y := m.integers.Read(instruction.Y)
+	x := m.integers.Read(instruction.X)
+	b := m.pointers.Read(instruction.B)
+	a := m.pointers.Read(instruction.A)
+	imm := instruction.Imm
+	address := memory.Address(a + imm)
+	target := memory.Address(m.pointers.Read(isa.BP) + imm)
+
+	switch op := instruction.Operation; op {
+	case isa.ILLEGAL:
+		return 0, fmt.Errorf("illegal instruction")
+
+	case isa.JALZ:
+		m.integers.Write(instruction.Z, uint16(nextIP))
+		nextIP = address
+
+	case isa.JAL:
+		m.pointers.Write(instruction.C, uint16(nextIP))
+		nextIP = address
+
+	case isa.BEQ, isa.BNE, isa.BLTS, isa.BGES, isa.BLTU, isa.BGEU:
+		if condition(op).Holds(y, x) {
+			nextIP = target
+		}
+
+	case isa.BEQA, isa.BNEA, isa.BZRA, isa.BNZA, isa.BLTA, isa.BGEA:
+		if pointerBranchTaken(op, b, a) {
+			nextIP = target
+		}
+
+	case isa.STOREB:
+		if err := m.memory.WriteB(address, byte(y)); err != nil {
+			return 0, err
+		}
+
+	case isa.STOREH:
+		if err := m.memory.WriteH(address, int16(y)); err != nil {
+			return 0, err
+		}
+
+	case isa.STOREA:
+		if err := m.memory.WriteH(address, int16(b)); err != nil {
+			return 0, err
+		}
+
+	case isa.LOADSB:
+		v, err := m.memory.ReadB(address)
+		if err != nil {
+			return 0, err
+		}
+		m.integers.Write(instruction.Z, uint16(int8(v)))
+
+	case isa.LOADUB:
+		v, err := m.memory.ReadB(address)
+		if err != nil {
+			return 0, err
+		}
+		m.integers.Write(instruction.Z, uint16(v))
+
+	case isa.LOADH:
+		v, err := m.memory.ReadH(address)
+		if err != nil {
+			return 0, err
+		}
+		m.integers.Write(instruction.Z, uint16(v))
+
+	case isa.LOADA:
+		v, err := m.memory.ReadH(address)
+		if err != nil {
+			return 0, err
+		}
+		m.pointers.Write(instruction.C, uint16(v))
+
+	case isa.SLTSI:
+		m.integers.Write(instruction.Z, cpu.SetIf(int16(x) < int16(imm)))
+
+	case isa.SLTUI:
+		m.integers.Write(instruction.Z, cpu.SetIf(x < imm))
+
+	case isa.ADDAI:
+		m.pointers.Write(instruction.C, a+imm)
+
+	case isa.SLTS:
+		m.integers.Write(instruction.Z, cpu.SetIf(int16(y) < int16(x)))
+
+	case isa.SLTU:
+		m.integers.Write(instruction.Z, cpu.SetIf(y < x))
+
+	case isa.ADDA:
+		m.pointers.Write(instruction.C, b+x)
+
+	case isa.SUBA:
+		m.pointers.Write(instruction.C, b-x)
+
+	case isa.DIFF:
+		m.integers.Write(instruction.Z, b-a)
+
+	case isa.PTOZ:
+		m.integers.Write(instruction.Z, a)
+
+	case isa.ZTOP:
+		m.pointers.Write(instruction.C, x)
+
+	case isa.ANDBI, isa.ORBI, isa.XORBI, isa.SRABI, isa.SRLBI, isa.SLLBI, isa.ADDBI:
+		m.integers.Write(instruction.Z, cpu.ALUB(uint16(op), x, imm))
+
+	case isa.ANDHI, isa.ORHI, isa.XORHI, isa.SRAHI, isa.SRLHI, isa.SLLHI, isa.ADDHI:
+		m.integers.Write(instruction.Z, cpu.ALUH(uint16(op), x, imm))
+
+	case isa.ANDB, isa.ORB, isa.XORB, isa.SRAB, isa.SRLB, isa.SLLB, isa.ADDB, isa.SUBB:
+		m.integers.Write(instruction.Z, cpu.ALUB(uint16(op), y, x))
+
+	case isa.ANDH, isa.ORH, isa.XORH, isa.SRAH, isa.SRLH, isa.SLLH, isa.ADDH, isa.SUBH:
+		m.integers.Write(instruction.Z, cpu.ALUH(uint16(op), y, x))
+
+	default:
+		return 0, fmt.Errorf("unknown operation: %04x", uint16(op))
+	}
+
+	return nextIP, nil
+}
+
+func (m *Machine) trap(err error) *Trap {
+	return &Trap{IP: m.ip, Err: err}
+}
+
+// LoadProgram copies position-independent code and data into memory at the
+// base address, and moves both BP and the IP to it.
+func (m *Machine) LoadProgram(base memory.Address, data []byte) error {
+	if len(data)+int(base) > memory.Size {
+		return fmt.Errorf("program too large for memory: %d bytes", len(data))
+	}
+
+	m.memory.WriteRaw(base, data)
+	m.pointers.Write(isa.BP, uint16(base))
+	m.ip = base
+	return nil
+}
+
+// LoadExecutable loads an sr16 executable at the base address and clears
+// its zero-initialised data. BP is moved to the base address and the IP to
+// the entrypoint, which is relative to BP like every other address of the
+// executable.
+func (m *Machine) LoadExecutable(f *exe.File, base memory.Address) error {
+	h := f.Header
+	if h.Arch != exe.PackName("SR16") || h.Endianness != exe.LittleEndian {
+		return fmt.Errorf("not a little-endian sr16 executable: %s", h.Arch)
+	}
+
+	data := f.Segments()
+	data = append(data, make([]byte, f.ZIDataSize)...)
+	if err := m.LoadProgram(base, data); err != nil {
+		return err
+	}
+
+	m.ip = base + memory.Address(f.Entrypoint)
+	return nil
+}
+
+// LoadImage loads a memory image and moves the IP to its entrypoint, or to
+// the program base address if it has none. Images have absolute addresses,
+// so BP is moved to the program base address.
+func (m *Machine) LoadImage(img *image.Image) {
+	m.memory.LoadImage(img)
+	m.pointers.Write(isa.BP, ProgramBase)
+
+	m.ip = ProgramBase
+	if img.HasEntrypoint {
+		m.ip = memory.Address(img.Entrypoint)
+	}
+}
+
+func (m *Machine) fetchNextInstruction() (isa.EncodedInstruction, error) {
+	v, err := cpu.Fetch(&m.memory, m.ip)
+	return isa.EncodedInstruction(v), err
+}
+
+// condition of a branch operation, which compares Y with X.
+func condition(op isa.Operation) cpu.Condition {
+	switch op {
+	case isa.BEQ:
+		return cpu.Equal
+	case isa.BNE:
+		return cpu.NotEqual
+	case isa.BLTS:
+		return cpu.LessSigned
+	case isa.BGES:
+		return cpu.GreaterEqualSigned
+	case isa.BLTU:
+		return cpu.LessUnsigned
+	default:
+		return cpu.GreaterEqualUnsigned
+	}
+}
+
+// pointerBranchTaken compares pointers as unsigned addresses.
+func pointerBranchTaken(op isa.Operation, b, a uint16) bool {
+	switch op {
+	case isa.BEQA:
+		return b == a
+	case isa.BNEA:
+		return b != a
+	case isa.BZRA:
+		return b == 0
+	case isa.BNZA:
+		return b != 0
+	case isa.BLTA:
+		return b < a
+	default:
+		return b >= a
+	}
+}
+
+// ProgramBase is the default load address of programs: the start of the
+// upper half of memory, where user programs run.
+const ProgramBase = 0x8000
+
+// All instructions are 32 bits long.
+const instructionSize = 4
@@ -0,0 +1,149 @@
+package machine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/internal/memory"
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_Dump_empty(t *testing.T) {
+	m := New()
+	verify(t, m)
+}
+
+func TestMachine_Step_program(t *testing.T) {
+	m := withProgram(t, ProgramBase, testProgram...)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verify(t, m)
+}
+
+func TestMachine_Step_relocation(t *testing.T) {
+	// The program computes the same results wherever it is loaded, apart
+	// from absolute addresses.
+	for _, base := range []memory.Address{0x8000, 0x9234} {
+		m := withProgram(t, base, testProgram...)
+		for range testProgramSteps {
+			require.Success(t, m.Step())
+		}
+
+		integers, pointers := m.IntegerRegisters(), m.PointerRegisters()
+		expect.Equal(t, base+0x20, m.IP())
+		expect.Equal(t, 0x46, integers.Read(isa.X2))
+		expect.Equal(t, 0x60, integers.Read(isa.X3))
+		expect.Equal(t, uint16(base+0x60), integers.Read(isa.X4))
+		expect.Equal(t, uint16(base), pointers.Read(isa.BP))
+		expect.Equal(t, uint16(base+0x60), pointers.Read(isa.A1))
+	}
+}
+
+func TestMachine_Step_illegal(t *testing.T) {
+	// Zero-initialised memory traps.
+	m := New()
+	if err := m.Step(); err == nil {
+		t.Error("expected illegal instruction to fail")
+	}
+}
+
+func TestMachine_Step_unaligned(t *testing.T) {
+	m := withProgram(t, ProgramBase, isa.DecodedInstruction{
+		Operation: isa.JALZ,
+		A:         isa.BP,
+		Imm:       1,
+	})
+	require.Success(t, m.Step())
+	if err := m.Step(); err == nil {
+		t.Error("expected unaligned IP to fail")
+	}
+}
+
+func TestMachine_LoadExecutable(t *testing.T) {
+	f := &exe.File{
+		Header: exe.Header{
+			Version: 1,
+			Size:    exe.Size16,
+			Arch:    exe.PackName("SR16"),
+		},
+		Code:       encodeProgram(testProgram),
+		ZIDataSize: 0x10,
+		Entrypoint: 0x1c,
+	}
+
+	m := New()
+	require.Success(t, m.LoadExecutable(f, 0xa000))
+	expect.Equal(t, 0xa01c, m.IP())
+	pointers := m.PointerRegisters()
+	expect.Equal(t, 0xa000, pointers.Read(isa.BP))
+
+	f.Header.Arch = exe.PackName("R16")
+	if err := m.LoadExecutable(f, 0xa000); err == nil {
+		t.Error("expected r16 executable to fail")
+	}
+}
+
+func verify(t *testing.T, m *Machine) {
+	t.Helper()
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func withProgram(t testing.TB, base memory.Address, instructions ...isa.DecodedInstruction) *Machine {
+	m := New()
+	require.Success(t, m.LoadProgram(base, encodeProgram(instructions)))
+	return m
+}
+
+func encodeProgram(instructions []isa.DecodedInstruction) []byte {
+	var buffer bytes.Buffer
+	for _, instruction := range instructions {
+		encoded := isa.Encode(instruction)
+		buffer.WriteByte(byte(encoded))
+		buffer.WriteByte(byte(encoded >> 8))
+		buffer.WriteByte(byte(encoded >> 16))
+		buffer.WriteByte(byte(encoded >> 24))
+	}
+
+	return buffer.Bytes()
+}
+
+// A short program that exercises every class of operation. Addresses are
+// relative to BP.
+var testProgram = []isa.DecodedInstruction{
+	// 00: Materialise a constant and a pointer to data.
+	{Operation: isa.ADDHI, Z: isa.X0, X: isa.ZR, Imm: 0x1234},
+	{Operation: isa.ADDAI, C: isa.A0, A: isa.BP, Imm: 0x0060},
+	// 08: Store the constant and load back its most significant byte.
+	{Operation: isa.STOREH, Y: isa.X0, A: isa.A0},
+	{Operation: isa.LOADSB, Z: isa.X1, A: isa.A0, Imm: 1},
+	// 10: Byte arithmetic on registers.
+	{Operation: isa.ADDB, Z: isa.X2, Y: isa.X0, X: isa.X1},
+	// 14: Skip the next instruction.
+	{Operation: isa.BNE, Y: isa.ZR, X: isa.X2, Imm: 0x001c},
+	{Operation: isa.ADDHI, Z: isa.Y0, X: isa.ZR, Imm: 1},
+	// 1c: Call, and halt on return.
+	{Operation: isa.JAL, C: isa.RP, A: isa.BP, Imm: 0x0028},
+	{Operation: isa.JALZ, Z: isa.ZR, A: isa.BP, Imm: 0x0020},
+	{Operation: isa.ILLEGAL},
+	// 28: Pointer arithmetic.
+	{Operation: isa.STOREA, B: isa.A0, A: isa.A0, Imm: 2},
+	{Operation: isa.LOADA, C: isa.A1, A: isa.A0, Imm: 2},
+	{Operation: isa.DIFF, Z: isa.X3, B: isa.A1, A: isa.BP},
+	{Operation: isa.PTOZ, Z: isa.X4, A: isa.A1},
+	{Operation: isa.SUBA, C: isa.A2, B: isa.A1, X: isa.X3},
+	// 3c: Trap if the pointers differ.
+	{Operation: isa.BNEA, B: isa.A2, A: isa.BP, Imm: 0x0024},
+	// 40: Return.
+	{Operation: isa.JALZ, Z: isa.ZR, A: isa.RP},
+}
+
+// Number of instructions retired by testProgram until it halts.
+const testProgramSteps = 15
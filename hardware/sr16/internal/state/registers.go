@@ -0,0 +1,111 @@
+// Package state implements the register files of the sr16 machine.
+//
+// The register files are their own package to enforce the invariant that
+// the integer register zero is always zero. Memory is shared with r16.
+package state
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+	"github.com/jespert/primordial/internal/quality/assert"
+)
+
+// IntegerRegisters is the integer register file, where ZR is hardwired to
+// zero.
+type IntegerRegisters struct {
+	values [NumRegisters - 1]uint16
+}
+
+func (r *IntegerRegisters) Read(register isa.IntegerRegister) uint16 {
+	assertValidRegister(uint8(register))
+
+	if register == isa.ZR {
+		return 0
+	}
+
+	return r.values[register-1]
+}
+
+func (r *IntegerRegisters) Write(register isa.IntegerRegister, value uint16) {
+	assertValidRegister(uint8(register))
+
+	if register != isa.ZR {
+		r.values[register-1] = value
+	}
+}
+
+// DumpNamed shows the non-zero registers by their aliases and annotates
+// them with their role in the calling convention.
+func (r *IntegerRegisters) DumpNamed(w io.Writer) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	allZero := true
+	for i := range NumRegisters {
+		register := isa.IntegerRegister(i)
+		v := r.Read(register)
+		if v == 0 {
+			continue
+		}
+
+		allZero = false
+		_, _ = fmt.Fprintf(
+			w,
+			"%s: 0x%04x S:%d U:%d (%s)\n",
+			register,
+			v,
+			int16(v),
+			v,
+			register.Group(),
+		)
+	}
+
+	if allZero {
+		_, _ = fmt.Fprint(w, "(none)\n")
+	}
+}
+
+// PointerRegisters is the pointer register file. None of its registers is
+// hardwired: BP is set by the loader, but programs can still overwrite it.
+type PointerRegisters struct {
+	values [NumRegisters]uint16
+}
+
+func (r *PointerRegisters) Read(register isa.PointerRegister) uint16 {
+	assertValidRegister(uint8(register))
+	return r.values[register]
+}
+
+func (r *PointerRegisters) Write(register isa.PointerRegister, value uint16) {
+	assertValidRegister(uint8(register))
+	r.values[register] = value
+}
+
+// DumpNamed shows the non-null registers by their aliases and annotates
+// them with their role in the calling convention.
+func (r *PointerRegisters) DumpNamed(w io.Writer) {
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	allZero := true
+	for i := range NumRegisters {
+		register := isa.PointerRegister(i)
+		v := r.Read(register)
+		if v == 0 {
+			continue
+		}
+
+		allZero = false
+		_, _ = fmt.Fprintf(w, "%s: 0x%04x (%s)\n", register, v, register.Group())
+	}
+
+	if allZero {
+		_, _ = fmt.Fprint(w, "(none)\n")
+	}
+}
+
+func assertValidRegister(register uint8) {
+	inBounds := register < NumRegisters
+	assert.Truef(inBounds, "register %d is out of bounds", register)
+}
+
+// Number of registers in each bank.
+const NumRegisters = 16
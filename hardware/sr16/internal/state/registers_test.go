@@ -0,0 +1,64 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/jespert/primordial/hardware/sr16/internal/isa"
+	"github.com/jespert/primordial/hardware/sr16/internal/state"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+)
+
+func TestIntegerRegisters_Write_to_zero_is_hardcoded(t *testing.T) {
+	var file state.IntegerRegisters
+	file.Write(isa.ZR, 1)
+	expect.Equal(t, 0, file.Read(isa.ZR))
+}
+
+func TestIntegerRegisters_Write_and_read_from_general_register(t *testing.T) {
+	var file state.IntegerRegisters
+	for i := 1; i < state.NumRegisters; i++ {
+		r := isa.IntegerRegister(i)
+		file.Write(r, uint16(i))
+		expect.Equal(t, uint16(i), file.Read(r))
+	}
+}
+
+func TestIntegerRegisters_Read_out_of_bounds(t *testing.T) {
+	var file state.IntegerRegisters
+	expect.Panic(t, func() { file.Read(state.NumRegisters) })
+}
+
+func TestPointerRegisters_Write_and_read(t *testing.T) {
+	// Unlike ZR, BP is an ordinary register.
+	var file state.PointerRegisters
+	for i := range state.NumRegisters {
+		r := isa.PointerRegister(i)
+		file.Write(r, uint16(0x8000+i))
+		expect.Equal(t, uint16(0x8000+i), file.Read(r))
+	}
+}
+
+func TestPointerRegisters_Read_out_of_bounds(t *testing.T) {
+	var file state.PointerRegisters
+	expect.Panic(t, func() { file.Read(state.NumRegisters) })
+}
+
+func TestRegisters_DumpNamed(t *testing.T) {
+	var integers state.IntegerRegisters
+	integers.Write(isa.Z0, 0xfff0)
+	integers.Write(isa.X0, 10)
+
+	var pointers state.PointerRegisters
+	pointers.Write(isa.BP, 0x8000)
+	pointers.Write(isa.FP, 0xfff8)
+	pointers.Write(isa.RP, 0x8004)
+
+	verifier := approval.NewTextVerifier(t)
+	integers.DumpNamed(verifier.Writer())
+	pointers.DumpNamed(verifier.Writer())
+
+	var empty state.PointerRegisters
+	empty.DumpNamed(verifier.Writer())
+	verifier.Verify()
+}
@@ -0,0 +1,8 @@
+// Package isamap provides the sr16 instruction set to the encoding map tool,
+// which cannot import the internal packages of sr16.
+package isamap
+
+import "github.com/jespert/primordial/hardware/sr16/internal/isa/spec"
+
+// ISA is the specification of the instruction set of sr16.
+var ISA = spec.ISA
@@ -0,0 +1,63 @@
+// Package density is the SRX target of the code density tool, which cannot
+// import the internal packages of SRX.
+package density
+
+import (
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	shareddensity "github.com/jespert/primordial/hardware/internal/density"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/srx/internal/asm"
+	"github.com/jespert/primordial/hardware/srx/internal/isa"
+	"github.com/jespert/primordial/hardware/srx/internal/machine"
+)
+
+// Targets run benchmarks on each variant of SRX. They are 16-bit machines,
+// like r16 and sr16.
+//
+// Their results are provisional: only some opcodes have operations, the
+// others being provisional allocations, so the lengths of the instructions
+// come from those allocations rather than from the design of SRX.
+var Targets = []shareddensity.Target{
+	target{"srx-tab", isa.Tab},
+	target{"srx-flags", isa.Flags},
+}
+
+type target struct {
+	name    string
+	variant *isa.Variant
+}
+
+func (t target) Name() string {
+	return t.name
+}
+
+func (t target) Provisional() bool {
+	return true
+}
+
+func (t target) Assemble(sources ...sharedasm.Source) (*exe.File, error) {
+	return asm.Assemble(t.variant, sources...)
+}
+
+func (t target) Lengths(code []byte) ([]int, error) {
+	instructions, _, err := t.variant.DecodeAll(code)
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := make([]int, len(instructions))
+	for i := range instructions {
+		lengths[i] = instructions[i].Size()
+	}
+
+	return lengths, nil
+}
+
+func (t target) Run(f *exe.File, steps int) (int, error) {
+	m := machine.New[uint16](t.variant)
+	if err := m.LoadExecutable(f, machine.ProgramBase); err != nil {
+		return 0, err
+	}
+
+	return shareddensity.RunUntilHalt(steps, func() uint64 { return uint64(m.IP()) }, m.Step)
+}
@@ -0,0 +1,231 @@
+// Package asm assembles SRX programs for either variant.
+//
+// Only the operations that the emulator runs can be assembled. The
+// operands of each operation follow the order of srx_flags.md: destination
+// first, then sources, then the immediate. Branch, call.x and jump targets,
+// and the target of aiupc.l, are relative to the instruction, so programs
+// are position-independent. They are assembled at address zero, so that
+// "call.ax %rp, %bp, f" calls f wherever BP points to the program.
+//
+// The instructions of the flags variant that share an opcode depending on
+// whether Z is zero cannot have ZR as their destination.
+package asm
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/srx/internal/isa"
+)
+
+// Arch is the name of the architecture in executables.
+var Arch = exe.PackName("SRX")
+
+// ArchFlagFlags is set in the architecture flags of executables of the
+// flags variant.
+const ArchFlagFlags = 1
+
+// Assemble the sources into a position-independent executable for the
+// variant. The executable includes a debug line table.
+func Assemble(variant *isa.Variant, sources ...asm.Source) (*exe.File, error) {
+	var flags uint64
+	if variant.HasFlags {
+		flags = ArchFlagFlags
+	}
+
+	return asm.Assemble(asm.Config{
+		Arch: arch{variant},
+		Header: exe.Header{
+			Endianness: exe.LittleEndian,
+			Type:       exe.StaticExecutable,
+			Arch:       Arch,
+			ArchFlags:  flags,
+		},
+	}, sources...)
+}
+
+type arch struct {
+	variant *isa.Variant
+}
+
+func (a arch) Instruction(mnemonic string, operands []asm.Operand) (int, asm.Encoder, error) {
+	o, ok := isa.ParseOperation(mnemonic)
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown instruction %s", mnemonic)
+	}
+
+	template, ok := a.variant.Template(o)
+	if !ok {
+		return 0, nil, fmt.Errorf("%s is not an instruction of the %s variant", o, a.variant.Name)
+	}
+
+	kinds, ok := signatures[o]
+	if !ok {
+		return 0, nil, fmt.Errorf("%s is not supported", o)
+	}
+
+	if len(operands) != len(kinds) {
+		return 0, nil, fmt.Errorf("%s takes %d operands, got %d", o, len(kinds), len(operands))
+	}
+
+	i := template
+	var imm *asm.Operand
+	var relative bool
+	for n, kind := range kinds {
+		operand := &operands[n]
+		switch kind {
+		case immediate, target:
+			if operand.IsRegister() {
+				msg := fmt.Sprintf("expected immediate, got register %%%s", operand.Register)
+				return 0, nil, &asm.Error{Pos: operand.Pos, Msg: msg}
+			}
+			imm, relative = operand, kind == target
+
+		default:
+			if err := setRegister(&i, kind, operand); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+
+	// Some operations of the flags variant share their opcode with others
+	// that have ZR as their destination.
+	decoded, err := a.variant.Decode(isa.Encode(i))
+	if err != nil || a.variant.Operation(&decoded) != o {
+		return 0, nil, fmt.Errorf("%s cannot have %%zr as its destination", o)
+	}
+
+	return i.Size(), func(e *asm.Env) ([]byte, error) {
+		if imm != nil {
+			v, err := evalImmediate(e, imm, i.Format.ImmBits(), relative)
+			if err != nil {
+				return nil, err
+			}
+			i.Imm = uint32(v) & (1<<i.Format.ImmBits() - 1)
+		}
+
+		return isa.Encode(i), nil
+	}, nil
+}
+
+// evalImmediate evaluates an immediate of the given width, which can be
+// either signed or unsigned. Targets are made relative to the instruction,
+// so they must be signed.
+func evalImmediate(e *asm.Env, o *asm.Operand, bits int, relative bool) (int64, error) {
+	lo, hi := -int64(1)<<(bits-1), int64(1)<<bits-1
+	if !relative {
+		return e.EvalRange(o.Expr, lo, hi)
+	}
+
+	v, err := e.Eval(o.Expr)
+	if err != nil {
+		return 0, err
+	}
+
+	v -= int64(e.Address)
+	if v < lo || v > -lo-1 {
+		msg := fmt.Sprintf("target out of range [%d, %d]: %d", lo, -lo-1, v)
+		return 0, &asm.Error{Pos: o.Pos, Msg: msg}
+	}
+
+	return v, nil
+}
+
+// operandKind says where an operand goes.
+type operandKind uint8
+
+const (
+	dataZ operandKind = iota
+	dataY
+	dataX
+	addressZ
+	addressY
+	addressX
+	immediate
+	target
+)
+
+// setRegister parses the register of an operand, which must be of the
+// bank that the operation expects.
+func setRegister(i *isa.Instruction, kind operandKind, o *asm.Operand) error {
+	bank := "D"
+	if kind >= addressZ {
+		bank = "A"
+	}
+
+	if !o.IsRegister() {
+		return &asm.Error{Pos: o.Pos, Msg: fmt.Sprintf("expected %s register", bank)}
+	}
+
+	data, isData := isa.ParseDataRegister(o.Register)
+	address, isAddress := isa.ParseAddressRegister(o.Register)
+	switch {
+	case !isData && !isAddress:
+		return &asm.Error{Pos: o.Pos, Msg: fmt.Sprintf("unknown register %%%s", o.Register)}
+	case bank == "D" && !isData:
+		msg := fmt.Sprintf("expected D register, got A register %%%s", o.Register)
+		return &asm.Error{Pos: o.Pos, Msg: msg}
+	case bank == "A" && !isAddress:
+		msg := fmt.Sprintf("expected A register, got D register %%%s", o.Register)
+		return &asm.Error{Pos: o.Pos, Msg: msg}
+	}
+
+	switch kind {
+	case dataZ:
+		i.Z = uint8(data)
+	case dataY:
+		i.Y = uint8(data)
+	case dataX:
+		i.X = uint8(data)
+	case addressZ:
+		i.Z = uint8(address)
+	case addressY:
+		i.Y = uint8(address)
+	default:
+		i.X = uint8(address)
+	}
+
+	return nil
+}
+
+// signatures lists the operands of every operation that can be assembled.
+// They follow the syntax of the operations, except that floating-point and
+// 128-bit operations are not supported, like in the machine.
+var signatures = map[isa.Operation][]operandKind{}
+
+func init() {
+	kinds := map[string]operandKind{
+		"%Z": dataZ, "%Y": dataY, "%X": dataX,
+		"%C": addressZ, "%B": addressY, "%A": addressX,
+		"target": target,
+	}
+	quad := []isa.Operation{
+		isa.LOADQX, isa.STOREQX, isa.ADDQL, isa.ANDQL, isa.ORQL, isa.XORQL, isa.LUILQ0, isa.LUILQ1,
+	}
+
+operations:
+	for o := range isa.Operation(math.MaxUint8) {
+		if o.Operands() == nil || slices.Contains(quad, o) {
+			continue
+		}
+
+		var signature []operandKind
+		for _, operand := range o.Operands() {
+			kind, ok := kinds[operand]
+			switch {
+			case ok:
+			case strings.HasPrefix(operand, "imm"):
+				kind = immediate
+			default:
+				continue operations
+			}
+			signature = append(signature, kind)
+		}
+
+		signatures[o] = signature
+	}
+}
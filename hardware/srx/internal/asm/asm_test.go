@@ -0,0 +1,171 @@
+package asm_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	sharedasm "github.com/jespert/primordial/hardware/internal/asm"
+	"github.com/jespert/primordial/hardware/srx/internal/asm"
+	"github.com/jespert/primordial/hardware/srx/internal/isa"
+	"github.com/jespert/primordial/hardware/srx/internal/machine"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+// Sums the numbers from 1 to n with the test-and-branch variant.
+const tabProgram = `
+n = 5
+
+	.entry main
+	.func main, sum
+
+main:	add.dl %x0, %zr, n
+	aiupc.l %a0, result
+	call.ax %rp, %bp, sum
+	store.hx %x1, %a0, 0
+halt:	beq.x %zr, %zr, halt
+
+sum:	add.dl %x1, %zr, 0
+loop:	add.dl %x2, %x0, 0
+inner:	add.dl %x1, %x1, 1
+	add.dl %x2, %x2, -1
+	bne.x %zr, %x2, inner
+	add.dl %x0, %x0, -1
+	bne.x %zr, %x0, loop
+	jmp.ax %rp, 0
+
+	.data
+	.object result
+result:	.half 0
+`
+
+// Sums the numbers from 1 to n with the flags variant.
+const flagsProgram = `
+n = 5
+
+	.entry main
+	.func main, sum
+
+main:	add.dl %x0, %zr, n
+	add.al %a0, %bp, result
+	call.x sum
+	store.hx %x1, %a0, 0
+halt:	jmp.x halt
+
+sum:	add.dl %x1, %zr, 0
+loop:	adds %x1, %x0
+	adds.i %x0, -1
+	jne.x loop
+	jmp.a %rp
+
+	.data
+	.object result
+result:	.half 0
+`
+
+// Assembled programs run wherever they are loaded.
+func TestAssemble_position_independent(t *testing.T) {
+	testCases := []struct {
+		variant *isa.Variant
+		source  string
+	}{
+		{isa.Tab, tabProgram},
+		{isa.Flags, flagsProgram},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.variant.Name, func(t *testing.T) {
+			f, err := asm.Assemble(tc.variant, sharedasm.Source{Name: "sum.s", Data: []byte(tc.source)})
+			require.Success(t, err)
+
+			for _, base := range []uint32{0x8000, 0x9002} {
+				m := machine.New[uint32](tc.variant)
+				require.Success(t, m.LoadExecutable(f, base))
+				for range 128 {
+					require.Success(t, m.Step())
+				}
+
+				var result [2]byte
+				m.ReadMemory(base+uint32(len(f.Code)), result[:])
+				expect.Equal(t, 15, binary.LittleEndian.Uint16(result[:]))
+			}
+		})
+	}
+}
+
+// Executables only load on machines of their variant.
+func TestAssemble_variant(t *testing.T) {
+	f, err := asm.Assemble(isa.Flags, sharedasm.Source{Name: "sum.s", Data: []byte(flagsProgram)})
+	require.Success(t, err)
+
+	m := machine.New[uint16](isa.Tab)
+	expect.Equal(t, "not an executable for the tab variant", m.LoadExecutable(f, 0x8000).Error())
+}
+
+// Every instruction decodes to the operation and operands it was
+// assembled from.
+func TestAssemble_encoding(t *testing.T) {
+	testCases := []struct {
+		variant  *isa.Variant
+		source   string
+		expected isa.Operation
+		size     int
+	}{
+		{isa.Tab, "load.sdx %z1, %a0, -8", isa.LOADSDX, 6},
+		{isa.Tab, "store.ax %c2, %sp, 16", isa.STOREAX, 6},
+		{isa.Tab, "blt.ax %a1, %a2, .", isa.BLTAX, 6},
+		{isa.Tab, "beqz.ax %a1, .", isa.BEQZAX, 6},
+		{isa.Tab, "lui.ld %x0, %x0, 0xffffffff", isa.LUILD, 6},
+		{isa.Flags, "add.al %c0, %sp, -32", isa.ADDAL, 6},
+		{isa.Flags, "jlo.x .", isa.JLOX, 6},
+		{isa.Flags, "bltz.x %y0, .", isa.BLTZX, 6},
+		{isa.Flags, "jmp.a %rp", isa.JMPA, 2},
+		{isa.Flags, "cmp %x0, %y1", isa.CMP, 2},
+		{isa.Flags, "cmp.a %a0, %b1", isa.CMPA, 2},
+		{isa.Flags, "cmp.i %x0, 15", isa.CMPI, 2},
+		{isa.Flags, "adds.i %x0, -8", isa.ADDSI, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			f, err := asm.Assemble(tc.variant, sharedasm.Source{Name: "test.s", Data: []byte(tc.source)})
+			require.Success(t, err)
+			expect.Equal(t, tc.size, len(f.Code))
+
+			i, err := tc.variant.Decode(f.Code)
+			require.Success(t, err)
+			expect.Equal(t, tc.expected, tc.variant.Operation(&i))
+		})
+	}
+}
+
+func TestAssemble_errors(t *testing.T) {
+	testCases := []struct {
+		variant  *isa.Variant
+		source   string
+		expected string
+	}{
+		{isa.Tab, "\tfoo", "test.s:1:2: unknown instruction foo"},
+		{isa.Tab, "\tcmp %x0, %x1", "test.s:1:2: cmp is not an instruction of the tab variant"},
+		{isa.Tab, "\tload.qx %z0, %a0, 0", "test.s:1:2: load.qx is not supported"},
+		{isa.Tab, "\tadd.dl %x0, %x1", "test.s:1:2: add.dl takes 3 operands, got 2"},
+		{isa.Tab, "\tadd.dl %a0, %x1, 1", "test.s:1:9: expected D register, got A register %a0"},
+		{isa.Tab, "\tload.ax %x0, %a0, 0", "test.s:1:10: expected A register, got D register %x0"},
+		{isa.Tab, "\tjmp.ax %rp, %x0", "test.s:1:14: expected immediate, got register %x0"},
+		{isa.Tab, "\tadd.dl %x0, %r1, 1", "test.s:1:14: unknown register %r1"},
+		{isa.Tab, "\tadd.dl %x0, %x1, 0x100000000", "test.s:1:19: value out of range [-2147483648, 4294967295]: 4294967296"},
+		{isa.Flags, "\tadds.i %x0, 16", "test.s:1:14: value out of range [-8, 15]: 16"},
+		{isa.Flags, "\tadd.dl %zr, %x0, 1", "test.s:1:2: add.dl cannot have %zr as its destination"},
+		{isa.Tab, "\tbeq.x %x0, %x1, 0x80000000", "test.s:1:18: target out of range [-2147483648, 2147483647]: 2147483648"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			_, err := asm.Assemble(tc.variant, sharedasm.Source{Name: "test.s", Data: []byte(tc.source)})
+			if err == nil {
+				t.Fatal("expected invalid source to fail")
+			}
+			expect.Equal(t, tc.expected, err.Error())
+		})
+	}
+}
@@ -0,0 +1,30 @@
+//go:build ignore
+
+// Gen writes the code and the tables of srx_tab.md and srx_flags.md that
+// are generated from package spec.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/hardware/srx/internal/isa/spec"
+)
+
+func main() {
+	src, err := spec.Go()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile("operations.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	for path, sections := range spec.Markdown() {
+		if err := isaspec.UpdateFile("../../"+path, sections); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// Package isa decodes the variable-length instructions of SRX.
+//
+// Instructions are 16, 32 or 48 bits long and made of little-endian 16-bit
+// parcels. The two least significant bits of the first parcel, the class,
+// determine the length: 0 for 16 bits, 1 and 2 for 32 bits, and 3 for 48
+// bits. Within a class, the opcode determines the format, and the format
+// determines where the fields are.
+//
+// The specifications do not allocate operations yet, so instructions are
+// decoded into their fields only. Registers are plain numbers because their
+// bank (D or A) depends on the operation. Both proposals are supported as
+// variants: test-and-branch (srx_tab.md) and flags (srx_flags.md).
+//
+// The operations and their allocation in each variant are generated from
+// package spec, which also generates the tables of the encodings of the
+// documentation.
+package isa
+
+//go:generate go run gen.go
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Format of an instruction, which determines the position of its fields.
+type Format uint8
+
+const (
+	// FormatNone marks reserved opcodes.
+	FormatNone Format = iota
+
+	// 16-bit formats, with one register that is both source and
+	// destination.
+	FormatC1 // Func8, X.
+	FormatC2 // Func4, Y, X.
+	FormatCE // imm8, X.
+	FormatCF // Func4, imm4, X.
+
+	// 32-bit formats.
+	FormatR  // Func8, W, Y, Z, X.
+	FormatE  // Func8, imm8, Z, X.
+	FormatA  // imm16, Z, X.
+	FormatB  // imm16 split around Y, X.
+	FormatS  // Like B, for stores of the flags variant.
+	FormatBC // imm16, Cond, Func4 (flags variant).
+
+	// 48-bit formats.
+	FormatXA  // imm32, Z, X.
+	FormatXB  // imm32 split around Y, X.
+	FormatXS  // Like XB, for stores of the flags variant.
+	FormatXBC // imm32, Func4, Cond (flags variant).
+)
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+
+	return fmt.Sprintf("format(%d)", uint8(f))
+}
+
+var formatNames = [...]string{
+	FormatNone: "none",
+	FormatC1:   "C1",
+	FormatC2:   "C2",
+	FormatCE:   "CE",
+	FormatCF:   "CF",
+	FormatR:    "R",
+	FormatE:    "E",
+	FormatA:    "A",
+	FormatB:    "B",
+	FormatS:    "S",
+	FormatBC:   "BC",
+	FormatXA:   "XA",
+	FormatXB:   "XB",
+	FormatXS:   "XS",
+	FormatXBC:  "XBC",
+}
+
+// Size returns the length in bytes of the instruction that starts with the
+// parcel.
+func Size(parcel uint16) int {
+	switch parcel & 0x3 {
+	case 0:
+		return 2
+	case 3:
+		return 6
+	default:
+		return 4
+	}
+}
+
+// Instruction has a field for everything that a format can hold. Fields
+// that the format does not have are zero.
+type Instruction struct {
+	Format Format
+
+	// Class is the two least significant bits, and Opcode the bits above
+	// them: two bits in 16-bit instructions and six bits otherwise.
+	Class  uint8
+	Opcode uint8
+
+	// Function field: eight bits in C1, R and E, and four bits in C2, CF,
+	// BC and XBC.
+	Func uint8
+
+	// Register numbers. 16-bit formats only have X, which is also the
+	// destination, and Y.
+	Z, Y, X, W uint8
+
+	// Condition of BC and XBC.
+	Cond uint8
+
+	// Immediate as encoded, zero-extended. Its width depends on the format.
+	Imm uint32
+}
+
+// Size returns the length of the instruction in bytes.
+func (i *Instruction) Size() int {
+	return Size(uint16(i.Class))
+}
+
+// ImmBits returns the width of the immediate of the format, or zero if it
+// has none.
+func (f Format) ImmBits() int {
+	switch f {
+	case FormatCF:
+		return 4
+	case FormatCE, FormatE:
+		return 8
+	case FormatA, FormatB, FormatS, FormatBC:
+		return 16
+	case FormatXA, FormatXB, FormatXS, FormatXBC:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// SignedImm returns the immediate sign-extended from its width.
+func (i *Instruction) SignedImm() int64 {
+	bits := i.Format.ImmBits()
+	if bits == 0 {
+		return 0
+	}
+
+	shift := 64 - bits
+	return int64(uint64(i.Imm)<<shift) >> shift
+}
+
+func (i Instruction) String() string {
+	s := fmt.Sprintf("%s class=%d opcode=%02x", i.Format, i.Class, i.Opcode)
+	switch i.Format {
+	case FormatC1:
+		s += fmt.Sprintf(" func=%02x x=%x", i.Func, i.X)
+	case FormatC2:
+		s += fmt.Sprintf(" func=%x y=%x x=%x", i.Func, i.Y, i.X)
+	case FormatCE, FormatCF:
+		if i.Format == FormatCF {
+			s += fmt.Sprintf(" func=%x", i.Func)
+		}
+		s += fmt.Sprintf(" imm=%x x=%x", i.Imm, i.X)
+	case FormatR:
+		s += fmt.Sprintf(" func=%02x w=%x y=%x z=%x x=%x", i.Func, i.W, i.Y, i.Z, i.X)
+	case FormatE:
+		s += fmt.Sprintf(" func=%02x imm=%02x z=%x x=%x", i.Func, i.Imm, i.Z, i.X)
+	case FormatA, FormatXA:
+		s += fmt.Sprintf(" imm=%x z=%x x=%x", i.Imm, i.Z, i.X)
+	case FormatB, FormatS, FormatXB, FormatXS:
+		s += fmt.Sprintf(" imm=%x y=%x x=%x", i.Imm, i.Y, i.X)
+	case FormatBC, FormatXBC:
+		s += fmt.Sprintf(" imm=%x cond=%x func=%x", i.Imm, i.Cond, i.Func)
+	}
+
+	return s
+}
+
+var (
+	// ErrTruncated is returned when the data ends in the middle of an
+	// instruction.
+	ErrTruncated = errors.New("truncated instruction")
+
+	// ErrReserved is returned for opcodes that the variant reserves.
+	ErrReserved = errors.New("reserved opcode")
+)
+
+// Decode the instruction at the start of the data.
+func (v *Variant) Decode(data []byte) (Instruction, error) {
+	if len(data) < 2 {
+		return Instruction{}, ErrTruncated
+	}
+
+	size := Size(uint16(data[0]))
+	if len(data) < size {
+		return Instruction{}, ErrTruncated
+	}
+
+	var e uint64
+	for i := size - 1; i >= 0; i-- {
+		e = e<<8 | uint64(data[i])
+	}
+
+	i := Instruction{Class: uint8(e & 0x3)}
+	if size == 2 {
+		i.Opcode = uint8(e>>2) & 0x3
+	} else {
+		i.Opcode = uint8(e>>2) & 0x3f
+	}
+
+	nibble := func(offset int) uint8 {
+		return uint8(e>>offset) & 0xf
+	}
+
+	i.Format = v.Format(i.Class, i.Opcode)
+	if i.Class == 3 && nibble(12) == 0 && v.ZeroZFormat(i.Opcode) != FormatNone {
+		i.Format = v.ZeroZFormat(i.Opcode)
+	}
+
+	if i.Format == FormatNone {
+		return i, fmt.Errorf("%w: class %d opcode %02x", ErrReserved, i.Class, i.Opcode)
+	}
+
+	switch i.Format {
+	case FormatC1:
+		i.Func, i.X = uint8(e>>8), nibble(4)
+	case FormatC2:
+		i.Func, i.Y, i.X = nibble(12), nibble(8), nibble(4)
+	case FormatCE:
+		i.Imm, i.X = uint32(e>>8)&0xff, nibble(4)
+	case FormatCF:
+		i.Func, i.Imm, i.X = nibble(12), uint32(nibble(8)), nibble(4)
+	case FormatR:
+		i.Func, i.W, i.Y, i.Z, i.X = uint8(e>>24), nibble(20), nibble(16), nibble(12), nibble(8)
+	case FormatE:
+		i.Func, i.Imm, i.Z, i.X = uint8(e>>24), uint32(e>>16)&0xff, nibble(12), nibble(8)
+	case FormatA:
+		i.Imm, i.Z, i.X = uint32(e>>16)&0xffff, nibble(12), nibble(8)
+	case FormatB, FormatS:
+		i.Imm = uint32(e>>20)&0xfff<<4 | uint32(nibble(12))
+		i.Y, i.X = nibble(16), nibble(8)
+	case FormatBC:
+		i.Imm, i.Cond, i.Func = uint32(e>>16)&0xffff, nibble(12), nibble(8)
+	case FormatXA:
+		i.Imm, i.Z, i.X = uint32(e>>16), nibble(12), nibble(8)
+	case FormatXB, FormatXS:
+		i.Imm = uint32(e>>20)<<4 | uint32(nibble(12))
+		i.Y, i.X = nibble(16), nibble(8)
+	case FormatXBC:
+		i.Imm, i.Func, i.Cond = uint32(e>>16), nibble(12), nibble(8)
+	}
+
+	return i, nil
+}
+
+// DecodeAll decodes a stream of instructions. On error, it returns the
+// instructions decoded so far and the offset of the one that failed.
+func (v *Variant) DecodeAll(data []byte) ([]Instruction, int, error) {
+	var instructions []Instruction
+	offset := 0
+	for offset < len(data) {
+		i, err := v.Decode(data[offset:])
+		if err != nil {
+			return instructions, offset, err
+		}
+
+		instructions = append(instructions, i)
+		offset += i.Size()
+	}
+
+	return instructions, offset, nil
+}
+
+// Encode an instruction. Fields that its format does not have must be zero,
+// and so must the bits of fields beyond their width.
+func Encode(i Instruction) []byte {
+	e := uint64(i.Class&0x3) | uint64(i.Opcode)<<2
+	nibble := func(v uint8, offset int) uint64 {
+		return uint64(v&0xf) << offset
+	}
+
+	switch i.Format {
+	case FormatC1:
+		e |= uint64(i.Func)<<8 | nibble(i.X, 4)
+	case FormatC2:
+		e |= nibble(i.Func, 12) | nibble(i.Y, 8) | nibble(i.X, 4)
+	case FormatCE:
+		e |= uint64(i.Imm&0xff)<<8 | nibble(i.X, 4)
+	case FormatCF:
+		e |= nibble(i.Func, 12) | nibble(uint8(i.Imm), 8) | nibble(i.X, 4)
+	case FormatR:
+		e |= uint64(i.Func)<<24 | nibble(i.W, 20) | nibble(i.Y, 16) | nibble(i.Z, 12) | nibble(i.X, 8)
+	case FormatE:
+		e |= uint64(i.Func)<<24 | uint64(i.Imm&0xff)<<16 | nibble(i.Z, 12) | nibble(i.X, 8)
+	case FormatA:
+		e |= uint64(i.Imm&0xffff)<<16 | nibble(i.Z, 12) | nibble(i.X, 8)
+	case FormatB, FormatS:
+		e |= uint64(i.Imm>>4&0xfff)<<20 | nibble(i.Y, 16) | nibble(uint8(i.Imm), 12) | nibble(i.X, 8)
+	case FormatBC:
+		e |= uint64(i.Imm&0xffff)<<16 | nibble(i.Cond, 12) | nibble(i.Func, 8)
+	case FormatXA:
+		e |= uint64(i.Imm)<<16 | nibble(i.Z, 12) | nibble(i.X, 8)
+	case FormatXB, FormatXS:
+		e |= uint64(i.Imm>>4)<<20 | nibble(i.Y, 16) | nibble(uint8(i.Imm), 12) | nibble(i.X, 8)
+	case FormatXBC:
+		e |= uint64(i.Imm)<<16 | nibble(i.Func, 12) | nibble(i.Cond, 8)
+	}
+
+	data := make([]byte, i.Size())
+	for n := range data {
+		data[n] = byte(e >> (8 * n))
+	}
+
+	return data
+}
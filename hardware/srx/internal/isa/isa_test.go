@@ -0,0 +1,456 @@
+package isa_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/hardware/srx/internal/isa"
+	"github.com/jespert/primordial/hardware/srx/internal/isa/spec"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestSize(t *testing.T) {
+	expect.Equal(t, 2, isa.Size(0xfffc))
+	expect.Equal(t, 4, isa.Size(0x0001))
+	expect.Equal(t, 4, isa.Size(0x0002))
+	expect.Equal(t, 6, isa.Size(0x0003))
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range encodingTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := tc.variant.Decode(tc.encoded)
+			require.Success(t, err)
+			if tc.decoded != actual {
+				t.Errorf("Expected %+v, got %+v", tc.decoded, actual)
+			}
+
+			// Check reversibility.
+			expect.Equal(t, fmt.Sprintf("% x", tc.encoded),
+				fmt.Sprintf("% x", isa.Encode(actual)))
+		})
+	}
+}
+
+func TestDecode_signed_immediate(t *testing.T) {
+	i, err := isa.Flags.Decode([]byte{0x03, 0x02, 0xfe, 0xff, 0xff, 0xff})
+	require.Success(t, err)
+	expect.Equal(t, int64(-2), i.SignedImm())
+
+	i, err = isa.Tab.Decode([]byte{0x38, 0x7f})
+	require.Success(t, err)
+	expect.Equal(t, int64(0x7f), i.SignedImm())
+}
+
+func TestDecode_truncated(t *testing.T) {
+	for _, data := range [][]byte{nil, {0x00}, {0x01, 0x00}, {0x03, 0, 0, 0, 0}} {
+		_, err := isa.Tab.Decode(data)
+		expect.Equal(t, true, errors.Is(err, isa.ErrTruncated))
+	}
+}
+
+func TestDecode_reserved(t *testing.T) {
+	// Opcode 39 of the 48-bit class is not allocated by the flags variant.
+	data := []byte{39<<2 | 3, 0, 0, 0, 0, 0}
+	_, err := isa.Flags.Decode(data)
+	expect.Equal(t, true, errors.Is(err, isa.ErrReserved))
+
+	_, err = isa.Tab.Decode(data)
+	require.Success(t, err)
+
+	// The floating-point loads and stores of the flags variant collide,
+	// so their opcodes are reserved.
+	_, err = isa.Flags.Decode([]byte{16<<2 | 3, 0x12, 0, 0, 0, 0})
+	expect.Equal(t, true, errors.Is(err, isa.ErrReserved))
+}
+
+func TestDecode_zero_Z(t *testing.T) {
+	// Opcode 0 of the flags variant jumps when Z is zero, and loads
+	// otherwise.
+	i, err := isa.Flags.Decode([]byte{0x03, 0x02, 0, 0, 0, 0})
+	require.Success(t, err)
+	expect.Equal(t, isa.FormatXBC, i.Format)
+	expect.Equal(t, isa.JEQX, isa.Flags.Operation(&i))
+
+	i, err = isa.Flags.Decode([]byte{0x03, 0x12, 0, 0, 0, 0})
+	require.Success(t, err)
+	expect.Equal(t, isa.FormatXA, i.Format)
+	expect.Equal(t, isa.LOADSBX, isa.Flags.Operation(&i))
+
+	// Arithmetic into ZR is unused.
+	i, err = isa.Flags.Decode([]byte{24<<2 | 3, 0x02, 0, 0, 0, 0})
+	require.Success(t, err)
+	expect.Equal(t, isa.Unknown, isa.Flags.Operation(&i))
+}
+
+func TestDecodeAll(t *testing.T) {
+	var stream []byte
+	for _, tc := range encodingTestCases {
+		if tc.variant == isa.Tab {
+			stream = append(stream, tc.encoded...)
+		}
+	}
+
+	verifier := approval.NewTextVerifier(t)
+	instructions, offset, err := isa.Tab.DecodeAll(stream)
+	require.Success(t, err)
+	expect.Equal(t, len(stream), offset)
+	for _, i := range instructions {
+		_, _ = fmt.Fprintln(verifier.Writer(), i)
+	}
+
+	// Decoding stops at the first instruction that is cut short.
+	instructions, offset, err = isa.Tab.DecodeAll(append(stream, 0x01, 0x00))
+	expect.Equal(t, true, errors.Is(err, isa.ErrTruncated))
+	expect.Equal(t, len(stream), offset)
+	_, _ = fmt.Fprintf(verifier.Writer(), "truncated after %d instructions\n",
+		len(instructions))
+
+	verifier.Verify()
+}
+
+// Every instruction that decodes encodes back to the same bytes, so
+// decoding loses nothing.
+func FuzzDecode(f *testing.F) {
+	for _, tc := range encodingTestCases {
+		f.Add(tc.encoded)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, v := range isa.Variants {
+			d, err := v.Decode(data)
+			if err != nil {
+				continue
+			}
+
+			encoded := isa.Encode(d)
+			if !bytes.Equal(data[:len(encoded)], encoded) {
+				t.Fatalf("%s: expected % x, got % x", v.Name, data[:len(encoded)], encoded)
+			}
+		}
+	})
+}
+
+var encodingTestCases = []struct {
+	name    string
+	variant *isa.Variant
+	decoded isa.Instruction
+	encoded []byte
+}{
+	{
+		name:    "C1",
+		variant: isa.Tab,
+		decoded: isa.Instruction{Format: isa.FormatC1, Func: 0x5a, X: 0xf},
+		encoded: []byte{0xf0, 0x5a},
+	},
+	{
+		name:    "C2",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatC2,
+			Opcode: 1,
+			Func:   0xa,
+			Y:      0xb,
+			X:      0xc,
+		},
+		encoded: []byte{0xc4, 0xab},
+	},
+	{
+		name:    "CE",
+		variant: isa.Tab,
+		decoded: isa.Instruction{Format: isa.FormatCE, Opcode: 2, Imm: 0x7f, X: 3},
+		encoded: []byte{0x38, 0x7f},
+	},
+	{
+		name:    "CF",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatCF,
+			Opcode: 3,
+			Func:   0x9,
+			Imm:    0x8,
+			X:      0x7,
+		},
+		encoded: []byte{0x7c, 0x98},
+	},
+	{
+		name:    "R",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatR,
+			Class:  1,
+			Opcode: 5,
+			Func:   0x12,
+			W:      3,
+			Y:      4,
+			Z:      5,
+			X:      6,
+		},
+		encoded: []byte{0x15, 0x56, 0x34, 0x12},
+	},
+	{
+		name:    "E",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatE,
+			Class:  1,
+			Opcode: 32,
+			Func:   0xfe,
+			Imm:    0x80,
+			Z:      1,
+			X:      2,
+		},
+		encoded: []byte{0x81, 0x12, 0x80, 0xfe},
+	},
+	{
+		name:    "A",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatA,
+			Class:  2,
+			Opcode: 1,
+			Imm:    0x1234,
+			Z:      0xe,
+			X:      0xd,
+		},
+		encoded: []byte{0x06, 0xed, 0x34, 0x12},
+	},
+	{
+		name:    "B",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatB,
+			Class:  2,
+			Opcode: 48,
+			Imm:    0xabcd,
+			Y:      1,
+			X:      2,
+		},
+		encoded: []byte{0xc2, 0xd2, 0xc1, 0xab},
+	},
+	{
+		name:    "XA",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatXA,
+			Class:  3,
+			Imm:    0x12345678,
+			Z:      1,
+			X:      2,
+		},
+		encoded: []byte{0x03, 0x12, 0x78, 0x56, 0x34, 0x12},
+	},
+	{
+		name:    "XB",
+		variant: isa.Tab,
+		decoded: isa.Instruction{
+			Format: isa.FormatXB,
+			Class:  3,
+			Opcode: 10,
+			Imm:    0x89abcdef,
+			Y:      3,
+			X:      4,
+		},
+		encoded: []byte{0x2b, 0xf4, 0xe3, 0xcd, 0xab, 0x89},
+	},
+	{
+		name:    "S",
+		variant: isa.Flags,
+		decoded: isa.Instruction{
+			Format: isa.FormatS,
+			Class:  2,
+			Opcode: 40,
+			Imm:    0xabcd,
+			Y:      1,
+			X:      2,
+		},
+		encoded: []byte{0xa2, 0xd2, 0xc1, 0xab},
+	},
+	{
+		name:    "BC",
+		variant: isa.Flags,
+		decoded: isa.Instruction{
+			Format: isa.FormatBC,
+			Class:  2,
+			Opcode: 56,
+			Imm:    0x0100,
+			Cond:   0xa,
+			Func:   5,
+		},
+		encoded: []byte{0xe2, 0xa5, 0x00, 0x01},
+	},
+	{
+		name:    "XS",
+		variant: isa.Flags,
+		decoded: isa.Instruction{
+			Format: isa.FormatXS,
+			Class:  3,
+			Opcode: 15,
+			Imm:    0x89abcdef,
+			Y:      3,
+			X:      4,
+		},
+		encoded: []byte{0x3f, 0xf4, 0xe3, 0xcd, 0xab, 0x89},
+	},
+	{
+		name:    "XBC",
+		variant: isa.Flags,
+		decoded: isa.Instruction{
+			Format: isa.FormatXBC,
+			Class:  3,
+			Imm:    0xfffffffe,
+			Cond:   2,
+		},
+		encoded: []byte{0x03, 0x02, 0xfe, 0xff, 0xff, 0xff},
+	},
+}
+
+func TestVariant_Template(t *testing.T) {
+	// Every operation of the test-and-branch variant has its own opcode.
+	for o := isa.LOADSBX; o <= isa.LUILQ1; o++ {
+		_, ok := isa.Tab.Template(o)
+		expect.Equal(t, true, ok)
+	}
+
+	// Templates decode to their operation, given a non-zero Z where the
+	// opcode is shared.
+	for _, v := range isa.Variants {
+		for o := isa.LOADSBX; o <= isa.ADDSI; o++ {
+			template, ok := v.Template(o)
+			if !ok {
+				continue
+			}
+
+			decoded, err := v.Decode(isa.Encode(template))
+			require.Success(t, err)
+			if v.Operation(&decoded) != o {
+				template.Z = 1
+				decoded, err = v.Decode(isa.Encode(template))
+				require.Success(t, err)
+			}
+
+			expect.Equal(t, o.String(), v.Operation(&decoded).String())
+		}
+	}
+
+	_, ok := isa.Tab.Template(isa.Unknown)
+	expect.Equal(t, false, ok)
+	_, ok = isa.Tab.Template(isa.CMP)
+	expect.Equal(t, false, ok)
+}
+
+func TestParseOperation(t *testing.T) {
+	for o := isa.LOADSBX; o <= isa.ADDSI; o++ {
+		parsed, ok := isa.ParseOperation(o.String())
+		expect.Equal(t, true, ok)
+		expect.Equal(t, o, parsed)
+	}
+
+	_, ok := isa.ParseOperation("unknown")
+	expect.Equal(t, false, ok)
+}
+
+func TestParseRegister(t *testing.T) {
+	for r := range isa.DataRegister(isa.NumRegisters) {
+		parsed, ok := isa.ParseDataRegister(strings.ToUpper(r.String()))
+		expect.Equal(t, true, ok)
+		expect.Equal(t, r, parsed)
+	}
+
+	for r := range isa.AddressRegister(isa.NumRegisters) {
+		parsed, ok := isa.ParseAddressRegister(r.String())
+		expect.Equal(t, true, ok)
+		expect.Equal(t, r, parsed)
+	}
+
+	fp, _ := isa.ParseAddressRegister("fp")
+	mp, _ := isa.ParseAddressRegister("MP")
+	expect.Equal(t, isa.C0, fp)
+	expect.Equal(t, isa.B0, mp)
+
+	_, ok := isa.ParseDataRegister("sp")
+	expect.Equal(t, false, ok)
+	_, ok = isa.ParseAddressRegister("zr")
+	expect.Equal(t, false, ok)
+}
+
+// TestSpec checks that the generated code and the tables of the
+// documentation match the spec. Run go generate to update them.
+func TestSpec(t *testing.T) {
+	expected, err := spec.Go()
+	require.Success(t, err)
+
+	actual, err := os.ReadFile("operations.go")
+	require.Success(t, err)
+	expect.Equal(t, string(expected), string(actual))
+
+	for path, sections := range spec.Markdown() {
+		expect.Success(t, isaspec.CheckFile("../../"+path, sections))
+	}
+}
+
+// TestSpec_collisions checks that the collisions that the generator allows
+// are exactly the ones of the spec, and that the others are errors.
+func TestSpec_collisions(t *testing.T) {
+	require.Success(t, spec.Tab.Check())
+
+	collisions, err := spec.Flags.Collisions()
+	require.Success(t, err)
+	var actual []string
+	for _, c := range collisions {
+		actual = append(actual, c.String())
+	}
+
+	expected := []string{
+		"load.fh0x and store.fh0x", "load.fh1x and store.fh1x",
+		"load.fw0x and store.fw0x", "load.fw1x and store.fw1x",
+		"load.fd0x and store.fd0x", "load.fd1x and store.fd1x",
+		"load.fq0x and store.fq0x", "load.fq1x and store.fq1x",
+	}
+	expect.Equal(t, strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	expect.Equal(t, strings.Join(expected, "\n"), strings.Join(spec.FlagsCollisions, "\n"))
+
+	err = spec.Flags.Check()
+	require.Equal(t, true, err != nil)
+	expect.Equal(t, true, strings.HasPrefix(err.Error(), "SRX flags: load.fh0x and store.fh0x collide\n"))
+}
+
+// TestSpec_variants checks that the variants allocate the opcodes of the
+// spec to its formats.
+func TestSpec_variants(t *testing.T) {
+	specs := map[*isa.Variant]*isaspec.ISA{isa.Tab: spec.Tab, isa.Flags: spec.Flags}
+	collided := map[string]bool{}
+	for _, c := range spec.FlagsCollisions {
+		first, second, _ := strings.Cut(c, " and ")
+		collided[first], collided[second] = true, true
+	}
+
+	for v, s := range specs {
+		for _, i := range s.Instructions {
+			t.Run(v.Name+"/"+i.Mnemonic, func(t *testing.T) {
+				o, ok := isa.ParseOperation(i.Mnemonic)
+				require.Equal(t, true, ok)
+
+				// Colliding instructions have no encoding.
+				template, ok := v.Template(o)
+				if v == isa.Flags && collided[i.Mnemonic] {
+					expect.Equal(t, false, ok)
+					return
+				}
+				require.Equal(t, true, ok)
+				expect.Equal(t, i.Format, template.Format.String())
+				expect.Equal(t, i.Values["class"], uint32(template.Class))
+				expect.Equal(t, i.Values["opcode"], uint32(template.Opcode))
+				expect.Equal(t, i.Values["func"], uint32(template.Func))
+				expect.Equal(t, i.Values["cond"], uint32(template.Cond))
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package isa
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (o Operation) String() string {
+	if o < numOperations {
+		return operationNames[o]
+	}
+
+	return fmt.Sprintf("operation(%d)", uint8(o))
+}
+
+// Operands returns the operands of the operation in assembly syntax, as
+// in the specifications. Registers are named after the field that holds
+// them: Z, Y and X for D registers, C, B and A for A registers, and F0
+// and F1 for the banks of floating-point registers.
+func (o Operation) Operands() []string {
+	if o >= numOperations || operationOperands[o] == "" {
+		return nil
+	}
+
+	return strings.Split(operationOperands[o], ", ")
+}
+
+// ParseOperation parses the mnemonic of an operation.
+func ParseOperation(mnemonic string) (Operation, bool) {
+	for o, name := range operationNames {
+		if o != int(Unknown) && name == mnemonic {
+			return Operation(o), true
+		}
+	}
+
+	return Unknown, false
+}
+
+// Operation returns the operation of a decoded instruction, or Unknown if
+// the variant does not allocate one to it.
+func (v *Variant) Operation(i *Instruction) Operation {
+	switch i.Format {
+	case FormatC1, FormatC2, FormatCF:
+		if i.Func > 0xf {
+			return Unknown
+		}
+		return v.compactOperations[i.Opcode&0x3][i.Func]
+
+	case FormatXBC:
+		if i.Func != 0 {
+			return Unknown
+		}
+		return v.conditions[i.Cond&0xf]
+
+	case FormatXA, FormatXB, FormatXS:
+		if i.Format == FormatXA && i.Z == 0 && v.zeroZFormats[i.Opcode&0x3f] == FormatXA {
+			return v.zeroZOperations[i.Opcode&0x3f]
+		}
+		return v.operations[i.Opcode&0x3f]
+
+	default:
+		return Unknown
+	}
+}
+
+// Template returns an instruction with the fields that select the
+// operation, if the variant has it. Operations that share their opcode
+// with another one when Z is zero need a non-zero Z.
+func (v *Variant) Template(o Operation) (Instruction, bool) {
+	if o == Unknown {
+		return Instruction{}, false
+	}
+
+	for opcode, operations := range v.compactOperations {
+		for function, candidate := range operations {
+			if candidate == o {
+				return Instruction{
+					Format: v.formats[0][opcode],
+					Opcode: uint8(opcode),
+					Func:   uint8(function),
+				}, true
+			}
+		}
+	}
+
+	for opcode := range uint8(64) {
+		i := Instruction{Class: 3, Opcode: opcode}
+		switch {
+		case v.zeroZFormats[opcode] == FormatXBC:
+			for cond, candidate := range v.conditions {
+				if candidate == o {
+					i.Format, i.Cond = FormatXBC, uint8(cond)
+					return i, true
+				}
+			}
+
+		case v.zeroZFormats[opcode] != FormatNone && v.zeroZOperations[opcode] == o:
+			i.Format = v.zeroZFormats[opcode]
+			return i, true
+		}
+
+		if v.operations[opcode] == o {
+			i.Format = v.formats[3][opcode]
+			return i, true
+		}
+	}
+
+	return Instruction{}, false
+}
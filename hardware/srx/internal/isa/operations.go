@@ -0,0 +1,525 @@
+// Code generated by gen.go from package spec; DO NOT EDIT.
+
+package isa
+
+// Operation of an instruction. The specifications only define the 48-bit
+// ones for now. The flags variant also has a few provisional 16-bit ones,
+// because flags cannot be set otherwise.
+type Operation uint8
+
+const (
+	// Unknown marks opcodes without an operation.
+	Unknown Operation = iota
+
+	// Loads into D registers, sign-extended (S) or zero-extended (U).
+	LOADSBX
+	LOADSHX
+	LOADSWX
+	LOADSDX
+	LOADUBX
+	LOADUHX
+	LOADUWX
+	LOADUDX
+	LOADQX
+
+	// Load into an A register.
+	LOADAX
+
+	// Stores.
+	STOREBX
+	STOREHX
+	STOREWX
+	STOREDX
+	STOREQX
+	STOREAX
+
+	// Floating-point loads and stores, for the low (0) and high (1) banks.
+	LOADFH0X
+	LOADFH1X
+	LOADFW0X
+	LOADFW1X
+	LOADFD0X
+	LOADFD1X
+	LOADFQ0X
+	LOADFQ1X
+	STOREFH0X
+	STOREFH1X
+	STOREFW0X
+	STOREFW1X
+	STOREFD0X
+	STOREFD1X
+	STOREFQ0X
+	STOREFQ1X
+
+	// Branches comparing D registers.
+	BEQX
+	BNEX
+	BLTSX
+	BLTUX
+	BGESX
+	BGEUX
+
+	// Branches comparing A registers.
+	BEQAX
+	BNEAX
+	BLTAX
+	BGEAX
+	BEQZAX
+	BNEZAX
+
+	// Unconditional control flow to an A register plus the immediate.
+	CALLAX
+	JMPAX
+
+	// Arithmetic with long immediates.
+	ADDWL
+	ADDDL
+	ADDQL
+	ANDWL
+	ANDDL
+	ANDQL
+	ORWL
+	ORDL
+	ORQL
+	XORWL
+	XORDL
+	XORQL
+	SLTUL
+	SLTSL
+	AIUPCL
+	LUILD
+	LUILQ0
+	LUILQ1
+
+	// Control flow relative to the IP (flags variant). The jumps are
+	// conditional on the flags.
+	CALLX
+	JMPX
+	JEQX
+	JNEX
+	JLTX
+	JLEX
+	JGEX
+	JGTX
+	JLOX
+	JLSX
+	JHSX
+	JHIX
+	JPIX
+	JNIX
+	JOVX
+	JNOX
+
+	// Branches comparing a register with zero (flags variant).
+	BEQZX
+	BNEZX
+	BLTZX
+	BGEZX
+	BLTZAX
+	BGEZAX
+
+	// Address arithmetic with a long immediate (flags variant).
+	ADDAL
+
+	// 16-bit operations (flags variant): a jump to an A register, for
+	// returns, and operations that set the flags.
+	JMPA
+	CMP
+	CMPA
+	ADDS
+	SUBS
+	CMPI
+	ADDSI
+
+	numOperations
+)
+
+var operationNames = [numOperations]string{
+	Unknown:   "unknown",
+	LOADSBX:   "load.sbx",
+	LOADSHX:   "load.shx",
+	LOADSWX:   "load.swx",
+	LOADSDX:   "load.sdx",
+	LOADUBX:   "load.ubx",
+	LOADUHX:   "load.uhx",
+	LOADUWX:   "load.uwx",
+	LOADUDX:   "load.udx",
+	LOADQX:    "load.qx",
+	LOADAX:    "load.ax",
+	STOREBX:   "store.bx",
+	STOREHX:   "store.hx",
+	STOREWX:   "store.wx",
+	STOREDX:   "store.dx",
+	STOREQX:   "store.qx",
+	STOREAX:   "store.ax",
+	LOADFH0X:  "load.fh0x",
+	LOADFH1X:  "load.fh1x",
+	LOADFW0X:  "load.fw0x",
+	LOADFW1X:  "load.fw1x",
+	LOADFD0X:  "load.fd0x",
+	LOADFD1X:  "load.fd1x",
+	LOADFQ0X:  "load.fq0x",
+	LOADFQ1X:  "load.fq1x",
+	STOREFH0X: "store.fh0x",
+	STOREFH1X: "store.fh1x",
+	STOREFW0X: "store.fw0x",
+	STOREFW1X: "store.fw1x",
+	STOREFD0X: "store.fd0x",
+	STOREFD1X: "store.fd1x",
+	STOREFQ0X: "store.fq0x",
+	STOREFQ1X: "store.fq1x",
+	BEQX:      "beq.x",
+	BNEX:      "bne.x",
+	BLTSX:     "blt.sx",
+	BLTUX:     "blt.ux",
+	BGESX:     "bge.sx",
+	BGEUX:     "bge.ux",
+	BEQAX:     "beq.ax",
+	BNEAX:     "bne.ax",
+	BLTAX:     "blt.ax",
+	BGEAX:     "bge.ax",
+	BEQZAX:    "beqz.ax",
+	BNEZAX:    "bnez.ax",
+	CALLAX:    "call.ax",
+	JMPAX:     "jmp.ax",
+	ADDWL:     "add.wl",
+	ADDDL:     "add.dl",
+	ADDQL:     "add.ql",
+	ANDWL:     "and.wl",
+	ANDDL:     "and.dl",
+	ANDQL:     "and.ql",
+	ORWL:      "or.wl",
+	ORDL:      "or.dl",
+	ORQL:      "or.ql",
+	XORWL:     "xor.wl",
+	XORDL:     "xor.dl",
+	XORQL:     "xor.ql",
+	SLTUL:     "slt.ul",
+	SLTSL:     "slt.sl",
+	AIUPCL:    "aiupc.l",
+	LUILD:     "lui.ld",
+	LUILQ0:    "lui.lq0",
+	LUILQ1:    "lui.lq1",
+	CALLX:     "call.x",
+	JMPX:      "jmp.x",
+	JEQX:      "jeq.x",
+	JNEX:      "jne.x",
+	JLTX:      "jlt.x",
+	JLEX:      "jle.x",
+	JGEX:      "jge.x",
+	JGTX:      "jgt.x",
+	JLOX:      "jlo.x",
+	JLSX:      "jls.x",
+	JHSX:      "jhs.x",
+	JHIX:      "jhi.x",
+	JPIX:      "jpi.x",
+	JNIX:      "jni.x",
+	JOVX:      "jov.x",
+	JNOX:      "jno.x",
+	BEQZX:     "beqz.x",
+	BNEZX:     "bnez.x",
+	BLTZX:     "bltz.x",
+	BGEZX:     "bgez.x",
+	BLTZAX:    "bltz.ax",
+	BGEZAX:    "bgez.ax",
+	ADDAL:     "add.al",
+	JMPA:      "jmp.a",
+	CMP:       "cmp",
+	CMPA:      "cmp.a",
+	ADDS:      "adds",
+	SUBS:      "subs",
+	CMPI:      "cmp.i",
+	ADDSI:     "adds.i",
+}
+
+// operationOperands are the operands of the operations in assembly
+// syntax, separated by commas.
+var operationOperands = [numOperations]string{
+	LOADSBX:   "%Z, %A, imm32",
+	LOADSHX:   "%Z, %A, imm32",
+	LOADSWX:   "%Z, %A, imm32",
+	LOADSDX:   "%Z, %A, imm32",
+	LOADUBX:   "%Z, %A, imm32",
+	LOADUHX:   "%Z, %A, imm32",
+	LOADUWX:   "%Z, %A, imm32",
+	LOADUDX:   "%Z, %A, imm32",
+	LOADQX:    "%Z, %A, imm32",
+	LOADAX:    "%C, %A, imm32",
+	STOREBX:   "%Y, %A, imm32",
+	STOREHX:   "%Y, %A, imm32",
+	STOREWX:   "%Y, %A, imm32",
+	STOREDX:   "%Y, %A, imm32",
+	STOREQX:   "%Y, %A, imm32",
+	STOREAX:   "%B, %A, imm32",
+	LOADFH0X:  "%F0, %A, imm32",
+	LOADFH1X:  "%F1, %A, imm32",
+	LOADFW0X:  "%F0, %A, imm32",
+	LOADFW1X:  "%F1, %A, imm32",
+	LOADFD0X:  "%F0, %A, imm32",
+	LOADFD1X:  "%F1, %A, imm32",
+	LOADFQ0X:  "%F0, %A, imm32",
+	LOADFQ1X:  "%F1, %A, imm32",
+	STOREFH0X: "%F0, %A, imm32",
+	STOREFH1X: "%F1, %A, imm32",
+	STOREFW0X: "%F0, %A, imm32",
+	STOREFW1X: "%F1, %A, imm32",
+	STOREFD0X: "%F0, %A, imm32",
+	STOREFD1X: "%F1, %A, imm32",
+	STOREFQ0X: "%F0, %A, imm32",
+	STOREFQ1X: "%F1, %A, imm32",
+	BEQX:      "%Y, %X, target",
+	BNEX:      "%Y, %X, target",
+	BLTSX:     "%Y, %X, target",
+	BLTUX:     "%Y, %X, target",
+	BGESX:     "%Y, %X, target",
+	BGEUX:     "%Y, %X, target",
+	BEQAX:     "%B, %A, target",
+	BNEAX:     "%B, %A, target",
+	BLTAX:     "%B, %A, target",
+	BGEAX:     "%B, %A, target",
+	BEQZAX:    "%A, target",
+	BNEZAX:    "%A, target",
+	CALLAX:    "%C, %A, imm32",
+	JMPAX:     "%A, imm32",
+	ADDWL:     "%Z, %X, imm32",
+	ADDDL:     "%Z, %X, imm32",
+	ADDQL:     "%Z, %X, imm32",
+	ANDWL:     "%Z, %X, imm32",
+	ANDDL:     "%Z, %X, imm32",
+	ANDQL:     "%Z, %X, imm32",
+	ORWL:      "%Z, %X, imm32",
+	ORDL:      "%Z, %X, imm32",
+	ORQL:      "%Z, %X, imm32",
+	XORWL:     "%Z, %X, imm32",
+	XORDL:     "%Z, %X, imm32",
+	XORQL:     "%Z, %X, imm32",
+	SLTUL:     "%Z, %X, imm32",
+	SLTSL:     "%Z, %X, imm32",
+	AIUPCL:    "%C, target",
+	LUILD:     "%Z, %X, imm32",
+	LUILQ0:    "%Z, %X, imm32",
+	LUILQ1:    "%Z, %X, imm32",
+	CALLX:     "target",
+	JMPX:      "target",
+	JEQX:      "target",
+	JNEX:      "target",
+	JLTX:      "target",
+	JLEX:      "target",
+	JGEX:      "target",
+	JGTX:      "target",
+	JLOX:      "target",
+	JLSX:      "target",
+	JHSX:      "target",
+	JHIX:      "target",
+	JPIX:      "target",
+	JNIX:      "target",
+	JOVX:      "target",
+	JNOX:      "target",
+	BEQZX:     "%X, target",
+	BNEZX:     "%X, target",
+	BLTZX:     "%X, target",
+	BGEZX:     "%X, target",
+	BLTZAX:    "%A, target",
+	BGEZAX:    "%A, target",
+	ADDAL:     "%C, %A, imm32",
+	JMPA:      "%A",
+	CMP:       "%X, %Y",
+	CMPA:      "%A, %B",
+	ADDS:      "%X, %Y",
+	SUBS:      "%X, %Y",
+	CMPI:      "%X, imm4",
+	ADDSI:     "%X, imm4",
+}
+
+// tabOperations follows the order in which srx_tab.md lists the classes of
+// 48-bit instructions, which consume exactly the 64 opcodes.
+var tabOperations = [64]Operation{
+	0:  LOADSBX,
+	1:  LOADSHX,
+	2:  LOADSWX,
+	3:  LOADSDX,
+	4:  LOADUBX,
+	5:  LOADUHX,
+	6:  LOADUWX,
+	7:  LOADUDX,
+	8:  LOADQX,
+	9:  LOADAX,
+	10: STOREBX,
+	11: STOREHX,
+	12: STOREWX,
+	13: STOREDX,
+	14: STOREQX,
+	15: STOREAX,
+	16: LOADFH0X,
+	17: LOADFH1X,
+	18: LOADFW0X,
+	19: LOADFW1X,
+	20: LOADFD0X,
+	21: LOADFD1X,
+	22: LOADFQ0X,
+	23: LOADFQ1X,
+	24: STOREFH0X,
+	25: STOREFH1X,
+	26: STOREFW0X,
+	27: STOREFW1X,
+	28: STOREFD0X,
+	29: STOREFD1X,
+	30: STOREFQ0X,
+	31: STOREFQ1X,
+	32: BEQX,
+	33: BNEX,
+	34: BLTSX,
+	35: BLTUX,
+	36: BGESX,
+	37: BGEUX,
+	38: BEQAX,
+	39: BNEAX,
+	40: BLTAX,
+	41: BGEAX,
+	42: BEQZAX,
+	43: BNEZAX,
+	44: CALLAX,
+	45: JMPAX,
+	46: ADDWL,
+	47: ADDDL,
+	48: ADDQL,
+	49: ANDWL,
+	50: ANDDL,
+	51: ANDQL,
+	52: ORWL,
+	53: ORDL,
+	54: ORQL,
+	55: XORWL,
+	56: XORDL,
+	57: XORQL,
+	58: SLTUL,
+	59: SLTSL,
+	60: AIUPCL,
+	61: LUILD,
+	62: LUILQ0,
+	63: LUILQ1,
+}
+
+// flagsOperations follows the table of 48-bit instructions of srx_flags.md
+// for a non-zero Z. Its floating-point loads and stores share opcodes, so
+// both are left out.
+var flagsOperations = [64]Operation{
+	0:  LOADSBX,
+	1:  LOADSHX,
+	2:  LOADSWX,
+	3:  LOADSDX,
+	4:  LOADUBX,
+	5:  LOADUHX,
+	6:  LOADUWX,
+	7:  LOADUDX,
+	8:  LOADQX,
+	9:  LOADAX,
+	10: STOREAX,
+	11: STOREQX,
+	12: STOREBX,
+	13: STOREHX,
+	14: STOREWX,
+	15: STOREDX,
+	24: ADDWL,
+	25: ANDWL,
+	26: ORWL,
+	27: XORWL,
+	28: ADDDL,
+	29: ANDDL,
+	30: ORDL,
+	31: XORDL,
+	32: ADDQL,
+	33: ANDQL,
+	34: ORQL,
+	35: XORQL,
+	36: SLTSL,
+	37: SLTUL,
+	38: ADDAL,
+}
+
+// flagsZeroZOperations are the operations of the same table for a zero Z.
+// Opcode 0 selects its operation with the condition field instead.
+var flagsZeroZOperations = [64]Operation{
+	1: BEQZX,
+	2: BNEZX,
+	3: BLTZX,
+	4: BGEZX,
+	5: BEQZAX,
+	6: BNEZAX,
+	7: BLTZAX,
+	8: BGEZAX,
+}
+
+// flagsConditions are the operations of XBC instructions, by condition.
+var flagsConditions = [16]Operation{
+	0:  CALLX,
+	1:  JMPX,
+	2:  JEQX,
+	3:  JNEX,
+	4:  JLTX,
+	5:  JLEX,
+	6:  JGEX,
+	7:  JGTX,
+	8:  JLOX,
+	9:  JLSX,
+	10: JHSX,
+	11: JHIX,
+	12: JPIX,
+	13: JNIX,
+	14: JOVX,
+	15: JNOX,
+}
+
+// flagsCompactOperations are provisional: srx_flags.md only says that CMP
+// is a 16-bit instruction. Flag-setting arithmetic sits next to it, and
+// the jump to an A register stands in for the register branches, which
+// have no layout yet.
+var flagsCompactOperations = [4][16]Operation{
+	0: {JMPA},
+	1: {CMP, CMPA, ADDS, SUBS},
+	3: {CMPI, ADDSI},
+}
+
+// tabFormats allocates the opcodes of the test-and-branch variant to
+// formats, as srx_tab.md lists them.
+var tabFormats = []allocation{
+	{0, 0, 0, FormatC1},   // One argument (provisional)
+	{0, 1, 1, FormatC2},   // Two arguments (provisional)
+	{0, 2, 2, FormatCE},   // Eight-bit immediate (provisional)
+	{0, 3, 3, FormatCF},   // Four-bit immediate (provisional)
+	{1, 0, 31, FormatR},   // Register (provisional)
+	{1, 32, 63, FormatE},  // Eight-bit immediate (provisional)
+	{2, 0, 47, FormatA},   // Assignment (provisional)
+	{2, 48, 63, FormatB},  // Branch (provisional)
+	{3, 0, 9, FormatXA},   // Loads: signed B, H, W, D; unsigned B, H, W, D; Q and A
+	{3, 10, 15, FormatXB}, // Stores: B, H, W, D, Q and A
+	{3, 16, 23, FormatXA}, // Floating-point loads: H, W, D and Q for both banks
+	{3, 24, 31, FormatXB}, // Floating-point stores: H, W, D and Q for both banks
+	{3, 32, 43, FormatXB}, // Branches on data and on addresses
+	{3, 44, 63, FormatXA}, // Call, jump, add.l, and.l, or.l, xor.l, slt.l, aiupc.l and lui.l
+}
+
+// flagsFormats allocates the opcodes of the flags variant to formats, as
+// srx_flags.md lists them, for a non-zero Z.
+var flagsFormats = []allocation{
+	{0, 0, 0, FormatC1},   // One argument (provisional)
+	{0, 1, 1, FormatC2},   // Two arguments (provisional)
+	{0, 2, 2, FormatCE},   // Eight-bit immediate (provisional)
+	{0, 3, 3, FormatCF},   // Four-bit immediate (provisional)
+	{1, 0, 31, FormatR},   // Register (provisional)
+	{1, 32, 63, FormatE},  // Eight-bit immediate (provisional)
+	{2, 0, 39, FormatA},   // Assignment (provisional)
+	{2, 40, 55, FormatS},  // Store (provisional)
+	{2, 56, 63, FormatBC}, // Branch on condition (provisional)
+	{3, 0, 9, FormatXA},   // Loads
+	{3, 10, 15, FormatXS}, // Stores
+	{3, 24, 38, FormatXA}, // Arithmetic
+}
+
+// flagsZeroZFormats are the formats of the 48-bit opcodes of the flags
+// variant that differ when Z is zero.
+var flagsZeroZFormats = []allocation{
+	{3, 0, 0, FormatXBC},  // Jumps on condition
+	{3, 1, 8, FormatXA},   // Branches on zero
+	{3, 24, 37, FormatXA}, // Unused
+}
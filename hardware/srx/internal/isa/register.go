@@ -0,0 +1,114 @@
+package isa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DataRegister is a register number in the D bank.
+type DataRegister uint8
+
+const (
+	ZR DataRegister = 0x0
+	X4 DataRegister = 0x1
+	X3 DataRegister = 0x2
+	X2 DataRegister = 0x3
+	X1 DataRegister = 0x4
+	X0 DataRegister = 0x5
+	Y1 DataRegister = 0x6
+	Y0 DataRegister = 0x7
+	Z0 DataRegister = 0x8
+	Z1 DataRegister = 0x9
+	Z2 DataRegister = 0xa
+	Z3 DataRegister = 0xb
+	Z4 DataRegister = 0xc
+	Z5 DataRegister = 0xd
+	Z6 DataRegister = 0xe
+	Z7 DataRegister = 0xf
+)
+
+// AddressRegister is a register number in the A bank.
+type AddressRegister uint8
+
+const (
+	SP AddressRegister = 0x0
+	BP AddressRegister = 0x1
+	TP AddressRegister = 0x2
+	A2 AddressRegister = 0x3
+	A1 AddressRegister = 0x4
+	A0 AddressRegister = 0x5
+	B1 AddressRegister = 0x6
+	B0 AddressRegister = 0x7
+	C0 AddressRegister = 0x8
+	C1 AddressRegister = 0x9
+	C2 AddressRegister = 0xa
+	C3 AddressRegister = 0xb
+	C4 AddressRegister = 0xc
+	C5 AddressRegister = 0xd
+	C6 AddressRegister = 0xe
+	RP AddressRegister = 0xf
+
+	// Aliases.
+	MP = B0
+	FP = C0
+)
+
+// NumRegisters is the number of registers in each bank.
+const NumRegisters = 16
+
+func (r DataRegister) String() string {
+	if int(r) < len(dataRegisterNames) {
+		return dataRegisterNames[r]
+	}
+
+	return fmt.Sprintf("data(%d)", uint8(r))
+}
+
+func (r AddressRegister) String() string {
+	if int(r) < len(addressRegisterNames) {
+		return addressRegisterNames[r]
+	}
+
+	return fmt.Sprintf("address(%d)", uint8(r))
+}
+
+var dataRegisterNames = [NumRegisters]string{
+	"zr", "x4", "x3", "x2", "x1", "x0", "y1", "y0",
+	"z0", "z1", "z2", "z3", "z4", "z5", "z6", "z7",
+}
+
+var addressRegisterNames = [NumRegisters]string{
+	"sp", "bp", "tp", "a2", "a1", "a0", "b1", "b0",
+	"c0", "c1", "c2", "c3", "c4", "c5", "c6", "rp",
+}
+
+// ParseDataRegister parses the name or alias of a D register,
+// case-insensitively.
+func ParseDataRegister(name string) (DataRegister, bool) {
+	for r, candidate := range dataRegisterNames {
+		if strings.EqualFold(name, candidate) {
+			return DataRegister(r), true
+		}
+	}
+
+	return 0, false
+}
+
+// ParseAddressRegister parses the name or alias of an A register,
+// case-insensitively.
+func ParseAddressRegister(name string) (AddressRegister, bool) {
+	switch strings.ToLower(name) {
+	case "mp":
+		return MP, true
+	case "fp":
+		return FP, true
+	}
+
+	for r, candidate := range addressRegisterNames {
+		if strings.EqualFold(name, candidate) {
+			return AddressRegister(r), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,241 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+)
+
+// Go returns the source of operations.go of package isa, which has the
+// constants of the operations, their mnemonics and operands, and their
+// allocation in each variant. It fails if the encodings of two
+// instructions of a variant collide, unless the collision is listed in
+// FlagsCollisions, in which case neither instruction gets the encoding.
+func Go() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("// Code generated by gen.go from package spec; DO NOT EDIT.\n\n")
+	b.WriteString("package isa\n\n")
+	b.WriteString("// Operation of an instruction. The specifications only define the 48-bit\n")
+	b.WriteString("// ones for now. The flags variant also has a few provisional 16-bit ones,\n")
+	b.WriteString("// because flags cannot be set otherwise.\n")
+	b.WriteString("type Operation uint8\n\n")
+	b.WriteString("const (\n")
+	b.WriteString("\t// Unknown marks opcodes without an operation.\n")
+	b.WriteString("\tUnknown Operation = iota\n")
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(&b, "\n\t// %s\n", strings.ReplaceAll(g.comment, "\n", "\n\t// "))
+		for _, o := range g.operations {
+			_, _ = fmt.Fprintf(&b, "\t%s\n", o.name)
+		}
+	}
+	b.WriteString("\n\tnumOperations\n)\n\n")
+
+	b.WriteString("var operationNames = [numOperations]string{\n")
+	b.WriteString("\tUnknown: \"unknown\",\n")
+	for _, g := range groups {
+		for _, o := range g.operations {
+			_, _ = fmt.Fprintf(&b, "\t%s: %q,\n", o.name, o.mnemonic)
+		}
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// operationOperands are the operands of the operations in assembly\n")
+	b.WriteString("// syntax, separated by commas.\n")
+	b.WriteString("var operationOperands = [numOperations]string{\n")
+	for _, g := range groups {
+		for _, o := range g.operations {
+			_, _ = fmt.Fprintf(&b, "\t%s: %q,\n", o.name, o.operands)
+		}
+	}
+	b.WriteString("}\n\n")
+
+	if err := checkFormats(Tab, tabFormats, nil, nil); err != nil {
+		return nil, err
+	}
+	if err := checkFormats(Flags, flagsFormats, flagsZeroZFormats, FlagsCollisions); err != nil {
+		return nil, err
+	}
+
+	tab, err := allocations(Tab, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := allocations(Flags, FlagsCollisions)
+	if err != nil {
+		return nil, err
+	}
+
+	b.WriteString("// tabOperations follows the order in which srx_tab.md lists the classes of\n")
+	b.WriteString("// 48-bit instructions, which consume exactly the 64 opcodes.\n")
+	writeArray(&b, "tabOperations", "[64]Operation", tab.operations[:])
+
+	b.WriteString("// flagsOperations follows the table of 48-bit instructions of srx_flags.md\n")
+	b.WriteString("// for a non-zero Z. Its floating-point loads and stores share opcodes, so\n")
+	b.WriteString("// both are left out.\n")
+	writeArray(&b, "flagsOperations", "[64]Operation", flags.operations[:])
+
+	b.WriteString("// flagsZeroZOperations are the operations of the same table for a zero Z.\n")
+	b.WriteString("// Opcode 0 selects its operation with the condition field instead.\n")
+	writeArray(&b, "flagsZeroZOperations", "[64]Operation", flags.zeroZOperations[:])
+
+	b.WriteString("// flagsConditions are the operations of XBC instructions, by condition.\n")
+	writeArray(&b, "flagsConditions", "[16]Operation", flags.conditions[:])
+
+	b.WriteString("// flagsCompactOperations are provisional: srx_flags.md only says that CMP\n")
+	b.WriteString("// is a 16-bit instruction. Flag-setting arithmetic sits next to it, and\n")
+	b.WriteString("// the jump to an A register stands in for the register branches, which\n")
+	b.WriteString("// have no layout yet.\n")
+	b.WriteString("var flagsCompactOperations = [4][16]Operation{\n")
+	for opcode, operations := range flags.compactOperations {
+		if operations != [16]string{} {
+			_, _ = fmt.Fprintf(&b, "\t%d: {%s},\n", opcode, strings.Join(trim(operations[:]), ", "))
+		}
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// tabFormats allocates the opcodes of the test-and-branch variant to\n")
+	b.WriteString("// formats, as srx_tab.md lists them.\n")
+	writeFormats(&b, "tabFormats", tabFormats)
+
+	b.WriteString("// flagsFormats allocates the opcodes of the flags variant to formats, as\n")
+	b.WriteString("// srx_flags.md lists them, for a non-zero Z.\n")
+	writeFormats(&b, "flagsFormats", flagsFormats)
+
+	b.WriteString("// flagsZeroZFormats are the formats of the 48-bit opcodes of the flags\n")
+	b.WriteString("// variant that differ when Z is zero.\n")
+	writeFormats(&b, "flagsZeroZFormats", flagsZeroZFormats)
+
+	return format.Source([]byte(b.String()))
+}
+
+// writeFormats writes the declaration of the allocation of ranges of
+// opcodes to formats.
+func writeFormats(b *strings.Builder, name string, ranges []formatRange) {
+	_, _ = fmt.Fprintf(b, "var %s = []allocation{\n", name)
+	for _, r := range ranges {
+		_, _ = fmt.Fprintf(b, "\t{%d, %d, %d, Format%s}, // %s\n", r.class, r.first, r.last, r.format, r.description())
+	}
+	b.WriteString("}\n")
+}
+
+// checkFormats returns an error if an instruction does not have the format
+// that the ranges allocate to its opcode. Instructions with a zero Z use
+// the zero-Z ranges, if there are any for their opcode. The instructions
+// of the allowed collisions have no encoding, so they are left out.
+func checkFormats(isa *isaspec.ISA, ranges, zeroZRanges []formatRange, allowed []string) error {
+	collided := map[string]bool{}
+	for _, c := range allowed {
+		first, second, _ := strings.Cut(c, " and ")
+		collided[first], collided[second] = true, true
+	}
+
+	for _, i := range isa.Instructions {
+		if collided[i.Mnemonic] {
+			continue
+		}
+
+		class, opcode := i.Values["class"], i.Values["opcode"]
+		r, ok := findRange(ranges, class, opcode)
+		if zr, zeroZ := findRange(zeroZRanges, class, opcode); zeroZ && isZeroZ(&i) {
+			r, ok = zr, true
+		}
+
+		switch {
+		case !ok:
+			return fmt.Errorf("%s: %s has opcode %d of class %d, which is reserved", isa.Name, i.Mnemonic, opcode, class)
+		case r.format != i.Format:
+			return fmt.Errorf("%s: %s has format %s, but its opcode has format %s", isa.Name, i.Mnemonic, i.Format, r.format)
+		}
+	}
+
+	return nil
+}
+
+// findRange returns the range that contains the opcode of the class.
+func findRange(ranges []formatRange, class, opcode uint32) (formatRange, bool) {
+	for _, r := range ranges {
+		if r.class == class && r.first <= opcode && opcode <= r.last {
+			return r, true
+		}
+	}
+
+	return formatRange{}, false
+}
+
+// allocation of the operations of a variant, by name, as in isa.Variant.
+type allocation struct {
+	operations        [64]string
+	zeroZOperations   [64]string
+	conditions        [16]string
+	compactOperations [4][16]string
+}
+
+// allocations returns the allocation of the operations of the
+// instruction set. The encodings of the allowed collisions are left
+// without an operation.
+func allocations(isa *isaspec.ISA, allowed []string) (*allocation, error) {
+	if err := isa.Check(allowed...); err != nil {
+		return nil, err
+	}
+
+	var a allocation
+	var shared []*string
+	for _, i := range isa.Instructions {
+		opcode := i.Values["opcode"]
+		var slot *string
+		switch _, zeroZ := i.Values["Z"]; {
+		case i.Values["class"] == 0:
+			slot = &a.compactOperations[opcode][i.Values["func"]]
+		case i.Format == "XBC":
+			slot = &a.conditions[i.Values["cond"]]
+		case zeroZ:
+			slot = &a.zeroZOperations[opcode]
+		case i.Values["class"] == 3:
+			slot = &a.operations[opcode]
+		default:
+			return nil, fmt.Errorf("%s: %s has no place in isa.Variant", isa.Name, i.Mnemonic)
+		}
+
+		if *slot != "" {
+			shared = append(shared, slot)
+		}
+		*slot = i.Name
+	}
+
+	for _, slot := range shared {
+		*slot = ""
+	}
+
+	return &a, nil
+}
+
+// writeArray writes the declaration of an array of operations, keyed by
+// index. Unknown operations are left out.
+func writeArray(b *strings.Builder, name, typ string, operations []string) {
+	_, _ = fmt.Fprintf(b, "var %s = %s{\n", name, typ)
+	for n, o := range operations {
+		if o != "" {
+			_, _ = fmt.Fprintf(b, "\t%d: %s,\n", n, o)
+		}
+	}
+	b.WriteString("}\n\n")
+}
+
+// trim removes the trailing empty names and names the others Unknown.
+func trim(names []string) []string {
+	end := len(names)
+	for end > 0 && names[end-1] == "" {
+		end--
+	}
+
+	trimmed := make([]string, end)
+	for n, name := range names[:end] {
+		trimmed[n] = cmp.Or(name, "Unknown")
+	}
+
+	return trimmed
+}
@@ -0,0 +1,225 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+)
+
+// Markdown returns the tables of the documentation by path, relative to
+// the directory of SRX, and name.
+func Markdown() map[string]map[string]string {
+	return map[string]map[string]string{
+		"srx_tab.md": {
+			"formats": formatTable(tabFormats, nil).String(),
+			"classes": classTable().String(),
+		},
+		"srx_flags.md": {
+			"formats":      formatTable(flagsFormats, flagsZeroZFormats).String(),
+			"compact":      compactTable(Flags).String(),
+			"instructions": extendedTable(Flags).String(),
+		},
+	}
+}
+
+// formatTable returns the allocation of the opcodes of each class to
+// formats, with the formats for a zero Z after the others, and the
+// reserved opcodes.
+func formatTable(ranges, zeroZRanges []formatRange) *isaspec.Table {
+	type row struct {
+		formatRange
+		zeroZ bool
+	}
+
+	var rows []row
+	for _, r := range ranges {
+		rows = append(rows, row{r, false})
+	}
+	for _, r := range zeroZRanges {
+		rows = append(rows, row{r, true})
+	}
+
+	// Every class has 64 opcodes, except the 16-bit one, which has 4.
+	for class := range uint32(4) {
+		size := uint32(64)
+		if class == 0 {
+			size = 4
+		}
+
+		next := uint32(0)
+		for _, r := range ranges {
+			if r.class != class {
+				continue
+			}
+			if r.first > next {
+				rows = append(rows, row{formatRange{class, next, r.first - 1, "", ""}, false})
+			}
+			next = r.last + 1
+		}
+		if next < size {
+			rows = append(rows, row{formatRange{class, next, size - 1, "", ""}, false})
+		}
+	}
+
+	slices.SortStableFunc(rows, func(x, y row) int {
+		return cmp.Or(
+			cmp.Compare(x.class, y.class),
+			cmp.Compare(x.first, y.first),
+			cmp.Compare(boolInt(x.zeroZ), boolInt(y.zeroZ)),
+		)
+	})
+
+	t := &isaspec.Table{Header: []string{"Class", "Opcodes", "Format", "Usage"}}
+	for _, r := range rows {
+		opcodes := fmt.Sprint(r.first)
+		if r.last > r.first {
+			opcodes = fmt.Sprintf("%d..%d", r.first, r.last)
+		}
+
+		format, usage := r.format, r.description()
+		switch {
+		case r.format == "":
+			format, usage = "", "(reserved)"
+		case r.zeroZ:
+			format += " (Z = 0)"
+		}
+
+		t.Rows = append(t.Rows, []string{fmt.Sprint(r.class), opcodes, format, usage})
+	}
+
+	return t
+}
+
+// compactTable returns the encodings of the 16-bit instructions.
+func compactTable(isa *isaspec.ISA) *isaspec.Table {
+	t := &isaspec.Table{Header: []string{"Instruction", "Format", "Opcode", "Func"}}
+	for _, i := range isa.Instructions {
+		if i.Values["class"] == 0 {
+			t.Rows = append(t.Rows, []string{
+				"`" + i.Syntax() + "`", i.Format, fmt.Sprint(i.Values["opcode"]), fmt.Sprintf("%x", i.Values["func"]),
+			})
+		}
+	}
+
+	return t
+}
+
+// classTable returns the classes of 48-bit instructions of the
+// test-and-branch variant and their opcodes.
+func classTable() *isaspec.Table {
+	t := &isaspec.Table{Header: []string{"Class", "Variants", "Opcodes", "Count"}}
+	opcode := 0
+	for _, c := range tabClasses {
+		last := opcode + len(c.names) - 1
+		opcodes := fmt.Sprint(opcode)
+		if last > opcode {
+			opcodes = fmt.Sprintf("%d..%d", opcode, last)
+		}
+
+		t.Rows = append(t.Rows, []string{c.name, c.variants, opcodes, fmt.Sprint(len(c.names))})
+		opcode = last + 1
+	}
+
+	return t
+}
+
+// extendedTable returns the encodings of the 48-bit instructions, by
+// opcode. Where an opcode is shared, the instructions with a zero Z come
+// first, and opcodes that only use a non-zero Z have an unused row.
+func extendedTable(isa *isaspec.ISA) *isaspec.Table {
+	var instructions []isaspec.Instruction
+	zeroZ := map[uint32]bool{}
+	for _, i := range isa.Instructions {
+		if i.Values["class"] == 3 {
+			instructions = append(instructions, i)
+		}
+		if isZeroZ(&i) {
+			zeroZ[i.Values["opcode"]] = true
+		}
+	}
+
+	slices.SortStableFunc(instructions, func(x, y isaspec.Instruction) int {
+		return cmp.Or(
+			cmp.Compare(x.Values["opcode"], y.Values["opcode"]),
+			cmp.Compare(boolInt(isZeroZ(&y)), boolInt(isZeroZ(&x))),
+		)
+	})
+
+	t := &isaspec.Table{Header: []string{
+		"Instruction", "[20..47]", "[16..19]", "[12..15]", "[8..11]", "[2..7]", "[0..1]",
+	}}
+	last := uint32(0)
+	for _, i := range instructions {
+		opcode := i.Values["opcode"]
+		if len(i.NonZero) > 0 && !zeroZ[opcode] {
+			t.Rows = append(t.Rows, []string{
+				"(unused)", "imm32", "imm32", "0", "*", fmt.Sprint(opcode), "3",
+			})
+		}
+
+		t.Rows = append(t.Rows, append([]string{"`" + i.Syntax() + "`"}, extendedCells(&i)...))
+		last = opcode
+	}
+
+	if last < 63 {
+		t.Rows = append(t.Rows, []string{
+			"(reserved)", "...", "...", "...", "...", fmt.Sprintf("%d..63", last+1), "3",
+		})
+	}
+
+	return t
+}
+
+// extendedCells returns the content of the bits of a 48-bit instruction,
+// from the most significant, after the 28 most significant bits of the
+// immediate.
+func extendedCells(i *isaspec.Instruction) []string {
+	opcode := fmt.Sprint(i.Values["opcode"])
+	if i.Format == "XBC" {
+		return []string{"imm32", "imm32", fmt.Sprintf("%x", i.Values["func"]), fmt.Sprintf("%x", i.Values["cond"]), opcode, "3"}
+	}
+
+	// Registers go in the fields Z (or Y) and X, in order. A lone
+	// register goes in X if it is named after it.
+	var registers []string
+	for _, operand := range strings.Split(i.Operands, ", ") {
+		if strings.HasPrefix(operand, "%") {
+			registers = append(registers, operand[1:])
+		}
+	}
+	if len(registers) == 1 && strings.Contains("XA", registers[0]) {
+		registers = []string{"", registers[0]}
+	}
+	registers = append(registers, "", "")
+	first, second := registers[0], cmp.Or(registers[1], "0")
+
+	if i.Format == "XA" {
+		switch _, zero := i.Values["Z"]; {
+		case zero:
+			first = "0"
+		case len(i.NonZero) > 0:
+			first += " != 0"
+		}
+
+		return []string{"imm32", "imm32", first, second, opcode, "3"}
+	}
+
+	return []string{"imm32", first, "imm32", second, opcode, "3"}
+}
+
+// isZeroZ reports whether the instruction takes the place of Z = 0.
+func isZeroZ(i *isaspec.Instruction) bool {
+	_, ok := i.Values["Z"]
+	return ok || i.Format == "XBC"
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
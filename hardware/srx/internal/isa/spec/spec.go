@@ -0,0 +1,428 @@
+// Package spec is the specification of the SRX instruction set, from which
+// the operations of package isa, their allocation in each variant, and the
+// tables of the encodings in srx_tab.md and srx_flags.md are generated.
+package spec
+
+import (
+	"fmt"
+
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+)
+
+// Tab is the specification of the test-and-branch variant.
+var Tab = newISA("SRX tab", tabInstructions())
+
+// Flags is the specification of the variant with condition flags.
+var Flags = newISA("SRX flags", flagsInstructions())
+
+// operation of the instruction set, which variants allocate to encodings.
+type operation struct {
+	name     string
+	mnemonic string
+
+	// Operands in assembly syntax. Registers are named after the field
+	// that holds them: Z, Y and X for D registers, C, B and A for A
+	// registers, and F0 and F1 for the banks of floating-point registers.
+	operands string
+}
+
+// group of operations that share a comment in code.
+type group struct {
+	comment    string
+	operations []operation
+}
+
+// groups are in the order of the constants of the operations.
+var groups = []group{
+	{"Loads into D registers, sign-extended (S) or zero-extended (U).", []operation{
+		{"LOADSBX", "load.sbx", "%Z, %A, imm32"},
+		{"LOADSHX", "load.shx", "%Z, %A, imm32"},
+		{"LOADSWX", "load.swx", "%Z, %A, imm32"},
+		{"LOADSDX", "load.sdx", "%Z, %A, imm32"},
+		{"LOADUBX", "load.ubx", "%Z, %A, imm32"},
+		{"LOADUHX", "load.uhx", "%Z, %A, imm32"},
+		{"LOADUWX", "load.uwx", "%Z, %A, imm32"},
+		{"LOADUDX", "load.udx", "%Z, %A, imm32"},
+		{"LOADQX", "load.qx", "%Z, %A, imm32"},
+	}},
+	{"Load into an A register.", []operation{
+		{"LOADAX", "load.ax", "%C, %A, imm32"},
+	}},
+	{"Stores.", []operation{
+		{"STOREBX", "store.bx", "%Y, %A, imm32"},
+		{"STOREHX", "store.hx", "%Y, %A, imm32"},
+		{"STOREWX", "store.wx", "%Y, %A, imm32"},
+		{"STOREDX", "store.dx", "%Y, %A, imm32"},
+		{"STOREQX", "store.qx", "%Y, %A, imm32"},
+		{"STOREAX", "store.ax", "%B, %A, imm32"},
+	}},
+	{"Floating-point loads and stores, for the low (0) and high (1) banks.", []operation{
+		{"LOADFH0X", "load.fh0x", "%F0, %A, imm32"},
+		{"LOADFH1X", "load.fh1x", "%F1, %A, imm32"},
+		{"LOADFW0X", "load.fw0x", "%F0, %A, imm32"},
+		{"LOADFW1X", "load.fw1x", "%F1, %A, imm32"},
+		{"LOADFD0X", "load.fd0x", "%F0, %A, imm32"},
+		{"LOADFD1X", "load.fd1x", "%F1, %A, imm32"},
+		{"LOADFQ0X", "load.fq0x", "%F0, %A, imm32"},
+		{"LOADFQ1X", "load.fq1x", "%F1, %A, imm32"},
+		{"STOREFH0X", "store.fh0x", "%F0, %A, imm32"},
+		{"STOREFH1X", "store.fh1x", "%F1, %A, imm32"},
+		{"STOREFW0X", "store.fw0x", "%F0, %A, imm32"},
+		{"STOREFW1X", "store.fw1x", "%F1, %A, imm32"},
+		{"STOREFD0X", "store.fd0x", "%F0, %A, imm32"},
+		{"STOREFD1X", "store.fd1x", "%F1, %A, imm32"},
+		{"STOREFQ0X", "store.fq0x", "%F0, %A, imm32"},
+		{"STOREFQ1X", "store.fq1x", "%F1, %A, imm32"},
+	}},
+	{"Branches comparing D registers.", []operation{
+		{"BEQX", "beq.x", "%Y, %X, target"},
+		{"BNEX", "bne.x", "%Y, %X, target"},
+		{"BLTSX", "blt.sx", "%Y, %X, target"},
+		{"BLTUX", "blt.ux", "%Y, %X, target"},
+		{"BGESX", "bge.sx", "%Y, %X, target"},
+		{"BGEUX", "bge.ux", "%Y, %X, target"},
+	}},
+	{"Branches comparing A registers.", []operation{
+		{"BEQAX", "beq.ax", "%B, %A, target"},
+		{"BNEAX", "bne.ax", "%B, %A, target"},
+		{"BLTAX", "blt.ax", "%B, %A, target"},
+		{"BGEAX", "bge.ax", "%B, %A, target"},
+		{"BEQZAX", "beqz.ax", "%A, target"},
+		{"BNEZAX", "bnez.ax", "%A, target"},
+	}},
+	{"Unconditional control flow to an A register plus the immediate.", []operation{
+		{"CALLAX", "call.ax", "%C, %A, imm32"},
+		{"JMPAX", "jmp.ax", "%A, imm32"},
+	}},
+	{"Arithmetic with long immediates.", []operation{
+		{"ADDWL", "add.wl", "%Z, %X, imm32"},
+		{"ADDDL", "add.dl", "%Z, %X, imm32"},
+		{"ADDQL", "add.ql", "%Z, %X, imm32"},
+		{"ANDWL", "and.wl", "%Z, %X, imm32"},
+		{"ANDDL", "and.dl", "%Z, %X, imm32"},
+		{"ANDQL", "and.ql", "%Z, %X, imm32"},
+		{"ORWL", "or.wl", "%Z, %X, imm32"},
+		{"ORDL", "or.dl", "%Z, %X, imm32"},
+		{"ORQL", "or.ql", "%Z, %X, imm32"},
+		{"XORWL", "xor.wl", "%Z, %X, imm32"},
+		{"XORDL", "xor.dl", "%Z, %X, imm32"},
+		{"XORQL", "xor.ql", "%Z, %X, imm32"},
+		{"SLTUL", "slt.ul", "%Z, %X, imm32"},
+		{"SLTSL", "slt.sl", "%Z, %X, imm32"},
+		{"AIUPCL", "aiupc.l", "%C, target"},
+		{"LUILD", "lui.ld", "%Z, %X, imm32"},
+		{"LUILQ0", "lui.lq0", "%Z, %X, imm32"},
+		{"LUILQ1", "lui.lq1", "%Z, %X, imm32"},
+	}},
+	{"Control flow relative to the IP (flags variant). The jumps are\nconditional on the flags.", []operation{
+		{"CALLX", "call.x", "target"},
+		{"JMPX", "jmp.x", "target"},
+		{"JEQX", "jeq.x", "target"},
+		{"JNEX", "jne.x", "target"},
+		{"JLTX", "jlt.x", "target"},
+		{"JLEX", "jle.x", "target"},
+		{"JGEX", "jge.x", "target"},
+		{"JGTX", "jgt.x", "target"},
+		{"JLOX", "jlo.x", "target"},
+		{"JLSX", "jls.x", "target"},
+		{"JHSX", "jhs.x", "target"},
+		{"JHIX", "jhi.x", "target"},
+		{"JPIX", "jpi.x", "target"},
+		{"JNIX", "jni.x", "target"},
+		{"JOVX", "jov.x", "target"},
+		{"JNOX", "jno.x", "target"},
+	}},
+	{"Branches comparing a register with zero (flags variant).", []operation{
+		{"BEQZX", "beqz.x", "%X, target"},
+		{"BNEZX", "bnez.x", "%X, target"},
+		{"BLTZX", "bltz.x", "%X, target"},
+		{"BGEZX", "bgez.x", "%X, target"},
+		{"BLTZAX", "bltz.ax", "%A, target"},
+		{"BGEZAX", "bgez.ax", "%A, target"},
+	}},
+	{"Address arithmetic with a long immediate (flags variant).", []operation{
+		{"ADDAL", "add.al", "%C, %A, imm32"},
+	}},
+	{"16-bit operations (flags variant): a jump to an A register, for\nreturns, and operations that set the flags.", []operation{
+		{"JMPA", "jmp.a", "%A"},
+		{"CMP", "cmp", "%X, %Y"},
+		{"CMPA", "cmp.a", "%A, %B"},
+		{"ADDS", "adds", "%X, %Y"},
+		{"SUBS", "subs", "%X, %Y"},
+		{"CMPI", "cmp.i", "%X, imm4"},
+		{"ADDSI", "adds.i", "%X, imm4"},
+	}},
+}
+
+// Fields that select the instruction: the class, which determines the
+// length, the opcode and the function.
+var (
+	classField     = isaspec.Field{Name: "class", Offset: 0, Width: 2}
+	opcode16Field  = isaspec.Field{Name: "opcode", Offset: 2, Width: 2}
+	opcodeField    = isaspec.Field{Name: "opcode", Offset: 2, Width: 6}
+	func8Field     = isaspec.Field{Name: "func", Offset: 24, Width: 8}
+	compact16Field = isaspec.Field{Name: "func", Offset: 12, Width: 4}
+)
+
+// formats of package isa. Register and immediate fields are left out,
+// except Z, which some 48-bit opcodes of the flags variant share.
+var formats = []isaspec.Format{
+	{Name: "C1", Bits: 16, Fields: []isaspec.Field{classField, opcode16Field, {Name: "func", Offset: 8, Width: 8}}},
+	{Name: "C2", Bits: 16, Fields: []isaspec.Field{classField, opcode16Field, compact16Field}},
+	{Name: "CE", Bits: 16, Fields: []isaspec.Field{classField, opcode16Field}},
+	{Name: "CF", Bits: 16, Fields: []isaspec.Field{classField, opcode16Field, compact16Field}},
+	{Name: "R", Bits: 32, Fields: []isaspec.Field{classField, opcodeField, func8Field}},
+	{Name: "E", Bits: 32, Fields: []isaspec.Field{classField, opcodeField, func8Field}},
+	{Name: "A", Bits: 32, Fields: []isaspec.Field{classField, opcodeField}},
+	{Name: "B", Bits: 32, Fields: []isaspec.Field{classField, opcodeField}},
+	{Name: "S", Bits: 32, Fields: []isaspec.Field{classField, opcodeField}},
+	{Name: "BC", Bits: 32, Fields: []isaspec.Field{
+		classField, opcodeField, {Name: "cond", Offset: 12, Width: 4}, {Name: "func", Offset: 8, Width: 4},
+	}},
+	{Name: "XA", Bits: 48, Fields: []isaspec.Field{classField, opcodeField, {Name: "Z", Offset: 12, Width: 4}}},
+	{Name: "XB", Bits: 48, Fields: []isaspec.Field{classField, opcodeField}},
+	{Name: "XS", Bits: 48, Fields: []isaspec.Field{classField, opcodeField}},
+	{Name: "XBC", Bits: 48, Fields: []isaspec.Field{
+		classField, opcodeField, {Name: "func", Offset: 12, Width: 4}, {Name: "cond", Offset: 8, Width: 4},
+	}},
+}
+
+// formatRange is a range of opcodes of a class that a variant allocates to
+// a format.
+type formatRange struct {
+	class       uint32
+	first, last uint32
+	format      string
+	usage       string
+}
+
+// provisional reports whether the range is not defined by the
+// specifications, which only allocate 48-bit opcodes for now.
+func (r formatRange) provisional() bool {
+	return r.class != 3
+}
+
+// description returns the usage of the range, noting whether it is
+// provisional.
+func (r formatRange) description() string {
+	if r.provisional() {
+		return r.usage + " (provisional)"
+	}
+
+	return r.usage
+}
+
+// tabFormats are the formats of the opcodes of the test-and-branch
+// variant.
+var tabFormats = []formatRange{
+	{0, 0, 0, "C1", "One argument"},
+	{0, 1, 1, "C2", "Two arguments"},
+	{0, 2, 2, "CE", "Eight-bit immediate"},
+	{0, 3, 3, "CF", "Four-bit immediate"},
+	{1, 0, 31, "R", "Register"},
+	{1, 32, 63, "E", "Eight-bit immediate"},
+	{2, 0, 47, "A", "Assignment"},
+	{2, 48, 63, "B", "Branch"},
+	{3, 0, 9, "XA", "Loads: signed B, H, W, D; unsigned B, H, W, D; Q and A"},
+	{3, 10, 15, "XB", "Stores: B, H, W, D, Q and A"},
+	{3, 16, 23, "XA", "Floating-point loads: H, W, D and Q for both banks"},
+	{3, 24, 31, "XB", "Floating-point stores: H, W, D and Q for both banks"},
+	{3, 32, 43, "XB", "Branches on data and on addresses"},
+	{3, 44, 63, "XA", "Call, jump, add.l, and.l, or.l, xor.l, slt.l, aiupc.l and lui.l"},
+}
+
+// flagsFormats are the formats of the opcodes of the flags variant. For
+// 48-bit opcodes, they are the formats when Z is not zero. Opcodes 16 to
+// 23 are left reserved, because the floating-point loads and stores
+// collide there.
+var flagsFormats = []formatRange{
+	{0, 0, 0, "C1", "One argument"},
+	{0, 1, 1, "C2", "Two arguments"},
+	{0, 2, 2, "CE", "Eight-bit immediate"},
+	{0, 3, 3, "CF", "Four-bit immediate"},
+	{1, 0, 31, "R", "Register"},
+	{1, 32, 63, "E", "Eight-bit immediate"},
+	{2, 0, 39, "A", "Assignment"},
+	{2, 40, 55, "S", "Store"},
+	{2, 56, 63, "BC", "Branch on condition"},
+	{3, 0, 9, "XA", "Loads"},
+	{3, 10, 15, "XS", "Stores"},
+	{3, 24, 38, "XA", "Arithmetic"},
+}
+
+// flagsZeroZFormats are the formats of the 48-bit opcodes of the flags
+// variant when Z is zero, where they differ.
+var flagsZeroZFormats = []formatRange{
+	{3, 0, 0, "XBC", "Jumps on condition"},
+	{3, 1, 8, "XA", "Branches on zero"},
+	{3, 24, 37, "XA", "Unused"},
+}
+
+// class of 48-bit instructions of the test-and-branch variant. Classes
+// get consecutive opcodes, in the order of srx_tab.md.
+type class struct {
+	name     string
+	variants string
+	format   string
+	names    []string
+}
+
+var tabClasses = []class{
+	{"Load signed data operations", "B, H, W, D", "XA", []string{"LOADSBX", "LOADSHX", "LOADSWX", "LOADSDX"}},
+	{"Load unsigned data operations", "B, H, W, D", "XA", []string{"LOADUBX", "LOADUHX", "LOADUWX", "LOADUDX"}},
+	{"Load", "Q, A", "XA", []string{"LOADQX", "LOADAX"}},
+	{"Store", "B, H, W, D, Q, A", "XB", []string{"STOREBX", "STOREHX", "STOREWX", "STOREDX", "STOREQX", "STOREAX"}},
+	{"Load (FP)", "H, W, D, Q (2 banks)", "XA", []string{
+		"LOADFH0X", "LOADFH1X", "LOADFW0X", "LOADFW1X", "LOADFD0X", "LOADFD1X", "LOADFQ0X", "LOADFQ1X",
+	}},
+	{"Store (FP)", "H, W, D, Q (2 banks)", "XB", []string{
+		"STOREFH0X", "STOREFH1X", "STOREFW0X", "STOREFW1X", "STOREFD0X", "STOREFD1X", "STOREFQ0X", "STOREFQ1X",
+	}},
+	{"Branch (data)", "EQ, NE, LT.S, LT.U, GE.S, GE.U", "XB", []string{"BEQX", "BNEX", "BLTSX", "BLTUX", "BGESX", "BGEUX"}},
+	{"Branch (address)", "EQ, NE, LT, GE, Z, NZ", "XB", []string{"BEQAX", "BNEAX", "BLTAX", "BGEAX", "BEQZAX", "BNEZAX"}},
+	{"Call", "A", "XA", []string{"CALLAX"}},
+	{"Jump", "A", "XA", []string{"JMPAX"}},
+	{"`add.l`", "W, D, Q", "XA", []string{"ADDWL", "ADDDL", "ADDQL"}},
+	{"`and.l`", "W, D, Q", "XA", []string{"ANDWL", "ANDDL", "ANDQL"}},
+	{"`or.l`", "W, D, Q", "XA", []string{"ORWL", "ORDL", "ORQL"}},
+	{"`xor.l`", "W, D, Q", "XA", []string{"XORWL", "XORDL", "XORQL"}},
+	{"`slt.l`", "U, S", "XA", []string{"SLTUL", "SLTSL"}},
+	{"`aiupc.l`", "A", "XA", []string{"AIUPCL"}},
+	{"`lui.ld`", "D (offset 32)", "XA", []string{"LUILD"}},
+	{"`lui.lq0`", "Q (offset 64)", "XA", []string{"LUILQ0"}},
+	{"`lui.lq1`", "Q (offset 96)", "XA", []string{"LUILQ1"}},
+}
+
+func tabInstructions() []isaspec.Instruction {
+	var instructions []isaspec.Instruction
+	opcode := uint32(0)
+	for _, c := range tabClasses {
+		instructions = append(instructions, extended(c.format).allocate(opcode, c.names...)...)
+		opcode += uint32(len(c.names))
+	}
+
+	return instructions
+}
+
+// FlagsCollisions are the collisions that the generator allows in the
+// flags variant, because srx_flags.md gives the floating-point loads and
+// stores the same opcodes. Package isa decodes neither of them.
+var FlagsCollisions = []string{
+	"load.fh0x and store.fh0x", "load.fh1x and store.fh1x",
+	"load.fw0x and store.fw0x", "load.fw1x and store.fw1x",
+	"load.fd0x and store.fd0x", "load.fd1x and store.fd1x",
+	"load.fq0x and store.fq0x", "load.fq1x and store.fq1x",
+}
+
+func flagsInstructions() []isaspec.Instruction {
+	// Loading into ZR is pointless, so loads and arithmetic leave Z = 0
+	// to other operations.
+	nonZeroZ := extended("XA")
+	nonZeroZ.nonZero = []string{"Z"}
+	zeroZ := extended("XA")
+	zeroZ.values["Z"] = 0
+
+	conditions := extended("XBC")
+	conditions.field = "cond"
+	conditions.values["opcode"] = 0
+	conditions.values["func"] = 0
+
+	return concat(
+		conditions.allocate(0,
+			"CALLX", "JMPX", "JEQX", "JNEX", "JLTX", "JLEX", "JGEX", "JGTX",
+			"JLOX", "JLSX", "JHSX", "JHIX", "JPIX", "JNIX", "JOVX", "JNOX"),
+		nonZeroZ.allocate(0,
+			"LOADSBX", "LOADSHX", "LOADSWX", "LOADSDX", "LOADUBX", "LOADUHX", "LOADUWX", "LOADUDX",
+			"LOADQX"),
+		zeroZ.allocate(1, "BEQZX", "BNEZX", "BLTZX", "BGEZX", "BEQZAX", "BNEZAX", "BLTZAX", "BGEZAX"),
+		extended("XA").allocate(9, "LOADAX"),
+		extended("XS").allocate(10, "STOREAX", "STOREQX", "STOREBX", "STOREHX", "STOREWX", "STOREDX"),
+		extended("XA").allocate(16,
+			"LOADFH0X", "LOADFH1X", "LOADFW0X", "LOADFW1X", "LOADFD0X", "LOADFD1X", "LOADFQ0X", "LOADFQ1X"),
+		extended("XS").allocate(16,
+			"STOREFH0X", "STOREFH1X", "STOREFW0X", "STOREFW1X", "STOREFD0X", "STOREFD1X", "STOREFQ0X", "STOREFQ1X"),
+		nonZeroZ.allocate(24,
+			"ADDWL", "ANDWL", "ORWL", "XORWL",
+			"ADDDL", "ANDDL", "ORDL", "XORDL",
+			"ADDQL", "ANDQL", "ORQL", "XORQL",
+			"SLTSL", "SLTUL"),
+		extended("XA").allocate(38, "ADDAL"),
+
+		// The 16-bit operations are provisional: srx_flags.md only says
+		// that CMP is a 16-bit instruction.
+		compact("C1", 0).allocate(0, "JMPA"),
+		compact("C2", 1).allocate(0, "CMP", "CMPA", "ADDS", "SUBS"),
+		compact("CF", 3).allocate(0, "CMPI", "ADDSI"),
+	)
+}
+
+// encoding of a range of operations, which get consecutive values of a
+// field.
+type encoding struct {
+	format  string
+	values  map[string]uint32
+	field   string
+	nonZero []string
+}
+
+// extended returns the encoding of 48-bit operations by opcode.
+func extended(format string) encoding {
+	return encoding{format: format, values: map[string]uint32{"class": 3}, field: "opcode"}
+}
+
+// compact returns the encoding of 16-bit operations of an opcode by
+// function.
+func compact(format string, opcode uint32) encoding {
+	return encoding{format: format, values: map[string]uint32{"class": 0, "opcode": opcode}, field: "func"}
+}
+
+// allocate the operations with the given names, starting at the value
+// first of the field.
+func (e encoding) allocate(first uint32, names ...string) []isaspec.Instruction {
+	var instructions []isaspec.Instruction
+	for n, name := range names {
+		o := lookup(name)
+		values := map[string]uint32{e.field: first + uint32(n)}
+		for field, v := range e.values {
+			values[field] = v
+		}
+
+		instructions = append(instructions, isaspec.Instruction{
+			Name:     o.name,
+			Mnemonic: o.mnemonic,
+			Operands: o.operands,
+			Format:   e.format,
+			Values:   values,
+			NonZero:  e.nonZero,
+		})
+	}
+
+	return instructions
+}
+
+// lookup returns the operation with the given name. It panics if there is
+// none, because the spec is wrong.
+func lookup(name string) operation {
+	for _, g := range groups {
+		for _, o := range g.operations {
+			if o.name == name {
+				return o
+			}
+		}
+	}
+
+	panic(fmt.Sprintf("unknown operation %s", name))
+}
+
+func concat(lists ...[]isaspec.Instruction) []isaspec.Instruction {
+	var instructions []isaspec.Instruction
+	for _, l := range lists {
+		instructions = append(instructions, l...)
+	}
+
+	return instructions
+}
+
+func newISA(name string, instructions []isaspec.Instruction) *isaspec.ISA {
+	return &isaspec.ISA{Name: name, Formats: formats, Instructions: instructions}
+}
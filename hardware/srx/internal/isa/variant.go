@@ -0,0 +1,124 @@
+package isa
+
+// Variant of the instruction set, which allocates opcodes to formats and
+// operations.
+//
+// The 48-bit allocations follow the specifications. The 16-bit and 32-bit
+// ones are provisional, because the specifications only define the formats:
+// every class gets contiguous ranges of opcodes, in the order in which the
+// specification lists its formats. Package spec generates all of them, and
+// the opcode tables of srx_tab.md and srx_flags.md, which mark the
+// provisional ones.
+type Variant struct {
+	Name string
+
+	// HasFlags is true if the variant has NZCV flags.
+	HasFlags bool
+
+	formats    [4][64]Format
+	operations [64]Operation
+
+	// Some 48-bit opcodes have a different use when Z is zero, because
+	// loading into ZR is pointless. These are their formats and operations
+	// in that case, for the opcodes that have one.
+	zeroZFormats    [64]Format
+	zeroZOperations [64]Operation
+
+	// Operations of C1, C2 and CF instructions, by opcode and function.
+	// C1 functions above 0xf have no operation yet.
+	compactOperations [4][16]Operation
+
+	// Operations of XBC instructions, by condition.
+	conditions [16]Operation
+}
+
+// Format returns the format of an opcode, or FormatNone if it is reserved.
+// For 48-bit opcodes, it is the format when Z is not zero.
+func (v *Variant) Format(class, opcode uint8) Format {
+	if class > 3 || opcode > 63 || (class == 0 && opcode > 3) {
+		return FormatNone
+	}
+
+	return v.formats[class][opcode]
+}
+
+// ZeroZFormat returns the format of a 48-bit opcode when Z is zero, or
+// FormatNone if it is the same as when Z is not zero.
+func (v *Variant) ZeroZFormat(opcode uint8) Format {
+	if opcode > 63 {
+		return FormatNone
+	}
+
+	return v.zeroZFormats[opcode]
+}
+
+// Tab is the test-and-branch variant, described in srx_tab.md.
+var Tab = newVariant(variantSpec{
+	name:       "tab",
+	formats:    tabFormats,
+	operations: tabOperations,
+})
+
+// Flags is the variant with condition flags, described in srx_flags.md.
+//
+// Its 48-bit opcodes 0 to 8 are shared between loads (Z != 0) and jumps or
+// branches (Z = 0), and so are opcodes 24 to 37 between arithmetic and
+// nothing. Jumps keep their condition where loads keep their address
+// register. The specification also gives floating-point loads and stores
+// the same opcodes (16 to 23), so they stay reserved until they get their
+// own.
+var Flags = newVariant(variantSpec{
+	name:              "flags",
+	hasFlags:          true,
+	formats:           flagsFormats,
+	zeroZFormats:      flagsZeroZFormats,
+	operations:        flagsOperations,
+	zeroZOperations:   flagsZeroZOperations,
+	compactOperations: flagsCompactOperations,
+	conditions:        flagsConditions,
+})
+
+// Variants lists all the variants.
+var Variants = []*Variant{Tab, Flags}
+
+type allocation struct {
+	class       uint8
+	first, last uint8
+	format      Format
+}
+
+type variantSpec struct {
+	name              string
+	hasFlags          bool
+	formats           []allocation
+	zeroZFormats      []allocation
+	operations        [64]Operation
+	zeroZOperations   [64]Operation
+	compactOperations [4][16]Operation
+	conditions        [16]Operation
+}
+
+func newVariant(spec variantSpec) *Variant {
+	v := &Variant{
+		Name:              spec.name,
+		HasFlags:          spec.hasFlags,
+		operations:        spec.operations,
+		zeroZOperations:   spec.zeroZOperations,
+		compactOperations: spec.compactOperations,
+		conditions:        spec.conditions,
+	}
+
+	for _, a := range spec.formats {
+		for opcode := a.first; opcode <= a.last; opcode++ {
+			v.formats[a.class][opcode] = a.format
+		}
+	}
+
+	for _, a := range spec.zeroZFormats {
+		for opcode := a.first; opcode <= a.last; opcode++ {
+			v.zeroZFormats[opcode] = a.format
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,594 @@
+// Package machine emulates SRX machines.
+//
+// The machine is generic over the width of its registers and addresses, so
+// the same program can run on 16, 32 and 64-bit machines. Results that are
+// wider than the machine are truncated to its width, and registers are
+// sign-extended when they are stored into wider memory locations. 128-bit
+// operations are not supported.
+//
+// Only the 48-bit instructions have operations for now, apart from a few
+// 16-bit ones of the flags variant. Branch targets are relative to the
+// branch. In the test-and-branch variant, jumps and calls go to an address
+// register plus the immediate. In the flags variant, they are relative to
+// the IP like branches, and jumps can be conditional on the NZCV flags,
+// which only the compare and flag-setting arithmetic operations write.
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/jespert/primordial/hardware/internal/exe"
+	"github.com/jespert/primordial/hardware/srx/internal/isa"
+)
+
+// Word is the type of the registers and addresses of a machine.
+type Word interface {
+	~uint16 | ~uint32 | ~uint64
+}
+
+// Machine state (registers and memory).
+type Machine[W Word] struct {
+	variant *isa.Variant
+	memory  Memory
+
+	// Register banks. ZR is never written.
+	data    [isa.NumRegisters]W
+	address [isa.NumRegisters]W
+
+	// Instruction pointer.
+	ip W
+
+	// NZCV flags, if the variant has them.
+	flags uint8
+}
+
+// Bits of the flags.
+const (
+	flagV uint8 = 1 << iota
+	flagC
+	flagZ
+	flagN
+)
+
+// New creates a new Machine of the variant, with the program base address
+// in BP and IP.
+func New[W Word](variant *isa.Variant) *Machine[W] {
+	m := &Machine[W]{variant: variant, ip: ProgramBase}
+	m.address[isa.BP] = ProgramBase
+	return m
+}
+
+// Trap is an error raised by the execution of an instruction.
+type Trap struct {
+	IP  uint64
+	Err error
+}
+
+func (t *Trap) Error() string {
+	return t.Err.Error()
+}
+
+func (t *Trap) Unwrap() error {
+	return t.Err
+}
+
+var (
+	errFloatingPoint = errors.New("floating-point operations are not supported")
+	errQuad          = errors.New("128-bit operations are not supported")
+)
+
+// Bits returns the width of the machine.
+func (m *Machine[W]) Bits() int {
+	return bits.OnesCount64(uint64(^W(0)))
+}
+
+// Dump the state in human-friendly string representation to the given writer.
+func (m *Machine[W]) Dump(w io.Writer) {
+	digits := m.Bits() / 4
+
+	// There is nothing we can do on IO failure, so we just ignore errors.
+	_, _ = fmt.Fprintf(w, "IP: 0x%0*x\n", digits, m.ip)
+	if m.variant.HasFlags {
+		_, _ = fmt.Fprintf(w, "Flags: %s\n", formatFlags(m.flags))
+	}
+
+	_, _ = fmt.Fprint(w, "\nNon-zero data registers:\n")
+	dumpRegisters(w, digits, m.data, func(r int) fmt.Stringer { return isa.DataRegister(r) })
+
+	_, _ = fmt.Fprint(w, "\nNon-null address registers:\n")
+	dumpRegisters(w, digits, m.address, func(r int) fmt.Stringer { return isa.AddressRegister(r) })
+
+	_, _ = fmt.Fprint(w, "\nMemory:\n")
+	m.memory.Dump(w, digits)
+}
+
+func dumpRegisters[W Word](w io.Writer, digits int, bank [isa.NumRegisters]W, name func(int) fmt.Stringer) {
+	empty := true
+	for r, v := range bank {
+		if v != 0 {
+			_, _ = fmt.Fprintf(w, "%s: 0x%0*x\n", name(r), digits, v)
+			empty = false
+		}
+	}
+
+	if empty {
+		_, _ = fmt.Fprint(w, "(none)\n")
+	}
+}
+
+// IP returns the instruction pointer.
+func (m *Machine[W]) IP() W {
+	return m.ip
+}
+
+// Data returns the value of a register of the D bank.
+func (m *Machine[W]) Data(r isa.DataRegister) W {
+	return m.data[r]
+}
+
+// Address returns the value of a register of the A bank.
+func (m *Machine[W]) Address(r isa.AddressRegister) W {
+	return m.address[r]
+}
+
+// ReadMemory copies memory from the address into data.
+func (m *Machine[W]) ReadMemory(address W, data []byte) {
+	for i := range data {
+		data[i] = m.memory.ReadB(uint64(address + W(i)))
+	}
+}
+
+// LoadProgram copies code and data into memory at the base address, and
+// moves both BP and the IP to it.
+func (m *Machine[W]) LoadProgram(base W, data []byte) {
+	for i, b := range data {
+		m.memory.WriteB(uint64(base+W(i)), b)
+	}
+
+	m.address[isa.BP] = base
+	m.ip = base
+}
+
+// LoadExecutable loads an SRX executable at the base address and clears its
+// zero-initialised data. BP is moved to the base address and the IP to the
+// entrypoint, which is relative to BP. Bit 0 of the architecture flags of
+// the executable must say whether it is for the flags variant.
+func (m *Machine[W]) LoadExecutable(f *exe.File, base W) error {
+	h := f.Header
+	if h.Arch != exe.PackName("SRX") || h.Endianness != exe.LittleEndian {
+		return fmt.Errorf("not a little-endian SRX executable: %s", h.Arch)
+	}
+
+	if hasFlags := h.ArchFlags&1 != 0; hasFlags != m.variant.HasFlags {
+		return fmt.Errorf("not an executable for the %s variant", m.variant.Name)
+	}
+
+	data := f.Segments()
+	data = append(data, make([]byte, f.ZIDataSize)...)
+	if uint64(len(data)) > uint64(^W(0)) {
+		return fmt.Errorf("program too large for memory: %d bytes", len(data))
+	}
+
+	m.LoadProgram(base, data)
+	m.ip = base + W(f.Entrypoint)
+	return nil
+}
+
+// Step executes the instruction at the IP. If the instruction traps, the
+// error is a *Trap.
+func (m *Machine[W]) Step() error {
+	instruction, err := m.fetchNextInstruction()
+	if err != nil {
+		return m.trap(err)
+	}
+
+	nextIP, err := m.execute(&instruction)
+	if err != nil {
+		return m.trap(fmt.Errorf("failed to execute instruction at %0*x: %w",
+			m.Bits()/4, m.ip, err))
+	}
+
+	m.ip = nextIP
+	return nil
+}
+
+// execute the instruction and return the address of the next one.
+func (m *Machine[W]) execute(instruction *isa.Instruction) (W, error) {
+	nextIP := m.ip + W(instruction.Size())
+
+	// Operands are read from both banks: the operation determines which
+	// ones are meaningful, and the others are harmless.
+	y := m.data[instruction.Y]
+	x := m.data[instruction.X]
+	b := m.address[instruction.Y]
+	a := m.address[instruction.X]
+	imm := W(instruction.SignedImm())
+	address := a + imm
+	target := m.ip + imm
+
+	switch op := m.variant.Operation(instruction); op {
+	case isa.Unknown:
+		return 0, fmt.Errorf("unknown instruction: %s", instruction)
+
+	case isa.LOADSBX, isa.LOADSHX, isa.LOADSWX, isa.LOADSDX:
+		size := loadSize(op)
+		v := signExtend(m.read(address, size), 8*size)
+		m.writeData(instruction.Z, W(v))
+
+	case isa.LOADUBX, isa.LOADUHX, isa.LOADUWX, isa.LOADUDX:
+		m.writeData(instruction.Z, W(m.read(address, loadSize(op))))
+
+	case isa.LOADAX:
+		m.address[instruction.Z] = W(m.read(address, m.Bits()/8))
+
+	case isa.STOREBX, isa.STOREHX, isa.STOREWX, isa.STOREDX:
+		m.write(address, storeSize(op), uint64(signed(y)))
+
+	case isa.STOREAX:
+		m.write(address, m.Bits()/8, uint64(b))
+
+	case isa.BEQX, isa.BNEX, isa.BLTSX, isa.BLTUX, isa.BGESX, isa.BGEUX:
+		if branchTaken(op, y, x) {
+			nextIP = target
+		}
+
+	case isa.BEQAX, isa.BNEAX, isa.BLTAX, isa.BGEAX, isa.BEQZAX, isa.BNEZAX,
+		isa.BLTZAX, isa.BGEZAX:
+		if addressBranchTaken(op, b, a) {
+			nextIP = target
+		}
+
+	case isa.CALLAX:
+		m.address[instruction.Z] = nextIP
+		nextIP = address
+
+	case isa.JMPAX:
+		nextIP = address
+
+	case isa.CALLX:
+		m.address[isa.RP] = nextIP
+		nextIP = target
+
+	case isa.JMPX:
+		nextIP = target
+
+	case isa.JEQX, isa.JNEX, isa.JLTX, isa.JLEX, isa.JGEX, isa.JGTX, isa.JLOX,
+		isa.JLSX, isa.JHSX, isa.JHIX, isa.JPIX, isa.JNIX, isa.JOVX, isa.JNOX:
+		if conditionHolds(op, m.flags) {
+			nextIP = target
+		}
+
+	case isa.BEQZX, isa.BNEZX, isa.BLTZX, isa.BGEZX:
+		if zeroBranchTaken(op, x) {
+			nextIP = target
+		}
+
+	case isa.JMPA:
+		nextIP = a
+
+	case isa.CMP:
+		_, m.flags = subtract(x, y)
+
+	case isa.CMPA:
+		_, m.flags = subtract(a, b)
+
+	case isa.CMPI:
+		_, m.flags = subtract(x, imm)
+
+	case isa.ADDS, isa.ADDSI, isa.SUBS:
+		var v W
+		switch op {
+		case isa.ADDS:
+			v, m.flags = add(x, y)
+		case isa.ADDSI:
+			v, m.flags = add(x, imm)
+		default:
+			v, m.flags = subtract(x, y)
+		}
+		m.writeData(instruction.X, v)
+
+	case isa.ADDAL:
+		m.address[instruction.Z] = address
+
+	case isa.ADDWL, isa.ADDDL, isa.ANDWL, isa.ANDDL,
+		isa.ORWL, isa.ORDL, isa.XORWL, isa.XORDL:
+		v := alu(op, uint64(signed(x)), uint64(instruction.SignedImm()))
+		m.writeData(instruction.Z, W(v))
+
+	case isa.SLTUL:
+		m.writeData(instruction.Z, setIf[W](x < imm))
+
+	case isa.SLTSL:
+		m.writeData(instruction.Z, setIf[W](signed(x) < signed(imm)))
+
+	case isa.AIUPCL:
+		m.address[instruction.Z] = m.ip + imm
+
+	case isa.LUILD:
+		m.writeData(instruction.Z, W(uint64(instruction.Imm)<<32|uint64(x)&0xffffffff))
+
+	case isa.LOADQX, isa.STOREQX, isa.ADDQL, isa.ANDQL, isa.ORQL, isa.XORQL,
+		isa.LUILQ0, isa.LUILQ1:
+		return 0, errQuad
+
+	case isa.LOADFH0X, isa.LOADFH1X, isa.LOADFW0X, isa.LOADFW1X,
+		isa.LOADFD0X, isa.LOADFD1X, isa.LOADFQ0X, isa.LOADFQ1X,
+		isa.STOREFH0X, isa.STOREFH1X, isa.STOREFW0X, isa.STOREFW1X,
+		isa.STOREFD0X, isa.STOREFD1X, isa.STOREFQ0X, isa.STOREFQ1X:
+		return 0, errFloatingPoint
+
+	default:
+		return 0, fmt.Errorf("unknown operation: %s", op)
+	}
+
+	return nextIP, nil
+}
+
+func (m *Machine[W]) trap(err error) *Trap {
+	return &Trap{IP: uint64(m.ip), Err: err}
+}
+
+func (m *Machine[W]) writeData(r uint8, v W) {
+	if isa.DataRegister(r) != isa.ZR {
+		m.data[r] = v
+	}
+}
+
+// read a little-endian value of size bytes. Addresses wrap around at the
+// width of the machine.
+func (m *Machine[W]) read(address W, size int) uint64 {
+	var v uint64
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint64(m.memory.ReadB(uint64(address+W(i))))
+	}
+
+	return v
+}
+
+// write a little-endian value of size bytes.
+func (m *Machine[W]) write(address W, size int, v uint64) {
+	for i := range size {
+		m.memory.WriteB(uint64(address+W(i)), byte(v>>(8*i)))
+	}
+}
+
+func (m *Machine[W]) fetchNextInstruction() (isa.Instruction, error) {
+	if m.ip%2 != 0 {
+		return isa.Instruction{}, fmt.Errorf("unaligned IP: %0*x", m.Bits()/4, m.ip)
+	}
+
+	var data [6]byte
+	m.ReadMemory(m.ip, data[:2])
+	size := isa.Size(uint16(data[0]))
+	m.ReadMemory(m.ip, data[:size])
+
+	instruction, err := m.variant.Decode(data[:size])
+	if err != nil {
+		return isa.Instruction{}, fmt.Errorf("failed to fetch instruction at %0*x: %w",
+			m.Bits()/4, m.ip, err)
+	}
+
+	return instruction, nil
+}
+
+func loadSize(op isa.Operation) int {
+	switch op {
+	case isa.LOADSBX, isa.LOADUBX:
+		return 1
+	case isa.LOADSHX, isa.LOADUHX:
+		return 2
+	case isa.LOADSWX, isa.LOADUWX:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func storeSize(op isa.Operation) int {
+	switch op {
+	case isa.STOREBX:
+		return 1
+	case isa.STOREHX:
+		return 2
+	case isa.STOREWX:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func branchTaken[W Word](op isa.Operation, y, x W) bool {
+	switch op {
+	case isa.BEQX:
+		return y == x
+	case isa.BNEX:
+		return y != x
+	case isa.BLTSX:
+		return signed(y) < signed(x)
+	case isa.BGESX:
+		return signed(y) >= signed(x)
+	case isa.BLTUX:
+		return y < x
+	default:
+		return y >= x
+	}
+}
+
+// addressBranchTaken compares addresses as unsigned numbers. The branches
+// against zero test A, like the other branches test it against B.
+func addressBranchTaken[W Word](op isa.Operation, b, a W) bool {
+	switch op {
+	case isa.BEQAX:
+		return b == a
+	case isa.BNEAX:
+		return b != a
+	case isa.BEQZAX:
+		return a == 0
+	case isa.BNEZAX:
+		return a != 0
+	case isa.BLTZAX:
+		return signed(a) < 0
+	case isa.BGEZAX:
+		return signed(a) >= 0
+	case isa.BLTAX:
+		return b < a
+	default:
+		return b >= a
+	}
+}
+
+// zeroBranchTaken compares a D register with zero.
+func zeroBranchTaken[W Word](op isa.Operation, x W) bool {
+	switch op {
+	case isa.BEQZX:
+		return x == 0
+	case isa.BNEZX:
+		return x != 0
+	case isa.BLTZX:
+		return signed(x) < 0
+	default:
+		return signed(x) >= 0
+	}
+}
+
+// add returns the sum and the flags that it sets. C is the carry out.
+func add[W Word](x, y W) (W, uint8) {
+	v := x + y
+	flags := resultFlags(v)
+	if v < x {
+		flags |= flagC
+	}
+	if signed(^(x^y)&(x^v)) < 0 {
+		flags |= flagV
+	}
+
+	return v, flags
+}
+
+// subtract returns the difference and the flags that it sets. C is set
+// when there is no borrow, as in ARM, so that x >= y (unsigned) is "hs".
+func subtract[W Word](x, y W) (W, uint8) {
+	v := x - y
+	flags := resultFlags(v)
+	if x >= y {
+		flags |= flagC
+	}
+	if signed((x^y)&(x^v)) < 0 {
+		flags |= flagV
+	}
+
+	return v, flags
+}
+
+func resultFlags[W Word](v W) uint8 {
+	var flags uint8
+	if signed(v) < 0 {
+		flags |= flagN
+	}
+	if v == 0 {
+		flags |= flagZ
+	}
+
+	return flags
+}
+
+// conditionHolds evaluates the condition of a conditional jump. After a
+// comparison of x with y, lt, le, ge and gt compare them as signed numbers,
+// and lo, ls, hs and hi as unsigned ones.
+func conditionHolds(op isa.Operation, flags uint8) bool {
+	n := flags&flagN != 0
+	z := flags&flagZ != 0
+	c := flags&flagC != 0
+	v := flags&flagV != 0
+
+	switch op {
+	case isa.JEQX:
+		return z
+	case isa.JNEX:
+		return !z
+	case isa.JLTX:
+		return n != v
+	case isa.JLEX:
+		return z || n != v
+	case isa.JGEX:
+		return n == v
+	case isa.JGTX:
+		return !z && n == v
+	case isa.JLOX:
+		return !c
+	case isa.JLSX:
+		return !c || z
+	case isa.JHSX:
+		return c
+	case isa.JHIX:
+		return c && !z
+	case isa.JPIX:
+		return !n
+	case isa.JNIX:
+		return n
+	case isa.JOVX:
+		return v
+	default:
+		return !v
+	}
+}
+
+// formatFlags shows set flags in upper case and clear ones in lower case.
+func formatFlags(flags uint8) string {
+	s := []byte("nzcv")
+	for i, bit := range []uint8{flagN, flagZ, flagC, flagV} {
+		if flags&bit != 0 {
+			s[i] -= 'a' - 'A'
+		}
+	}
+
+	return string(s)
+}
+
+// alu applies a long-immediate operation to 64-bit operands. Word (W)
+// operations sign-extend their 32-bit result.
+func alu(op isa.Operation, x, imm uint64) uint64 {
+	var v uint64
+	switch op {
+	case isa.ADDWL, isa.ADDDL:
+		v = x + imm
+	case isa.ANDWL, isa.ANDDL:
+		v = x & imm
+	case isa.ORWL, isa.ORDL:
+		v = x | imm
+	default:
+		v = x ^ imm
+	}
+
+	switch op {
+	case isa.ADDWL, isa.ANDWL, isa.ORWL, isa.XORWL:
+		return uint64(signExtend(v, 32))
+	default:
+		return v
+	}
+}
+
+// signed interprets a word as a two's complement number.
+func signed[W Word](v W) int64 {
+	return signExtend(uint64(v), bits.OnesCount64(uint64(^W(0))))
+}
+
+func signExtend(v uint64, width int) int64 {
+	shift := 64 - width
+	return int64(v<<shift) >> shift
+}
+
+func setIf[W Word](condition bool) W {
+	if condition {
+		return 1
+	}
+
+	return 0
+}
+
+// ProgramBase is the default load address of programs. It is the same for
+// every width, so that programs can be compared across widths.
+const ProgramBase = 0x8000
@@ -0,0 +1,333 @@
+package machine
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jespert/primordial/hardware/srx/internal/isa"
+	"github.com/jespert/primordial/internal/quality/approval"
+	"github.com/jespert/primordial/internal/quality/expect"
+	"github.com/jespert/primordial/internal/quality/require"
+)
+
+func TestMachine_Dump_empty(t *testing.T) {
+	m := New[uint16](isa.Tab)
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMachine_Step_program(t *testing.T) {
+	m := New[uint64](isa.Tab)
+	m.LoadProgram(ProgramBase, testProgram)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestMachine_Step_widths(t *testing.T) {
+	// The same program computes the same results on every width.
+	testWidth[uint16](t)
+	testWidth[uint32](t)
+	testWidth[uint64](t)
+}
+
+func testWidth[W Word](t *testing.T) {
+	m := New[W](isa.Tab)
+	m.LoadProgram(ProgramBase, testProgram)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	// The last instruction branches to itself.
+	ip := m.IP()
+	require.Success(t, m.Step())
+	expect.Equal(t, ip, m.IP())
+	expect.Equal(t, W(ProgramBase+0x1e), ip)
+
+	var data [4]byte
+	m.ReadMemory(ProgramBase+0x3c, data[:])
+	expect.Equal(t, [4]byte{0x05, 0x50, 0x00, 0x00}, data)
+	expect.Equal(t, W(0x5005), m.Data(isa.Y0))
+	expect.Equal(t, W(ProgramBase+0x12), m.Address(isa.RP))
+}
+
+func TestMachine_Step_variants(t *testing.T) {
+	// Both variants compute the same result, in the same number of
+	// instructions, with programs of different sizes.
+	testCases := []struct {
+		variant  *isa.Variant
+		program  []byte
+		halt     uint32
+		data     uint32
+		expected int
+	}{
+		{isa.Tab, testProgram, 0x1e, 0x3c, 64},
+		{isa.Flags, flagsTestProgram, 0x1a, 0x34, 56},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.variant.Name, func(t *testing.T) {
+			expect.Equal(t, tc.expected, len(tc.program))
+			m := New[uint32](tc.variant)
+			m.LoadProgram(ProgramBase, tc.program)
+			for range testProgramSteps {
+				require.Success(t, m.Step())
+			}
+
+			require.Success(t, m.Step())
+			expect.Equal(t, ProgramBase+tc.halt, m.IP())
+
+			var data [4]byte
+			m.ReadMemory(ProgramBase+tc.data, data[:])
+			expect.Equal(t, [4]byte{0x05, 0x50, 0x00, 0x00}, data)
+		})
+	}
+}
+
+func TestMachine_Step_flags(t *testing.T) {
+	m := New[uint16](isa.Flags)
+	m.LoadProgram(ProgramBase, flagsTestProgram)
+	for range testProgramSteps {
+		require.Success(t, m.Step())
+	}
+
+	verifier := approval.NewTextVerifier(t)
+	m.Dump(verifier.Writer())
+	verifier.Verify()
+}
+
+func TestConditionHolds(t *testing.T) {
+	// After comparing x with y, every condition agrees with Go's
+	// comparisons.
+	values := []uint16{0, 1, 2, 0x7fff, 0x8000, 0x8001, 0xfffe, 0xffff}
+	for _, x := range values {
+		for _, y := range values {
+			v, flags := subtract(x, y)
+			sx, sy := int16(x), int16(y)
+			overflow := (sx < 0) != (sy < 0) && (sx < 0) != (int16(v) < 0)
+			expected := map[isa.Operation]bool{
+				isa.JEQX: x == y,
+				isa.JNEX: x != y,
+				isa.JLTX: sx < sy,
+				isa.JLEX: sx <= sy,
+				isa.JGEX: sx >= sy,
+				isa.JGTX: sx > sy,
+				isa.JLOX: x < y,
+				isa.JLSX: x <= y,
+				isa.JHSX: x >= y,
+				isa.JHIX: x > y,
+				isa.JPIX: int16(v) >= 0,
+				isa.JNIX: int16(v) < 0,
+				isa.JOVX: overflow,
+				isa.JNOX: !overflow,
+			}
+
+			for op, holds := range expected {
+				if conditionHolds(op, flags) != holds {
+					t.Errorf("%s after comparing 0x%04x with 0x%04x: expected %t",
+						op, x, y, holds)
+				}
+			}
+		}
+	}
+}
+
+func TestAdd_flags(t *testing.T) {
+	v, flags := add[uint16](0xffff, 1)
+	expect.Equal(t, 0, v)
+	expect.Equal(t, "nZCv", formatFlags(flags))
+
+	v, flags = add[uint16](0x7fff, 1)
+	expect.Equal(t, 0x8000, v)
+	expect.Equal(t, "NzcV", formatFlags(flags))
+}
+
+func TestMachine_Step_truncation(t *testing.T) {
+	program := slices.Concat(
+		tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.Z0), Imm: 0x12345678}),
+		tab(isa.LUILD, isa.Instruction{Z: uint8(isa.Z1), X: uint8(isa.Z0), Imm: 0x9abcdef0}),
+		tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.Z2), Imm: 0xffffffff}),
+		tab(isa.SLTSL, isa.Instruction{Z: uint8(isa.Z3), X: uint8(isa.Z2)}),
+		tab(isa.SLTUL, isa.Instruction{Z: uint8(isa.Z4), X: uint8(isa.Z2)}),
+		tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.ZR), Imm: 1}),
+	)
+
+	run := func(m interface{ Step() error }) {
+		for range 6 {
+			require.Success(t, m.Step())
+		}
+	}
+
+	m16 := New[uint16](isa.Tab)
+	m16.LoadProgram(ProgramBase, program)
+	run(m16)
+	expect.Equal(t, 0x5678, m16.Data(isa.Z0))
+	expect.Equal(t, 0x5678, m16.Data(isa.Z1))
+	expect.Equal(t, 0xffff, m16.Data(isa.Z2))
+
+	m32 := New[uint32](isa.Tab)
+	m32.LoadProgram(ProgramBase, program)
+	run(m32)
+	expect.Equal(t, 0x12345678, m32.Data(isa.Z1))
+	expect.Equal(t, 0xffffffff, m32.Data(isa.Z2))
+
+	m64 := New[uint64](isa.Tab)
+	m64.LoadProgram(ProgramBase, program)
+	run(m64)
+	expect.Equal(t, 0x9abcdef012345678, m64.Data(isa.Z1))
+	expect.Equal(t, 0xffffffffffffffff, m64.Data(isa.Z2))
+	expect.Equal(t, 1, m64.Data(isa.Z3))
+	expect.Equal(t, 0, m64.Data(isa.Z4))
+	expect.Equal(t, 0, m64.Data(isa.ZR))
+}
+
+func TestMachine_Step_sparse(t *testing.T) {
+	// Only the page that holds the program and its data is allocated.
+	const base = 0xffff_ffff_0000_0000
+	m := New[uint64](isa.Tab)
+	m.LoadProgram(base, slices.Concat(
+		tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.X0), Imm: 0xffff8000}),
+		tab(isa.STOREDX, isa.Instruction{Y: uint8(isa.X0), X: uint8(isa.BP), Imm: 0x100}),
+	))
+	require.Success(t, m.Step())
+	require.Success(t, m.Step())
+
+	var data [8]byte
+	m.ReadMemory(base+0x100, data[:])
+	expect.Equal(t, [8]byte{0x00, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, data)
+	expect.Equal(t, 1, m.memory.Pages())
+}
+
+func TestMachine_Step_wrap_around(t *testing.T) {
+	// Instructions and addresses wrap around at the width of the machine.
+	m := New[uint16](isa.Tab)
+	m.LoadProgram(0xfffe, tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.X0), Imm: 7}))
+	require.Success(t, m.Step())
+	expect.Equal(t, 0x0004, m.IP())
+	expect.Equal(t, 7, m.Data(isa.X0))
+}
+
+func TestMachine_Step_traps(t *testing.T) {
+	testCases := []struct {
+		name    string
+		program []byte
+	}{
+		{
+			// Zero-initialised memory holds 16-bit instructions, which
+			// have no operations yet.
+			name: "unknown",
+		},
+		{
+			name:    "unaligned",
+			program: tab(isa.JMPAX, isa.Instruction{X: uint8(isa.BP), Imm: 1}),
+		},
+		{
+			name:    "quad",
+			program: tab(isa.ADDQL, isa.Instruction{Z: uint8(isa.X0)}),
+		},
+		{
+			// The flag-setting operations belong to the flags variant.
+			name:    "flags",
+			program: flags(isa.CMP, isa.Instruction{}),
+		},
+		{
+			name:    "floating point",
+			program: tab(isa.LOADFW0X, isa.Instruction{X: uint8(isa.BP)}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New[uint32](isa.Tab)
+			m.LoadProgram(ProgramBase, tc.program)
+			err := m.Step()
+			if tc.name == "unaligned" {
+				require.Success(t, err)
+				err = m.Step()
+			}
+
+			var trap *Trap
+			expect.Equal(t, true, errors.As(err, &trap))
+		})
+	}
+
+	m := New[uint32](isa.Tab)
+	m.LoadProgram(ProgramBase, tab(isa.LUILQ0, isa.Instruction{}))
+	expect.Equal(t, true, errors.Is(m.Step(), errQuad))
+}
+
+// tab encodes an instruction of the test-and-branch variant.
+func tab(op isa.Operation, i isa.Instruction) []byte {
+	return encode(isa.Tab, op, i)
+}
+
+// flags encodes an instruction of the flags variant.
+func flags(op isa.Operation, i isa.Instruction) []byte {
+	return encode(isa.Flags, op, i)
+}
+
+func encode(v *isa.Variant, op isa.Operation, i isa.Instruction) []byte {
+	template, ok := v.Template(op)
+	if !ok {
+		panic("no encoding for " + op.String())
+	}
+
+	i.Class, i.Opcode, i.Format = template.Class, template.Opcode, template.Format
+	i.Func |= template.Func
+	i.Cond |= template.Cond
+	return isa.Encode(i)
+}
+
+// testProgram calls a subroutine five times that adds 0x1001 to a word of
+// memory, and then halts by branching to itself.
+var testProgram = slices.Concat(
+	// 0x00: Address of the data.
+	tab(isa.AIUPCL, isa.Instruction{Z: uint8(isa.A0), Imm: 0x3c}),
+	// 0x06: Loop counter.
+	tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.X0), X: uint8(isa.ZR), Imm: 5}),
+	// 0x0c: Loop.
+	tab(isa.CALLAX, isa.Instruction{Z: uint8(isa.RP), X: uint8(isa.BP), Imm: 0x24}),
+	tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.X0), X: uint8(isa.X0), Imm: 0xffffffff}),
+	tab(isa.BNEX, isa.Instruction{Y: uint8(isa.X0), X: uint8(isa.ZR), Imm: 0xfffffff4}),
+	// 0x1e: Halt.
+	tab(isa.BEQX, isa.Instruction{}),
+	// 0x24: Subroutine.
+	tab(isa.LOADUWX, isa.Instruction{Z: uint8(isa.Y0), X: uint8(isa.A0)}),
+	tab(isa.ADDWL, isa.Instruction{Z: uint8(isa.Y0), X: uint8(isa.Y0), Imm: 0x1001}),
+	tab(isa.STOREWX, isa.Instruction{Y: uint8(isa.Y0), X: uint8(isa.A0)}),
+	tab(isa.JMPAX, isa.Instruction{X: uint8(isa.RP)}),
+	// 0x3c: Data.
+	[]byte{0, 0, 0, 0},
+)
+
+// testProgramSteps is the number of instructions until the halt, in
+// both variants.
+const testProgramSteps = 2 + 5*7
+
+// flagsTestProgram is testProgram for the flags variant. The loop counter
+// sets the flags as it is decremented.
+var flagsTestProgram = slices.Concat(
+	// 0x00: Address of the data.
+	flags(isa.ADDAL, isa.Instruction{Z: uint8(isa.A0), X: uint8(isa.BP), Imm: 0x34}),
+	// 0x06: Loop counter.
+	flags(isa.ADDWL, isa.Instruction{Z: uint8(isa.X0), X: uint8(isa.ZR), Imm: 5}),
+	// 0x0c: Loop.
+	flags(isa.CALLX, isa.Instruction{Imm: 0x20 - 0x0c}),
+	flags(isa.ADDSI, isa.Instruction{X: uint8(isa.X0), Imm: 0xf}),
+	flags(isa.JNEX, isa.Instruction{Imm: 0xfffffff8}),
+	// 0x1a: Halt.
+	flags(isa.JMPX, isa.Instruction{}),
+	// 0x20: Subroutine.
+	flags(isa.LOADUWX, isa.Instruction{Z: uint8(isa.Y0), X: uint8(isa.A0)}),
+	flags(isa.ADDWL, isa.Instruction{Z: uint8(isa.Y0), X: uint8(isa.Y0), Imm: 0x1001}),
+	flags(isa.STOREWX, isa.Instruction{Y: uint8(isa.Y0), X: uint8(isa.A0)}),
+	flags(isa.JMPA, isa.Instruction{X: uint8(isa.RP)}),
+	// 0x34: Data.
+	[]byte{0, 0, 0, 0},
+)
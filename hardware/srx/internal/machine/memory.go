@@ -0,0 +1,71 @@
+package machine
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Memory is sparse: it only allocates the pages that have been written, so
+// that 32-bit and 64-bit address spaces can be emulated. Pages that have
+// never been written read as zero.
+type Memory struct {
+	pages map[uint64]*[pageSize]byte
+}
+
+const pageSize = 4096
+
+// ReadB returns the byte at the address.
+func (m *Memory) ReadB(address uint64) byte {
+	page, ok := m.pages[address/pageSize]
+	if !ok {
+		return 0
+	}
+
+	return page[address%pageSize]
+}
+
+// WriteB stores the byte at the address, allocating its page if needed.
+func (m *Memory) WriteB(address uint64, v byte) {
+	if m.pages == nil {
+		m.pages = make(map[uint64]*[pageSize]byte)
+	}
+
+	page, ok := m.pages[address/pageSize]
+	if !ok {
+		page = new([pageSize]byte)
+		m.pages[address/pageSize] = page
+	}
+
+	page[address%pageSize] = v
+}
+
+// Pages returns the number of allocated pages.
+func (m *Memory) Pages() int {
+	return len(m.pages)
+}
+
+// Dump the non-zero lines of the allocated pages, in address order, with
+// addresses of the given number of hexadecimal digits.
+func (m *Memory) Dump(w io.Writer, digits int) {
+	const lineSize = 16
+
+	pages := make([]uint64, 0, len(m.pages))
+	for n := range m.pages {
+		pages = append(pages, n)
+	}
+	slices.Sort(pages)
+
+	for _, n := range pages {
+		page := m.pages[n]
+		for offset := 0; offset < pageSize; offset += lineSize {
+			line := page[offset : offset+lineSize]
+			if !slices.ContainsFunc(line, func(b byte) bool { return b != 0 }) {
+				continue
+			}
+
+			// There is nothing we can do on IO failure, so we just ignore errors.
+			_, _ = fmt.Fprintf(w, "%0*x  % x\n", digits, n*pageSize+uint64(offset), line)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package isamap provides the instruction sets of SRX to the encoding map
+// tool, which cannot import the internal packages of SRX.
+package isamap
+
+import (
+	"github.com/jespert/primordial/hardware/internal/isaspec"
+	"github.com/jespert/primordial/hardware/srx/internal/isa/spec"
+)
+
+// ISAs are the specifications of the instruction sets of each variant of
+// SRX.
+var ISAs = []*isaspec.ISA{spec.Tab, spec.Flags}